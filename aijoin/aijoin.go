@@ -1,59 +1,485 @@
 // Reverse AISplit to create single context/canvas that can be split by aisplit
 
-package main
+// Package aijoin is the aijoin tool, usable standalone (cmd/aijoin) or as `depgraph aijoin`.
+package aijoin
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"fortio.org/cli"
 	"fortio.org/log" // Use fortio/log for consistency
+	"github.com/ldemailly/depgraph/aimanifest"
 )
 
 const (
-	headerSeparator = "// ========================================================================"
-	headerPrefix    = "// File: "
+	headerSeparator    = "// ========================================================================"
+	headerPrefix       = "// File: "
+	continuationPrefix = "// Continued: " // Header when a file is split across chunks, aisplit appends to the file.
+	bytesPerToken      = 4                // Rough estimate for -max-tokens
 )
 
-func main() {
-	cli.ArgsHelp = "file1 [file2...]" // Set custom usage text for arguments
-	cli.MinArgs = 1                   // Require at least one file name
-	cli.MaxArgs = -1                  // Allow any number of file names
-	cli.Main()                        // Parses flags, validates args, handles version/help flags
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var res []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
 
-	output := bufio.NewWriter(os.Stdout)
-	defer output.Flush()
+// matchAny returns true if any of the glob patterns matches either the base name
+// or the (slash separated) relative path.
+func matchAny(patterns []string, relPath string) bool {
+	base := path.Base(relPath)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, relPath); ok {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, filename := range flag.Args() {
-		log.Infof("Processing file: %s", filename)
+// ignoreRule is one .gitignore pattern, compiled to a regexp matching the slash separated
+// path relative to the directory of its .gitignore.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
 
-		// Write header
-		fmt.Fprintln(output, headerSeparator)
-		fmt.Fprintf(output, "%s %s\n", headerPrefix, filename)
-		fmt.Fprintln(output, headerSeparator)
-		fmt.Fprintln(output) // Extra newline for readability
+// gitIgnore holds the rules of one .gitignore file, base being its directory relative to
+// the walked root ("" for the root itself). Follows gitignore(5) for the patterns:
+// comments, escapes, negation, trailing / for directories, anchoring by a leading or
+// inner /, and the leading **/, inner /**/ and trailing /** forms. The global excludes
+// (core.excludesFile) and .git/info/exclude aren't read.
+type gitIgnore struct {
+	base  string
+	rules []ignoreRule
+}
 
-		// Open file for reading
-		file, err := os.Open(filename)
+// loadGitIgnore reads the .gitignore of root/base, returning nil if there is none.
+func loadGitIgnore(root, base string) *gitIgnore {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(base), ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return parseGitIgnore(base, f)
+}
+
+// parseGitIgnore parses the content of a .gitignore, invalid patterns being skipped with a warning.
+func parseGitIgnore(base string, r io.Reader) *gitIgnore {
+	gi := &gitIgnore{base: base}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1] // trailing spaces are ignored unless escaped
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A slash at the start or in the middle anchors the pattern to the .gitignore's
+		// directory, otherwise it matches at any depth.
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		re, err := ignoreRegexp(line, anchored)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to open file '%s': %v\n", filename, err)
+			log.Warnf("Ignoring invalid .gitignore pattern %q in %q: %v", scanner.Text(), base, err)
 			continue
 		}
+		rule.re = re
+		gi.rules = append(gi.rules, rule)
+	}
+	return gi
+}
 
-		// Copy file content to output
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			fmt.Fprintln(output, scanner.Text())
+// ignoreRegexp translates a gitignore glob pattern into a regexp.
+func ignoreRegexp(pattern string, anchored bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString("(?:.*/)?") // zero or more directories
+			i += 2
+		case pattern[i:] == "/**":
+			b.WriteString("/.*") // everything inside
+			i += 2
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '[':
+			class, n := ignoreClass(pattern[i:])
+			if n == 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(class)
+			i += n - 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
 		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
 
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed reading file '%s': %v\n", filename, err)
+// ignoreClass translates the bracket expression at the start of s, returning the regexp
+// class and the length consumed, 0 if the bracket isn't closed (and so is a literal [).
+func ignoreClass(s string) (string, int) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		b.WriteString("^")
+		i++
+	}
+	for first := true; i < len(s); i, first = i+1, false {
+		c := s[i]
+		switch {
+		case c == ']' && !first:
+			b.WriteString("]")
+			return b.String(), i + 1
+		case c == '\\' && i+1 < len(s):
+			i++
+			c = s[i]
+			fallthrough
+		case c == '\\' || c == '[' || c == ']':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
 		}
-		fmt.Fprintln(output) // extra newline between files
-		file.Close()
 	}
+	return "", 0
+}
 
-	log.Infof("Done.")
+// match returns whether one of the rules matches relPath (slash separated, relative to
+// the walked root) and if so whether the last matching one ignores it.
+func (gi *gitIgnore) match(relPath string, isDir bool) (matched, ignored bool) {
+	if gi.base != "" {
+		if !strings.HasPrefix(relPath, gi.base+"/") {
+			return false, false
+		}
+		relPath = relPath[len(gi.base)+1:]
+	}
+	for _, r := range gi.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(relPath) {
+			matched, ignored = true, !r.negate
+		}
+	}
+	return matched, ignored
+}
+
+// gitIgnores are the .gitignore files applying to a walked path, from the root's to the
+// deepest one: as in git the last matching rule wins, so the deeper files take precedence.
+type gitIgnores []*gitIgnore
+
+func (s gitIgnores) ignored(relPath string, isDir bool) bool {
+	res := false
+	for _, gi := range s {
+		if matched, ignored := gi.match(relPath, isDir); matched {
+			res = ignored
+		}
+	}
+	return res
+}
+
+// enter drops the .gitignore files of the directories the walk left, relPath being the
+// next walked path.
+func (s gitIgnores) enter(relPath string) gitIgnores {
+	for len(s) > 0 && s[len(s)-1].base != "" && !strings.HasPrefix(relPath, s[len(s)-1].base+"/") {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// collector expands the command line arguments (files, directories, globs) into the
+// ordered list of files to join.
+type collector struct {
+	include   []string
+	exclude   []string
+	gitignore bool
+	seen      map[string]bool
+	files     []string
+}
+
+func (c *collector) add(filename string) {
+	if c.seen[filename] {
+		return
+	}
+	c.seen[filename] = true
+	c.files = append(c.files, filename)
+}
+
+// walk recursively adds the files under dir that pass the filters, honoring the
+// .gitignore files of dir and of its subdirectories.
+func (c *collector) walk(dir string) error {
+	var ignores gitIgnores
+	if c.gitignore {
+		if gi := loadGitIgnore(dir, ""); gi != nil {
+			ignores = append(ignores, gi)
+		}
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		ignores = ignores.enter(rel)
+		if d.Name() == ".git" || matchAny(c.exclude, rel) || ignores.ignored(rel, d.IsDir()) {
+			log.LogVf("  Skipping %s", p)
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if c.gitignore {
+				if gi := loadGitIgnore(dir, rel); gi != nil {
+					ignores = append(ignores, gi)
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(c.include) > 0 && !matchAny(c.include, rel) {
+			return nil
+		}
+		c.add(p)
+		return nil
+	})
+}
+
+// expand handles one command line argument.
+func (c *collector) expand(arg string) error {
+	matches := []string{arg}
+	if strings.ContainsAny(arg, "*?[") {
+		var err error
+		matches, err = filepath.Glob(arg)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			log.Warnf("No match for %s", arg)
+		}
+	}
+	for _, m := range matches {
+		st, err := os.Stat(m)
+		if err != nil {
+			return err
+		}
+		if st.IsDir() {
+			if err := c.walk(m); err != nil {
+				return err
+			}
+			continue
+		}
+		if matchAny(c.exclude, filepath.ToSlash(m)) {
+			log.LogVf("  Excluding %s", m)
+			continue
+		}
+		c.add(m) // explicitly listed files are always included (even if not matching -include)
+	}
+	return nil
+}
+
+// chunker accumulates the joined output, starting a new chunk when the size budget
+// would be exceeded (0 means no limit). Files too big for one chunk are continued in the
+// next chunk(s) after a continuation header.
+type chunker struct {
+	max    int
+	chunks []*bytes.Buffer
+	cur    *bytes.Buffer
+	start  int // size of the current chunk's header(s), to know if it has content
+}
+
+func (c *chunker) newChunk() {
+	c.cur = &bytes.Buffer{}
+	c.chunks = append(c.chunks, c.cur)
+	c.start = 0
+}
+
+func (c *chunker) fits(n int) bool {
+	return c.max <= 0 || c.cur.Len()+n <= c.max
+}
+
+func writeHeader(w *bytes.Buffer, prefix, filename string) {
+	fmt.Fprintln(w, headerSeparator)
+	fmt.Fprintf(w, "%s %s\n", prefix, filename)
+	fmt.Fprintln(w, headerSeparator)
+	if prefix == headerPrefix {
+		fmt.Fprintln(w) // Extra newline for readability (but not in the middle of a continued file)
+	}
+}
+
+// addFile adds a file's lines (with its header) to the output.
+func (c *chunker) addFile(filename string, lines []string) {
+	var header bytes.Buffer
+	writeHeader(&header, headerPrefix, filename)
+	firstLen := header.Len()
+	if len(lines) > 0 {
+		firstLen += len(lines[0]) + 1
+	}
+	if c.cur == nil || (c.cur.Len() > 0 && !c.fits(firstLen)) {
+		c.newChunk()
+	}
+	c.cur.Write(header.Bytes())
+	c.start = c.cur.Len()
+	for _, line := range lines {
+		if !c.fits(len(line)+1) && c.cur.Len() > c.start {
+			log.LogVf("  Continuing %s in chunk %d", filename, len(c.chunks)+1)
+			c.newChunk()
+			writeHeader(c.cur, continuationPrefix, filename)
+			c.start = c.cur.Len()
+		}
+		c.cur.WriteString(line)
+		c.cur.WriteByte('\n')
+	}
+	c.cur.WriteByte('\n') // extra newline between files
+}
+
+// readLines reads a whole file as lines.
+func readLines(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// writeChunks writes the chunks to stdout (with a chunk header when there is more than one)
+// or to numbered files <prefix>.N.txt if prefix isn't empty.
+func writeChunks(chunks []*bytes.Buffer, prefix string) error {
+	n := len(chunks)
+	if prefix == "" {
+		output := bufio.NewWriter(os.Stdout)
+		defer output.Flush()
+		for i, ch := range chunks {
+			if n > 1 {
+				fmt.Fprintf(output, "// ===== Chunk %d/%d =====\n", i+1, n)
+			}
+			if _, err := output.Write(ch.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, ch := range chunks {
+		name := fmt.Sprintf("%s.%d.txt", prefix, i+1)
+		data := append([]byte(fmt.Sprintf("// ===== Chunk %d/%d =====\n", i+1, n)), ch.Bytes()...)
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			return err
+		}
+		log.Infof("Wrote %s (%d bytes)", name, len(data))
+	}
+	return nil
+}
+
+// Main is the aijoin entry point: parses its flags (with fortio/cli) and joins the files.
+func Main() {
+	includeFlag := flag.String("include", "",
+		"Comma separated `globs` of files to keep when walking directories (e.g. \"*.go,*.md\"), default all")
+	excludeFlag := flag.String("exclude", "", "Comma separated `globs` of files or directories to skip")
+	noGitIgnoreFlag := flag.Bool("no-gitignore", false, "Don't skip files listed in the walked directories' .gitignore")
+	maxBytesFlag := flag.Int("max-bytes", 0, "Split the output into chunks of at most this many bytes (0 for no limit)")
+	maxTokensFlag := flag.Int("max-tokens", 0,
+		fmt.Sprintf("Split the output into chunks of at most this many (estimated, %d bytes each) tokens", bytesPerToken))
+	outputFlag := flag.String("o", "", "Write chunks to `prefix`.N.txt files instead of stdout")
+	manifestFlag := flag.String("manifest", "", "Write a manifest (file list and hashes) to this `file`, for aisplit -verify")
+	cli.ArgsHelp = "file1|dir1|glob1 [file2...]" // Set custom usage text for arguments
+	cli.MinArgs = 1                              // Require at least one file name
+	cli.MaxArgs = -1                             // Allow any number of file names
+	cli.Main()                                   // Parses flags, validates args, handles version/help flags
+
+	c := &collector{
+		include:   splitList(*includeFlag),
+		exclude:   splitList(*excludeFlag),
+		gitignore: !*noGitIgnoreFlag,
+		seen:      make(map[string]bool),
+	}
+	for _, arg := range flag.Args() {
+		if err := c.expand(arg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	maxSize := *maxBytesFlag
+	if t := *maxTokensFlag * bytesPerToken; t > 0 && (maxSize <= 0 || t < maxSize) {
+		maxSize = t
+	}
+	out := &chunker{max: maxSize}
+	manifest := &aimanifest.Manifest{}
+	for _, filename := range c.files {
+		log.Infof("Processing file: %s", filename)
+		lines, err := readLines(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed reading file '%s': %v\n", filename, err)
+			continue
+		}
+		name := filepath.ToSlash(filename)
+		out.addFile(name, lines)
+		manifest.Add(name, lines)
+	}
+	if err := writeChunks(out.chunks, *outputFlag); err != nil {
+		log.Fatalf("Failed writing output: %v", err)
+	}
+	if *manifestFlag != "" {
+		if err := manifest.Write(*manifestFlag); err != nil {
+			log.Fatalf("Failed writing manifest: %v", err)
+		}
+		log.Infof("Wrote manifest %s", *manifestFlag)
+	}
+	log.Infof("Done, %d files in %d chunk(s).", len(c.files), len(out.chunks))
 }
@@ -0,0 +1,123 @@
+package aijoin
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGitIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/sub/a.log", false, true},
+		{"*.log", "a.log.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/sub/a.txt", false, false},
+		{"doc/*.txt", "x/doc/a.txt", false, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", true, true},
+		{"**/foo/bar", "a/foo/bar", false, true},
+		{"**/foo/bar", "foo/bar", false, true},
+		{"abc/**", "abc/x", false, true},
+		{"abc/**", "abc/x/y", false, true},
+		{"abc/**", "abc", true, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/xb", false, false},
+		{"file?.go", "file1.go", false, true},
+		{"file?.go", "file/.go", false, false},
+		{"[abc].go", "b.go", false, true},
+		{"[!abc].go", "b.go", false, false},
+		{"[!abc].go", "d.go", false, true},
+		{"[unclosed", "[unclosed", false, true},
+		{`\#hash`, "#hash", false, true},
+		{`\!bang`, "!bang", false, true},
+		{"trailing   ", "trailing", false, true},
+		{`space\ `, "space ", false, true},
+		{"# comment", "# comment", false, false},
+	}
+	for _, tt := range tests {
+		gi := parseGitIgnore("", strings.NewReader(tt.pattern+"\n"))
+		if got := (gitIgnores{gi}).ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("pattern %q on %q (dir %v): got %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestGitIgnoreNegationAndBase(t *testing.T) {
+	root := parseGitIgnore("", strings.NewReader("*.log\n!keep.log\n"))
+	sub := parseGitIgnore("sub", strings.NewReader("/local.txt\n!debug.log\n"))
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a.log", true},
+		{"keep.log", false},
+		{"sub/a.log", true},
+		{"sub/debug.log", false}, // the deeper .gitignore wins
+		{"debug.log", true},
+		{"sub/local.txt", true},
+		{"local.txt", false}, // anchored to sub/
+		{"sub/deeper/local.txt", false},
+		{"subway/local.txt", false},
+	}
+	for _, tt := range tests {
+		if got := (gitIgnores{root, sub}).ignored(tt.path, false); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWalkNestedGitIgnore(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "*.tmp\nout/\n",
+		"main.go":             "package main\n",
+		"x.tmp":               "",
+		"out/gen.go":          "",
+		"pkg/.gitignore":      "/gen_*.go\n!keep.tmp\ncache/**\n",
+		"pkg/a.go":            "",
+		"pkg/gen_a.go":        "",
+		"pkg/keep.tmp":        "",
+		"pkg/cache/data":      "",
+		"pkg/sub/gen_b.go":    "", // /gen_*.go is anchored to pkg/
+		"pkg/sub/c.tmp":       "",
+		"other/.gitignore":    "!*.tmp\n",
+		"other/o.tmp":         "",
+		"other/out/never.txt": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &collector{gitignore: true, seen: make(map[string]bool)}
+	if err := c.walk(dir); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range c.files {
+		rel, _ := filepath.Rel(dir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{".gitignore", "main.go", "other/.gitignore", "other/o.tmp", "pkg/.gitignore", "pkg/a.go", "pkg/keep.tmp", "pkg/sub/gen_b.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("walk got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Package aimanifest is the round-trip manifest shared by aijoin (which writes it)
+// and aisplit (which verifies the split files against it).
+package aimanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// Entry describes one joined file.
+type Entry struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Lines  int    `json:"lines"`
+}
+
+// Manifest is the list of files joined, in order.
+type Manifest struct {
+	Files []Entry `json:"files"`
+}
+
+// Content returns the canonical content for lines, i.e. what aisplit writes back:
+// each line followed by a newline, so an empty file has an empty content.
+func Content(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// Hash returns the hex sha256 of content.
+func Hash(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+// Add appends an entry for the given file name and lines.
+func (m *Manifest) Add(name string, lines []string) {
+	m.Files = append(m.Files, Entry{Name: name, SHA256: Hash(Content(lines)), Lines: len(lines)})
+}
+
+// Write saves the manifest as indented JSON.
+func (m *Manifest) Write(filename string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
+
+// Read loads a manifest written by [Manifest.Write].
+func Read(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	return m, json.Unmarshal(data, m)
+}
@@ -3,24 +3,81 @@
 
 // This is also mostly AI written and such pretty bad code.
 
-package main
+// Package aisplit is the aisplit tool, usable standalone (cmd/aisplit) or as `depgraph aisplit`.
+package aisplit
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec" // Import os/exec
+	"path/filepath"
 	"strings"
 
 	"fortio.org/cli"
 	"fortio.org/log" // Use fortio/log for consistency
+	"github.com/ldemailly/depgraph/aimanifest"
 )
 
 const (
-	fileMarkerPrefix = "// File: "
-	separatorPrefix  = "// =====" // Re-define separator to skip it
+	fileMarkerPrefix   = "// File: "
+	continuationPrefix = "// Continued: " // File split across aijoin chunks, append to it
+	separatorPrefix    = "// ====="       // Re-define separator to skip it
 )
 
+// section is one extracted file: its name and content (lines joined with \n, trailing \n included).
+type section struct {
+	filename string // as found in the marker
+	path     string // where it will be written (under -dir)
+	content  []byte
+	lines    int
+}
+
+// safePath returns the path under dir for the given (untrusted) filename, rejecting
+// absolute paths and anything escaping dir (path traversal), including through an existing
+// symbolic link in its directories or the file itself.
+func safePath(dir, filename string) (string, error) {
+	clean := filepath.FromSlash(filename)
+	if !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("unsafe file name %q (absolute or escapes output directory)", filename)
+	}
+	clean = filepath.Clean(clean)
+	p := dir
+	for _, part := range strings.Split(clean, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			break // the rest is created by writeSections
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("unsafe file name %q (%s is a symbolic link)", filename, p)
+		}
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// resolvePaths sets the output path of each section, dropping (and logging) unsafe ones.
+func resolvePaths(sections []*section, dir string) []*section {
+	res := make([]*section, 0, len(sections))
+	for _, s := range sections {
+		p, err := safePath(dir, s.filename)
+		if err != nil {
+			log.Errf("Skipping: %v", err)
+			continue
+		}
+		s.path = p
+		res = append(res, s)
+	}
+	return res
+}
+
 // runGoFumpt executes "gofumpt -w" on the specified file
 func runGoFumpt(filename string) {
 	// only on .go files:
@@ -41,18 +98,23 @@ func runGoFumpt(filename string) {
 	}
 }
 
-func main() {
-	cli.Main()
-	// --- Use Stdin as Input ---
-	log.Printf("Reading from stdin... Paste combined code and signal EOF (Ctrl+D).")
-
-	// --- Scan and Split ---
-	scanner := bufio.NewScanner(os.Stdin)
-	var currentFile *os.File
-	var currentFilename string
-	writing := false // Only true when actively writing to a file section
-	linesInCurrentFile := 0
-
+// splitSections scans the combined input and returns the file sections in order.
+func splitSections(scanner *bufio.Scanner) ([]*section, error) {
+	var sections []*section
+	byName := make(map[string]*section)
+	var current *section
+	var buf bytes.Buffer
+	finish := func() {
+		if current != nil {
+			current.content = append(current.content, buf.Bytes()...)
+			if byName[current.filename] == nil {
+				sections = append(sections, current)
+				byName[current.filename] = current
+			}
+			current = nil
+		}
+		buf.Reset()
+	}
 	lineNum := 0
 	for scanner.Scan() {
 		lineNum++
@@ -61,85 +123,313 @@ func main() {
 
 		// Check for File Marker
 		if strings.HasPrefix(trimmedLine, fileMarkerPrefix) {
-			filenameToFormat := "" // Store filename before resetting
-			// Close previous file if open
-			if currentFile != nil {
-				filenameToFormat = currentFilename // Remember filename for gofumpt
-				log.Infof("  Finished %s (%d lines written)", currentFilename, linesInCurrentFile)
-				if err := currentFile.Close(); err != nil {
-					log.Warnf("Failed to close previous file '%s': %v", currentFilename, err)
-					filenameToFormat = "" // Don't format if close failed? Or format anyway? Let's format.
-				}
-				currentFile = nil
-			}
-			// Run gofumpt on the completed file *after* closing it
-			runGoFumpt(filenameToFormat)
-
-			// Extract new filename
-			filename := strings.TrimSpace(strings.TrimPrefix(line, fileMarkerPrefix))
+			finish()
+			filename := strings.TrimSpace(strings.TrimPrefix(trimmedLine, fileMarkerPrefix))
 			if filename == "" {
 				log.Warnf("Warning: Found marker with empty filename on line %d: %s", lineNum, line)
-				writing = false // Stop writing until a valid marker is found
-				continue
+				continue // Stop collecting until a valid marker is found
 			}
-
-			// Create/Truncate new output file
-			log.Infof("  Extracting %s...", filename)
-			outFile, err := os.Create(filename)
-			if err != nil {
-				log.Errf("Failed to create output file '%s': %v", filename, err)
-				writing = false // Stop writing if file creation fails
-				currentFile = nil
-				currentFilename = ""
-				continue
+			current = &section{filename: filename}
+			continue // Don't write the marker line itself
+		}
+		// Continuation of a file split across chunks by aijoin
+		if strings.HasPrefix(trimmedLine, continuationPrefix) {
+			finish()
+			filename := strings.TrimSpace(strings.TrimPrefix(trimmedLine, continuationPrefix))
+			current = byName[filename]
+			if current == nil {
+				log.Warnf("Continuation for unknown file %q on line %d, treating it as a new file", filename, lineNum)
+				current = &section{filename: filename}
 			}
-
-			// Update state
-			currentFile = outFile
-			currentFilename = filename
-			writing = true
-			linesInCurrentFile = 0 // Reset line counter
-			continue               // Don't write the marker line itself
+			continue
 		}
-
 		// Check for Separator and skip writing it
 		if strings.HasPrefix(trimmedLine, separatorPrefix) {
-			// Don't close the file here, just skip writing the separator line
 			continue
 		}
+		if current != nil {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+			current.lines++
+		}
+	}
+	finish()
+	// Remove the aijoin framing: blank line after the header and extra newline between files.
+	for _, s := range sections {
+		if bytes.HasPrefix(s.content, []byte("\n")) {
+			s.content = s.content[1:]
+			s.lines--
+		}
+		// An empty file is just that extra newline, matching aimanifest.Content's empty content.
+		if bytes.HasSuffix(s.content, []byte("\n\n")) || bytes.Equal(s.content, []byte("\n")) {
+			s.content = s.content[:len(s.content)-1]
+			s.lines--
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// verify checks the extracted sections against an aijoin manifest, reporting missing,
+// modified and extra files. Returns false if any file from the manifest is missing.
+func verify(sections []*section, m *aimanifest.Manifest) bool {
+	byName := make(map[string]*section, len(sections))
+	for _, s := range sections {
+		byName[s.filename] = s
+	}
+	ok := true
+	inManifest := make(map[string]bool, len(m.Files))
+	unchanged, modified := 0, 0
+	for _, e := range m.Files {
+		inManifest[e.Name] = true
+		s := byName[e.Name]
+		switch {
+		case s == nil:
+			log.Errf("Missing file %s (%d lines) from the manifest", e.Name, e.Lines)
+			ok = false
+		case aimanifest.Hash(s.content) != e.SHA256:
+			log.Warnf("Modified file %s (%d lines, was %d)", e.Name, s.lines, e.Lines)
+			modified++
+		default:
+			log.LogVf("Unchanged file %s", e.Name)
+			unchanged++
+		}
+	}
+	extra := 0
+	for _, s := range sections {
+		if !inManifest[s.filename] {
+			log.Infof("New file %s (%d lines) not in the manifest", s.filename, s.lines)
+			extra++
+		}
+	}
+	log.Infof("Verify: %d unchanged, %d modified, %d new, %d missing", unchanged, modified, extra, len(m.Files)-unchanged-modified)
+	return ok
+}
+
+// showDiff prints a unified diff between the existing file (if any) and the new content.
+// Uses the system `diff` tool, like we use gofumpt.
+func showDiff(filename string, content []byte, exists bool) {
+	oldName := filename
+	if !exists {
+		oldName = os.DevNull
+	}
+	cmd := exec.Command("diff", "-u", "--label", "a/"+filename, "--label", "b/"+filename, oldName, "-")
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) { // 1 just means "differences found"
+		log.Warnf("  diff failed for %s: %v", filename, err)
+	}
+}
+
+// preview reports what would happen to each file without writing anything.
+func preview(sections []*section, withDiff bool) {
+	for _, s := range sections {
+		existing, err := os.ReadFile(s.path)
+		exists := err == nil
+		action := "create"
+		switch {
+		case exists && bytes.Equal(existing, s.content):
+			action = "unchanged"
+		case exists:
+			action = "overwrite"
+		case !os.IsNotExist(err):
+			log.Warnf("  Unable to read existing %s: %v", s.path, err)
+		}
+		fmt.Printf("%-9s %s (%d lines)\n", action, s.path, s.lines)
+		if withDiff && action != "unchanged" {
+			showDiff(s.path, s.content, exists)
+		}
+	}
+}
+
+// backupFile renames an existing file to file.bak (replacing any previous backup).
+// Returns false if the file didn't exist.
+func backupFile(path string) (bool, error) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+	return true, os.Rename(path, path+".bak")
+}
+
+// runGit runs a git command in dir and returns its (trimmed) combined output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	output, err := cmd.CombinedOutput()
+	out := strings.TrimSpace(string(output))
+	if err != nil {
+		return out, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, out)
+	}
+	return out, nil
+}
+
+// checkGitClean fails if dir isn't in a git work tree, or if it has uncommitted changes (unless force).
+func checkGitClean(dir string, force bool) error {
+	status, err := runGit(dir, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status != "" && !force {
+		return fmt.Errorf("git tree in %s is dirty (use -force to commit anyway):\n%s", dir, status)
+	}
+	return nil
+}
+
+// gitCommit stages and commits the written files (and only those).
+func gitCommit(dir string, written []string, message string) error {
+	if len(written) == 0 {
+		log.Warnf("Nothing written, not committing")
+		return nil
+	}
+	rel := make([]string, 0, len(written))
+	for _, p := range written {
+		r, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = append(rel, r)
+	}
+	if _, err := runGit(dir, append([]string{"add", "--"}, rel...)...); err != nil {
+		return err
+	}
+	out, err := runGit(dir, append([]string{"commit", "-m", message, "--"}, rel...)...)
+	if err != nil {
+		return err
+	}
+	log.Infof("Committed %d files:\n%s", len(rel), out)
+	return nil
+}
 
-		// If NOT a marker or separator, and we have successfully opened a file, write the line
-		if writing && currentFile != nil {
-			_, err := fmt.Fprintln(currentFile, line)
+// writeSections writes (creates/truncates) each section's file and runs gofumpt on it.
+// Returns the paths successfully written.
+func writeSections(sections []*section, backup bool) []string {
+	var written []string
+	for _, s := range sections {
+		log.Infof("  Extracting %s...", s.path)
+		if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+			log.Errf("Failed to create directory for '%s': %v", s.path, err)
+			continue
+		}
+		if backup {
+			backedUp, err := backupFile(s.path)
 			if err != nil {
-				log.Errf("Failed to write line %d to file '%s': %v", lineNum, currentFilename, err)
-				writing = false
-				currentFile.Close()
-				currentFile = nil
-				currentFilename = "" // Stop writing on error
+				log.Errf("Failed to back up '%s', not overwriting it: %v", s.path, err)
 				continue
 			}
-			// Sync removed as likely unnecessary
-			linesInCurrentFile++
+			if backedUp {
+				log.Infof("  Backed up existing %s to %s.bak", s.path, s.path)
+			}
 		}
-	} // End scanner loop
+		if err := os.WriteFile(s.path, s.content, 0o644); err != nil {
+			log.Errf("Failed to write output file '%s': %v", s.path, err)
+			continue
+		}
+		log.Infof("  Finished %s (%d lines written)", s.path, s.lines)
+		runGoFumpt(s.path)
+		written = append(written, s.path)
+	}
+	return written
+}
+
+// clipboardCommands are the commands tried, in order, to read the system clipboard.
+var clipboardCommands = [][]string{
+	{"pbpaste"},                                                   // macOS
+	{"wl-paste", "--no-newline"},                                  // Wayland
+	{"xclip", "-selection", "clipboard", "-o"},                    // X11
+	{"xsel", "--clipboard", "--output"},                           // X11
+	{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}, // Windows (and WSL)
+}
 
-	filenameToFormat := "" // Store filename before resetting
-	// Close the last opened file
-	if currentFile != nil {
-		filenameToFormat = currentFilename // Remember filename
-		log.Infof("  Finished %s (%d lines written) (at EOF)", currentFilename, linesInCurrentFile)
-		if err := currentFile.Close(); err != nil {
-			log.Warnf("Failed to close last file '%s': %v", currentFilename, err)
-			filenameToFormat = "" // Don't format if close failed? Let's format.
+// readClipboard returns the content of the system clipboard using the first available tool.
+func readClipboard() ([]byte, error) {
+	for _, args := range clipboardCommands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
 		}
+		log.LogVf("Reading clipboard using %s", args[0])
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", args[0], err)
+		}
+		return bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n")), nil
+	}
+	return nil, errors.New("no clipboard tool found (pbpaste, wl-paste, xclip, xsel or powershell.exe)")
+}
+
+// openInput returns the scanner for the selected input source: clipboard, file or stdin.
+func openInput(clipboard bool, inputFile string) (*bufio.Scanner, string, error) {
+	switch {
+	case clipboard && inputFile != "":
+		return nil, "", errors.New("-clipboard and -i are mutually exclusive")
+	case clipboard:
+		data, err := readClipboard()
+		if err != nil {
+			return nil, "", err
+		}
+		return bufio.NewScanner(bytes.NewReader(data)), "clipboard", nil
+	case inputFile != "" && inputFile != "-":
+		f, err := os.Open(inputFile) // closed at exit
+		if err != nil {
+			return nil, "", err
+		}
+		return bufio.NewScanner(f), inputFile, nil
+	default:
+		log.Printf("Reading from stdin... Paste combined code and signal EOF (Ctrl+D).")
+		return bufio.NewScanner(os.Stdin), "stdin", nil
+	}
+}
+
+// Main is the aisplit entry point: parses its flags (with fortio/cli) and splits stdin into files.
+func Main() {
+	dryRunFlag := flag.Bool("n", false, "Dry run: list files that would be created/overwritten, don't write anything")
+	diffFlag := flag.Bool("diff", false, "Show a unified diff against existing files (implies -n)")
+	dirFlag := flag.String("dir", ".", "Directory to write the extracted files under (file names can't escape it)")
+	backupFlag := flag.Bool("backup", false, "Rename existing files to <name>.bak before overwriting them")
+	gitCommitFlag := flag.String("git-commit", "", "Stage and git commit the extracted files with this commit `message`")
+	forceFlag := flag.Bool("force", false, "With -git-commit, proceed even if the git tree has uncommitted changes")
+	clipboardFlag := flag.Bool("clipboard", false, "Read the input from the system clipboard instead of stdin")
+	inputFlag := flag.String("i", "", "Read the input from this `file` instead of stdin")
+	verifyFlag := flag.String("verify", "", "Verify the input against this aijoin -manifest `file`, doesn't write anything if files are missing")
+	cli.Main()
+	// --- Select Input (stdin by default) ---
+	scanner, source, err := openInput(*clipboardFlag, *inputFlag)
+	if err != nil {
+		log.Fatalf("Failed opening input: %v", err)
 	}
-	// Run gofumpt on the very last file *after* closing it
-	runGoFumpt(filenameToFormat)
 
+	// --- Scan and Split ---
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // allow long lines
+	sections, err := splitSections(scanner)
 	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Failed reading input from stdin: %v", err)
+	if err != nil {
+		log.Fatalf("Failed reading input from %s: %v", source, err)
+	}
+	sections = resolvePaths(sections, *dirFlag)
+	if *verifyFlag != "" {
+		m, err := aimanifest.Read(*verifyFlag)
+		if err != nil {
+			log.Fatalf("Failed reading manifest: %v", err)
+		}
+		if !verify(sections, m) {
+			log.Fatalf("Verification failed, files are missing (dropped during the round trip?)")
+		}
+	}
+	if *dryRunFlag || *diffFlag {
+		preview(sections, *diffFlag)
+		log.Infof("Dry run, %d files, nothing written.", len(sections))
+		return
+	}
+	if *gitCommitFlag != "" {
+		if err := checkGitClean(*dirFlag, *forceFlag); err != nil {
+			log.Fatalf("Not extracting: %v", err)
+		}
+	}
+	written := writeSections(sections, *backupFlag)
+	if *gitCommitFlag != "" {
+		if err := gitCommit(*dirFlag, written, *gitCommitFlag); err != nil {
+			log.Fatalf("Commit failed: %v", err)
+		}
 	}
 	log.Infof("Done.")
 }
@@ -0,0 +1,120 @@
+package aisplit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/aimanifest"
+)
+
+// join frames the files as aijoin does: separator, file marker, separator, blank line,
+// the lines and an extra newline between files.
+func join(names []string, files map[string][]string) string {
+	var b strings.Builder
+	sep := separatorPrefix + "==================================================================="
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s\n%s %s\n%s\n\n", sep, strings.TrimSpace(fileMarkerPrefix), name, sep)
+		for _, line := range files[name] {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	names := []string{"empty.txt", "blank.txt", "one.go", "trailing_blank.md", "multi.go"}
+	files := map[string][]string{
+		"empty.txt":         nil,
+		"blank.txt":         {""},
+		"one.go":            {"package one"},
+		"trailing_blank.md": {"# Title", ""},
+		"multi.go":          {"package multi", "", "func F() {}"},
+	}
+	m := &aimanifest.Manifest{}
+	for _, name := range names {
+		m.Add(name, files[name])
+	}
+	sections, err := splitSections(bufio.NewScanner(strings.NewReader(join(names, files))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != len(names) {
+		t.Fatalf("got %d sections, want %d", len(sections), len(names))
+	}
+	for i, s := range sections {
+		e := m.Files[i]
+		if s.filename != e.Name {
+			t.Errorf("section %d is %q, want %q", i, s.filename, e.Name)
+			continue
+		}
+		if got, want := string(s.content), string(aimanifest.Content(files[e.Name])); got != want {
+			t.Errorf("%s: content %q, want %q", e.Name, got, want)
+		}
+		if aimanifest.Hash(s.content) != e.SHA256 {
+			t.Errorf("%s: hash differs from the manifest's", e.Name)
+		}
+		if s.lines != e.Lines {
+			t.Errorf("%s: %d lines, want %d", e.Name, s.lines, e.Lines)
+		}
+	}
+	if !verify(sections, m) {
+		t.Errorf("verify reported missing files")
+	}
+}
+
+func TestSafePath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "f.txt"), filepath.Join(dir, "sub", "f.txt")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filename string
+		want     string // "" for an error
+	}{
+		{"a.go", "a.go"},
+		{"sub/b.go", "sub/b.go"},
+		{"new/dir/c.go", "new/dir/c.go"},
+		{"sub/../d.go", "d.go"},
+		{"../x.go", ""},
+		{"sub/../../x.go", ""},
+		{"/etc/passwd", ""},
+		{"link/x.go", ""},        // symlinked directory
+		{"sub/../link/x.go", ""}, // same
+		{"sub/f.txt", ""},        // symlinked file
+		{"link", ""},             // the link itself
+		{"sub/new/../f.txt", ""}, // cleaned to sub/f.txt
+	}
+	for _, tt := range tests {
+		got, err := safePath(dir, tt.filename)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: path %s, want an error", tt.filename, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: error %v", tt.filename, err)
+		case tt.want != "" && got != filepath.Join(dir, filepath.FromSlash(tt.want)):
+			t.Errorf("%s: path %s, want %s under %s", tt.filename, got, tt.want, dir)
+		}
+	}
+	sections := resolvePaths([]*section{
+		{filename: "link/evil.txt", content: []byte("evil\n"), lines: 1},
+		{filename: "sub/ok.txt", content: []byte("ok\n"), lines: 1},
+	}, dir)
+	if written := writeSections(sections, false); len(written) != 1 || written[0] != filepath.Join(dir, "sub", "ok.txt") {
+		t.Errorf("written %v, want only sub/ok.txt", written)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("%d files written through the symbolic link", len(entries))
+	}
+}
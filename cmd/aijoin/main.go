@@ -0,0 +1,8 @@
+// Standalone aijoin entry point, same as `depgraph aijoin`.
+package main
+
+import "github.com/ldemailly/depgraph/aijoin"
+
+func main() {
+	aijoin.Main()
+}
@@ -0,0 +1,8 @@
+// Standalone aisplit entry point, same as `depgraph aisplit`.
+package main
+
+import "github.com/ldemailly/depgraph/aisplit"
+
+func main() {
+	aisplit.Main()
+}
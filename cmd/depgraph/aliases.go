@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Module Path Aliases ---
+
+// aliasRule is a parsed aliases entry: module paths starting with from are renamed to start with to.
+type aliasRule struct {
+	from, to string
+}
+
+// aliases parses the "path == path" entries. Which side is kept is decided by applyAliases.
+func (cfg *config) aliases() ([]aliasRule, error) {
+	var res []aliasRule
+	for _, e := range cfg.Aliases {
+		left, right, found := strings.Cut(e, "==")
+		left, right = strings.TrimSpace(left), strings.TrimSpace(right)
+		if !found || left == "" || right == "" || left == right {
+			return nil, fmt.Errorf("invalid aliases entry %q, expecting \"path == other/path\"", e)
+		}
+		res = append(res, aliasRule{from: right, to: left})
+	}
+	return res, nil
+}
+
+// hasPathPrefix returns true if path is prefix or in it (prefix followed by /).
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// canonicalPath returns the path with the first matching rule applied.
+func canonicalPath(rules []aliasRule, path string) string {
+	for _, r := range rules {
+		if hasPathPrefix(path, r.from) {
+			return r.to + path[len(r.from):]
+		}
+	}
+	return path
+}
+
+// renameKeys returns the map with its keys renamed by the rules (nil stays nil).
+func renameKeys[V any](rules []aliasRule, m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	res := make(map[string]V, len(m))
+	for _, k := range graph.SortedKeys(m) {
+		res[canonicalPath(rules, k)] = m[k]
+	}
+	return res
+}
+
+// applyAliases merges the module paths declared as aliases (e.g. a vanity domain and the
+// GitHub path) into one: the path declared by a scanned module's go.mod is kept, or the
+// left one of the entry if that doesn't tell. Renames the scanned modules, their
+// dependencies and all the encountered paths. To be called before determining the nodes.
+func applyAliases(sr *scan.Result, rules []aliasRule) {
+	if len(rules) == 0 {
+		return
+	}
+	oriented := make([]aliasRule, 0, len(rules))
+	for _, r := range rules {
+		scannedFrom, scannedTo := false, false
+		for path := range sr.Modules {
+			scannedFrom = scannedFrom || hasPathPrefix(path, r.from)
+			scannedTo = scannedTo || hasPathPrefix(path, r.to)
+		}
+		if scannedFrom && !scannedTo {
+			r.from, r.to = r.to, r.from // keep the declared path
+		}
+		log.LogVf("Aliasing module paths %s to %s", r.from, r.to)
+		oriented = append(oriented, r)
+	}
+	modules := make(map[string]*graph.ModuleInfo, len(sr.Modules))
+	for _, path := range graph.SortedKeys(sr.Modules) {
+		info := sr.Modules[path]
+		newPath := canonicalPath(oriented, path)
+		if prev, found := modules[newPath]; found {
+			log.Warnf("Module %s (in %s) is an alias of %s (in %s), keeping the first one", path, info.RepoPath, newPath, prev.RepoPath)
+			continue
+		}
+		info.Path = newPath
+		info.Deps = renameKeys(oriented, info.Deps)
+		info.IndirectDeps = renameKeys(oriented, info.IndirectDeps)
+		info.IgnoredDeps = renameKeys(oriented, info.IgnoredDeps)
+		info.LocalReplaces = renameKeys(oriented, info.LocalReplaces)
+		info.Replaces = renameKeys(oriented, info.Replaces)
+		info.APICoupling = renameKeys(oriented, info.APICoupling)
+		delete(info.Deps, newPath) // a module can't depend on itself
+		modules[newPath] = info
+	}
+	sr.Modules = modules
+	sr.AllPaths = renameKeys(oriented, sr.AllPaths)
+	sr.LocalDirs = renameKeys(oriented, sr.LocalDirs)
+}
+
+// --- End Module Path Aliases ---
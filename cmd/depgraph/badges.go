@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// --- Badges ---
+
+// writeBadges writes the badges of the graph's scanned modules to dir/<module path>/<kind>.svg
+// (-badges), the same paths as serve's /badges/, and returns how many were written.
+func writeBadges(dir string, g *graph.Graph) (int, error) {
+	count := 0
+	for _, path := range g.Paths() {
+		if g.Nodes[path].Module == nil {
+			continue
+		}
+		moduleDir := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(moduleDir, 0o755); err != nil {
+			return count, err
+		}
+		for _, kind := range render.BadgeKinds {
+			b, err := render.NewBadge(g, path, kind)
+			if err != nil {
+				return count, err
+			}
+			var buf bytes.Buffer
+			if err := b.WriteSVG(&buf); err != nil {
+				return count, err
+			}
+			if err := os.WriteFile(filepath.Join(moduleDir, kind+".svg"), buf.Bytes(), 0o644); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// --- End Badges ---
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"fortio.org/cli"
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Cache Subcommand ---
+
+// cacheAges are the upper bounds of the age distribution buckets of `cache stats`.
+var cacheAges = []struct {
+	label string
+	max   time.Duration
+}{
+	{"< 1h", time.Hour},
+	{"< 1d", 24 * time.Hour},
+	{"< 1w", 7 * 24 * time.Hour},
+	{"< 30d", 30 * 24 * time.Hour},
+	{">= 30d", 0}, // the rest
+}
+
+// printCacheStats prints the number and size of the entries, by kind and by age.
+func printCacheStats(cacheDir string, entries []scan.CacheEntry, now time.Time) {
+	type total struct {
+		count, size int
+	}
+	var all total
+	byKind := make(map[string]*total)
+	byAge := make([]total, len(cacheAges))
+	for _, e := range entries {
+		all.count++
+		all.size += e.Size
+		kind := e.Kind()
+		if byKind[kind] == nil {
+			byKind[kind] = &total{}
+		}
+		byKind[kind].count++
+		byKind[kind].size += e.Size
+		age := now.Sub(e.Time)
+		for i, a := range cacheAges {
+			if a.max == 0 || age < a.max {
+				byAge[i].count++
+				byAge[i].size += e.Size
+				break
+			}
+		}
+	}
+	fmt.Printf("Cache %s: %d entries, %s\n", cacheDir, all.count, formatBytes(all.size))
+	fmt.Printf("By kind:\n")
+	for _, kind := range graph.SortedKeys(byKind) {
+		fmt.Printf("  %-9s %6d entries %10s\n", kind, byKind[kind].count, formatBytes(byKind[kind].size))
+	}
+	fmt.Printf("By age:\n")
+	for i, a := range cacheAges {
+		fmt.Printf("  %-9s %6d entries %10s\n", a.label, byAge[i].count, formatBytes(byAge[i].size))
+	}
+}
+
+// formatBytes returns a human readable size.
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// cacheMain is the `depgraph cache` subcommand: inspects the cache (stats, ls) and removes
+// selected entries (rm), instead of the whole cache with -clear-cache.
+func cacheMain() {
+	backendFlag := flag.String("cache-backend", scan.CacheBackendFiles, "Cache storage to inspect: files, bolt or remote cache url (ls and stats show the local entries, rm removes remote ones too)")
+	cli.ArgsHelp = "stats | ls [owner[/repo]] | rm owner[/repo]|key\n" +
+		"stats: number, size and age of the entries; ls: the entries (of an owner or repository);\n" +
+		"rm: removes the entries of an owner or repository, or one entry by key (as listed by ls)"
+	cli.MinArgs = 1
+	cli.MaxArgs = 2
+	cli.Main()
+	cache, err := scan.NewCache(*backendFlag, true)
+	if err != nil {
+		cli.ErrUsage("Invalid -cache-backend: %v", err)
+	}
+	cmd, arg := flag.Arg(0), flag.Arg(1)
+	switch {
+	case cmd == "stats" && flag.NArg() == 1, cmd == "ls":
+	case cmd == "rm" && flag.NArg() == 2:
+	default:
+		cli.ErrUsage("Expecting stats, ls [owner[/repo]] or rm owner[/repo]|key")
+	}
+	if err := cache.Open(); err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	defer cache.Close()
+	entries, err := cache.Entries()
+	if err != nil {
+		log.Fatalf("Failed to list the cache entries: %v", err)
+	}
+	if cmd == "stats" {
+		printCacheStats(cache.Dir, entries, time.Now())
+		return
+	}
+	// Entries selected by the owner[/repo] or key argument
+	owner, repo, _ := strings.Cut(arg, "/")
+	byKey := strings.Contains(arg, "|") || strings.HasSuffix(arg, ".json")
+	var selected []scan.CacheEntry
+	for _, e := range entries {
+		switch {
+		case arg == "":
+			selected = append(selected, e)
+		case byKey:
+			if e.HasKey(arg) {
+				selected = append(selected, e)
+			}
+		case e.Matches(owner, repo):
+			selected = append(selected, e)
+		}
+	}
+	if cmd == "ls" {
+		now := time.Now()
+		for _, e := range selected {
+			fmt.Printf("%10s %9s  %s\n", now.Sub(e.Time).Round(time.Second), formatBytes(e.Size), e.Name())
+		}
+		fmt.Printf("%d entries.\n", len(selected))
+		return
+	}
+	removed := 0
+	for _, e := range selected {
+		if err := cache.Remove(e.Key); err != nil {
+			log.Errf("Error removing %s: %v", e.Name(), err)
+			continue
+		}
+		log.LogVf("Removed %s", e.Name())
+		removed++
+	}
+	if len(selected) == 0 {
+		log.Warnf("No cache entry for %s", arg)
+	}
+	fmt.Printf("Removed %d entries.\n", removed)
+}
+
+// --- End Cache Subcommand ---
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"gopkg.in/yaml.v3"
+)
+
+// --- Configuration File ---
+
+// defaultConfigFile is the configuration file used, when present in the current directory,
+// without -config: teams can commit it and run a bare `depgraph` (e.g. in CI).
+const defaultConfigFile = ".depgraph.yaml"
+
+// config is the content of the (optional, -config) YAML configuration file.
+type config struct {
+	// Owners are the arguments (owners, owner/repo or, with -local, directories) when none
+	// are given on the command line.
+	Owners []string `yaml:"owners"`
+	// Flags are command line flags (name without the dash: value), used when not given on
+	// the command line, e.g. noext: true, format: topo, cache-ttl: 24h.
+	Flags map[string]any `yaml:"flags"`
+	// Colors are the DOT fill colors of the modules of each owner (in the arguments order),
+	// replacing the default palette, ForkColors the same for the forks.
+	Colors     []string `yaml:"colors"`
+	ForkColors []string `yaml:"fork-colors"`
+	// IgnoreEdges are "from -> to" dependencies that are known and accepted (grandfathered
+	// exceptions): they are still drawn but don't count for cycle detection and checks.
+	IgnoreEdges []string `yaml:"ignore-edges"`
+	// Effort is the estimated release effort (e.g. in days) per module path (or suffix), to
+	// compute the cumulative effort per topological level. EffortDefault (default 1) is used
+	// for the scanned modules not listed, external ones count as 0.
+	Effort        map[string]float64 `yaml:"effort"`
+	EffortDefault *float64           `yaml:"effort-default"`
+	// Aliases are "path == other/path" module paths (or path prefixes) to merge into one node,
+	// e.g. a vanity domain and the GitHub path of the same modules.
+	Aliases []string `yaml:"aliases"`
+	// Freshness are the SLAs the requirements must meet, checked using the module proxy.
+	Freshness []freshnessRule `yaml:"freshness"`
+}
+
+// readConfig reads and validates the YAML configuration file.
+func readConfig(filename string) (*config, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if _, err := cfg.ignoredEdges(); err != nil {
+		return nil, fmt.Errorf("in %s: %w", filename, err)
+	}
+	if _, err := cfg.aliases(); err != nil {
+		return nil, fmt.Errorf("in %s: %w", filename, err)
+	}
+	if _, err := cfg.freshnessRules(); err != nil {
+		return nil, fmt.Errorf("in %s: %w", filename, err)
+	}
+	for _, name := range graph.SortedKeys(cfg.Flags) {
+		if name == "config" || flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("in %s: unknown flag %q in flags", filename, name)
+		}
+	}
+	return cfg, nil
+}
+
+// configFileName returns the configuration file to use: the -config one, else
+// defaultConfigFile if it exists, "" for none.
+func configFileName(configFlag string) string {
+	if configFlag != "" {
+		return configFlag
+	}
+	if _, err := os.Stat(defaultConfigFile); err != nil {
+		return ""
+	}
+	log.Infof("Using the configuration file %s", defaultConfigFile)
+	return defaultConfigFile
+}
+
+// applyFlags sets the flags of the configuration that weren't given on the command line.
+// Lists are comma separated values, e.g. manifests: [go, npm].
+func (cfg *config) applyFlags() error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range graph.SortedKeys(cfg.Flags) {
+		if set[name] {
+			log.LogVf("Flag -%s of the command line overrides the configuration's", name)
+			continue
+		}
+		value := fmt.Sprint(cfg.Flags[name])
+		if list, ok := cfg.Flags[name].([]any); ok {
+			values := make([]string, 0, len(list))
+			for _, v := range list {
+				values = append(values, fmt.Sprint(v))
+			}
+			value = strings.Join(values, ",")
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("flag %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// edgeRule is a parsed ignore-edges entry.
+type edgeRule struct {
+	from, to string
+}
+
+// ignoredEdges parses the "from -> to" entries.
+func (cfg *config) ignoredEdges() ([]edgeRule, error) {
+	var res []edgeRule
+	for _, e := range cfg.IgnoreEdges {
+		from, to, found := strings.Cut(e, "->")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid ignore-edges entry %q, expecting \"from -> to\"", e)
+		}
+		res = append(res, edgeRule{from: from, to: to})
+	}
+	return res, nil
+}
+
+// effortTracker returns the release effort tracker of the effort weights, nil if there are none.
+func (cfg *config) effortTracker() *render.EffortTracker {
+	def := 1.
+	if cfg.EffortDefault != nil {
+		def = *cfg.EffortDefault
+	}
+	return render.NewEffortTracker(cfg.Effort, def)
+}
+
+// applyIgnoredEdges moves the direct dependencies matching the rules from Deps to IgnoredDeps,
+// so they are excluded from cycle detection and checks but still shown. To be called after
+// the nodes to graph are determined so no node gets hidden.
+func applyIgnoredEdges(modulesFoundInOwners map[string]*graph.ModuleInfo, rules []edgeRule) {
+	if len(rules) == 0 {
+		return
+	}
+	used := make([]bool, len(rules))
+	for _, modPath := range graph.SortedKeys(modulesFoundInOwners) {
+		info := modulesFoundInOwners[modPath]
+		for _, depPath := range graph.SortedKeys(info.Deps) {
+			for i, r := range rules {
+				if !graph.MatchModule(r.from, modPath) || !graph.MatchModule(r.to, depPath) {
+					continue
+				}
+				if info.IgnoredDeps == nil {
+					info.IgnoredDeps = make(map[string]string)
+				}
+				log.LogVf("Ignoring edge %s -> %s (rule %q)", modPath, depPath, r.from+" -> "+r.to)
+				info.IgnoredDeps[depPath] = info.Deps[depPath]
+				delete(info.Deps, depPath)
+				used[i] = true
+				break
+			}
+		}
+	}
+	unused := []string{}
+	for i, r := range rules {
+		if !used[i] {
+			unused = append(unused, r.from+" -> "+r.to)
+		}
+	}
+	sort.Strings(unused)
+	for _, u := range unused {
+		log.Warnf("ignore-edges entry %q didn't match any dependency", u)
+	}
+}
+
+// --- End Configuration File ---
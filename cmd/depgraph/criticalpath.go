@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// --- Critical Path (Longest Chain) ---
+
+// longestChain returns the longest dependency chain among the scanned modules of the graph,
+// in release order (dependencies first): the critical path for rolling releases in
+// topological order. With effort weights, the chain with the largest total effort is
+// returned instead, with that total (else the number of modules). Modules in or depending
+// on cycles are skipped.
+func longestChain(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, effort *render.EffortTracker) ([]string, float64) {
+	weight := func(string) float64 { return 1 }
+	if effort != nil {
+		weight = func(path string) float64 { return effort.Of(path, modulesFoundInOwners) }
+	}
+	// Internal subgraph, dependencies first (Kahn's algorithm)
+	inDegree := make(map[string]int)        // number of internal dependencies not yet processed
+	dependents := make(map[string][]string) // dep -> internal modules depending on it
+	for path := range nodesToGraph {
+		info := modulesFoundInOwners[path]
+		if info == nil {
+			continue
+		}
+		inDegree[path] = 0
+		for dep := range info.Deps {
+			if _, internal := modulesFoundInOwners[dep]; internal && nodesToGraph[dep] {
+				inDegree[path]++
+				dependents[dep] = append(dependents[dep], path)
+			}
+		}
+	}
+	queue := []string{}
+	for _, path := range graph.SortedKeys(inDegree) {
+		if inDegree[path] == 0 {
+			queue = append(queue, path)
+		}
+	}
+	total := make(map[string]float64) // path -> total weight of the longest chain ending with it
+	prev := make(map[string]string)   // path -> previous module in that chain
+	processed := 0
+	best := ""
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		processed++
+		total[cur] += weight(cur)
+		if best == "" || total[cur] > total[best] || (total[cur] == total[best] && cur < best) {
+			best = cur
+		}
+		next := dependents[cur]
+		sort.Strings(next)
+		for _, d := range next {
+			if total[cur] > total[d] || (total[cur] == total[d] && (prev[d] == "" || cur < prev[d])) {
+				total[d], prev[d] = total[cur], cur
+			}
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				queue = append(queue, d)
+			}
+		}
+	}
+	if skipped := len(inDegree) - processed; skipped > 0 {
+		log.Warnf("Skipped %d modules in or depending on dependency cycles for the longest chain", skipped)
+	}
+	if best == "" {
+		return nil, 0
+	}
+	chain := []string{}
+	for cur := best; cur != ""; cur = prev[cur] {
+		chain = append(chain, cur)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, total[best]
+}
+
+// printLongestChain outputs the longest chain of the scanned modules and its length.
+func printLongestChain(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, effort *render.EffortTracker) {
+	chain, total := longestChain(modulesFoundInOwners, nodesToGraph, effort)
+	length := fmt.Sprintf("%d modules", len(chain))
+	if effort != nil {
+		length += ", effort " + render.FormatEffort(total)
+	}
+	fmt.Printf("Longest Dependency Chain (critical path, release order, %s):\n", length)
+	for i, path := range chain {
+		fmt.Printf("  %d. %s\n", i+1, path)
+	}
+}
+
+// --- End Critical Path (Longest Chain) ---
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Reverse Dependencies (Dependents) ---
+
+// dependent is a scanned module depending on the queried module.
+type dependent struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"` // required version of the module (direct dependents)
+	Via     string `json:"via,omitempty"`     // module it depends on the queried one through (transitive dependents)
+	Depth   int    `json:"depth"`             // 1 for direct dependents
+}
+
+// dependentsResult is the -dependents output.
+type dependentsResult struct {
+	Module     string      `json:"module"`
+	Dependents []dependent `json:"dependents"`
+}
+
+// findDependents returns the scanned modules depending directly on the module (path, or
+// path suffix, see graph.MatchModule, among all the encountered ones) and, if transitive, the
+// ones depending on those, breadth first. The ignored edges (ignore-edges config) count:
+// the dependency is still there.
+func findDependents(modulesFoundInOwners map[string]*graph.ModuleInfo, allModulePaths map[string]bool, query string, transitive bool) (*dependentsResult, error) {
+	target := depgraph.ResolveModule(query, allModulePaths)
+	if target == "" {
+		return nil, fmt.Errorf("module %q is neither scanned nor a dependency of a scanned module", query)
+	}
+	reverse := make(map[string]map[string]string) // dep -> dependent -> version
+	for _, path := range graph.SortedKeys(modulesFoundInOwners) {
+		info := modulesFoundInOwners[path]
+		for _, deps := range []map[string]string{info.Deps, info.IgnoredDeps} {
+			for dep, version := range deps {
+				if reverse[dep] == nil {
+					reverse[dep] = make(map[string]string)
+				}
+				reverse[dep][path] = version
+			}
+		}
+	}
+	res := &dependentsResult{Module: target, Dependents: []dependent{}}
+	seen := map[string]bool{target: true}
+	level := []string{target}
+	for depth := 1; len(level) > 0; depth++ {
+		var next []string
+		for _, mod := range level {
+			for _, path := range graph.SortedKeys(reverse[mod]) {
+				if seen[path] {
+					continue
+				}
+				seen[path] = true
+				d := dependent{Path: path, Depth: depth}
+				if depth == 1 {
+					d.Version = reverse[mod][path]
+				} else {
+					d.Via = mod
+				}
+				res.Dependents = append(res.Dependents, d)
+				next = append(next, path)
+			}
+		}
+		if !transitive {
+			break
+		}
+		sort.Strings(next)
+		level = next
+	}
+	return res, nil
+}
+
+// printDependents outputs the dependents as text, or JSON if asJSON.
+func printDependents(w io.Writer, res *dependentsResult, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	fmt.Fprintf(w, "Dependents of %s (%d):\n", res.Module, len(res.Dependents))
+	for _, d := range res.Dependents {
+		if d.Depth == 1 {
+			fmt.Fprintf(w, "  - %s (requires %s)\n", d.Path, d.Version)
+		} else {
+			fmt.Fprintf(w, "  - %s (via %s, depth %d)\n", d.Path, d.Via, d.Depth)
+		}
+	}
+	return nil
+}
+
+// --- End Reverse Dependencies (Dependents) ---
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fortio.org/cli"
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// --- Graph Diff ---
+
+// graphDiff is what changed between 2 graphs.
+type graphDiff struct {
+	Added   []string               // paths of the nodes only in the new graph
+	Removed []string               // paths of the nodes only in the old graph
+	Changed map[string][]depChange // path of the nodes in both -> changes of their dependencies
+}
+
+// depChange is an added (Old ""), removed (New "") or updated dependency.
+type depChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// diffGraphs compares the nodes, and the dependencies of the nodes in both, of 2 graphs.
+func diffGraphs(oldGraph, newGraph *render.JSONOutput) *graphDiff {
+	oldNodes := make(map[string]render.JSONNode, len(oldGraph.Nodes))
+	for _, n := range oldGraph.Nodes {
+		oldNodes[n.Path] = n
+	}
+	newNodes := make(map[string]render.JSONNode, len(newGraph.Nodes))
+	for _, n := range newGraph.Nodes {
+		newNodes[n.Path] = n
+	}
+	d := &graphDiff{Changed: make(map[string][]depChange)}
+	for _, path := range graph.SortedKeys(oldNodes) {
+		if _, found := newNodes[path]; !found {
+			d.Removed = append(d.Removed, path)
+		}
+	}
+	for _, path := range graph.SortedKeys(newNodes) {
+		oldNode, found := oldNodes[path]
+		if !found {
+			d.Added = append(d.Added, path)
+			continue
+		}
+		newDeps := newNodes[path].Deps
+		all := make(map[string]bool, len(oldNode.Deps)+len(newDeps))
+		for dep := range oldNode.Deps {
+			all[dep] = true
+		}
+		for dep := range newDeps {
+			all[dep] = true
+		}
+		for _, dep := range graph.SortedKeys(all) {
+			if oldNode.Deps[dep] != newDeps[dep] {
+				d.Changed[path] = append(d.Changed[path], depChange{Path: dep, Old: oldNode.Deps[dep], New: newDeps[dep]})
+			}
+		}
+	}
+	return d
+}
+
+// printDiff writes the diff as text: + added, - removed and ~ updated.
+func printDiff(w io.Writer, d *graphDiff) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	printf("Added modules (%d):\n", len(d.Added))
+	for _, path := range d.Added {
+		printf("  + %s\n", path)
+	}
+	printf("Removed modules (%d):\n", len(d.Removed))
+	for _, path := range d.Removed {
+		printf("  - %s\n", path)
+	}
+	printf("Changed dependencies (%d modules):\n", len(d.Changed))
+	for _, path := range graph.SortedKeys(d.Changed) {
+		printf("  %s:\n", path)
+		for _, c := range d.Changed[path] {
+			switch {
+			case c.Old == "":
+				printf("    + %s %s\n", c.Path, c.New)
+			case c.New == "":
+				printf("    - %s %s\n", c.Path, c.Old)
+			default:
+				printf("    ~ %s %s -> %s\n", c.Path, c.Old, c.New)
+			}
+		}
+	}
+	return err
+}
+
+// diffMain is the `depgraph diff` subcommand: what changed between 2 graphs saved with -json
+// (or snapshots), e.g. of the same org at different times.
+func diffMain() {
+	cli.ArgsHelp = "old.json new.json\n" +
+		"Outputs the modules added to and removed from the graph, and the dependencies changed"
+	cli.MinArgs = 2
+	cli.MaxArgs = 2
+	cli.Main()
+	oldGraph, err := readJSONGraph(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read graph: %v", err)
+	}
+	newGraph, err := readJSONGraph(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Failed to read graph: %v", err)
+	}
+	if err := printDiff(os.Stdout, diffGraphs(oldGraph, newGraph)); err != nil {
+		log.Fatalf("Failed writing diff output: %v", err)
+	}
+}
+
+// --- End Graph Diff ---
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/render"
+)
+
+// diffTestGraphs returns the graphs of the diff tests: x and y in both (x's dependencies
+// added, removed and updated), z removed and w added.
+func diffTestGraphs() (oldGraph, newGraph *render.JSONOutput) {
+	oldGraph = &render.JSONOutput{Nodes: []render.JSONNode{
+		{Path: "x", Deps: map[string]string{"y": "v1.0.0", "z": "v1.0.0"}},
+		{Path: "y"},
+		{Path: "z"},
+	}}
+	newGraph = &render.JSONOutput{Nodes: []render.JSONNode{
+		{Path: "w"},
+		{Path: "x", Deps: map[string]string{"y": "v1.1.0", "w": "v0.1.0"}},
+		{Path: "y"},
+	}}
+	return oldGraph, newGraph
+}
+
+func TestDiffGraphs(t *testing.T) {
+	oldGraph, newGraph := diffTestGraphs()
+	d := diffGraphs(oldGraph, newGraph)
+	if !slices.Equal(d.Added, []string{"w"}) || !slices.Equal(d.Removed, []string{"z"}) {
+		t.Errorf("added %v, removed %v, want [w] and [z]", d.Added, d.Removed)
+	}
+	want := map[string][]depChange{"x": {
+		{Path: "w", New: "v0.1.0"},
+		{Path: "y", Old: "v1.0.0", New: "v1.1.0"},
+		{Path: "z", Old: "v1.0.0"},
+	}}
+	if !maps.EqualFunc(d.Changed, want, slices.Equal) {
+		t.Errorf("changed %v, want %v", d.Changed, want)
+	}
+	if d := diffGraphs(newGraph, newGraph); len(d.Added)+len(d.Removed)+len(d.Changed) != 0 {
+		t.Errorf("same graph: %+v, want no changes", d)
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestPrintDiff(t *testing.T) {
+	oldGraph, newGraph := diffTestGraphs()
+	d := diffGraphs(oldGraph, newGraph)
+	var out strings.Builder
+	if err := printDiff(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	want := `Added modules (1):
+  + w
+Removed modules (1):
+  - z
+Changed dependencies (1 modules):
+  x:
+    + w v0.1.0
+    ~ y v1.0.0 -> v1.1.0
+    - z v1.0.0
+`
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if err := printDiff(errWriter{}, d); err == nil {
+		t.Errorf("failing writer: no error")
+	}
+}
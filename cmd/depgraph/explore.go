@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/rivo/tview"
+)
+
+// --- Terminal Explorer ---
+
+// exploreKeys are the key bindings of the explore terminal UI, shown in its status line.
+const exploreKeys = "Enter: go to  Tab: next pane  /: filter  c: cycles  space: mark  b: back  e: export DOT  q: quit"
+
+// explorer is the state of an explore session, independent of its terminal UI: the modules
+// listed (matching the filter, or in the cycles), the current module and the marked ones.
+type explorer struct {
+	g        *graph.Graph
+	opts     render.Options
+	filter   string          // the listed modules contain it
+	cycles   bool            // list the modules of the cycles, by cycle, instead of all
+	current  string          // "" before any
+	history  []string        // previous modules, for back
+	marked   map[string]bool // modules marked for the export
+	cycleNum map[string]int  // path -> number (from 1) of its cycle
+}
+
+// newExplorer returns the explorer of the graph, exporting with the render options.
+func newExplorer(g *graph.Graph, opts render.Options) *explorer {
+	e := &explorer{g: g, opts: opts, marked: make(map[string]bool), cycleNum: make(map[string]int)}
+	for i, c := range g.Cycles {
+		for _, n := range c.Nodes {
+			e.cycleNum[n.Path] = i + 1
+		}
+	}
+	return e
+}
+
+// listed returns the modules of the module list: containing the filter, and of the cycles
+// (by cycle) in cycles mode.
+func (e *explorer) listed() []string {
+	var paths []string
+	if e.cycles {
+		for _, c := range e.g.Cycles {
+			for _, n := range c.Nodes {
+				paths = append(paths, n.Path)
+			}
+		}
+	} else {
+		paths = e.g.Paths()
+	}
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if strings.Contains(path, e.filter) {
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
+// label returns the list entry of a module: marked with * and followed by [ext] (external)
+// and [cycle N] if applicable, after the extra text (e.g. the required version).
+func (e *explorer) label(path, extra string) string {
+	var b strings.Builder
+	if e.marked[path] {
+		b.WriteString("* ")
+	} else {
+		b.WriteString("  ")
+	}
+	b.WriteString(path)
+	if extra != "" {
+		b.WriteString(" " + extra)
+	}
+	if e.g.Nodes[path].Module == nil {
+		b.WriteString(" [ext]")
+	}
+	if n := e.cycleNum[path]; n > 0 {
+		fmt.Fprintf(&b, " [cycle %d]", n)
+	}
+	return b.String()
+}
+
+// goTo makes the module the current one.
+func (e *explorer) goTo(path string) {
+	if e.current != "" && e.current != path {
+		e.history = append(e.history, e.current)
+	}
+	e.current = path
+}
+
+// back goes back to the previous module, returns false if there is none.
+func (e *explorer) back() bool {
+	if len(e.history) == 0 {
+		return false
+	}
+	e.current = e.history[len(e.history)-1]
+	e.history = e.history[:len(e.history)-1]
+	return true
+}
+
+// toggleMark marks the module for the export, or unmarks it.
+func (e *explorer) toggleMark(path string) {
+	if e.marked[path] {
+		delete(e.marked, path)
+	} else {
+		e.marked[path] = true
+	}
+}
+
+// edges returns the dependencies, or the dependents, of the current module: the paths of the
+// other ends and the required versions.
+func (e *explorer) edges(dependents bool) ([]string, []string) {
+	if e.current == "" {
+		return nil, nil
+	}
+	edges := e.g.Dependencies(e.current)
+	if dependents {
+		edges = e.g.Dependents(e.current)
+	}
+	paths := make([]string, 0, len(edges))
+	versions := make([]string, 0, len(edges))
+	for _, edge := range edges {
+		other := edge.To
+		if dependents {
+			other = edge.From
+		}
+		paths = append(paths, other.Path)
+		versions = append(versions, edge.Version)
+	}
+	return paths, versions
+}
+
+// details returns the description of the current module: repository, go version, fork
+// parent and number of dependencies and dependents.
+func (e *explorer) details() string {
+	if e.current == "" {
+		return "No current module: select one in the list (Enter)"
+	}
+	var b strings.Builder
+	b.WriteString(e.current)
+	info := e.g.Nodes[e.current].Module
+	if info == nil {
+		b.WriteString("\nExternal dependency")
+	} else {
+		fmt.Fprintf(&b, "\nRepository %s", info.RepoPath)
+		if info.Dir != "" {
+			fmt.Fprintf(&b, " (%s)", info.Dir)
+		}
+		if info.GoVersion != "" {
+			fmt.Fprintf(&b, ", go %s", info.GoVersion)
+		}
+		if info.IsFork {
+			fmt.Fprintf(&b, "\nFork of %s", info.OriginalModulePath)
+		}
+		if info.Error != "" {
+			fmt.Fprintf(&b, "\nError: %s", info.Error)
+		}
+	}
+	fmt.Fprintf(&b, "\n%d dependencies, %d dependents", len(e.g.Dependencies(e.current)), len(e.g.Dependents(e.current)))
+	if n := e.cycleNum[e.current]; n > 0 {
+		fmt.Fprintf(&b, "\nIn dependency cycle %d (of %d modules)", n, len(e.g.Cycles[n-1].Nodes))
+	}
+	return b.String()
+}
+
+// selection returns the modules to export: the marked ones or, if none, the current module
+// with its dependencies and dependents.
+func (e *explorer) selection() map[string]bool {
+	res := make(map[string]bool, len(e.marked))
+	for path := range e.marked {
+		res[path] = true
+	}
+	if len(res) > 0 || e.current == "" {
+		return res
+	}
+	res[e.current] = true
+	for _, dependents := range []bool{false, true} {
+		paths, _ := e.edges(dependents)
+		for _, path := range paths {
+			res[path] = true
+		}
+	}
+	return res
+}
+
+// export writes the graph of the selection as DOT to w, returns its number of modules.
+func (e *explorer) export(w io.Writer) (int, error) {
+	selection := e.selection()
+	if len(selection) == 0 {
+		return 0, errors.New("nothing selected: mark modules (space) or go to one (Enter) first")
+	}
+	return len(selection), render.Render(render.FormatDOT, graph.New(nil, e.g.Modules, selection), w, e.opts)
+}
+
+// exportFile writes the graph of the selection as DOT to the file, like export.
+func (e *explorer) exportFile(filename string) (int, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	n, err := e.export(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// exploreUI is the terminal UI of an explore session: the filter and module list on the left,
+// the current module with its dependencies and dependents on the right, and the status line
+// (or export prompt) at the bottom.
+type exploreUI struct {
+	e          *explorer
+	app        *tview.Application
+	filter     *tview.InputField
+	modules    *tview.List
+	details    *tview.TextView
+	deps       *tview.List
+	dependents *tview.List
+	status     *tview.TextView
+	prompt     *tview.InputField
+	bottom     *tview.Pages
+	paths      map[*tview.List][]string // the modules of each list's items
+}
+
+// newExploreUI returns the terminal UI of the explorer.
+func newExploreUI(e *explorer) *exploreUI {
+	u := &exploreUI{e: e, app: tview.NewApplication(), paths: make(map[*tview.List][]string)}
+	u.filter = tview.NewInputField().SetLabel("Filter: ").SetChangedFunc(func(text string) {
+		u.e.filter = text
+		u.refreshModules()
+	}).SetDoneFunc(func(tcell.Key) { u.app.SetFocus(u.modules) })
+	newList := func(title string) *tview.List {
+		l := tview.NewList().ShowSecondaryText(false)
+		l.SetSelectedFunc(func(i int, _, _ string, _ rune) { u.goTo(u.paths[l][i]) })
+		l.SetBorder(true).SetTitle(title)
+		return l
+	}
+	u.modules, u.deps, u.dependents = newList(" Modules "), newList(" Dependencies "), newList(" Dependents ")
+	u.details = tview.NewTextView()
+	u.details.SetBorder(true)
+	u.status = tview.NewTextView()
+	u.prompt = tview.NewInputField().SetLabel("Export the selection as DOT to: ")
+	u.prompt.SetDoneFunc(u.exportDone)
+	u.bottom = tview.NewPages().AddPage("status", u.status, true, true).AddPage("prompt", u.prompt, true, false)
+	left := tview.NewFlex().SetDirection(tview.FlexRow).AddItem(u.filter, 1, 0, false).AddItem(u.modules, 0, 1, true)
+	right := tview.NewFlex().SetDirection(tview.FlexRow).AddItem(u.details, 7, 0, false).
+		AddItem(u.deps, 0, 1, false).AddItem(u.dependents, 0, 1, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tview.NewFlex().AddItem(left, 0, 1, true).AddItem(right, 0, 1, false), 0, 1, true).
+		AddItem(u.bottom, 1, 0, false)
+	u.app.SetRoot(root, true).SetFocus(u.modules).SetInputCapture(u.keys)
+	u.setStatus(fmt.Sprintf("%d modules, %d dependencies, %d cycles", len(e.g.Nodes), len(e.g.Edges), len(e.g.Cycles)))
+	u.refreshModules()
+	u.refreshCurrent()
+	return u
+}
+
+// list returns the focused list, nil if the focus is elsewhere (filter, prompt).
+func (u *exploreUI) list() *tview.List {
+	l, _ := u.app.GetFocus().(*tview.List)
+	return l
+}
+
+// fill replaces the items of the list by the modules (and their labels), keeping the
+// highlighted one if still listed.
+func (u *exploreUI) fill(l *tview.List, paths, labels []string) {
+	highlighted := ""
+	if prev := u.paths[l]; l.GetCurrentItem() < len(prev) {
+		highlighted = prev[l.GetCurrentItem()]
+	}
+	u.paths[l] = paths
+	l.Clear()
+	for i, label := range labels {
+		l.AddItem(tview.Escape(label), "", 0, nil)
+		if paths[i] == highlighted {
+			l.SetCurrentItem(i)
+		}
+	}
+}
+
+// refreshModules refills the module list, per the filter and the cycles mode.
+func (u *exploreUI) refreshModules() {
+	paths := u.e.listed()
+	labels := make([]string, 0, len(paths))
+	for _, path := range paths {
+		labels = append(labels, u.e.label(path, ""))
+	}
+	u.fill(u.modules, paths, labels)
+	title := " Modules "
+	if u.e.cycles {
+		title = " Modules in cycles "
+	}
+	if u.e.filter != "" {
+		title += fmt.Sprintf("matching %q ", u.e.filter)
+	}
+	u.modules.SetTitle(fmt.Sprintf("%s(%d) ", title, len(paths)))
+}
+
+// refreshCurrent shows the current module with its dependencies and dependents.
+func (u *exploreUI) refreshCurrent() {
+	u.details.SetText(u.e.details())
+	for _, dependents := range []bool{false, true} {
+		l := u.deps
+		if dependents {
+			l = u.dependents
+		}
+		paths, versions := u.e.edges(dependents)
+		labels := make([]string, 0, len(paths))
+		for i, path := range paths {
+			labels = append(labels, u.e.label(path, versions[i]))
+		}
+		u.fill(l, paths, labels)
+	}
+}
+
+// goTo makes the module the current one.
+func (u *exploreUI) goTo(path string) {
+	u.e.goTo(path)
+	u.refreshCurrent()
+}
+
+// setStatus shows the message, followed by the key bindings, in the status line.
+func (u *exploreUI) setStatus(msg string) {
+	u.status.SetText(tview.Escape(msg + " | " + exploreKeys))
+}
+
+// keys handles the key bindings (see exploreKeys), but while typing a filter or file name.
+func (u *exploreUI) keys(ev *tcell.EventKey) *tcell.EventKey {
+	if _, typing := u.app.GetFocus().(*tview.InputField); typing {
+		return ev
+	}
+	switch ev.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		order := []tview.Primitive{u.modules, u.deps, u.dependents}
+		i := 0
+		for j, p := range order {
+			if p == u.app.GetFocus() {
+				i = j
+			}
+		}
+		step := 1
+		if ev.Key() == tcell.KeyBacktab {
+			step = len(order) - 1
+		}
+		u.app.SetFocus(order[(i+step)%len(order)])
+		return nil
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		u.back()
+		return nil
+	case tcell.KeyRune:
+	default:
+		return ev
+	}
+	switch ev.Rune() {
+	case 'q':
+		u.app.Stop()
+	case '/':
+		u.app.SetFocus(u.filter)
+	case 'c':
+		u.e.cycles = !u.e.cycles
+		u.refreshModules()
+		u.app.SetFocus(u.modules)
+	case ' ':
+		if l := u.list(); l != nil && l.GetItemCount() > 0 {
+			u.e.toggleMark(u.paths[l][l.GetCurrentItem()])
+			u.refreshModules()
+			u.refreshCurrent()
+			u.setStatus(fmt.Sprintf("%d modules marked", len(u.e.marked)))
+		}
+	case 'b':
+		u.back()
+	case 'e':
+		if u.prompt.GetText() == "" {
+			u.prompt.SetText("selection.dot")
+		}
+		u.bottom.SwitchToPage("prompt")
+		u.app.SetFocus(u.prompt)
+	default:
+		return ev
+	}
+	return nil
+}
+
+// back goes back to the previous module.
+func (u *exploreUI) back() {
+	if !u.e.back() {
+		u.setStatus("No previous module")
+		return
+	}
+	u.refreshCurrent()
+}
+
+// exportDone handles the end of the export prompt: writes the file on Enter, not on Escape.
+func (u *exploreUI) exportDone(key tcell.Key) {
+	if key == tcell.KeyEnter {
+		filename := strings.TrimSpace(u.prompt.GetText())
+		if n, err := u.e.exportFile(filename); err != nil {
+			u.setStatus(fmt.Sprintf("Export failed: %v", err))
+		} else {
+			u.setStatus(fmt.Sprintf("Wrote %d modules to %s", n, filename))
+		}
+	}
+	u.bottom.SwitchToPage("status")
+	u.app.SetFocus(u.modules)
+}
+
+// explore runs the terminal UI browsing the graph, until q (or Ctrl-C), on the screen (nil
+// for the terminal). The selection is exported as DOT with the render options.
+func explore(g *graph.Graph, opts render.Options, screen tcell.Screen) error {
+	u := newExploreUI(newExplorer(g, opts))
+	if screen != nil {
+		u.app.SetScreen(screen)
+	}
+	return u.app.Run()
+}
+
+// --- End Terminal Explorer ---
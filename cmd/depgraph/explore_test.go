@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// testGraph returns the graph of the test modules (see testGoMods).
+func testGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	p, err := newTestRescanner(t, depgraph.DefaultConfig(), &config{}, writeTestModules(t, testGoMods)).scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.graph()
+}
+
+func TestExplorer(t *testing.T) {
+	g := testGraph(t)
+	tests := []struct {
+		name          string
+		filter        string
+		cycles        bool
+		goTo          []string
+		mark          []string
+		wantListed    []string
+		wantDeps      []string
+		wantSelection []string
+	}{
+		{
+			name:       "all",
+			wantListed: []string{"example.com/a", "example.com/b", "example.com/c", "example.com/ext", "vanity.example/c"},
+		},
+		{
+			name:       "filter",
+			filter:     "/c",
+			wantListed: []string{"example.com/c", "vanity.example/c"},
+		},
+		{
+			name:       "cycles",
+			cycles:     true,
+			wantListed: []string{"example.com/a", "example.com/b"},
+		},
+		{
+			name:          "current module neighborhood",
+			filter:        "ext",
+			goTo:          []string{"example.com/a"},
+			wantListed:    []string{"example.com/ext"},
+			wantDeps:      []string{"example.com/b", "vanity.example/c"},
+			wantSelection: []string{"example.com/a", "example.com/b", "vanity.example/c"},
+		},
+		{
+			name:          "marked modules",
+			goTo:          []string{"example.com/a", "example.com/c"},
+			mark:          []string{"example.com/c", "example.com/ext", "example.com/a", "example.com/a"},
+			wantListed:    []string{"example.com/a", "example.com/b", "example.com/c", "example.com/ext", "vanity.example/c"},
+			wantDeps:      []string{"example.com/ext"},
+			wantSelection: []string{"example.com/c", "example.com/ext"},
+		},
+	}
+	for _, tt := range tests {
+		e := newExplorer(g, render.Options{})
+		e.filter, e.cycles = tt.filter, tt.cycles
+		for _, path := range tt.goTo {
+			e.goTo(path)
+		}
+		for _, path := range tt.mark {
+			e.toggleMark(path)
+		}
+		if got := e.listed(); !slices.Equal(got, tt.wantListed) {
+			t.Errorf("%s: listed %v, want %v", tt.name, got, tt.wantListed)
+		}
+		if got, _ := e.edges(false); !slices.Equal(got, tt.wantDeps) {
+			t.Errorf("%s: dependencies %v, want %v", tt.name, got, tt.wantDeps)
+		}
+		if got := slices.Sorted(maps.Keys(e.selection())); !slices.Equal(got, tt.wantSelection) {
+			t.Errorf("%s: selection %v, want %v", tt.name, got, tt.wantSelection)
+		}
+	}
+}
+
+func TestExplorerNavigation(t *testing.T) {
+	e := newExplorer(testGraph(t), render.Options{})
+	if e.back() {
+		t.Errorf("back without history")
+	}
+	if _, err := e.export(&strings.Builder{}); err == nil {
+		t.Errorf("export without selection: no error")
+	}
+	e.goTo("example.com/a")
+	e.goTo("example.com/b")
+	e.goTo("example.com/b")
+	if got, want := e.label("example.com/b", "v1.1.0"), "  example.com/b v1.1.0 [cycle 1]"; got != want {
+		t.Errorf("label %q, want %q", got, want)
+	}
+	if d := e.details(); !strings.Contains(d, "In dependency cycle 1 (of 2 modules)") {
+		t.Errorf("details:\n%s", d)
+	}
+	if !e.back() || e.current != "example.com/a" || e.back() {
+		t.Errorf("back: current %q, history %v", e.current, e.history)
+	}
+	var dot strings.Builder
+	if n, err := e.export(&dot); err != nil || n != 3 {
+		t.Fatalf("export: %d modules, %v", n, err)
+	}
+	if !strings.Contains(dot.String(), "digraph") || !strings.Contains(dot.String(), `"vanity.example/c"`) {
+		t.Errorf("export:\n%s", dot.String())
+	}
+}
+
+// TestExploreUI drives the terminal UI, on a simulation screen, with keys: go to a module,
+// follow a dependency, go back, mark a module of the cycles and export it.
+func TestExploreUI(t *testing.T) {
+	u := newExploreUI(newExplorer(testGraph(t), render.Options{}))
+	screen := tcell.NewSimulationScreen("UTF-8")
+	u.app.SetScreen(screen)
+	output := filepath.Join(t.TempDir(), "cycle.dot")
+	key := func(k tcell.Key) *tcell.EventKey { return tcell.NewEventKey(k, 0, tcell.ModNone) }
+	char := func(r rune) *tcell.EventKey { return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone) }
+	keys := []*tcell.EventKey{
+		key(tcell.KeyEnter), // example.com/a
+		key(tcell.KeyTab),   // its dependencies
+		key(tcell.KeyDown),  // vanity.example/c
+		key(tcell.KeyEnter),
+		char('b'),          // back to example.com/a
+		char('c'),          // the cycles
+		key(tcell.KeyDown), // example.com/b
+		char(' '),          // marked
+		char('e'),
+		key(tcell.KeyCtrlU), // clears the default file name
+	}
+	for _, r := range output {
+		keys = append(keys, char(r))
+	}
+	keys = append(keys, key(tcell.KeyEnter), char('q'))
+	go func() {
+		for _, k := range keys {
+			u.app.QueueEvent(k)
+		}
+	}()
+	if err := u.app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if u.e.current != "example.com/a" || len(u.e.history) != 0 {
+		t.Errorf("current %q, history %v, want example.com/a and none", u.e.current, u.e.history)
+	}
+	if !u.e.cycles || !u.e.marked["example.com/b"] || len(u.e.marked) != 1 {
+		t.Errorf("cycles mode %v, marked %v, want the cycles and example.com/b", u.e.cycles, u.e.marked)
+	}
+	dot, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.Contains(string(dot), `"example.com/b"`) || strings.Contains(string(dot), `"example.com/a"`) {
+		t.Errorf("exported:\n%s", dot)
+	}
+}
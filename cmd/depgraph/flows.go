@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Flow (Sankey/Chord) Export ---
+
+// flow is the number of dependency edges from one group to another.
+type flow struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Value  int    `json:"value"`
+}
+
+// flowGroup returns the group of a graph node: its owner for the scanned modules, else
+// "ext:" and the host and first path element of the module path (e.g. ext:golang.org/x).
+func flowGroup(modulesFoundInOwners map[string]*graph.ModuleInfo, path string) string {
+	if info, found := modulesFoundInOwners[path]; found {
+		return info.Owner
+	}
+	parts := strings.SplitN(path, "/", 3)
+	return "ext:" + strings.Join(parts[:min(2, len(parts))], "/")
+}
+
+// computeFlows counts the direct dependency edges of the graph between groups (including
+// within a group, when source and target are the same), sorted by source and target.
+func computeFlows(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) []flow {
+	counts := make(map[string]int) // "source\ntarget" -> edges
+	for _, src := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[src]
+		if info == nil {
+			continue
+		}
+		for dep := range info.Deps {
+			if nodesToGraph[dep] {
+				counts[flowGroup(modulesFoundInOwners, src)+"\n"+flowGroup(modulesFoundInOwners, dep)]++
+			}
+		}
+	}
+	res := make([]flow, 0, len(counts))
+	for _, key := range graph.SortedKeys(counts) {
+		source, target, _ := strings.Cut(key, "\n")
+		res = append(res, flow{Source: source, Target: target, Value: counts[key]})
+	}
+	return res
+}
+
+// writeFlowsCSV writes the flows as source,target,value CSV (with a header line).
+func writeFlowsCSV(w io.Writer, flows []flow) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"source", "target", "value"})
+	for _, f := range flows {
+		_ = cw.Write([]string{f.Source, f.Target, strconv.Itoa(f.Value)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flowsHTMLTemplate is a self-contained chord diagram page (d3 from a CDN), %s is the
+// flows JSON.
+const flowsHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>depgraph dependency flows</title>
+<script src="https://cdn.jsdelivr.net/npm/d3@7"></script>
+<style>body { font-family: Helvetica, sans-serif; } .group text { font-size: 12px; }</style>
+</head>
+<body>
+<h3>Dependency flows between owners</h3>
+<div id="chord"></div>
+<script>
+const flows = %s;
+const names = Array.from(new Set(flows.flatMap(f => [f.source, f.target]))).sort();
+const index = new Map(names.map((n, i) => [n, i]));
+const matrix = names.map(() => names.map(() => 0));
+for (const f of flows) matrix[index.get(f.source)][index.get(f.target)] += f.value;
+const size = 800, outer = size / 2 - 120, inner = outer - 12;
+const color = d3.scaleOrdinal(names, d3.quantize(d3.interpolateRainbow, names.length + 1));
+const chords = d3.chordDirected().padAngle(0.04).sortSubgroups(d3.descending)(matrix);
+const svg = d3.select("#chord").append("svg")
+  .attr("viewBox", [-size / 2, -size / 2, size, size]).attr("width", size).attr("height", size);
+const group = svg.append("g").selectAll("g").data(chords.groups).join("g").attr("class", "group");
+group.append("path").attr("fill", d => color(names[d.index]))
+  .attr("d", d3.arc().innerRadius(inner).outerRadius(outer));
+group.append("text")
+  .each(d => { d.angle = (d.startAngle + d.endAngle) / 2; })
+  .attr("dy", "0.35em")
+  .attr("transform", d => "rotate(" + (d.angle * 180 / Math.PI - 90) + ") translate(" + (outer + 5) + ")" + (d.angle > Math.PI ? " rotate(180)" : ""))
+  .attr("text-anchor", d => d.angle > Math.PI ? "end" : null)
+  .text(d => names[d.index]);
+group.append("title").text(d => names[d.index] + ": " + d.value + " dependency edges out");
+svg.append("g").attr("fill-opacity", 0.7).selectAll("path").data(chords).join("path")
+  .attr("d", d3.ribbonArrow().radius(inner - 1))
+  .attr("fill", d => color(names[d.source.index]))
+  .append("title").text(d => names[d.source.index] + " → " + names[d.target.index] + ": " + d.source.value);
+</script>
+</body>
+</html>
+`
+
+// writeFlowsHTML writes a chord diagram view of the flows.
+func writeFlowsHTML(w io.Writer, flows []flow) error {
+	data, err := json.Marshal(flows)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, flowsHTMLTemplate, data)
+	return bw.Flush()
+}
+
+// writeFlows writes the owner to owner dependency flows in the given format (depgraph.FlowsCSV or depgraph.FlowsHTML).
+func writeFlows(w io.Writer, format string, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) error {
+	flows := computeFlows(modulesFoundInOwners, nodesToGraph)
+	if format == depgraph.FlowsHTML {
+		return writeFlowsHTML(w, flows)
+	}
+	return writeFlowsCSV(w, flows)
+}
+
+// --- End Flow (Sankey/Chord) Export ---
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+	"golang.org/x/mod/semver"
+)
+
+// --- Freshness SLA Checks ---
+
+// freshnessRule is a freshness SLA from the configuration file, e.g. "internal pins must be
+// within 2 minor versions of latest" or "no dependency older than 18 months".
+type freshnessRule struct {
+	Name           string   `yaml:"name"`
+	Modules        []string `yaml:"modules"`          // dependencies the rule applies to (graph.MatchModule patterns), default all
+	Internal       bool     `yaml:"internal"`         // only the dependencies on scanned modules
+	MaxMinorBehind *int     `yaml:"max-minor-behind"` // minor versions behind the latest one
+	MaxAge         string   `yaml:"max-age"`          // of the required version, e.g. 90d, 8w, 18mo, 2y
+	Fail           bool     `yaml:"fail"`             // violations make depgraph exit with an error
+
+	maxAge time.Duration
+}
+
+// freshnessViolation is a requirement not meeting a freshness SLA.
+type freshnessViolation struct {
+	rule    *freshnessRule
+	module  string // requiring module
+	dep     string
+	version string
+	reason  string
+}
+
+// freshnessRules validates the freshness rules and parses their max-age.
+func (cfg *config) freshnessRules() ([]*freshnessRule, error) {
+	res := make([]*freshnessRule, 0, len(cfg.Freshness))
+	for i := range cfg.Freshness {
+		r := &cfg.Freshness[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("freshness rule %d", i+1)
+		}
+		if r.MaxMinorBehind == nil && r.MaxAge == "" {
+			return nil, fmt.Errorf("%s: expecting max-minor-behind and/or max-age", r.Name)
+		}
+		if r.MaxAge != "" {
+			age, err := scan.ParseAge(r.MaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Name, err)
+			}
+			r.maxAge = age
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// appliesTo returns true if the rule covers the dependency.
+func (r *freshnessRule) appliesTo(dep string, modulesFoundInOwners map[string]*graph.ModuleInfo) bool {
+	if r.Internal && modulesFoundInOwners[dep] == nil {
+		return false
+	}
+	if len(r.Modules) == 0 {
+		return true
+	}
+	for _, pattern := range r.Modules {
+		if graph.MatchModule(pattern, dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// minorsBehind returns how many minor versions required is behind latest, or -1 when the
+// major versions differ (e.g. +incompatible).
+func minorsBehind(required, latest string) int {
+	if semver.Major(required) != semver.Major(latest) {
+		return -1
+	}
+	minor := func(v string) int {
+		_, m, _ := strings.Cut(semver.MajorMinor(v), ".")
+		n, _ := strconv.Atoi(m)
+		return n
+	}
+	return max(0, minor(latest)-minor(required))
+}
+
+// checkFreshness evaluates the freshness rules against the direct requirements of the graph's
+// modules, using the proxy's latest versions (and version times for max-age).
+func checkFreshness(ctx context.Context, pc *scan.ProxyClient, rules []*freshnessRule,
+	modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, latest map[string]*scan.ProxyModuleInfo,
+) []freshnessViolation {
+	var res []freshnessViolation
+	now := time.Now()
+	for _, src := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[src]
+		if info == nil {
+			continue
+		}
+		for _, dep := range graph.SortedKeys(info.Deps) {
+			pi := latest[dep]
+			if pi == nil || !pi.Found {
+				continue // Unknown to the proxy (or not a Go module): nothing to compare with
+			}
+			version := info.Deps[dep]
+			for _, r := range rules {
+				if !r.appliesTo(dep, modulesFoundInOwners) {
+					continue
+				}
+				v := freshnessViolation{rule: r, module: src, dep: dep, version: version}
+				if r.MaxMinorBehind != nil && scan.IsOutdated(version, pi) {
+					switch behind := minorsBehind(version, pi.Latest); {
+					case behind < 0:
+						v.reason = "major version behind latest " + pi.Latest
+					case behind > *r.MaxMinorBehind:
+						v.reason = fmt.Sprintf("%d minor versions behind latest %s", behind, pi.Latest)
+					}
+				}
+				if v.reason == "" && r.maxAge > 0 {
+					t, err := pc.VersionTime(ctx, dep, version)
+					if err != nil {
+						log.Warnf("Error getting proxy info for %s@%s: %v", dep, version, err)
+					} else if age := now.Sub(t); !t.IsZero() && age > r.maxAge {
+						v.reason = fmt.Sprintf("published %s, older than %s", t.Format(time.DateOnly), r.MaxAge)
+					}
+				}
+				if v.reason != "" {
+					res = append(res, v)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// reportFreshness logs the violations (as errors for the failing rules) and returns how many
+// are from rules with fail set.
+func reportFreshness(violations []freshnessViolation) int {
+	failures := 0
+	for _, v := range violations {
+		if v.rule.Fail {
+			failures++
+			log.Errf("Freshness SLA %q: %s requires %s %s (%s)", v.rule.Name, v.module, v.dep, v.version, v.reason)
+		} else {
+			log.Warnf("Freshness SLA %q: %s requires %s %s (%s)", v.rule.Name, v.module, v.dep, v.version, v.reason)
+		}
+	}
+	if len(violations) == 0 {
+		log.Infof("All requirements meet the freshness SLAs")
+	}
+	return failures
+}
+
+// --- End Freshness SLA Checks ---
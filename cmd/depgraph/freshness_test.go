@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+func TestFreshnessRules(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name     string
+		rules    []freshnessRule
+		wantErr  string // "" for none
+		wantName string // of the first rule
+	}{
+		{name: "default name", rules: []freshnessRule{{MaxMinorBehind: &two}}, wantName: "freshness rule 1"},
+		{name: "max age", rules: []freshnessRule{{Name: "old", MaxAge: "18mo"}}, wantName: "old"},
+		{name: "no limit", rules: []freshnessRule{{Name: "ok", MaxAge: "1y"}, {Name: "none"}}, wantErr: "none: expecting max-minor-behind and/or max-age"},
+		{name: "invalid age", rules: []freshnessRule{{Name: "bad", MaxAge: "18 months"}}, wantErr: "bad: invalid age"},
+	}
+	for _, tt := range tests {
+		cfg := &config{Freshness: tt.rules}
+		rules, err := cfg.freshnessRules()
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: error %v", tt.name, err)
+		case rules[0].Name != tt.wantName:
+			t.Errorf("%s: name %q, want %q", tt.name, rules[0].Name, tt.wantName)
+		}
+	}
+}
+
+func TestMinorsBehind(t *testing.T) {
+	tests := []struct {
+		required, latest string
+		want             int
+	}{
+		{"v1.2.3", "v1.2.9", 0},
+		{"v1.2.3", "v1.5.0", 3},
+		{"v0.9.0", "v1.0.0", -1},
+		{"v2.0.0+incompatible", "v1.9.0", -1},
+	}
+	for _, tt := range tests {
+		if got := minorsBehind(tt.required, tt.latest); got != tt.want {
+			t.Errorf("minorsBehind(%s, %s) = %d, want %d", tt.required, tt.latest, got, tt.want)
+		}
+	}
+}
+
+// TestCheckFreshness checks app's requirements: lib (scanned) 3 minor versions behind, ext a
+// major version behind, and old (latest) published long ago, on the fake module proxy.
+func TestCheckFreshness(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/old/@v/v1.0.0.info" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Version": "v1.0.0", "Time": "2020-01-02T00:00:00Z"}`))
+	}))
+	defer proxy.Close()
+	t.Setenv("GOPROXY", proxy.URL)
+	modules := map[string]*graph.ModuleInfo{
+		"example.com/app": {Path: "example.com/app", Deps: map[string]string{
+			"example.com/lib": "v1.1.0", "example.com/ext": "v0.9.0", "example.com/old": "v1.0.0", "example.com/unknown": "v1.0.0",
+		}},
+		"example.com/lib": {Path: "example.com/lib"},
+	}
+	nodes := map[string]bool{"example.com/app": true, "example.com/lib": true, "example.com/ext": true, "example.com/old": true, "example.com/unknown": true}
+	latest := map[string]*scan.ProxyModuleInfo{
+		"example.com/lib":     {Latest: "v1.4.0", Found: true},
+		"example.com/ext":     {Latest: "v1.0.0", Found: true},
+		"example.com/old":     {Latest: "v1.0.0", Found: true},
+		"example.com/unknown": {Found: false},
+	}
+	two, zero := 2, 0
+	cfg := &config{Freshness: []freshnessRule{
+		{Name: "internal", Internal: true, MaxMinorBehind: &two, Fail: true},
+		{Name: "ext", Modules: []string{"ext"}, MaxMinorBehind: &zero},
+		{Name: "age", MaxAge: "1y"},
+	}}
+	rules, err := cfg.freshnessRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := checkFreshness(context.Background(), scan.NewProxyClient(nil, nil), rules, modules, nodes, latest)
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.rule.Name+": "+v.dep+" "+v.version+" ("+v.reason+")")
+	}
+	want := []string{
+		"ext: example.com/ext v0.9.0 (major version behind latest v1.0.0)",
+		"internal: example.com/lib v1.1.0 (3 minor versions behind latest v1.4.0)",
+		"age: example.com/old v1.0.0 (published 2020-01-02, older than 1y)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if failures := reportFreshness(violations); failures != 1 {
+		t.Errorf("%d failures, want 1 (the internal rule)", failures)
+	}
+}
@@ -0,0 +1,659 @@
+// The depgraph command: graphs the Go module dependencies of GitHub owners (or local
+// directories), see the README.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"fortio.org/cli" // Import fortio cli
+	"fortio.org/log" // Import fortio log
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/aijoin"
+	"github.com/ldemailly/depgraph/aisplit"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+	"golang.org/x/oauth2"
+)
+
+// The subcommands running the graph generation (graphMain), with different defaults.
+const (
+	cmdScan    = "scan"    // scans and outputs the graph, the default without a subcommand
+	cmdTopo    = "topo"    // same with the topological sort output (-topo-sort) by default
+	cmdRender  = "render"  // renders a snapshot (-load-snapshot) given as argument
+	cmdServe   = "serve"   // serves the graph (web UI and JSON API), rescanning periodically
+	cmdExplore = "explore" // terminal UI browsing the graph
+)
+
+// subcommands are the commands of the depgraph binary (`depgraph render ...`), including the
+// other bundled tools (`depgraph aisplit ...`). Without one of these as first argument,
+// depgraph does its normal scan of owners (`depgraph owner` is `depgraph scan owner`).
+var subcommands = map[string]func(){
+	cmdScan:    func() { graphMain(cmdScan) },
+	cmdTopo:    func() { graphMain(cmdTopo) },
+	cmdRender:  func() { graphMain(cmdRender) },
+	cmdServe:   func() { graphMain(cmdServe) },
+	cmdExplore: func() { graphMain(cmdExplore) },
+	"diff":     diffMain,
+	"setop":    setOpMain,
+	"merge":    mergeMain,
+	"cache":    cacheMain,
+	"aisplit":  aisplit.Main,
+	"aijoin":   aijoin.Main,
+}
+
+// graphEnv is the logger and clock used by the graph package functions.
+var graphEnv = graph.DefaultEnv()
+
+// runSubcommand runs the subcommand named by the first argument, if any, and returns true if it did.
+func runSubcommand() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	sub, found := subcommands[os.Args[1]]
+	if !found {
+		return false
+	}
+	cli.ProgramName = "depgraph " + os.Args[1]
+	os.Args = append([]string{os.Args[0] + " " + os.Args[1]}, os.Args[2:]...)
+	sub()
+	return true
+}
+
+// main is the entry point, using fortio/cli: runs the subcommand if any, else scans.
+func main() {
+	if runSubcommand() {
+		return
+	}
+	graphMain(cmdScan)
+}
+
+// graphMain contains the application logic of the scan, topo and render subcommands.
+func graphMain(cmd string) {
+	// Define flags locally, bound to the fields of the run's configuration
+	conf := depgraph.DefaultConfig()
+	if cmd == cmdTopo {
+		conf.Format = render.FormatTopo
+	}
+	flag.BoolVar(&conf.NoExt, "noext", conf.NoExt, "Exclude external (non-org/user) dependencies from the graph")
+	flag.BoolVar(&conf.UseCache, "use-cache", conf.UseCache, "Enable filesystem caching for GitHub API calls")
+	flag.BoolVar(&conf.ClearCache, "clear-cache", conf.ClearCache, "Clear the cache directory before running")
+	flag.BoolVar(&conf.TopoSort, "topo-sort", conf.TopoSort, "Output dependencies in topological sort order by level (text format, disables DOT output), same as -format="+render.FormatTopo)
+	flag.StringVar(&conf.Format, "format", conf.Format, "Graph output `format`: "+render.FormatNames())
+	flag.BoolVar(&conf.Left2Right, "left2right", conf.Left2Right, "Generate graph left-to-right instead of top-to-bottom (default)") // New flag
+	flag.BoolVar(&conf.Local, "local", conf.Local, "Arguments are local directories to walk for go.mod files instead of GitHub owners (no API calls)")
+	flag.BoolVar(&conf.Scan.AllModules, "all-modules", conf.Scan.AllModules, "Find all go.mod files in each repository (monorepos), not just the root one")
+	flag.BoolVar(&conf.Scan.Tree, "tree", conf.Scan.Tree, "Get each repository's git tree first (one call) to only fetch the go.mod, go.sum and manifests it has"+
+		" (fewer calls for repositories without go.mod, or with -transitive and -manifests)")
+	flag.BoolVar(&conf.APICoupling, "api-coupling", conf.APICoupling, "With -local, detect internal modules whose types appear in the exported API of other modules (API-coupling edges)")
+	flag.StringVar(&conf.Manifests, "manifests", conf.Manifests, "Experimental: comma separated manifest `types` to scan: go, npm (package.json), cargo (Cargo.toml)")
+	flag.StringVar(&conf.IncludeModule, "include-module", conf.IncludeModule, "Only include the modules whose path matches this `regexp` in the graph")
+	flag.StringVar(&conf.ExcludeModule, "exclude-module", conf.ExcludeModule, "Exclude the modules whose path matches this `regexp` from the graph (e.g. ^golang\\.org/x/)")
+	flag.StringVar(&conf.Root, "root", conf.Root, "Only include the subgraph reachable from the given `module` (path or path suffix)")
+	flag.BoolVar(&conf.Reverse, "reverse", conf.Reverse, "With -root, include the subgraph reaching the module (its dependents) instead")
+	flag.IntVar(&conf.MaxDepth, "max-depth", conf.MaxDepth, "Maximum number of dependency hops from the roots for the nodes in the graph: the -root module, else the scanned modules no other one requires"+
+		" (scanned modules requiring each other count as hops, transitive dependencies as 2, -1: no limit)")
+	flag.StringVar(&conf.Query, "query", conf.Query, "Only include the nodes matching this `expression`, e.g. \"dependents(acme/log) & level<=2 & !external\""+
+		" (see the README for the terms)")
+	flag.BoolVar(&conf.ErrorNodes, "error-nodes", conf.ErrorNodes, "Include the repos whose go.mod failed to parse as error nodes (error in tooltip/JSON) instead of only logging a warning")
+	flag.BoolVar(&conf.Transitive, "transitive", conf.Transitive, "Also include transitive dependencies (from go.sum, or `go mod graph` with -local) as dashed edges")
+	flag.BoolVar(&conf.CheckLatest, "check-latest", conf.CheckLatest, "Query the module proxy (GOPROXY) for each module's latest version, shown as DOT tooltips and outdated edge labels")
+	flag.BoolVar(&conf.CheckDeprecated, "check-deprecated", conf.CheckDeprecated, "Fetch the go.mod of each module's latest version from the module proxy to find the deprecated ones"+
+		" (distinct DOT style, warnings for the scanned modules depending on them)")
+	flag.BoolVar(&conf.LatestReport, "latest-report", conf.LatestReport, "Output a text report of latest versions and outdated requirements (implies -check-latest, disables DOT output)")
+	flag.StringVar(&conf.Replace, "replace", conf.Replace, "How to handle go.mod replace directives (to other modules): \"annotate\" edges with the replacement,"+
+		" or \"rewrite\" them to point to the replacement module (default: ignored)")
+	flag.BoolVar(&conf.ReplaceReport, "replace-report", conf.ReplaceReport, "Output a text report of the go.mod replace directives pointing to local paths (disables DOT output)")
+	flag.StringVar(&conf.Scan.Visibility, "visibility", conf.Scan.Visibility,
+		"Repositories to scan: `all|public|private` (private ones need a GITHUB_TOKEN with access to them)")
+	flag.BoolVar(&conf.Scan.Resume, "resume", conf.Scan.Resume, "Resume the interrupted (or rate limited) scan with the same arguments: what it already fetched is reused from the cache as is")
+	flag.BoolVar(&conf.Offline, "offline", conf.Offline,
+		"Answer everything from the cache (expired entries included), making no GitHub, module proxy nor deps.dev requests: what isn't cached is an error")
+	flag.StringVar(&conf.CacheBackend, "cache-backend", conf.CacheBackend,
+		"Cache storage: files (one JSON file per entry), bolt (a single bbolt database file, with entries indexed by endpoint/owner/repo and time)"+
+			" or the `url` of a remote cache shared over HTTP (GET/PUT/DELETE of the files entries, kept locally too; auth: "+scan.RemoteCacheAuthEnv+" env var)")
+	flag.StringVar(&conf.CacheTTL, "cache-ttl", conf.CacheTTL,
+		"Cache entries `ttl`, e.g. 24h or 7d, and/or per kind of entry e.g. 24h,lists=1h,contents=7d (kinds: lists, repos, contents, proxy, depsdev). Default is no expiry")
+	flag.BoolVar(&conf.Scan.Incremental, "incremental", conf.Scan.Incremental,
+		"Refresh the repository listings and refetch the go.mod (and other files) of only the repositories pushed to since they were cached (per their pushed_at)")
+	flag.BoolVar(&conf.Scan.Revalidate, "revalidate", conf.Scan.Revalidate, "Revalidate the cached GitHub responses with conditional (ETag) requests, to pick up changes cheaply: 304 Not Modified answers don't count against the rate limit")
+	statsJSONFlag := flag.String("stats-json", "", "Also write the API usage metrics (calls by endpoint, HTTP requests and time, cache hits, retries, waits, rate limit left) as JSON to this `file`, for CI tracking")
+	flag.IntVar(&conf.Retries, "retries", conf.Retries, "Number of retries of the requests failing with a network or 5xx server error, 0 for none")
+	flag.DurationVar(&conf.RetryDelay, "retry-delay", conf.RetryDelay, "Wait before the first retry (see -retries), doubled at each retry, with a random jitter")
+	flag.DurationVar(&conf.Scan.RateWait, "rate-limit-wait", conf.Scan.RateWait, "Maximum time to pause for a GitHub rate limit reset before retrying, 0 to fail right away")
+	flag.StringVar(&conf.Scan.TokenFile, "token-file", conf.Scan.TokenFile, "Read the GitHub token from this `file` (one per line, used in turn like GITHUB_TOKENS) instead of GITHUB_TOKEN, GH_TOKEN or gh auth token")
+	flag.BoolVar(&conf.Scan.GraphQL, "graphql", conf.Scan.GraphQL, "Fetch the go.mod files and fork parents of the repositories in batches with the GitHub GraphQL API (requires GITHUB_TOKEN)")
+	flag.IntVar(&conf.Scan.Concurrency, "concurrency", conf.Scan.Concurrency, "Number of repositories scanned in parallel (fetching go.mod files, fork parents...), 1 for serial")
+	flag.StringVar(&conf.Scan.Ref, "ref", conf.Scan.Ref, "Git `ref` (branch or tag) to scan in each repository instead of the default branch (per repository: owner/repo@ref)")
+	flag.BoolVar(&conf.CriticalPath, "critical-path", conf.CriticalPath, "Output the longest dependency chain among the scanned modules (critical path for rolling releases, weighted by the config's effort if any) instead of the graph")
+	flag.BoolVar(&conf.GoLabel, "go-label", conf.GoLabel, "Add the go (and toolchain) directive of the scanned modules to their DOT node labels")
+	flag.StringVar(&conf.OldGoReport, "old-go-report", conf.OldGoReport, "Output a report of the modules whose go directive is older than this `version` (e.g. 1.22) or missing (disables DOT output)")
+	flag.StringVar(&conf.Report, "report", conf.Report, "Output a report instead of the graph: `licenses` (dependencies grouped by license, flagging unknown and copyleft ones, implies -depsdev)")
+	flag.BoolVar(&conf.ModCheck, "modcheck", conf.ModCheck, "Output a report of go.mod hygiene issues (missing go directive, unsorted or redundant requires, mismatched go/toolchain versions) (disables DOT output)")
+	flag.BoolVar(&conf.FailOnOutdated, "fail-on-outdated", conf.FailOnOutdated, "Exit with status 3 if any requirement is behind the latest version (implies -check-latest), for CI")
+	flag.BoolVar(&conf.FailOnCycle, "fail-on-cycle", conf.FailOnCycle, "Exit with status 3 if the graph has any dependency cycle, for CI")
+	flag.StringVar(&conf.FailOnNewExternal, "fail-on-new-external", conf.FailOnNewExternal, "Exit with status 3 if the graph has external dependencies not in this baseline `file` (saved with -json, or a snapshot), for CI")
+	flag.IntVar(&conf.MaxDepthAllowed, "max-depth-allowed", conf.MaxDepthAllowed, "Exit with status 3 if a dependency chain among the scanned modules is longer than this many hops, -1 for no limit, for CI")
+	badgesFlag := flag.String("badges", "", "Also write SVG badges of the scanned modules (internal dependents, external dependencies, cycle) to this `directory`, as <module path>/<kind>.svg")
+	policyJSONFlag := flag.String("policy-json", "", "Also write the result of the CI policies (-fail-on-*, -max-depth-allowed) as JSON to this `file`")
+	flag.BoolVar(&conf.Scan.NoForks, "no-forks", conf.Scan.NoForks, "Skip the forks entirely: not scanned (no fork parent lookups) nor shown (a fork required by others is then an external dependency)")
+	flag.BoolVar(&conf.Scan.Gists, "gists", conf.Scan.Gists, "Also scan the owners' public gists for go.mod files")
+	flag.BoolVar(&conf.DepsDev, "depsdev", conf.DepsDev, "Query deps.dev for each module's licenses, advisories and dependents count (DOT tooltips and JSON output)")
+	flag.BoolVar(&conf.JSON, "json", conf.JSON, "Output the graph as JSON (nodes with their dependencies and annotations) instead of DOT")
+	flag.BoolVar(&conf.JSONStats, "json-stats", conf.JSONStats, "With -json, also include the API usage of the run (calls, cache hit ratio, GitHub API calls remaining...) as a stats header: the output then differs from run to run")
+	flag.StringVar(&conf.Dependents, "dependents", conf.Dependents, "Output the scanned modules depending on the given `module` (path or path suffix) instead of the graph (text, or JSON with -json)")
+	flag.BoolVar(&conf.DependentsTransitive, "dependents-transitive", conf.DependentsTransitive, "With -dependents, also list the modules depending on it indirectly (through other scanned modules)")
+	flag.StringVar(&conf.ReleasePlan, "release-plan", conf.ReleasePlan, "Output the release plan for a change of the given `module`: its scanned dependents to bump and re-release, level by level (text, or JSON with -json)")
+	flag.StringVar(&conf.Flows, "flows", conf.Flows, "Output the owner to owner dependency flows (edge counts) instead of DOT: `csv|html` (source,target,value for Sankey tools, or a chord diagram page)")
+	flag.BoolVar(&conf.ScaleNodes, "scale-nodes", conf.ScaleNodes, "Scale the DOT nodes font size and border width by their number of internal dependents")
+	flag.StringVar(&conf.Group, "group", conf.Group, "Owners sharing a color and a DOT cluster: `name=owner1,owner2` groups, separated by ;"+
+		" (e.g. platform=acme-core,acme-infra for code spread across several orgs)")
+	flag.StringVar(&conf.Label, "label", conf.Label, "DOT labels of the scanned modules: `module|repo|both` (module path, repository, or both), default module path but repository for forks")
+	flag.StringVar(&conf.Title, "title", conf.Title, "`Title` shown at the top of the DOT graph")
+	bannerFlag := flag.Bool("banner", false, "Add the provenance to the DOT graph title: scan time, owners scanned, depgraph version and flags set, so shared images are self-describing")
+	flag.StringVar(&conf.StripLabelPrefix, "strip-label-prefix", conf.StripLabelPrefix, "Comma separated `prefixes` to remove from the DOT node labels (e.g. github.com/acme/), or auto for the common prefix of the scanned modules")
+	flag.IntVar(&conf.MaxLabelWidth, "max-label-width", conf.MaxLabelWidth, "Wrap the DOT node labels longer than this many `characters` on /, truncating longer parts, 0 for no limit")
+	flag.BoolVar(&conf.NoEdgeLabels, "no-edge-labels", conf.NoEdgeLabels, "Don't label the DOT edges with the required versions (lighter large graphs)")
+	flag.BoolVar(&conf.ShortEdgeLabels, "short-edge-labels", conf.ShortEdgeLabels, "Shorten the versions of the DOT edge labels to major.minor (v1.2.3 -> 1.2)")
+	flag.BoolVar(&conf.ColorEdges, "color-edges", conf.ColorEdges, "Color the DOT edges by the owner of the depending module")
+	flag.BoolVar(&conf.ClusterRepos, "cluster-repos", conf.ClusterRepos, "Group modules from the same repository into a cluster in the DOT output")
+	configFlag := flag.String("config", "", "YAML configuration `file` (e.g. ignore-edges: [\"acme/tools -> acme/legacy\"]), "+defaultConfigFile+" if present by default")
+	saveSnapshotFlag := flag.String("save-snapshot", "", "Save the scan result to this JSON `file`, to re-render or compare it later without API calls")
+	loadSnapshotFlag := flag.String("load-snapshot", "", "Load the scan result from this JSON `file` (saved with -save-snapshot) instead of scanning")
+	timeoutFlag := flag.Duration("timeout", 0, "Maximum `duration` of the run (e.g. 10m in CI), 0 for none: when exceeded the scan and its in-flight requests are canceled, as with Ctrl-C")
+	logFormatFlag := flag.String("logformat", "text", "Log `format` on stderr: text, or json (one JSON object per line, for CI log collectors)")
+	watchFlag := flag.Duration("watch", 0, "Keep running and rescan every `interval` (using the cache), rewriting the -o file only when the graph changed")
+	outputFlag := flag.String("o", "", "With -watch, the `file` to write the graph (-format or -json output) to")
+	webhookFlag := flag.String("webhook", "", "With -watch, `URL` (e.g. a Slack incoming webhook) to POST the changes of the graph to: new cycles, new dependencies, version bumps...")
+	metricsListenFlag := flag.String("metrics-listen", "", "With -watch, address (`host:port`) to serve the Prometheus metrics of the scans on (/metrics), none by default")
+	var listenFlag *string
+	var rescanFlag *time.Duration
+	if cmd == cmdServe {
+		listenFlag = flag.String("listen", ":8080", "Address (`host:port`) to serve the web UI and JSON API on")
+		rescanFlag = flag.Duration("rescan", time.Hour, "Rescan the owners every `interval` (using the cache), 0 to only scan at startup")
+	}
+	orgsFlag := flag.String("orgs", "", "Comma separated GitHub `organizations` to scan, in addition to the owners arguments (same as org:name arguments: not probed as users)")
+	usersFlag := flag.String("users", "", "Comma separated GitHub `users` to scan, in addition to the owners arguments (same as user:name arguments: not probed as organizations)")
+	reposFileFlag := flag.String("repos-file", "", "File listing repositories to scan (one `owner/repo` or GitHub URL per line), in addition to the owners arguments")
+
+	// Configure and run fortio/cli to handle flags and args
+	cli.ArgsHelp = "owner1|owner/repo[@ref] [owner2...] or, with -local, dir1 [dir2...]" +
+		"\nor the subcommands: depgraph {scan|topo|render|serve|explore|diff|setop|merge|cache|aisplit|aijoin} [flags] ..." // Set custom usage text for arguments
+	cli.MinArgs = 0  // At least one owner name, unless -repos-file (checked below)
+	cli.MaxArgs = -1 // Allow any number of owner names
+	if cmd == cmdRender {
+		cli.ArgsHelp = "snapshot.json\nRenders a scan saved with -save-snapshot (same as -load-snapshot), with the output and filtering flags"
+		cli.MinArgs, cli.MaxArgs = 1, 1
+	}
+	cli.Main() // Parses flags, validates args, handles version/help flags
+
+	// --- Start of application logic ---
+
+	cfg := &config{}
+	if configFile := configFileName(*configFlag); configFile != "" {
+		var err error
+		cfg, err = readConfig(configFile)
+		if err != nil {
+			log.Fatalf("Failed to read configuration: %v", err)
+		}
+		if err := cfg.applyFlags(); err != nil {
+			log.Fatalf("Failed to apply the configuration %s: %v", configFile, err)
+		}
+		conf.Colors, conf.ForkColors = cfg.Colors, cfg.ForkColors
+	}
+	freshnessRules, err := cfg.freshnessRules()
+	if err != nil {
+		log.Fatalf("Invalid freshness rules: %v", err)
+	}
+	if len(freshnessRules) > 0 {
+		conf.CheckLatest = true
+	}
+	typed := append(typedOwners(scan.OwnerOrg, *orgsFlag), typedOwners(scan.OwnerUser, *usersFlag)...)
+	if len(typed) > 0 && conf.Local {
+		cli.ErrUsage("-orgs and -users can't be used with -local")
+	}
+	args := slices.Concat(flag.Args(), typed)
+	if len(args) == 0 && *loadSnapshotFlag == "" && cmd != cmdRender {
+		args = cfg.Owners // bare `depgraph`, e.g. in CI
+	}
+	owners := args // Get owners from arguments after flag parsing by cli.Main
+	if cmd == cmdRender {
+		if *loadSnapshotFlag != "" {
+			cli.ErrUsage("-load-snapshot can't be used with render, the snapshot is its argument")
+		}
+		*loadSnapshotFlag, owners = flag.Arg(0), nil
+	}
+	var repos []scan.RepoSpec
+	if !conf.Local {
+		// owner/repo arguments (detected by the slash) are single repositories, not owners
+		var err error
+		if owners, repos, err = scan.SplitOwnersAndRepos(owners); err != nil {
+			cli.ErrUsage("%v", err)
+		}
+	}
+	if *reposFileFlag != "" {
+		if conf.Local {
+			cli.ErrUsage("-repos-file can't be used with -local")
+		}
+		fileRepos, err := scan.ReadReposFile(*reposFileFlag)
+		if err != nil {
+			log.Fatalf("Failed to read repositories file: %v", err)
+		}
+		repos = append(repos, fileRepos...)
+	}
+	if err := conf.Resolve(); err != nil {
+		cli.ErrUsage("%v", err)
+	}
+	quiet := flag.Lookup("quiet").Value.String() == "true" // the cli's -quiet (which also sets the log level to Error)
+	if err := setLogging(*logFormatFlag, quiet); err != nil {
+		cli.ErrUsage("%v", err)
+	}
+	if *timeoutFlag < 0 {
+		cli.ErrUsage("-timeout can't be negative")
+	}
+	if *loadSnapshotFlag != "" {
+		if len(owners) > 0 || len(repos) > 0 {
+			cli.ErrUsage("No owner, repository nor directory expected with -load-snapshot")
+		}
+	} else if len(owners) == 0 && len(repos) == 0 {
+		cli.ErrUsage("At least one owner (or -repos-file) expected")
+	}
+
+	if cmd == cmdServe {
+		if *loadSnapshotFlag != "" {
+			cli.ErrUsage("serve scans the owners and repositories given as arguments (or in the configuration), not -load-snapshot")
+		}
+		ctx, cancel := runContext(*timeoutFlag)
+		defer cancel()
+		var cache *scan.Cache
+		if !conf.Local || annotated(conf) {
+			cache = openCache(conf)
+			defer cache.Close()
+		}
+		if err := serve(ctx, newRescanner(conf, cfg, cache, rescanClient(ctx, conf, cache), owners, repos), args, *listenFlag, *rescanFlag); err != nil {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+		return
+	}
+	if *watchFlag != 0 || *outputFlag != "" || *metricsListenFlag != "" || *webhookFlag != "" {
+		switch {
+		case *watchFlag <= 0 || *outputFlag == "":
+			cli.ErrUsage("-watch needs a positive interval and the -o file to write")
+		case *loadSnapshotFlag != "":
+			cli.ErrUsage("-watch scans the owners and repositories given as arguments (or in the configuration), not -load-snapshot")
+		case conf.Dependents != "" || conf.ReleasePlan != "" || conf.LatestReport || conf.Report != "" || conf.OldGoReport != "" ||
+			conf.ModCheck || conf.ReplaceReport || conf.Flows != "" || conf.CriticalPath:
+			cli.ErrUsage("-watch only writes the graph (-format or -json), not the reports")
+		case conf.JSONStats:
+			cli.ErrUsage("-json-stats can't be used with -watch, which only rewrites the output when the graph changed")
+		}
+		ctx, cancel := runContext(*timeoutFlag)
+		defer cancel()
+		var cache *scan.Cache
+		if !conf.Local || annotated(conf) {
+			cache = openCache(conf)
+			defer cache.Close()
+		}
+		if err := watch(ctx, newRescanner(conf, cfg, cache, rescanClient(ctx, conf, cache), owners, repos), *outputFlag, *watchFlag, *metricsListenFlag, *webhookFlag); err != nil {
+			log.Fatalf("Watch failed: %v", err)
+		}
+		return
+	}
+	var baselineExternals map[string]bool // read before scanning to fail fast
+	if conf.FailOnNewExternal != "" {
+		var err error
+		if baselineExternals, err = readBaselineExternals(conf.FailOnNewExternal); err != nil {
+			log.Fatalf("Failed to read the -fail-on-new-external baseline: %v", err)
+		}
+	}
+	// Store module info: map[modulePath]graph.ModuleInfo
+	// and keep track of all unique module paths encountered (sources and dependencies)
+	res := conf.NewResult()
+	ctx, cancel := runContext(*timeoutFlag)
+	defer cancel()
+	var cache *scan.Cache // opened when first needed (GitHub scan, module proxy, deps.dev)
+	defer func() { cache.Close() }()
+
+	scanTime, scanned := time.Now(), args // for -banner and -format=sql
+	switch {
+	case *loadSnapshotFlag != "":
+		snap, err := loadSnapshot(*loadSnapshotFlag, res)
+		if err != nil {
+			log.Fatalf("Failed to load snapshot: %v", err)
+		}
+		scanTime, scanned = snap.Created, snap.Args
+		log.Infof("Loaded snapshot of %v from %s (%d modules)", snap.Args, snap.Created.Format(time.DateTime), len(snap.Modules))
+	case conf.Local:
+		scanLocal(ctx, conf, owners, res) // no GitHub access nor cache needed
+	default:
+		cache = openCache(conf)
+		scanGitHub(ctx, cache, owners, repos, &conf.Scan, !quiet, res)
+	}
+	exitIfCanceled(ctx, !conf.Local && *loadSnapshotFlag == "" && conf.UseCache)
+	if conf.Scan.NoForks {
+		if n := res.DropForks(); n > 0 { // from the snapshot, the scan skips them
+			log.Infof("Dropped %d forks (-no-forks)", n)
+		}
+	}
+	if *saveSnapshotFlag != "" {
+		if err := saveSnapshot(*saveSnapshotFlag, args, res); err != nil {
+			log.Fatalf("Failed to save snapshot: %v", err)
+		}
+		log.Infof("Saved snapshot of %d modules to %s", len(res.Modules), *saveSnapshotFlag)
+	}
+	if cache == nil && annotated(conf) {
+		cache = openCache(conf)
+	}
+	p, err := postScan(ctx, conf, cfg, cache, res)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	modulesFoundInOwners, allModulePaths, nodesToGraph, ann, pc := p.modules, p.allPaths, p.nodesToGraph, p.ann, p.pc
+
+	exitIfCanceled(ctx, false)
+
+	if *bannerFlag {
+		conf.Title = banner(conf.Title, scanTime, scanned)
+	}
+	// --- Generate Output ---
+	switch {
+	case cmd == cmdExplore:
+		if err := explore(graph.New(graphEnv, modulesFoundInOwners, nodesToGraph), conf.RenderOptions(ann, cfg.effortTracker()), nil); err != nil {
+			log.Fatalf("Explore failed: %v", err)
+		}
+	case conf.Dependents != "":
+		// Blast radius of a change: uses the whole scan, not just the graph's nodes
+		dependents, err := findDependents(modulesFoundInOwners, allModulePaths, conf.Dependents, conf.DependentsTransitive)
+		if err != nil {
+			log.Fatalf("Error finding dependents: %v", err)
+		}
+		if err := printDependents(os.Stdout, dependents, conf.JSON); err != nil {
+			log.Fatalf("Failed writing dependents output: %v", err)
+		}
+	case conf.ReleasePlan != "":
+		plan, err := planRelease(modulesFoundInOwners, allModulePaths, conf.ReleasePlan, cfg.effortTracker())
+		if err != nil {
+			log.Fatalf("Error planning the release: %v", err)
+		}
+		if err := printReleasePlan(os.Stdout, plan, conf.JSON); err != nil {
+			log.Fatalf("Failed writing release plan output: %v", err)
+		}
+	case conf.LatestReport:
+		if err := scan.WriteLatestReport(os.Stdout, modulesFoundInOwners, nodesToGraph, ann.Latest); err != nil {
+			log.Fatalf("Failed writing latest versions report: %v", err)
+		}
+	case conf.Report == scan.ReportLicenses:
+		if err := scan.WriteLicenseReport(os.Stdout, modulesFoundInOwners, nodesToGraph, ann); err != nil {
+			log.Fatalf("Failed writing license report: %v", err)
+		}
+	case conf.OldGoReport != "":
+		if err := scan.WriteOldGoReport(os.Stdout, modulesFoundInOwners, conf.OldGoReport); err != nil {
+			log.Fatalf("Failed writing old go report: %v", err)
+		}
+	case conf.ModCheck:
+		if err := scan.WriteModCheckReport(os.Stdout, modulesFoundInOwners); err != nil {
+			log.Fatalf("Failed writing go.mod checks report: %v", err)
+		}
+	case conf.ReplaceReport:
+		if err := scan.WriteLocalReplaceReport(os.Stdout, modulesFoundInOwners, nodesToGraph); err != nil {
+			log.Fatalf("Failed writing local replaces report: %v", err)
+		}
+	case conf.JSON:
+		var stats *scan.APIStats // opt-in, not deterministic
+		if conf.JSONStats {
+			stats = res.Stats
+		}
+		if err := render.WriteJSON(os.Stdout, graph.New(graphEnv, modulesFoundInOwners, nodesToGraph), ann, stats, res.ErrorSummary()); err != nil {
+			log.Fatalf("Failed writing JSON output: %v", err)
+		}
+	case conf.Flows != "":
+		if err := writeFlows(os.Stdout, conf.Flows, modulesFoundInOwners, nodesToGraph); err != nil {
+			log.Fatalf("Failed writing flows output: %v", err)
+		}
+	case conf.CriticalPath:
+		printLongestChain(modulesFoundInOwners, nodesToGraph, cfg.effortTracker())
+	default:
+		renderOpts := conf.RenderOptions(ann, cfg.effortTracker())
+		renderOpts.ScanTime = scanTime
+		if err := render.Render(conf.Format, graph.New(graphEnv, modulesFoundInOwners, nodesToGraph), os.Stdout, renderOpts); err != nil {
+			log.Fatalf("Failed writing %s output: %v", conf.Format, err)
+		}
+	}
+	// --- End Generate Output ---
+	failures := 0
+	if len(freshnessRules) > 0 {
+		failures = reportFreshness(checkFreshness(ctx, pc, freshnessRules, modulesFoundInOwners, nodesToGraph, ann.Latest))
+		exitIfCanceled(ctx, false)
+	}
+	res.ErrorSummary().LogReport()
+	res.Stats.LogReport()
+	logSummary(graph.New(nil, modulesFoundInOwners, nodesToGraph).Summary(), res.Stats)
+	if *statsJSONFlag != "" {
+		if err := res.Stats.WriteStatsJSON(*statsJSONFlag); err != nil {
+			log.Errf("Failed writing -stats-json %s: %v", *statsJSONFlag, err)
+		}
+	}
+	if *badgesFlag != "" {
+		n, err := writeBadges(*badgesFlag, graph.New(nil, modulesFoundInOwners, nodesToGraph))
+		if err != nil {
+			log.Fatalf("Failed writing -badges to %s: %v", *badgesFlag, err)
+		}
+		log.Infof("Wrote %d badges to %s", n, *badgesFlag)
+	}
+	checkPolicies(conf, modulesFoundInOwners, nodesToGraph, baselineExternals, ann.Latest, failures).finish(*policyJSONFlag)
+}
+
+// banner returns the title followed by the -banner provenance lines: scan time, owners
+// scanned, depgraph version and the flags set (on the command line or by the configuration).
+func banner(title string, scanTime time.Time, owners []string) string {
+	var lines []string
+	if title != "" {
+		lines = append(lines, title)
+	}
+	lines = append(lines, fmt.Sprintf("Scanned %s: %s", scanTime.Format(time.DateTime+" MST"), strings.Join(owners, " ")),
+		"depgraph "+cli.ShortVersion)
+	var flags []string
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "title" && f.Name != "banner" {
+			flags = append(flags, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	if len(flags) > 0 {
+		lines = append(lines, "Flags: "+strings.Join(flags, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// typedOwners returns the comma separated names of the -orgs or -users flag as kind:name owners.
+func typedOwners(kind, names string) []string {
+	var res []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			res = append(res, kind+":"+name)
+		}
+	}
+	return res
+}
+
+// logSummary logs the summary of the run (also in the -json output, with the API usage
+// only with -json-stats).
+func logSummary(s *graph.Summary, stats *scan.APIStats) {
+	msg := fmt.Sprintf("Summary: %d repositories, %d modules (%d forks and %d external in the graph of %d), %d cycles",
+		s.Repos, s.Modules, s.Forks, s.External, s.Nodes, s.Cycles)
+	if api := stats.Summary(); api != "" {
+		msg += ", " + api
+	}
+	log.Infof("%s", msg)
+}
+
+// setLogging applies -logformat, and -quiet when set by the configuration file (the cli
+// only applies the command line's).
+func setLogging(format string, quiet bool) error {
+	switch format {
+	case "text":
+	case "json":
+		log.Config.JSON, log.Config.ConsoleColor, log.Config.ForceColor = true, false, false
+		log.SetColorMode()
+	default:
+		return fmt.Errorf("invalid -logformat %q, expecting text or json", format)
+	}
+	if quiet {
+		log.SetLogLevelQuiet(log.Error)
+	}
+	return nil
+}
+
+// runContext returns the context of the run: canceled by Ctrl-C (or SIGTERM) and, if not 0,
+// when the timeout expires. A second Ctrl-C exits right away.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("-timeout %v exceeded", timeout))
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// exitIfCanceled exits with an error if the run was canceled (Ctrl-C, -timeout), instead of
+// going on with partial results. resumable is true when the GitHub scan recorded its
+// progress, for -resume.
+func exitIfCanceled(ctx context.Context, resumable bool) {
+	if ctx.Err() == nil {
+		return
+	}
+	reason := context.Cause(ctx).Error() // -timeout exceeded
+	if errors.Is(context.Cause(ctx), context.Canceled) {
+		reason = "interrupted"
+	}
+	if resumable {
+		reason += ", run again with -resume to continue the scan"
+	}
+	log.Fatalf("Canceled: %s", reason)
+}
+
+// checkpointArgs returns the arguments identifying a scan (but -resume), to only resume the same one.
+func checkpointArgs() string {
+	args := make([]string, 0, len(os.Args))
+	for _, arg := range os.Args[1:] {
+		switch strings.TrimPrefix(arg, "-") {
+		case "-resume", "resume", "-resume=true", "resume=true":
+			continue
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// scanGitHub sets up the GitHub client, using the (opened) cache, and scans the given owners
+// and repos into res, reporting the progress if asked.
+func scanGitHub(ctx context.Context, cache *scan.Cache, owners []string, repos []scan.RepoSpec, opts *scan.Options, progress bool, res *scan.Result) {
+	client := newGitHubClient(ctx, cache, opts, res.Stats)
+	if progress {
+		client.Progress = scan.NewProgress(res.Stats)
+		defer client.Progress.Finish()
+	}
+	if cache.Use {
+		var err error
+		client.Checkpoint, err = scan.StartCheckpoint(cache, checkpointArgs(), opts.Resume)
+		if err != nil {
+			log.Warnf("Can't record the scan progress (for -resume): %v", err)
+		}
+	}
+	scan.OwnersAndRepos(ctx, client, owners, repos, opts, res)
+	if ctx.Err() == nil {
+		client.Checkpoint.Finish()
+	}
+}
+
+// openCache creates and opens the cache of conf, cleared first with -clear-cache. The caller
+// closes it when done.
+func openCache(conf *depgraph.Config) *scan.Cache {
+	cache, err := conf.NewCache()
+	if err != nil {
+		log.Fatalf("%v", err) // already validated
+	}
+	if err := cache.Open(); err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	if conf.ClearCache {
+		if err := cache.Clear(); err != nil {
+			log.Fatalf("Failed to clear cache: %v", err)
+		}
+	}
+	return cache
+}
+
+// rescanClient returns the GitHub client of the serve and watch rescans, nil with -local.
+func rescanClient(ctx context.Context, conf *depgraph.Config, cache *scan.Cache) *scan.ClientWrapper {
+	if conf.Local {
+		return nil
+	}
+	return newGitHubClient(ctx, cache, &conf.Scan, conf.NewResult().Stats)
+}
+
+// newGitHubClient returns the GitHub client, using the (opened) cache and its settings
+// (offline, retries).
+func newGitHubClient(ctx context.Context, cache *scan.Cache, opts *scan.Options, stats *scan.APIStats) *scan.ClientWrapper {
+	// --- GitHub Client Setup ---
+	tokens, tokenSource, err := scan.GitHubTokens(ctx, opts.TokenFile)
+	if err != nil {
+		log.Fatalf("Failed to get the GitHub token: %v", err)
+	}
+	token := ""
+	if len(tokens) > 0 {
+		token = tokens[0]
+		log.Infof("Using the GitHub token from %s", tokenSource)
+	}
+	base := scan.BaseTransport(stats)
+	var httpClient *http.Client = nil
+	switch {
+	case cache.Offline:
+		httpClient = &http.Client{Transport: scan.OfflineTransport{}}
+	case len(tokens) > 1:
+		log.Infof("Using %d GitHub tokens (%s), in turn as their rate limits run out", len(tokens), tokenSource)
+		httpClient = &http.Client{Transport: scan.NewTokenTransport(base, tokens)}
+	case token != "":
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		httpClient = oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: base}), ts)
+	default:
+		httpClient = &http.Client{Transport: base}
+		log.Warnf("No GitHub token (GITHUB_TOKEN, GH_TOKEN, -token-file or gh auth token). Using unauthenticated access (may hit rate limits).")
+	}
+	if !cache.Offline {
+		httpClient = &http.Client{Transport: scan.NewRateLimitTransport(scan.NewRetryTransport(&scan.ETagTransport{Base: httpClient.Transport}, cache.Retries, stats), opts.RateWait, stats)}
+	}
+	ghClient := github.NewClient(httpClient)
+	if token != "" && !cache.Offline {
+		if err := scan.CheckTokens(ctx, ghClient, len(tokens), opts.Visibility); err != nil {
+			log.Fatalf("%v, token from %s", err, tokenSource)
+		}
+	}
+	// Create client wrapper
+	client := scan.NewClientWrapper(ghClient, cache, stats)
+	client.Revalidate = opts.Revalidate && !cache.Offline
+	client.Incremental = opts.Incremental
+	if opts.GraphQL && !cache.Offline {
+		if token == "" {
+			log.Warnf("-graphql requires a GITHUB_TOKEN (GraphQL API is authenticated only), using the REST API")
+		} else {
+			client.GraphQL = true
+		}
+	}
+	// --- End GitHub Client Setup ---
+	return client
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"fortio.org/cli"
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Merging Snapshots ---
+
+// readSnapshot reads a snapshot saved with -save-snapshot (or by merge).
+func readSnapshot(filename string) (*snapshot, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := parseSnapshot(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("%s is not a depgraph snapshot (saved with -save-snapshot)", filename)
+	}
+	return snap, nil
+}
+
+// mergeSnapshots returns the union of the snapshots: their modules merged with
+// graph.MergeModules (the first snapshots win the ties), all their paths and arguments. Its
+// creation time is the most recent of theirs, so merging the same snapshots gives the same file.
+func mergeSnapshots(snaps []*snapshot) *snapshot {
+	res := &snapshot{Version: snapshotVersion}
+	scans := make([]map[string]*graph.ModuleInfo, 0, len(snaps))
+	allPaths := make(map[string]bool)
+	for _, snap := range snaps {
+		res.Args = append(res.Args, snap.Args...)
+		if snap.Created.After(res.Created) {
+			res.Created = snap.Created
+		}
+		scans = append(scans, snap.Modules)
+		for _, path := range snap.AllPaths {
+			allPaths[path] = true
+		}
+	}
+	res.Modules = graph.MergeModules(graphEnv, scans...)
+	for path := range res.Modules {
+		allPaths[path] = true // error nodes aren't always in AllPaths of older snapshots
+	}
+	res.AllPaths = graph.SortedKeys(allPaths)
+	return res
+}
+
+// mergeMain is the `depgraph merge` subcommand: combines snapshots of separate scans (other
+// owners, providers, -local directories, runs...) into one, to render with -load-snapshot.
+func mergeMain() {
+	cli.ArgsHelp = "snap1.json snap2.json ...\n" +
+		"Outputs the snapshot (see -save-snapshot) combining the scans, to use with -load-snapshot"
+	cli.MinArgs = 2
+	cli.MaxArgs = -1
+	cli.Main()
+	snaps := make([]*snapshot, 0, flag.NArg())
+	for _, filename := range flag.Args() {
+		snap, err := readSnapshot(filename)
+		if err != nil {
+			log.Fatalf("Failed to read snapshot: %v", err)
+		}
+		snaps = append(snaps, snap)
+	}
+	res := mergeSnapshots(snaps)
+	log.Infof("Merged %d snapshots: %d modules", len(snaps), len(res.Modules))
+	content, err := encodeSnapshot(res)
+	if err != nil {
+		log.Fatalf("Failed encoding the snapshot: %v", err)
+	}
+	if _, err := os.Stdout.Write(content); err != nil {
+		log.Fatalf("Failed writing the snapshot: %v", err)
+	}
+}
+
+// --- End Merging Snapshots ---
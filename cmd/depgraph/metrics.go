@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Prometheus Metrics ---
+
+// ownerMetrics are the gauges of an owner's modules in the graph.
+type ownerMetrics struct {
+	Modules  int // scanned modules
+	Edges    int // dependencies of its modules
+	Cycles   int // cycles involving its modules
+	External int // distinct external dependencies of its modules
+}
+
+// newOwnerMetrics returns the metrics of the graph, by owner.
+func newOwnerMetrics(g *graph.Graph) map[string]*ownerMetrics {
+	res := make(map[string]*ownerMetrics)
+	get := func(owner string) *ownerMetrics {
+		m := res[owner]
+		if m == nil {
+			m = &ownerMetrics{}
+			res[owner] = m
+		}
+		return m
+	}
+	for _, n := range g.Nodes {
+		if n.Module != nil {
+			get(n.Module.Owner).Modules++
+		}
+	}
+	external := make(map[string]map[string]bool) // owner -> external dependencies
+	for _, e := range g.Edges {
+		if e.From.Module == nil {
+			continue
+		}
+		owner := e.From.Module.Owner
+		get(owner).Edges++
+		if e.To.Module == nil {
+			if external[owner] == nil {
+				external[owner] = make(map[string]bool)
+			}
+			external[owner][e.To.Path] = true
+		}
+	}
+	for owner, deps := range external {
+		get(owner).External = len(deps)
+	}
+	for _, c := range g.Cycles {
+		owners := make(map[string]bool)
+		for _, n := range c.Nodes {
+			if n.Module != nil {
+				owners[n.Module.Owner] = true
+			}
+		}
+		for owner := range owners {
+			get(owner).Cycles++
+		}
+	}
+	return res
+}
+
+// scanMetrics are the metrics of the serve and watch scans, served on /metrics in the
+// Prometheus text format.
+type scanMetrics struct {
+	mu       sync.Mutex
+	owners   map[string]*ownerMetrics // of the last successful scan
+	duration time.Duration            // of the last successful scan
+	scanned  time.Time                // zero until the first successful scan
+	scans    int
+	failures int
+}
+
+// record updates the metrics with a scan's result, the previous graph's being kept on error.
+func (m *scanMetrics) record(g *graph.Graph, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scans++
+	if err != nil {
+		m.failures++
+		return
+	}
+	m.owners, m.duration, m.scanned = newOwnerMetrics(g), duration, time.Now()
+}
+
+// promLabel escapes a Prometheus label value.
+func promLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *scanMetrics) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	metric := func(name, help, typ string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	owners := graph.SortedKeys(m.owners)
+	for _, g := range []struct {
+		name, help string
+		value      func(*ownerMetrics) int
+	}{
+		{"depgraph_modules", "Scanned modules in the graph, by owner.", func(o *ownerMetrics) int { return o.Modules }},
+		{"depgraph_edges", "Dependencies of the owner's modules.", func(o *ownerMetrics) int { return o.Edges }},
+		{"depgraph_cycles", "Dependency cycles involving the owner's modules.", func(o *ownerMetrics) int { return o.Cycles }},
+		{"depgraph_external_dependencies", "Distinct external dependencies of the owner's modules.", func(o *ownerMetrics) int { return o.External }},
+	} {
+		metric(g.name, g.help, "gauge")
+		for _, owner := range owners {
+			fmt.Fprintf(bw, "%s{owner=\"%s\"} %d\n", g.name, promLabel(owner), g.value(m.owners[owner]))
+		}
+	}
+	metric("depgraph_scan_duration_seconds", "Duration of the last successful scan.", "gauge")
+	fmt.Fprintf(bw, "depgraph_scan_duration_seconds %g\n", m.duration.Seconds())
+	metric("depgraph_last_scan_timestamp_seconds", "Time of the last successful scan, 0 before the first.", "gauge")
+	var last float64
+	if !m.scanned.IsZero() {
+		last = float64(m.scanned.UnixMilli()) / 1000
+	}
+	fmt.Fprintf(bw, "depgraph_last_scan_timestamp_seconds %g\n", last)
+	metric("depgraph_scans_total", "Scans done, failed ones included.", "counter")
+	fmt.Fprintf(bw, "depgraph_scans_total %d\n", m.scans)
+	metric("depgraph_scan_failures_total", "Failed scans (the previous graph's metrics are kept).", "counter")
+	fmt.Fprintf(bw, "depgraph_scan_failures_total %d\n", m.failures)
+	return bw.Flush()
+}
+
+func (m *scanMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.write(w); err != nil {
+		log.Warnf("Failed writing the metrics: %v", err)
+	}
+}
+
+// serveMetrics serves the metrics on listen's /metrics until ctx is canceled (for -watch,
+// serve having them on its own address).
+func serveMetrics(ctx context.Context, m *scanMetrics, listen string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	srv := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	context.AfterFunc(ctx, func() { _ = srv.Shutdown(context.Background()) })
+	log.Infof("Serving the metrics on http://%s/metrics", listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Errf("Failed to serve the metrics: %v", err)
+	}
+}
+
+// --- End Prometheus Metrics ---
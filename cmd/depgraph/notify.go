@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Change Notifications ---
+
+// webhookTimeout is the maximum duration of a -webhook POST.
+const webhookTimeout = 30 * time.Second
+
+// webhookMaxLines is the maximum number of lines per section of the notification text.
+const webhookMaxLines = 20
+
+// webhookPayload is the JSON POSTed to -webhook when the watched graph changed: a Slack
+// incoming webhook message (text), with the changes also as data for other receivers.
+type webhookPayload struct {
+	Text      string                 `json:"text"`
+	Added     []string               `json:"added_modules,omitempty"`
+	Removed   []string               `json:"removed_modules,omitempty"`
+	Changed   map[string][]depChange `json:"changed_dependencies,omitempty"`
+	NewCycles [][]string             `json:"new_cycles,omitempty"`
+}
+
+// newCycles returns the cycles of cur not in prev.
+func newCycles(prev, cur [][]string) [][]string {
+	known := make(map[string]bool, len(prev))
+	for _, c := range prev {
+		known[strings.Join(c, " ")] = true
+	}
+	var res [][]string
+	for _, c := range cur {
+		if !known[strings.Join(c, " ")] {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// newWebhookPayload returns the notification of the changes, nil if there are none.
+func newWebhookPayload(d *graphDiff, cycles [][]string) *webhookPayload {
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(cycles) == 0 {
+		return nil
+	}
+	var added, bumped, removed []string
+	for _, path := range graph.SortedKeys(d.Changed) {
+		for _, c := range d.Changed[path] {
+			switch {
+			case c.Old == "":
+				added = append(added, fmt.Sprintf("`%s` now requires `%s` %s", path, c.Path, c.New))
+			case c.New == "":
+				removed = append(removed, fmt.Sprintf("`%s` no longer requires `%s` %s", path, c.Path, c.Old))
+			default:
+				bumped = append(bumped, fmt.Sprintf("`%s` requires `%s` %s -> %s", path, c.Path, c.Old, c.New))
+			}
+		}
+	}
+	cycleLines := make([]string, 0, len(cycles))
+	for _, c := range cycles {
+		cycleLines = append(cycleLines, "`"+strings.Join(c, "` <-> `")+"`")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dependency graph changed: %d new cycles, %d new dependencies, %d version bumps, %d removed dependencies, %d modules added, %d removed",
+		len(cycles), len(added), len(bumped), len(removed), len(d.Added), len(d.Removed))
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n*%s*", title)
+		for i, line := range lines {
+			if i == webhookMaxLines {
+				fmt.Fprintf(&b, "\n• ... and %d more", len(lines)-i)
+				break
+			}
+			b.WriteString("\n• " + line)
+		}
+	}
+	section("New cycles", cycleLines)
+	section("New dependencies", added)
+	section("Version bumps", bumped)
+	section("Removed dependencies", removed)
+	section("Added modules", d.Added)
+	section("Removed modules", d.Removed)
+	return &webhookPayload{Text: b.String(), Added: d.Added, Removed: d.Removed, Changed: d.Changed, NewCycles: cycles}
+}
+
+// postWebhook POSTs the payload as JSON to the url.
+func postWebhook(ctx context.Context, url string, p *webhookPayload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// --- End Change Notifications ---
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewWebhookPayload(t *testing.T) {
+	many := &graphDiff{}
+	for i := range webhookMaxLines + 5 {
+		many.Added = append(many.Added, fmt.Sprintf("example.com/m%02d", i))
+	}
+	tests := []struct {
+		name     string
+		diff     *graphDiff
+		cycles   [][]string
+		wantNil  bool
+		want     []string // lines of the text
+		dontWant []string
+	}{
+		{name: "no change", diff: &graphDiff{}, wantNil: true},
+		{
+			name: "changes",
+			diff: &graphDiff{
+				Added:   []string{"example.com/new"},
+				Removed: []string{"example.com/old"},
+				Changed: map[string][]depChange{"example.com/a": {
+					{Path: "example.com/b", Old: "v1.0.0", New: "v1.1.0"},
+					{Path: "example.com/c", New: "v0.1.0"},
+					{Path: "example.com/d", Old: "v0.2.0"},
+				}},
+			},
+			want: []string{
+				"Dependency graph changed: 0 new cycles, 1 new dependencies, 1 version bumps, 1 removed dependencies, 1 modules added, 1 removed",
+				"• `example.com/a` requires `example.com/b` v1.0.0 -> v1.1.0",
+				"• `example.com/a` now requires `example.com/c` v0.1.0",
+				"• `example.com/a` no longer requires `example.com/d` v0.2.0",
+				"*Added modules*", "• example.com/new",
+				"*Removed modules*", "• example.com/old",
+			},
+			dontWant: []string{"*New cycles*"},
+		},
+		{
+			name:   "new cycle only",
+			diff:   &graphDiff{},
+			cycles: [][]string{{"example.com/a", "example.com/b"}},
+			want:   []string{"*New cycles*", "• `example.com/a` <-> `example.com/b`"},
+		},
+		{
+			name:     "truncated",
+			diff:     many,
+			want:     []string{"• example.com/m19", "• ... and 5 more"},
+			dontWant: []string{"• example.com/m20"},
+		},
+	}
+	for _, tt := range tests {
+		p := newWebhookPayload(tt.diff, tt.cycles)
+		if (p == nil) != tt.wantNil {
+			t.Errorf("%s: payload %+v, want nil %v", tt.name, p, tt.wantNil)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		lines := strings.Split(p.Text, "\n")
+		for _, want := range tt.want {
+			found := false
+			for _, line := range lines {
+				found = found || line == want
+			}
+			if !found {
+				t.Errorf("%s: missing line %q in:\n%s", tt.name, want, p.Text)
+			}
+		}
+		for _, dontWant := range tt.dontWant {
+			if strings.Contains(p.Text, dontWant) {
+				t.Errorf("%s: unexpected %q in:\n%s", tt.name, dontWant, p.Text)
+			}
+		}
+		if len(p.Added) != len(tt.diff.Added) || len(p.NewCycles) != len(tt.cycles) {
+			t.Errorf("%s: payload data %+v doesn't match the changes", tt.name, p)
+		}
+	}
+}
+
+func TestNewCycles(t *testing.T) {
+	prev := [][]string{{"a", "b"}}
+	cur := [][]string{{"a", "b"}, {"c", "d", "e"}}
+	if got := newCycles(prev, cur); len(got) != 1 || strings.Join(got[0], " ") != "c d e" {
+		t.Errorf("newCycles() = %v, want [[c d e]]", got)
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "error", status: http.StatusInternalServerError, wantErr: "500 Internal Server Error: invalid_payload"},
+		{name: "not found", status: http.StatusNotFound, wantErr: "404 Not Found: invalid_payload"},
+	}
+	for _, tt := range tests {
+		var gotContentType, gotBody string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			gotContentType, gotBody = r.Header.Get("Content-Type"), r.Method+" "+string(body)
+			w.WriteHeader(tt.status)
+			if tt.status != http.StatusNoContent {
+				fmt.Fprintln(w, "invalid_payload")
+			}
+		}))
+		err := postWebhook(context.Background(), s.URL, &webhookPayload{Text: "changed"})
+		s.Close()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if gotContentType != "application/json" || !strings.HasPrefix(gotBody, `POST {"text":"changed"`) {
+			t.Errorf("%s: request %s %q", tt.name, gotContentType, gotBody)
+		}
+	}
+}
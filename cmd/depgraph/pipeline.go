@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Post-Scan Pipeline ---
+
+// annotated returns true if the graph is annotated with the module proxy or deps.dev
+// information, queried through the cache.
+func annotated(conf *depgraph.Config) bool {
+	return conf.CheckLatest || conf.LatestReport || conf.CheckDeprecated || conf.DepsDev
+}
+
+// scanLocal scans the local directories (-local) into res, logging the ones failing.
+func scanLocal(ctx context.Context, conf *depgraph.Config, dirs []string, res *scan.Result) {
+	for i, dir := range dirs {
+		log.Infof("Processing directory %d: %s", i+1, dir)
+		if err := scan.LocalDir(ctx, dir, i, res); err != nil {
+			log.Errf("Error scanning directory %s: %v", dir, err)
+		}
+	}
+	if conf.APICoupling {
+		scan.DetectAPICoupling(res.Modules, res.LocalDirs)
+	}
+}
+
+// processed is a scan result gone through postScan.
+type processed struct {
+	modules      map[string]*graph.ModuleInfo // scanned modules
+	allPaths     map[string]bool              // all the module paths, scanned or dependencies
+	nodesToGraph map[string]bool              // the graph's nodes
+	ann          *scan.Annotations
+	pc           *scan.ProxyClient // nil if the module proxy wasn't queried
+}
+
+// graph returns the graph of the processed scan.
+func (p *processed) graph() *graph.Graph {
+	return graph.New(graphEnv, p.modules, p.nodesToGraph)
+}
+
+// postScan applies the processing shared by the scan, serve and watch modes to the modules
+// scanned into res: the configuration's aliases, the owner groups, the graph's nodes (per
+// the filters), the retracted requirements warnings, the configuration's ignored edges and
+// the module proxy and deps.dev annotations, queried with the (opened) cache if annotated.
+func postScan(ctx context.Context, conf *depgraph.Config, cfg *config, cache *scan.Cache, res *scan.Result) (*processed, error) {
+	aliasRules, _ := cfg.aliases() // already validated by readConfig
+	applyAliases(res, aliasRules)
+	conf.GroupOwners(res.Modules)
+	p := &processed{modules: res.Modules, allPaths: res.AllPaths}
+
+	// --- Determine Nodes to Include in Graph ---
+	p.nodesToGraph = graph.NodesToGraph(graphEnv, p.modules, p.allPaths, conf.NoExt)
+	if err := conf.FilterNodes(p.modules, p.nodesToGraph); err != nil {
+		return nil, err
+	}
+	// --- End Determine Nodes to Include in Graph ---
+	scan.WarnRetractedRequirements(p.modules, p.nodesToGraph)
+	ignoreRules, _ := cfg.ignoredEdges() // already validated by readConfig
+	applyIgnoredEdges(p.modules, ignoreRules)
+
+	// --- Module Proxy and deps.dev Information ---
+	p.ann = &scan.Annotations{GoLabel: conf.GoLabel, ScaleNodes: conf.ScaleNodes}
+	if conf.CheckLatest || conf.LatestReport || conf.CheckDeprecated {
+		p.pc = scan.NewProxyClient(cache, res.Stats)
+		latest := scan.FetchProxyInfo(ctx, p.pc, p.nodesToGraph)
+		if conf.CheckLatest || conf.LatestReport {
+			p.ann.Latest = latest
+		}
+		if conf.CheckDeprecated {
+			p.ann.Deprecated = scan.FetchDeprecations(ctx, p.pc, latest)
+			scan.WarnDeprecatedDependencies(p.modules, p.nodesToGraph, p.ann.Deprecated)
+		}
+	}
+	if conf.DepsDev {
+		p.ann.DepsDev = scan.FetchDepsDevInfo(ctx, scan.NewDepsDevClient(cache, res.Stats), p.modules, p.nodesToGraph)
+	}
+	return p, nil
+}
+
+// rescanner scans the same owners and repositories again and again, for the serve and watch
+// modes, processing each result like the scan mode does (see postScan).
+type rescanner struct {
+	conf   *depgraph.Config
+	cfg    *config
+	cache  *scan.Cache         // opened, nil with -local and no annotations
+	client *scan.ClientWrapper // nil with -local
+	owners []string            // directories with -local
+	repos  []scan.RepoSpec
+}
+
+// newRescanner returns the rescanner of the owners and repos, using the (opened) cache and
+// the GitHub client (nil with -local). Unless offline, the client revalidates its cache hits
+// and refreshes the listings (as with -revalidate and -incremental): without a -cache-ttl,
+// the rescans would otherwise all be answered from the cache of the first scan.
+func newRescanner(conf *depgraph.Config, cfg *config, cache *scan.Cache, client *scan.ClientWrapper, owners []string, repos []scan.RepoSpec) *rescanner {
+	if client != nil && !cache.Offline {
+		client.Revalidate, client.Incremental = true, true
+	}
+	return &rescanner{conf: conf, cfg: cfg, cache: cache, client: client, owners: owners, repos: repos}
+}
+
+// scan scans the owners and repositories (the directories with -local) and processes the result.
+func (r *rescanner) scan(ctx context.Context) (*processed, error) {
+	res := r.conf.NewResult()
+	if r.conf.Local {
+		scanLocal(ctx, r.conf, r.owners, res)
+	} else {
+		scan.OwnersAndRepos(ctx, r.client, r.owners, r.repos, &r.conf.Scan, res)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return postScan(ctx, r.conf, r.cfg, r.cache, res)
+}
+
+// --- End Post-Scan Pipeline ---
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// testGoMods are the go.mod files of the test modules, by directory: example.com/a requiring
+// example.com/b (which requires a back: a cycle) and vanity.example/c, an alias of the
+// example.com/c module, which requires the external example.com/ext.
+var testGoMods = map[string]string{
+	"a": "module example.com/a\n\ngo 1.22\n\nrequire (\n\texample.com/b v1.0.0\n\tvanity.example/c v1.0.0\n)\n",
+	"b": "module example.com/b\n\ngo 1.22\n\nrequire example.com/a v1.1.0\n",
+	"c": "module example.com/c\n\ngo 1.22\n\nrequire example.com/ext v0.1.0\n",
+}
+
+// writeTestModules writes the go.mod files (see testGoMods) in a temporary directory,
+// returning the module directories, for -local scans.
+func writeTestModules(t *testing.T, goMods map[string]string) []string {
+	t.Helper()
+	root := t.TempDir()
+	var dirs []string
+	for _, name := range graph.SortedKeys(goMods) {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMods[name]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// newTestRescanner returns the rescanner of the directories (-local) with the configuration.
+func newTestRescanner(t *testing.T, conf *depgraph.Config, cfg *config, dirs []string) *rescanner {
+	t.Helper()
+	conf.Local = true
+	if err := conf.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	return newRescanner(conf, cfg, nil, nil, dirs, nil)
+}
+
+// newTestGitHubRescanner returns the rescanner of the owners of the fake GitHub API with the
+// configuration, using a new cache (with the default settings: no -cache-ttl nor -revalidate).
+func newTestGitHubRescanner(t *testing.T, s *fakegithub.Server, conf *depgraph.Config, owners []string) *rescanner {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if err := conf.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := conf.NewCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	client := github.NewClient(&http.Client{Transport: &scan.ETagTransport{Base: s.Server.Client().Transport}})
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return newRescanner(conf, &config{}, cache, scan.NewClientWrapper(client, cache, nil), owners, nil)
+}
+
+func TestRescannerPipeline(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config
+		noExt      bool
+		wantNodes  []string
+		wantCycles int
+	}{
+		{
+			name:       "plain",
+			cfg:        &config{},
+			wantNodes:  []string{"example.com/a", "example.com/b", "example.com/c", "example.com/ext", "vanity.example/c"},
+			wantCycles: 1,
+		},
+		{
+			name:       "aliases",
+			cfg:        &config{Aliases: []string{"vanity.example/c == example.com/c"}},
+			wantNodes:  []string{"example.com/a", "example.com/b", "example.com/c", "example.com/ext"},
+			wantCycles: 1,
+		},
+		{
+			name:      "ignored edges",
+			cfg:       &config{IgnoreEdges: []string{"example.com/b -> example.com/a"}},
+			wantNodes: []string{"example.com/a", "example.com/b", "example.com/c", "example.com/ext", "vanity.example/c"},
+		},
+		{
+			name:       "noext",
+			cfg:        &config{},
+			noExt:      true,
+			wantNodes:  []string{"example.com/a", "example.com/b", "example.com/c"},
+			wantCycles: 1,
+		},
+	}
+	dirs := writeTestModules(t, testGoMods)
+	for _, tt := range tests {
+		conf := depgraph.DefaultConfig()
+		conf.NoExt = tt.noExt
+		p, err := newTestRescanner(t, conf, tt.cfg, dirs).scan(context.Background())
+		if err != nil {
+			t.Errorf("%s: scan error: %v", tt.name, err)
+			continue
+		}
+		g := p.graph()
+		if got := g.Paths(); !slices.Equal(got, tt.wantNodes) {
+			t.Errorf("%s: nodes %v, want %v", tt.name, got, tt.wantNodes)
+		}
+		if len(g.Cycles) != tt.wantCycles {
+			t.Errorf("%s: %d cycles, want %d", tt.name, len(g.Cycles), tt.wantCycles)
+		}
+		if p.ann == nil || p.pc != nil {
+			t.Errorf("%s: annotations %+v, proxy client %v, want empty annotations and no proxy client", tt.name, p.ann, p.pc)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- CI Policies ---
+
+// policyExitCode is the exit status when policies are violated, 1 being for errors (and 2
+// for usage errors), so CI can tell a failed gate from a failed run.
+const policyExitCode = 3
+
+// policyViolation is a failed policy check.
+type policyViolation struct {
+	Policy  string   `json:"policy"` // flag name, e.g. fail-on-cycle
+	Message string   `json:"message"`
+	Modules []string `json:"modules,omitempty"` // involved, e.g. the modules of the cycle
+}
+
+// policyReport is the result of the policy checks (-policy-json).
+type policyReport struct {
+	Passed     bool              `json:"passed"`
+	Violations []policyViolation `json:"violations"`
+}
+
+func (r *policyReport) add(policy string, modules []string, format string, args ...any) {
+	r.Violations = append(r.Violations, policyViolation{Policy: policy, Message: fmt.Sprintf(format, args...), Modules: modules})
+}
+
+// readBaselineExternals returns the external dependencies of the graph saved with -json (or
+// snapshot) for -fail-on-new-external.
+func readBaselineExternals(filename string) (map[string]bool, error) {
+	g, err := readJSONGraph(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if n.External {
+			res[n.Path] = true
+		}
+	}
+	return res, nil
+}
+
+// checkPolicies checks the graph against the configuration's policies: -fail-on-cycle,
+// -fail-on-new-external (baseline are its external dependencies), -max-depth-allowed and
+// -fail-on-outdated (latest from the module proxy). freshnessFailures are the requirements
+// violating the freshness rules of the configuration file, with fail: true.
+func checkPolicies(conf *depgraph.Config, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool,
+	baseline map[string]bool, latest map[string]*scan.ProxyModuleInfo, freshnessFailures int,
+) *policyReport {
+	report := &policyReport{Violations: []policyViolation{}}
+	if conf.FailOnCycle {
+		for _, c := range graph.New(nil, modulesFoundInOwners, nodesToGraph).Cycles {
+			paths := make([]string, 0, len(c.Nodes))
+			for _, n := range c.Nodes {
+				paths = append(paths, n.Path)
+			}
+			report.add("fail-on-cycle", paths, "dependency cycle: %s", strings.Join(paths, " <-> "))
+		}
+	}
+	if baseline != nil {
+		var added []string
+		for _, path := range graph.SortedKeys(nodesToGraph) {
+			if modulesFoundInOwners[path] == nil && !baseline[path] {
+				added = append(added, path)
+			}
+		}
+		if len(added) > 0 {
+			report.add("fail-on-new-external", added, "%d new external dependencies: %s", len(added), strings.Join(added, ", "))
+		}
+	}
+	if conf.MaxDepthAllowed >= 0 {
+		if chain, _ := longestChain(modulesFoundInOwners, nodesToGraph, nil); len(chain)-1 > conf.MaxDepthAllowed {
+			report.add("max-depth-allowed", chain, "dependency chain of depth %d (more than %d): %s",
+				len(chain)-1, conf.MaxDepthAllowed, strings.Join(chain, " <- "))
+		}
+	}
+	if conf.FailOnOutdated {
+		outdated := scan.OutdatedRequirements(modulesFoundInOwners, nodesToGraph, latest)
+		for _, dep := range graph.SortedKeys(outdated) {
+			report.add("fail-on-outdated", append([]string{dep}, outdated[dep]...), "%s is behind its latest version %s: %s",
+				dep, latest[dep].Latest, strings.Join(outdated[dep], ", "))
+		}
+	}
+	if freshnessFailures > 0 {
+		report.add("freshness", nil, "%d requirements not meeting the freshness SLAs", freshnessFailures)
+	}
+	report.Passed = len(report.Violations) == 0
+	return report
+}
+
+// finish logs the violations, writes the report to filename (if not empty) and exits with
+// policyExitCode if there are violations.
+func (r *policyReport) finish(filename string) {
+	for _, v := range r.Violations {
+		log.Errf("Policy %s violated: %s", v.Policy, v.Message)
+	}
+	if filename != "" {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false) // <- in the messages
+		enc.SetIndent("", "  ")
+		err := enc.Encode(r)
+		if err == nil {
+			err = os.WriteFile(filename, buf.Bytes(), 0o644)
+		}
+		if err != nil {
+			log.Errf("Failed writing -policy-json %s: %v", filename, err)
+		}
+	}
+	if !r.Passed {
+		log.Errf("%d policy violations", len(r.Violations))
+		os.Exit(policyExitCode)
+	}
+}
+
+// --- End CI Policies ---
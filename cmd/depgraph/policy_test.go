@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// policyModules returns the scanned modules of the policy tests: app requiring lib (requiring
+// base) and the external ext, c1 and c2 requiring each other (a cycle).
+func policyModules() (map[string]*graph.ModuleInfo, map[string]bool) {
+	mods := map[string]*graph.ModuleInfo{
+		"app":  {Path: "app", Fetched: true, Deps: map[string]string{"lib": "v1.0.0", "ext": "v0.1.0"}},
+		"lib":  {Path: "lib", Fetched: true, Deps: map[string]string{"base": "v1.0.0"}},
+		"base": {Path: "base", Fetched: true},
+		"c1":   {Path: "c1", Fetched: true, Deps: map[string]string{"c2": "v1.0.0"}},
+		"c2":   {Path: "c2", Fetched: true, Deps: map[string]string{"c1": "v1.0.0"}},
+	}
+	nodes := map[string]bool{"ext": true}
+	for path := range mods {
+		nodes[path] = true
+	}
+	return mods, nodes
+}
+
+func TestCheckPolicies(t *testing.T) {
+	latest := map[string]*scan.ProxyModuleInfo{"ext": {Latest: "v0.2.0", Found: true}}
+	tests := []struct {
+		name      string
+		conf      func(c *depgraph.Config)
+		baseline  map[string]bool
+		freshness int
+		want      []string // policies violated
+	}{
+		{name: "none", conf: func(*depgraph.Config) {}},
+		{name: "cycle", conf: func(c *depgraph.Config) { c.FailOnCycle = true }, want: []string{"fail-on-cycle"}},
+		{name: "new external", conf: func(*depgraph.Config) {}, baseline: map[string]bool{"old": true}, want: []string{"fail-on-new-external"}},
+		{name: "known external", conf: func(*depgraph.Config) {}, baseline: map[string]bool{"ext": true}},
+		{name: "too deep", conf: func(c *depgraph.Config) { c.MaxDepthAllowed = 1 }, want: []string{"max-depth-allowed"}},
+		{name: "deep enough", conf: func(c *depgraph.Config) { c.MaxDepthAllowed = 2 }},
+		{name: "outdated", conf: func(c *depgraph.Config) { c.FailOnOutdated = true }, want: []string{"fail-on-outdated"}},
+		{name: "freshness", conf: func(*depgraph.Config) {}, freshness: 2, want: []string{"freshness"}},
+		{
+			name: "all",
+			conf: func(c *depgraph.Config) {
+				c.FailOnCycle, c.MaxDepthAllowed, c.FailOnOutdated = true, 0, true
+			},
+			baseline: map[string]bool{},
+			want:     []string{"fail-on-cycle", "fail-on-new-external", "max-depth-allowed", "fail-on-outdated"},
+		},
+	}
+	for _, tt := range tests {
+		conf := depgraph.DefaultConfig()
+		tt.conf(conf)
+		mods, nodes := policyModules()
+		report := checkPolicies(conf, mods, nodes, tt.baseline, latest, tt.freshness)
+		var got []string
+		for _, v := range report.Violations {
+			got = append(got, v.Policy)
+		}
+		if !slices.Equal(got, tt.want) || report.Passed != (len(tt.want) == 0) {
+			t.Errorf("%s: violations %v (passed %v), want %v", tt.name, got, report.Passed, tt.want)
+		}
+	}
+}
+
+func TestPolicyViolationModules(t *testing.T) {
+	conf := depgraph.DefaultConfig()
+	conf.FailOnCycle, conf.MaxDepthAllowed, conf.FailOnOutdated = true, 1, true
+	mods, nodes := policyModules()
+	latest := map[string]*scan.ProxyModuleInfo{"ext": {Latest: "v0.2.0", Found: true}}
+	report := checkPolicies(conf, mods, nodes, map[string]bool{}, latest, 0)
+	want := map[string][]string{
+		"fail-on-cycle":        {"c1", "c2"},
+		"fail-on-new-external": {"ext"},
+		"max-depth-allowed":    {"base", "lib", "app"},
+		"fail-on-outdated":     {"ext", "app requires v0.1.0"},
+	}
+	for _, v := range report.Violations {
+		if !slices.Equal(v.Modules, want[v.Policy]) {
+			t.Errorf("%s: modules %v, want %v", v.Policy, v.Modules, want[v.Policy])
+		}
+	}
+}
+
+func TestReadBaselineExternals(t *testing.T) {
+	content, err := json.Marshal(&render.JSONOutput{Nodes: []render.JSONNode{
+		{Path: "app", Deps: map[string]string{"ext": "v0.1.0"}},
+		{Path: "ext", External: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(filename, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := readBaselineExternals(filename)
+	if err != nil || len(baseline) != 1 || !baseline["ext"] {
+		t.Errorf("baseline %v, error %v, want ext", baseline, err)
+	}
+	if _, err := readBaselineExternals(filepath.Join(t.TempDir(), "nope.json")); err == nil {
+		t.Errorf("missing baseline: no error")
+	}
+}
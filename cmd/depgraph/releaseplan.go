@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// --- Release (Upgrade Order) Plan ---
+
+// releaseStep is a module to re-release in a release plan, after bumping its dependencies.
+type releaseStep struct {
+	Path string   `json:"path"`
+	Bump []string `json:"bump,omitempty"` // dependencies to bump: the ones released at a previous level
+}
+
+// releaseLevel is a set of modules that can be re-released in parallel.
+type releaseLevel struct {
+	Level   int           `json:"level"`
+	Modules []releaseStep `json:"modules"`
+	Effort  *float64      `json:"effort,omitempty"` // with the config's effort weights
+}
+
+// releasePlan is the -release-plan output.
+type releasePlan struct {
+	Module string         `json:"module"`
+	Levels []releaseLevel `json:"levels"`
+	Cycles []string       `json:"cycles,omitempty"` // dependents in dependency cycles, to handle manually
+}
+
+// planRelease builds the release plan for a change of the module (path or suffix): its
+// scanned dependents, transitively, level by level. A module is at the level after the
+// last of its dependencies in the plan, so it is released once, after all of them.
+func planRelease(modulesFoundInOwners map[string]*graph.ModuleInfo, allModulePaths map[string]bool, query string, effort *render.EffortTracker) (*releasePlan, error) {
+	dependents, err := findDependents(modulesFoundInOwners, allModulePaths, query, true)
+	if err != nil {
+		return nil, err
+	}
+	inPlan := map[string]bool{dependents.Module: true}
+	for _, d := range dependents.Dependents {
+		inPlan[d.Path] = true
+	}
+	// Bumps of each module: its (direct or ignored) dependencies that are in the plan
+	bumps := make(map[string][]string)
+	bumpedBy := make(map[string][]string) // dep -> modules of the plan bumping it
+	remaining := make(map[string]int)     // number of bumps not yet released
+	for _, path := range graph.SortedKeys(inPlan) {
+		info := modulesFoundInOwners[path]
+		if info == nil || path == dependents.Module {
+			continue
+		}
+		for _, deps := range []map[string]string{info.Deps, info.IgnoredDeps} {
+			for dep := range deps {
+				if inPlan[dep] {
+					bumps[path] = append(bumps[path], dep)
+					bumpedBy[dep] = append(bumpedBy[dep], path)
+				}
+			}
+		}
+		sort.Strings(bumps[path])
+		remaining[path] = len(bumps[path])
+	}
+	plan := &releasePlan{Module: dependents.Module}
+	released := make(map[string]bool)
+	level := []string{dependents.Module}
+	for len(level) > 0 {
+		step := releaseLevel{Level: len(plan.Levels), Modules: make([]releaseStep, 0, len(level))}
+		total := 0.
+		var next []string
+		for _, path := range level {
+			released[path] = true
+			step.Modules = append(step.Modules, releaseStep{Path: path, Bump: bumps[path]})
+			if effort != nil {
+				total += effort.Of(path, modulesFoundInOwners)
+			}
+			for _, d := range bumpedBy[path] {
+				remaining[d]--
+				if remaining[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		if effort != nil {
+			step.Effort = &total
+		}
+		plan.Levels = append(plan.Levels, step)
+		sort.Strings(next)
+		level = next
+	}
+	for _, path := range graph.SortedKeys(inPlan) {
+		if !released[path] {
+			plan.Cycles = append(plan.Cycles, path)
+		}
+	}
+	if len(plan.Cycles) > 0 {
+		log.Warnf("%d dependents of %s are in dependency cycles: %s", len(plan.Cycles), plan.Module, strings.Join(plan.Cycles, ", "))
+	}
+	return plan, nil
+}
+
+// printReleasePlan outputs the release plan as text, or JSON if asJSON.
+func printReleasePlan(w io.Writer, plan *releasePlan, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	fmt.Fprintf(w, "Release Plan for a change of %s (%d levels):\n", plan.Module, len(plan.Levels))
+	cumulative := 0.
+	for _, l := range plan.Levels {
+		suffix := ""
+		if l.Effort != nil {
+			cumulative += *l.Effort
+			suffix = fmt.Sprintf(" (effort %s, cumulative %s)", render.FormatEffort(*l.Effort), render.FormatEffort(cumulative))
+		}
+		fmt.Fprintf(w, "Level %d%s:\n", l.Level, suffix)
+		for _, m := range l.Modules {
+			if len(m.Bump) == 0 {
+				fmt.Fprintf(w, "  - %s (changed)\n", m.Path)
+			} else {
+				fmt.Fprintf(w, "  - %s: bump %s\n", m.Path, strings.Join(m.Bump, ", "))
+			}
+		}
+	}
+	if len(plan.Cycles) > 0 {
+		fmt.Fprintf(w, "In dependency cycles (release manually):\n")
+		for _, path := range plan.Cycles {
+			fmt.Fprintf(w, "  - %s\n", path)
+		}
+	}
+	return nil
+}
+
+// --- End Release (Upgrade Order) Plan ---
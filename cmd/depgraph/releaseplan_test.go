@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// releaseModules returns the scanned modules of the release plan tests: lib requiring log,
+// app requiring lib and log, tool requiring app (an ignored edge), x and y requiring each
+// other (a cycle) and x log, other requiring nothing of them.
+func releaseModules() (map[string]*graph.ModuleInfo, map[string]bool) {
+	modules := map[string]*graph.ModuleInfo{
+		"example.com/log":   {Path: "example.com/log"},
+		"example.com/lib":   {Path: "example.com/lib", Deps: map[string]string{"example.com/log": "v1.0.0"}},
+		"example.com/app":   {Path: "example.com/app", Deps: map[string]string{"example.com/lib": "v1.0.0", "example.com/log": "v1.0.0"}},
+		"example.com/tool":  {Path: "example.com/tool", IgnoredDeps: map[string]string{"example.com/app": "v1.0.0"}},
+		"example.com/x":     {Path: "example.com/x", Deps: map[string]string{"example.com/log": "v1.0.0", "example.com/y": "v1.0.0"}},
+		"example.com/y":     {Path: "example.com/y", Deps: map[string]string{"example.com/x": "v1.0.0"}},
+		"example.com/other": {Path: "example.com/other", Deps: map[string]string{"example.com/ext": "v1.0.0"}},
+	}
+	allPaths := map[string]bool{"example.com/ext": true}
+	for path := range modules {
+		allPaths[path] = true
+	}
+	return modules, allPaths
+}
+
+func TestReleasePlan(t *testing.T) {
+	modules, allPaths := releaseModules()
+	plan, err := planRelease(modules, allPaths, "log", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	if err := printReleasePlan(&text, plan, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `Release Plan for a change of example.com/log (4 levels):
+Level 0:
+  - example.com/log (changed)
+Level 1:
+  - example.com/lib: bump example.com/log
+Level 2:
+  - example.com/app: bump example.com/lib, example.com/log
+Level 3:
+  - example.com/tool: bump example.com/app
+In dependency cycles (release manually):
+  - example.com/x
+  - example.com/y
+`
+	if text.String() != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", &text, want)
+	}
+	var js strings.Builder
+	if err := printReleasePlan(&js, plan, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded releasePlan
+	if err := json.Unmarshal([]byte(js.String()), &decoded); err != nil {
+		t.Fatalf("JSON plan: %v\n%s", err, &js)
+	}
+	if len(decoded.Levels) != 4 || decoded.Levels[2].Modules[0].Path != "example.com/app" || len(decoded.Cycles) != 2 {
+		t.Errorf("JSON plan:\n%s", &js)
+	}
+	if plan, err := planRelease(modules, allPaths, "ext", nil); err != nil || len(plan.Levels) != 2 || plan.Levels[1].Modules[0].Path != "example.com/other" {
+		t.Errorf("plan for ext: %+v, %v", plan, err)
+	}
+	if _, err := planRelease(modules, allPaths, "nope", nil); err == nil {
+		t.Errorf("plan for an unknown module: no error")
+	}
+}
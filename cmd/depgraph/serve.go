@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Serve Mode ---
+
+// server serves the graph of the last scan of the owners: web UI, JSON API and metrics.
+type server struct {
+	conf    *depgraph.Config
+	scanner *rescanner
+	args    []string // owners, repos or directories
+
+	mu      sync.RWMutex
+	g       *graph.Graph      // nil until the first scan is done
+	ann     *scan.Annotations // of g
+	scanned time.Time
+	scanErr error // of the last scan, the previous graph is kept
+	metrics scanMetrics
+}
+
+// rescan scans the owners again and replaces the served graph.
+func (s *server) rescan(ctx context.Context) {
+	start := time.Now()
+	p, err := s.scanner.scan(ctx)
+	if ctx.Err() != nil {
+		return // canceled (Ctrl-C, -timeout)
+	}
+	var g *graph.Graph
+	if err == nil {
+		g = p.graph()
+	}
+	s.metrics.record(g, time.Since(start), err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanErr = err
+	if err != nil {
+		log.Errf("Scan failed, still serving the previous graph: %v", err)
+		return
+	}
+	s.g, s.ann, s.scanned = g, p.ann, time.Now()
+	log.Infof("Scanned %v in %v: %d nodes, %d edges, %d cycles", s.args, time.Since(start).Round(time.Millisecond), len(g.Nodes), len(g.Edges), len(g.Cycles))
+}
+
+// graph returns the served graph, nil (after replying with an error) if there is none yet.
+func (s *server) graph(w http.ResponseWriter) *graph.Graph {
+	g, _ := s.annotatedGraph(w)
+	return g
+}
+
+// annotatedGraph returns the served graph and its annotations, like graph.
+func (s *server) annotatedGraph(w http.ResponseWriter) (*graph.Graph, *scan.Annotations) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.g == nil {
+		http.Error(w, "First scan in progress, try again later", http.StatusServiceUnavailable)
+	}
+	return s.g, s.ann
+}
+
+// handler returns the routes of the UI and API.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /module/{path...}", s.modulePage)
+	mux.HandleFunc("GET /api/graph", s.apiGraph)
+	mux.HandleFunc("GET /api/modules", s.apiModules)
+	mux.HandleFunc("GET /api/modules/{path...}", s.apiModule)
+	mux.HandleFunc("GET /api/module/{path...}", s.apiModule)
+	mux.HandleFunc("GET /api/cycles", s.apiCycles)
+	mux.HandleFunc("GET /api/status", s.apiStatus)
+	mux.HandleFunc("GET /graph.dot", s.dot)
+	mux.Handle("GET /metrics", &s.metrics)
+	mux.HandleFunc("GET /badges/{path...}", s.badge)
+	mux.Handle("GET /static/", http.FileServerFS(staticFiles))
+	return mux
+}
+
+// moduleView is a module with its dependencies and dependents, for the module page and API.
+type moduleView struct {
+	Path         string            `json:"path"`
+	Module       *graph.ModuleInfo `json:"module,omitempty"` // nil for external dependencies
+	InCycle      bool              `json:"in_cycle,omitempty"`
+	Dependencies []edgeView        `json:"dependencies"`
+	Dependents   []edgeView        `json:"dependents"`
+}
+
+// edgeView is the other end of a dependency edge and the required version.
+type edgeView struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// moduleView returns the module of the graph, nil if it's not in it.
+func newModuleView(g *graph.Graph, path string) *moduleView {
+	n := g.Nodes[path]
+	if n == nil {
+		return nil
+	}
+	v := &moduleView{Path: path, Module: n.Module, InCycle: n.PartOfLoop, Dependencies: []edgeView{}, Dependents: []edgeView{}}
+	for _, e := range g.Dependencies(path) {
+		v.Dependencies = append(v.Dependencies, edgeView{Path: e.To.Path, Version: e.Version})
+	}
+	for _, e := range g.Dependents(path) {
+		v.Dependents = append(v.Dependents, edgeView{Path: e.From.Path, Version: e.Version})
+	}
+	return v
+}
+
+// cyclePaths returns the paths of the nodes of each cycle.
+func cyclePaths(g *graph.Graph) [][]string {
+	res := make([][]string, 0, len(g.Cycles))
+	for _, c := range g.Cycles {
+		paths := make([]string, 0, len(c.Nodes))
+		for _, n := range c.Nodes {
+			paths = append(paths, n.Path)
+		}
+		res = append(res, paths)
+	}
+	return res
+}
+
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Warnf("Failed writing the JSON response: %v", err)
+	}
+}
+
+// apiGraph serves the graph as JSON (the -json output), or in another -format with ?format=.
+func (s *server) apiGraph(w http.ResponseWriter, r *http.Request) {
+	g, ann := s.annotatedGraph(w)
+	if g == nil {
+		return
+	}
+	format := r.FormValue("format")
+	if format == "" || format == "json" {
+		writeJSONResponse(w, render.BuildJSON(g, ann, nil, nil))
+		return
+	}
+	if _, found := render.Renderers[format]; !found {
+		http.Error(w, fmt.Sprintf("Unknown format %q: json, %s", format, render.FormatNames()), http.StatusBadRequest)
+		return
+	}
+	contentType := "text/plain; charset=utf-8"
+	if format == render.FormatDOT {
+		contentType = "text/vnd.graphviz"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := render.Render(format, g, w, s.conf.RenderOptions(ann, nil)); err != nil {
+		log.Warnf("Failed writing the %s response: %v", format, err)
+	}
+}
+
+// moduleListItem is a module of the /api/modules list.
+type moduleListItem struct {
+	Path         string `json:"path"`
+	RepoPath     string `json:"repo,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	External     bool   `json:"external,omitempty"`
+	Fork         bool   `json:"fork,omitempty"`
+	InCycle      bool   `json:"in_cycle,omitempty"`
+	Dependencies int    `json:"dependencies"`
+	Dependents   int    `json:"dependents"`
+}
+
+// apiModules lists the modules of the graph, sorted, the ones containing ?q= if set.
+func (s *server) apiModules(w http.ResponseWriter, r *http.Request) {
+	g := s.graph(w)
+	if g == nil {
+		return
+	}
+	q := r.FormValue("q")
+	res := []moduleListItem{}
+	for _, path := range g.Paths() {
+		if !strings.Contains(path, q) {
+			continue
+		}
+		n := g.Nodes[path]
+		item := moduleListItem{Path: path, External: n.Module == nil, InCycle: n.PartOfLoop,
+			Dependencies: len(g.Dependencies(path)), Dependents: len(g.Dependents(path))}
+		if info := n.Module; info != nil {
+			item.RepoPath, item.Owner, item.Fork = info.RepoPath, info.Owner, info.IsFork
+		}
+		res = append(res, item)
+	}
+	writeJSONResponse(w, res)
+}
+
+// apiModule serves a module with its dependencies and dependents, or with /dependents at the
+// end of the path, its dependents (direct or, with ?transitive=true, not) as with -dependents.
+func (s *server) apiModule(w http.ResponseWriter, r *http.Request) {
+	g := s.graph(w)
+	if g == nil {
+		return
+	}
+	path := r.PathValue("path")
+	if modulePath, found := strings.CutSuffix(path, "/dependents"); found && !g.Has(path) {
+		if !g.Has(modulePath) {
+			http.NotFound(w, r)
+			return
+		}
+		transitive, _ := strconv.ParseBool(r.FormValue("transitive"))
+		res, err := findDependents(g.Modules, g.Set(), modulePath, transitive)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSONResponse(w, res)
+		return
+	}
+	v := newModuleView(g, path)
+	if v == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSONResponse(w, v)
+}
+
+func (s *server) apiCycles(w http.ResponseWriter, _ *http.Request) {
+	if g := s.graph(w); g != nil {
+		writeJSONResponse(w, cyclePaths(g))
+	}
+}
+
+// serveStatus is the /api/status response.
+type serveStatus struct {
+	Args    []string  `json:"args"`
+	Scanned time.Time `json:"scanned,omitzero"` // zero until the first scan is done
+	Error   string    `json:"error,omitempty"`  // of the last scan
+	Nodes   int       `json:"nodes"`
+}
+
+func (s *server) apiStatus(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	st := serveStatus{Args: s.args, Scanned: s.scanned}
+	if s.scanErr != nil {
+		st.Error = s.scanErr.Error()
+	}
+	if s.g != nil {
+		st.Nodes = len(s.g.Nodes)
+	}
+	s.mu.RUnlock()
+	writeJSONResponse(w, st)
+}
+
+func (s *server) dot(w http.ResponseWriter, _ *http.Request) {
+	g, ann := s.annotatedGraph(w)
+	if g == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	if err := render.Render(render.FormatDOT, g, w, s.conf.RenderOptions(ann, nil)); err != nil {
+		log.Warnf("Failed writing the DOT response: %v", err)
+	}
+}
+
+// badge serves the SVG badge of a module: /badges/<module path>/<kind>.svg, as written by -badges.
+func (s *server) badge(w http.ResponseWriter, r *http.Request) {
+	g := s.graph(w)
+	if g == nil {
+		return
+	}
+	modulePath, file := path.Split(r.PathValue("path"))
+	kind, found := strings.CutSuffix(file, ".svg")
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := render.NewBadge(g, strings.TrimSuffix(modulePath, "/"), kind)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=300") // rescans are infrequent
+	if err := b.WriteSVG(w); err != nil {
+		log.Warnf("Failed writing the badge: %v", err)
+	}
+}
+
+// staticFiles are the scripts of the web UI, embedded so serve works offline: graph.js draws
+// the /api/graph as an SVG (a simple layered layout, no vis-network nor other CDN library).
+//
+//go:embed static
+var staticFiles embed.FS
+
+// indexTemplate is the searchable graph page: the graph (drawn from /api/graph by
+// static/graph.js), the list of modules filtered by the search box, and the cycles.
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>depgraph {{.Args}}</title>
+<style>
+body { font-family: Helvetica, sans-serif; margin: 1em; }
+#graph { height: 70vh; overflow: auto; border: 1px solid lightgrey; }
+.ext { color: grey; } .cycle { color: red; }
+</style>
+</head>
+<body>
+<h3>Dependencies of {{.Args}}</h3>
+<p>{{len .Paths}} modules, scanned {{.Scanned.Format "2006-01-02 15:04:05"}}.
+<a href="/graph.dot">DOT</a> - <a href="/api/graph">JSON</a></p>
+<input id="search" type="search" placeholder="Search modules" size="50" autofocus>
+<div id="graph"></div>
+<h4>Modules</h4>
+<ul id="modules">
+{{range .Paths}}<li><a href="/module/{{.}}">{{.}}</a></li>
+{{end}}</ul>
+<h4>Cycles ({{len .Cycles}})</h4>
+<ul>
+{{range .Cycles}}<li class="cycle">{{range $i, $p := .}}{{if $i}} &harr; {{end}}<a href="/module/{{$p}}">{{$p}}</a>{{end}}</li>
+{{end}}</ul>
+<script src="/static/graph.js"></script>
+</body>
+</html>
+`))
+
+// moduleTemplate is the page of a module: its information, dependencies and dependents.
+var moduleTemplate = template.Must(template.New("module").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Path}}</title>
+<style>body { font-family: Helvetica, sans-serif; margin: 1em; } .cycle { color: red; }</style>
+</head>
+<body>
+<p><a href="/">All modules</a></p>
+<h3>{{.Path}}{{if .InCycle}} <span class="cycle">(in a dependency cycle)</span>{{end}}</h3>
+{{with .Module}}<ul>
+<li>Repository: {{.RepoPath}}{{with .Dir}} ({{.}}){{end}}{{with .Ref}} @{{.}}{{end}}</li>
+{{if .IsFork}}<li>Fork{{with .OriginalModulePath}} of {{.}}{{end}}</li>{{end}}
+{{with .GoVersion}}<li>go {{.}}</li>{{end}}
+{{with .License}}<li>License: {{.}}</li>{{end}}
+{{with .Error}}<li class="cycle">Error: {{.}}</li>{{end}}
+</ul>{{else}}<p>External dependency.</p>{{end}}
+<h4>Dependencies ({{len .Dependencies}})</h4>
+<ul>
+{{range .Dependencies}}<li><a href="/module/{{.Path}}">{{.Path}}</a> {{.Version}}</li>
+{{end}}</ul>
+<h4>Dependents ({{len .Dependents}})</h4>
+<ul>
+{{range .Dependents}}<li><a href="/module/{{.Path}}">{{.Path}}</a> requires {{.Version}}</li>
+{{end}}</ul>
+<p><a href="/api/modules/{{.Path}}">JSON</a></p>
+</body>
+</html>
+`))
+
+func (s *server) index(w http.ResponseWriter, _ *http.Request) {
+	g := s.graph(w)
+	if g == nil {
+		return
+	}
+	s.mu.RLock()
+	data := struct {
+		Args    []string
+		Scanned time.Time
+		Paths   []string
+		Cycles  [][]string
+	}{s.args, s.scanned, g.Paths(), cyclePaths(g)}
+	s.mu.RUnlock()
+	if err := indexTemplate.Execute(w, data); err != nil {
+		log.Warnf("Failed writing the index page: %v", err)
+	}
+}
+
+func (s *server) modulePage(w http.ResponseWriter, r *http.Request) {
+	g := s.graph(w)
+	if g == nil {
+		return
+	}
+	v := newModuleView(g, r.PathValue("path"))
+	if v == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := moduleTemplate.Execute(w, v); err != nil {
+		log.Warnf("Failed writing the module page: %v", err)
+	}
+}
+
+// serve scans with the scanner, then rescans every interval (if not 0) in the background,
+// while serving the web UI and API of the graph of args on listen, until ctx is canceled.
+func serve(ctx context.Context, scanner *rescanner, args []string, listen string, interval time.Duration) error {
+	s := &server{conf: scanner.conf, scanner: scanner, args: args}
+	srv := &http.Server{Addr: listen, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		s.rescan(ctx)
+		if interval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return // canceled (Ctrl-C, -timeout)
+			case <-ticker.C:
+				s.rescan(ctx)
+			}
+		}
+	}()
+	context.AfterFunc(ctx, func() { _ = srv.Shutdown(context.Background()) })
+	log.Infof("Serving the dependency graph of %v on http://%s/", args, listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// --- End Serve Mode ---
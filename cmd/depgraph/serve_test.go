@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+)
+
+func TestServeHandlers(t *testing.T) {
+	dirs := writeTestModules(t, testGoMods)
+	s := &server{conf: depgraph.DefaultConfig(), args: dirs}
+	s.scanner = newTestRescanner(t, s.conf, &config{}, dirs)
+	h := s.handler()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/graph", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first scan: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	s.rescan(context.Background())
+	tests := []struct {
+		path            string
+		wantStatus      int
+		wantContentType string
+		want            []string // in the body
+		dontWant        []string
+	}{
+		{path: "/", wantStatus: 200, want: []string{`<a href="/module/example.com/a">`, `<script src="/static/graph.js">`}, dontWant: []string{"cdn."}},
+		{path: "/static/graph.js", wantStatus: 200, wantContentType: "text/javascript", want: []string{"function drawGraph("}},
+		{path: "/module/example.com/a", wantStatus: 200, want: []string{"(in a dependency cycle)", `<a href="/module/example.com/b">example.com/b</a> v1.0.0`}},
+		{path: "/module/example.com/nope", wantStatus: 404},
+		{path: "/api/graph", wantStatus: 200, wantContentType: "application/json", want: []string{`"path": "example.com/a"`, `"example.com/b": "v1.0.0"`}},
+		{path: "/api/graph?format=topo", wantStatus: 200, want: []string{"example.com/ext"}},
+		{path: "/api/graph?format=nope", wantStatus: 400, want: []string{`Unknown format "nope"`}},
+		{path: "/api/modules?q=example.com/", wantStatus: 200, want: []string{`"path": "example.com/a"`, `"in_cycle": true`}, dontWant: []string{"vanity.example"}},
+		{path: "/api/module/example.com/a", wantStatus: 200, want: []string{`"dependents": [`, `"path": "example.com/b"`}},
+		{path: "/api/modules/example.com/c", wantStatus: 200, want: []string{`"path": "example.com/ext"`}},
+		{path: "/api/module/example.com/a/dependents", wantStatus: 200, want: []string{"example.com/b"}},
+		{path: "/api/module/example.com/nope", wantStatus: 404},
+		{path: "/api/cycles", wantStatus: 200, want: []string{`"example.com/a",`}},
+		{path: "/api/status", wantStatus: 200, want: []string{`"nodes": 5`, `"scanned": "`}, dontWant: []string{`"error"`}},
+		{path: "/graph.dot", wantStatus: 200, wantContentType: "text/vnd.graphviz", want: []string{"digraph"}},
+		{path: "/metrics", wantStatus: 200, want: []string{"depgraph_"}},
+		{path: "/badges/example.com/a/cycle.svg", wantStatus: 200, wantContentType: "image/svg+xml", want: []string{"in cycle!"}},
+		{path: "/badges/example.com/c/externals.svg", wantStatus: 200, want: []string{"depends on 1 external"}},
+		{path: "/badges/example.com/a/cycle.png", wantStatus: 404},
+		{path: "/badges/example.com/ext/cycle.svg", wantStatus: 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body := w.Body.String()
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", tt.path, w.Code, tt.wantStatus, body)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+			t.Errorf("%s: content type %q, want %q", tt.path, ct, tt.wantContentType)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: missing %q in:\n%s", tt.path, want, body)
+			}
+		}
+		for _, dontWant := range tt.dontWant {
+			if strings.Contains(body, dontWant) {
+				t.Errorf("%s: unexpected %q in:\n%s", tt.path, dontWant, body)
+			}
+		}
+	}
+}
+
+// TestServeRescan serves an owner of the fake GitHub API, with the default cache settings (no
+// -cache-ttl nor -revalidate), and checks the data changed between two rescans is served.
+func TestServeRescan(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     string // of the go.mod changed
+		goMod    string
+		path     string
+		want     string // in the body after the rescan
+		dontWant string
+	}{
+		{
+			name:     "version bump",
+			repo:     "tools",
+			goMod:    "module github.com/acme/tools\n\ngo 1.22\n\nrequire (\n\tgithub.com/acme/log v1.3.0\n\tgithub.com/ext/lib v0.3.0\n)\n",
+			path:     "/api/module/github.com/acme/tools",
+			want:     `"version": "v1.3.0"`,
+			dontWant: `"version": "v1.2.0"`,
+		},
+		{
+			name:     "removed dependency",
+			repo:     "app",
+			goMod:    "module github.com/acme/app\n\ngo 1.23\n\nrequire github.com/acme/tools v0.5.0\n",
+			path:     "/api/module/github.com/acme/app",
+			want:     `"path": "github.com/acme/tools"`,
+			dontWant: "github.com/bob/util",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakegithub.Fixture()
+			defer fake.Close()
+			s := &server{conf: depgraph.DefaultConfig(), args: []string{"acme"}}
+			s.scanner = newTestGitHubRescanner(t, fake, s.conf, s.args)
+			h := s.handler()
+			get := func() string {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s: status %d: %s", tt.path, w.Code, w.Body.String())
+				}
+				return w.Body.String()
+			}
+			s.rescan(context.Background())
+			if body := get(); !strings.Contains(body, tt.dontWant) {
+				t.Fatalf("first scan: missing %q in:\n%s", tt.dontWant, body)
+			}
+			fake.SetFile("acme", tt.repo, "go.mod", tt.goMod)
+			s.rescan(context.Background())
+			body := get()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("after the rescan: missing %q in:\n%s", tt.want, body)
+			}
+			if strings.Contains(body, tt.dontWant) {
+				t.Errorf("after the rescan: still %q in:\n%s", tt.dontWant, body)
+			}
+		})
+	}
+}
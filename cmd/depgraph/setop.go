@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"fortio.org/cli"
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// --- Set Operations on Saved Graphs ---
+
+// readJSONGraph reads a graph saved with -json, or the graph of a snapshot (-save-snapshot).
+func readJSONGraph(filename string) (*render.JSONOutput, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := parseSnapshot(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	if snap != nil {
+		return snapshotGraph(snap), nil
+	}
+	g := &render.JSONOutput{}
+	if err := json.Unmarshal(content, g); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	return g, nil
+}
+
+// setOps are the supported operations: which nodes (by path) of a and b to keep.
+var setOps = map[string]func(inA, inB bool) bool{
+	"union":     func(inA, inB bool) bool { return inA || inB },
+	"intersect": func(inA, inB bool) bool { return inA && inB },
+	"subtract":  func(inA, inB bool) bool { return inA && !inB },
+}
+
+// mergeDeps returns the union of 2 dependency maps, a's versions taking precedence.
+func mergeDeps(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+	res := make(map[string]string, len(a)+len(b))
+	for path, version := range b {
+		res[path] = version
+	}
+	for path, version := range a {
+		res[path] = version
+	}
+	return res
+}
+
+// setOperation applies op on the nodes of a and b. Nodes present in both graphs use a's
+// information (b's dependencies are added for union). Dependencies are restricted to the
+// resulting nodes and the cycles are recomputed.
+func setOperation(op string, a, b *render.JSONOutput) *render.JSONOutput {
+	keep := setOps[op]
+	nodesA := make(map[string]render.JSONNode, len(a.Nodes))
+	nodesB := make(map[string]render.JSONNode, len(b.Nodes))
+	allPaths := make(map[string]bool)
+	for _, n := range a.Nodes {
+		nodesA[n.Path] = n
+		allPaths[n.Path] = true
+	}
+	for _, n := range b.Nodes {
+		nodesB[n.Path] = n
+		allPaths[n.Path] = true
+	}
+	kept := make(map[string]bool)
+	for path := range allPaths {
+		_, inA := nodesA[path]
+		_, inB := nodesB[path]
+		if keep(inA, inB) {
+			kept[path] = true
+		}
+	}
+	res := &render.JSONOutput{Nodes: make([]render.JSONNode, 0, len(kept))}
+	modules := make(map[string]*graph.ModuleInfo)
+	for _, path := range graph.SortedKeys(kept) {
+		n, inA := nodesA[path]
+		nb, inB := nodesB[path]
+		switch {
+		case !inA:
+			n = nb
+		case inB && op == "union":
+			n.Deps = mergeDeps(n.Deps, nb.Deps)
+			n.IndirectDeps = mergeDeps(n.IndirectDeps, nb.IndirectDeps)
+			n.External = n.External && nb.External
+		}
+		n.Deps = render.GraphDeps(n.Deps, kept)
+		n.IndirectDeps = render.GraphDeps(n.IndirectDeps, kept)
+		n.IgnoredDeps = render.GraphDeps(n.IgnoredDeps, kept)
+		if !n.External {
+			modules[path] = &graph.ModuleInfo{Path: path, Deps: n.Deps}
+		}
+		res.Nodes = append(res.Nodes, n)
+	}
+	g := graph.New(graphEnv, modules, kept)
+	for i := range res.Nodes {
+		res.Nodes[i].InCycle = g.Nodes[res.Nodes[i].Path].PartOfLoop
+	}
+	return res
+}
+
+// setOpMain is the `depgraph setop` subcommand: set operations between graphs saved with -json
+// (or snapshots).
+func setOpMain() {
+	cli.ArgsHelp = "union|intersect|subtract a.json b.json\n" +
+		"Outputs (as JSON) the nodes in a or b (union), in both (intersect) or in a but not b (subtract)"
+	cli.MinArgs = 3
+	cli.MaxArgs = 3
+	cli.Main()
+	op := flag.Arg(0)
+	if setOps[op] == nil {
+		cli.ErrUsage("Unknown operation %q, expecting union, intersect or subtract", op)
+	}
+	a, err := readJSONGraph(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Failed to read graph: %v", err)
+	}
+	b, err := readJSONGraph(flag.Arg(2))
+	if err != nil {
+		log.Fatalf("Failed to read graph: %v", err)
+	}
+	res := setOperation(op, a, b)
+	log.Infof("%s: %d nodes (%d in a, %d in b)", op, len(res.Nodes), len(a.Nodes), len(b.Nodes))
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		log.Fatalf("Failed writing JSON output: %v", err)
+	}
+}
+
+// --- End Set Operations on Saved Graphs ---
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+)
+
+// setOpGraphs returns the graphs of the set operation tests: a with x and y requiring each
+// other (a cycle) and x requiring the external z, b with y requiring w and z.
+func setOpGraphs() (a, b *render.JSONOutput) {
+	a = &render.JSONOutput{Nodes: []render.JSONNode{
+		{Path: "x", Deps: map[string]string{"y": "v1.0.0", "z": "v1.0.0"}, InCycle: true},
+		{Path: "y", Deps: map[string]string{"x": "v1.0.0"}, InCycle: true},
+		{Path: "z", External: true},
+	}}
+	b = &render.JSONOutput{Nodes: []render.JSONNode{
+		{Path: "w"},
+		{Path: "y", Deps: map[string]string{"w": "v2.0.0", "x": "v0.9.0", "z": "v1.1.0"}},
+		{Path: "z", External: true},
+	}}
+	return a, b
+}
+
+func TestSetOperation(t *testing.T) {
+	tests := []struct {
+		op          string
+		wantNodes   []string
+		wantDeps    map[string]map[string]string
+		wantInCycle []string
+	}{
+		{
+			op:        "union",
+			wantNodes: []string{"w", "x", "y", "z"},
+			wantDeps: map[string]map[string]string{
+				"x": {"y": "v1.0.0", "z": "v1.0.0"},
+				"y": {"w": "v2.0.0", "x": "v1.0.0", "z": "v1.1.0"}, // a's version of x
+			},
+			wantInCycle: []string{"x", "y"},
+		},
+		{
+			op:        "intersect",
+			wantNodes: []string{"y", "z"},
+			wantDeps:  map[string]map[string]string{}, // y's only dep in a is x
+		},
+		{
+			op:        "subtract",
+			wantNodes: []string{"x"},
+			wantDeps:  map[string]map[string]string{}, // y and z are in b
+		},
+	}
+	for _, tt := range tests {
+		a, b := setOpGraphs()
+		res := setOperation(tt.op, a, b)
+		var nodes, inCycle []string
+		deps := make(map[string]map[string]string)
+		for _, n := range res.Nodes {
+			nodes = append(nodes, n.Path)
+			if n.InCycle {
+				inCycle = append(inCycle, n.Path)
+			}
+			if n.Deps != nil {
+				deps[n.Path] = n.Deps
+			}
+		}
+		if !slices.Equal(nodes, tt.wantNodes) {
+			t.Errorf("%s: nodes %v, want %v", tt.op, nodes, tt.wantNodes)
+		}
+		if !maps.EqualFunc(deps, tt.wantDeps, maps.Equal) {
+			t.Errorf("%s: dependencies %v, want %v", tt.op, deps, tt.wantDeps)
+		}
+		if !slices.Equal(inCycle, tt.wantInCycle) {
+			t.Errorf("%s: in cycle %v, want %v", tt.op, inCycle, tt.wantInCycle)
+		}
+	}
+}
+
+func TestReadJSONGraph(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := setOpGraphs()
+	content, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "a.json")
+	if err := os.WriteFile(jsonFile, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snap := &snapshot{Version: snapshotVersion, Args: []string{"acme"}, AllPaths: []string{"x", "y", "z"},
+		Modules: map[string]*graph.ModuleInfo{
+			"x": {Path: "x", Fetched: true, Deps: map[string]string{"y": "v1.0.0", "z": "v1.0.0"}},
+			"y": {Path: "y", Fetched: true, Deps: map[string]string{"x": "v1.0.0"}},
+		}}
+	if content, err = encodeSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	snapFile := filepath.Join(dir, "snap.json")
+	if err := os.WriteFile(snapFile, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{jsonFile, snapFile} {
+		g, err := readJSONGraph(filename)
+		if err != nil {
+			t.Errorf("%s: error %v", filename, err)
+			continue
+		}
+		if d := diffGraphs(a, g); len(d.Added)+len(d.Removed)+len(d.Changed) != 0 {
+			t.Errorf("%s: differs from the graph: %+v", filename, d)
+		}
+		if !g.Nodes[0].InCycle || !g.Nodes[2].External {
+			t.Errorf("%s: nodes %+v, want x in a cycle and z external", filename, g.Nodes)
+		}
+	}
+	if err := os.WriteFile(jsonFile, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readJSONGraph(jsonFile); err == nil {
+		t.Errorf("invalid JSON: no error")
+	}
+}
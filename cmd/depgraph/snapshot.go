@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Scan Snapshots ---
+
+// snapshotVersion is the current snapshot format version.
+const snapshotVersion = 1
+
+// snapshot is a saved scan result (-save-snapshot), to re-render it or compare it later
+// (-load-snapshot, setop, merge) without any API call.
+type snapshot struct {
+	Version  int                          `json:"depgraph_snapshot"` // snapshotVersion, also identifies snapshots
+	Created  time.Time                    `json:"created"`
+	Args     []string                     `json:"args"` // scanned owners, repos or directories
+	Modules  map[string]*graph.ModuleInfo `json:"modules"`
+	AllPaths []string                     `json:"all_paths"`
+}
+
+// saveSnapshot writes the scan result to filename.
+func saveSnapshot(filename string, args []string, res *scan.Result) error {
+	snap := snapshot{
+		Version:  snapshotVersion,
+		Created:  time.Now().UTC(),
+		Args:     args,
+		Modules:  res.Modules,
+		AllPaths: graph.SortedKeys(res.AllPaths),
+	}
+	content, err := encodeSnapshot(&snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, content, 0o644)
+}
+
+// encodeSnapshot returns the snapshot as indented JSON.
+func encodeSnapshot(snap *snapshot) ([]byte, error) {
+	content, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// parseSnapshot returns the snapshot in content, or nil if it's not one (e.g. -json output).
+func parseSnapshot(filename string, content []byte) (*snapshot, error) {
+	snap := &snapshot{}
+	if err := json.Unmarshal(content, snap); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if snap.Version == 0 {
+		return nil, nil
+	}
+	if snap.Version > snapshotVersion {
+		return nil, fmt.Errorf("%s: snapshot version %d is newer than supported %d", filename, snap.Version, snapshotVersion)
+	}
+	return snap, nil
+}
+
+// loadSnapshot reads a snapshot saved with -save-snapshot into res.
+func loadSnapshot(filename string, res *scan.Result) (*snapshot, error) {
+	snap, err := readSnapshot(filename)
+	if err != nil {
+		return nil, err
+	}
+	for path, info := range snap.Modules {
+		info.Path = path
+		res.Modules[path] = info
+	}
+	for _, path := range snap.AllPaths {
+		res.AllPaths[path] = true
+	}
+	return snap, nil
+}
+
+// snapshotGraph returns the graph (as -json would output it) of a snapshot, with the
+// external dependencies.
+func snapshotGraph(snap *snapshot) *render.JSONOutput {
+	allPaths := make(map[string]bool, len(snap.AllPaths))
+	for _, path := range snap.AllPaths {
+		allPaths[path] = true
+	}
+	nodesToGraph := graph.NodesToGraph(graphEnv, snap.Modules, allPaths, false)
+	return render.BuildJSON(graph.New(graphEnv, snap.Modules, nodesToGraph), nil, nil, nil)
+}
+
+// --- End Scan Snapshots ---
@@ -0,0 +1,103 @@
+// graph.js draws the /api/graph JSON of depgraph serve as an SVG, without any dependency:
+// layered top down (each module above its dependencies), external modules in grey, the ones
+// in a cycle in red. The modules matching the search box are highlighted, a double click
+// opens the module page.
+"use strict";
+
+const svgNS = "http://www.w3.org/2000/svg";
+
+function svgElement(name, attrs) {
+  const e = document.createElementNS(svgNS, name);
+  for (const [k, v] of Object.entries(attrs)) e.setAttribute(k, v);
+  return e;
+}
+
+// layers returns the layer of each module: 0 for the ones nothing depends on, otherwise one
+// more than their deepest dependent (ignoring the edges closing a cycle).
+function layers(nodes) {
+  const layer = new Map(), visiting = new Set();
+  const dependents = new Map(nodes.map(n => [n.path, []]));
+  for (const n of nodes) {
+    for (const to of Object.keys(n.deps || {})) dependents.get(to)?.push(n.path);
+  }
+  const visit = path => {
+    if (layer.has(path)) return layer.get(path);
+    if (visiting.has(path)) return -1; // cycle
+    visiting.add(path);
+    let l = 0;
+    for (const from of dependents.get(path)) l = Math.max(l, visit(from) + 1);
+    visiting.delete(path);
+    layer.set(path, l);
+    return l;
+  };
+  for (const n of nodes) visit(n.path);
+  return layer;
+}
+
+function drawGraph(container, g) {
+  const charWidth = 7, boxHeight = 22, gapX = 20, gapY = 60;
+  const byLayer = [];
+  for (const [path, l] of layers(g.nodes)) (byLayer[l] ||= []).push(path);
+  const pos = new Map();
+  let width = 0;
+  byLayer.forEach((paths, l) => {
+    let x = gapX;
+    for (const path of paths.sort()) {
+      const w = path.length * charWidth + 10;
+      pos.set(path, {x, y: gapY / 2 + l * (boxHeight + gapY), w});
+      x += w + gapX;
+    }
+    width = Math.max(width, x);
+  });
+  const svg = svgElement("svg", {width, height: byLayer.length * (boxHeight + gapY)});
+  const marker = svgElement("marker", {id: "arrow", viewBox: "0 0 10 10", refX: 10, refY: 5,
+    markerWidth: 6, markerHeight: 6, orient: "auto"});
+  marker.append(svgElement("path", {d: "M0,0 L10,5 L0,10 z", fill: "grey"}));
+  const defs = svgElement("defs", {});
+  defs.append(marker);
+  svg.append(defs);
+  for (const n of g.nodes) {
+    const from = pos.get(n.path);
+    for (const [to, version] of Object.entries(n.deps || {})) {
+      const p = pos.get(to);
+      if (!p) continue;
+      const line = svgElement("line", {x1: from.x + from.w / 2, y1: from.y + boxHeight,
+        x2: p.x + p.w / 2, y2: p.y, stroke: "grey", "marker-end": "url(#arrow)"});
+      const title = svgElement("title", {});
+      title.textContent = `${n.path} -> ${to} ${version}`;
+      line.append(title);
+      svg.append(line);
+    }
+  }
+  const boxes = new Map();
+  for (const n of g.nodes) {
+    const p = pos.get(n.path);
+    const box = svgElement("g", {transform: `translate(${p.x},${p.y})`, cursor: "pointer"});
+    box.append(svgElement("rect", {width: p.w, height: boxHeight, rx: 3, stroke: "grey",
+      fill: n.external ? "lightgrey" : (n.in_cycle ? "#ffb0b0" : "lightblue")}));
+    const label = svgElement("text", {x: 5, y: 15, "font-size": 12, "font-family": "monospace"});
+    label.textContent = n.path;
+    box.append(label);
+    box.addEventListener("dblclick", () => { location.href = "/module/" + n.path; });
+    svg.append(box);
+    boxes.set(n.path, box);
+  }
+  container.replaceChildren(svg);
+  return boxes;
+}
+
+fetch("/api/graph").then(r => r.json()).then(g => {
+  const boxes = drawGraph(document.getElementById("graph"), g);
+  document.getElementById("search").addEventListener("input", e => {
+    const q = e.target.value.toLowerCase();
+    for (const li of document.querySelectorAll("#modules li")) li.hidden = !li.textContent.toLowerCase().includes(q);
+    let first = null;
+    for (const [path, box] of boxes) {
+      const match = q !== "" && path.toLowerCase().includes(q);
+      box.querySelector("rect").setAttribute("stroke-width", match ? 3 : 1);
+      box.querySelector("rect").setAttribute("stroke", match ? "black" : "grey");
+      if (match && !first) first = box;
+    }
+    first?.scrollIntoView({block: "center", inline: "center", behavior: "smooth"});
+  });
+});
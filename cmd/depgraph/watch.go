@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Watch Mode ---
+
+// logDiffSummary logs what changed between the previous and new graphs.
+func logDiffSummary(d *graphDiff) {
+	log.Infof("Graph changed: %d modules added, %d removed, %d with changed dependencies", len(d.Added), len(d.Removed), len(d.Changed))
+	for _, path := range d.Added {
+		log.Infof("  + %s", path)
+	}
+	for _, path := range d.Removed {
+		log.Infof("  - %s", path)
+	}
+	for _, path := range graph.SortedKeys(d.Changed) {
+		for _, c := range d.Changed[path] {
+			switch {
+			case c.Old == "":
+				log.Infof("  %s: + %s %s", path, c.Path, c.New)
+			case c.New == "":
+				log.Infof("  %s: - %s %s", path, c.Path, c.Old)
+			default:
+				log.Infof("  %s: ~ %s %s -> %s", path, c.Path, c.Old, c.New)
+			}
+		}
+	}
+}
+
+// watch scans with the scanner every interval, until ctx is canceled, and rewrites the
+// output file with the graph (as -json or -format) only when it changed. The metrics of the
+// scans are served on metricsListen's /metrics, and the changes of the graph POSTed to the
+// webhook URL, if not empty, whether the output changed or not.
+func watch(ctx context.Context, scanner *rescanner, output string, interval time.Duration, metricsListen, webhook string) error {
+	conf := scanner.conf
+	prevContent, _ := os.ReadFile(output) // unchanged outputs aren't rewritten, even across runs
+	var prev *render.JSONOutput
+	var prevCycles [][]string
+	var metrics scanMetrics
+	if metricsListen != "" {
+		go serveMetrics(ctx, &metrics, metricsListen)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		p, err := scanner.scan(ctx)
+		if ctx.Err() != nil {
+			return nil // canceled (Ctrl-C, -timeout)
+		}
+		var g *graph.Graph
+		if err == nil {
+			g = p.graph()
+		}
+		metrics.record(g, time.Since(start), err)
+		if err != nil {
+			log.Errf("Scan failed, keeping the previous output: %v", err)
+		} else {
+			// The changes are found on the JSON model of the graph, not on the output: version
+			// bumps aren't in all the formats (e.g. -no-edge-labels, -topo-sort).
+			cur := render.BuildJSON(g, p.ann, nil, nil)
+			if prev != nil {
+				d := diffGraphs(prev, cur)
+				if payload := newWebhookPayload(d, newCycles(prevCycles, cyclePaths(g))); payload != nil {
+					logDiffSummary(d)
+					if webhook != "" {
+						if err := postWebhook(ctx, webhook, payload); err != nil {
+							log.Errf("Failed to notify the webhook: %v", err)
+						}
+					}
+				}
+			}
+			var buf bytes.Buffer
+			if conf.JSON {
+				err = render.WriteJSON(&buf, g, p.ann, nil, nil)
+			} else {
+				err = render.Render(conf.Format, g, &buf, conf.RenderOptions(p.ann, nil))
+			}
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(buf.Bytes(), prevContent) {
+				log.Infof("Output unchanged (%d nodes), %s not rewritten", len(g.Nodes), output)
+			} else {
+				if err := scan.WriteFileAtomic(output, buf.Bytes()); err != nil {
+					return err
+				}
+				log.Infof("Wrote the graph (%d nodes) to %s", len(g.Nodes), output)
+				prevContent = buf.Bytes()
+			}
+			prev, prevCycles = cur, cyclePaths(g)
+		}
+		log.Infof("Next scan in %v", interval)
+		select {
+		case <-ctx.Done():
+			return nil // canceled (Ctrl-C, -timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- End Watch Mode ---
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldemailly/depgraph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// TestWatch watches the test modules, changes a go.mod once the output is written, and checks
+// the change is POSTed to the webhook and the output rewritten, if the format shows it.
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string // -json if empty
+		noLabels   bool   // -no-edge-labels
+		dir        string // of the go.mod changed
+		goMod      string
+		wantText   string // in the webhook text
+		wantOutput string // in the rewritten output, "" if not rewritten
+	}{
+		{
+			name:       "version bump",
+			dir:        "b",
+			goMod:      "module example.com/b\n\ngo 1.22\n\nrequire example.com/a v1.2.0\n",
+			wantText:   "`example.com/b` requires `example.com/a` v1.1.0 -> v1.2.0",
+			wantOutput: `"example.com/a": "v1.2.0"`,
+		},
+		{
+			name:       "new dependency",
+			dir:        "c",
+			goMod:      "module example.com/c\n\ngo 1.22\n\nrequire (\n\texample.com/ext v0.1.0\n\texample.com/new v0.3.0\n)\n",
+			wantText:   "`example.com/c` now requires `example.com/new` v0.3.0",
+			wantOutput: `"path": "example.com/new"`,
+		},
+		{
+			name:       "removed dependency",
+			dir:        "b",
+			goMod:      "module example.com/b\n\ngo 1.22\n",
+			wantText:   "`example.com/b` no longer requires `example.com/a` v1.1.0",
+			wantOutput: `"cycles": 0`,
+		},
+		{
+			name:     "version bump without edge labels",
+			format:   render.FormatDOT,
+			noLabels: true,
+			dir:      "b",
+			goMod:    "module example.com/b\n\ngo 1.22\n\nrequire example.com/a v1.2.0\n",
+			wantText: "`example.com/b` requires `example.com/a` v1.1.0 -> v1.2.0",
+		},
+		{
+			name:     "version bump in topological order",
+			format:   render.FormatTopo,
+			dir:      "b",
+			goMod:    "module example.com/b\n\ngo 1.22\n\nrequire example.com/a v1.2.0\n",
+			wantText: "`example.com/b` requires `example.com/a` v1.1.0 -> v1.2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs := writeTestModules(t, testGoMods)
+			conf := depgraph.DefaultConfig()
+			conf.JSON = tt.format == ""
+			if tt.format != "" {
+				conf.Format, conf.NoEdgeLabels = tt.format, tt.noLabels
+			}
+			checkWatch(t, newTestRescanner(t, conf, &config{}, dirs), func() {
+				if err := scan.WriteFileAtomic(filepath.Join(filepath.Dir(dirs[0]), tt.dir, "go.mod"), []byte(tt.goMod)); err != nil {
+					t.Fatal(err)
+				}
+			}, tt.wantText, tt.wantOutput)
+		})
+	}
+}
+
+// TestWatchGitHub watches an owner of the fake GitHub API, with the default cache settings
+// (no -cache-ttl nor -revalidate), and checks the go.mod changes are picked up by the rescans.
+func TestWatchGitHub(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       string // of the go.mod changed
+		goMod      string
+		wantText   string
+		wantOutput string
+	}{
+		{
+			name:       "version bump",
+			repo:       "tools",
+			goMod:      "module github.com/acme/tools\n\ngo 1.22\n\nrequire (\n\tgithub.com/acme/log v1.3.0\n\tgithub.com/ext/lib v0.3.0\n)\n",
+			wantText:   "`github.com/acme/tools` requires `github.com/acme/log` v1.2.0 -> v1.3.0",
+			wantOutput: `"github.com/acme/log": "v1.3.0"`,
+		},
+		{
+			name:       "new dependency",
+			repo:       "log",
+			goMod:      "module github.com/acme/log\n\ngo 1.22\n\nrequire github.com/ext/new v0.1.0\n",
+			wantText:   "`github.com/acme/log` now requires `github.com/ext/new` v0.1.0",
+			wantOutput: `"path": "github.com/ext/new"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakegithub.Fixture()
+			defer s.Close()
+			conf := depgraph.DefaultConfig()
+			conf.JSON = true
+			checkWatch(t, newTestGitHubRescanner(t, s, conf, []string{"acme"}), func() {
+				s.SetFile("acme", tt.repo, "go.mod", tt.goMod)
+			}, tt.wantText, tt.wantOutput)
+		})
+	}
+}
+
+// checkWatch watches with the scanner, makes the change once the output is written, and checks
+// it is POSTed (once) to the webhook, with wantText, and the output rewritten, with wantOutput
+// (left as is if empty).
+func checkWatch(t *testing.T, scanner *rescanner, change func(), wantText, wantOutput string) {
+	t.Helper()
+	payloads := make(chan *webhookPayload, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("invalid webhook payload: %v", err)
+		}
+		payloads <- &p
+	}))
+	defer hook.Close()
+	output := filepath.Join(t.TempDir(), "deps.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, scanner, output, 10*time.Millisecond, "", hook.URL)
+	}()
+	waitFor(t, func() bool { _, err := os.Stat(output); return err == nil })
+	before, _ := os.ReadFile(output)
+	change()
+	select {
+	case p := <-payloads:
+		if !strings.Contains(p.Text, wantText) {
+			t.Errorf("webhook text:\n%s\nwant %q", p.Text, wantText)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no webhook call")
+	}
+	if wantOutput != "" {
+		waitFor(t, func() bool {
+			content, _ := os.ReadFile(output)
+			return strings.Contains(string(content), wantOutput)
+		})
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watch error: %v", err)
+	}
+	if after, _ := os.ReadFile(output); wantOutput == "" && !bytes.Equal(after, before) {
+		t.Errorf("output rewritten:\n%s\nwas:\n%s", after, before)
+	}
+	if len(payloads) != 0 {
+		t.Errorf("%d more webhook calls, want 1", len(payloads))
+	}
+}
+
+// waitFor waits (up to 10s) for the condition to be true.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !condition(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+	}
+}
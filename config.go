@@ -0,0 +1,273 @@
+package depgraph
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Configuration ---
+
+// Config holds all the settings of a depgraph run, the command's flags (named in the
+// comments) being bound to its fields. Start from DefaultConfig and call Validate once set.
+type Config struct {
+	// Scan
+	Local       bool         // -local: the arguments are local directories instead of GitHub owners
+	Scan        scan.Options // -all-modules, -gists, -ref, -visibility, -concurrency, -graphql, -rate-limit-wait, -revalidate, -incremental, -tree, -resume, -no-forks, -token-file
+	Manifests   string       // -manifests: comma separated manifest types to scan (go, npm, cargo)
+	Transitive  bool         // -transitive: also record the transitive dependencies
+	ErrorNodes  bool         // -error-nodes: show the modules whose go.mod failed to parse
+	Replace     string       // -replace: scan.ReplaceOff, scan.ReplaceAnnotate or scan.ReplaceRewrite
+	APICoupling bool         // -api-coupling: detect API-coupling edges (needs Local)
+
+	// Cache and API calls
+	UseCache     bool          // -use-cache
+	ClearCache   bool          // -clear-cache
+	CacheBackend string        // -cache-backend: files, bolt or the URL of a remote cache
+	CacheTTL     string        // -cache-ttl, e.g. 24h,lists=1h
+	Offline      bool          // -offline: answer everything from the cache
+	Retries      int           // -retries of the requests failing with a transient error
+	RetryDelay   time.Duration // -retry-delay before the first retry
+
+	// Graph filters
+	NoExt         bool   // -noext: exclude the external dependencies
+	IncludeModule string // -include-module regexp
+	ExcludeModule string // -exclude-module regexp
+	Root          string // -root: only the subgraph reachable from this module (path or path suffix)
+	Reverse       bool   // -reverse: with Root, the subgraph reaching it instead
+	MaxDepth      int    // -max-depth hops from the roots (Root, else the scanned modules nothing requires), -1 for no limit
+	Query         string // -query selecting the nodes, see Query
+
+	// Enrichments
+	CheckLatest     bool // -check-latest versions from the module proxy
+	CheckDeprecated bool // -check-deprecated modules
+	DepsDev         bool // -depsdev information
+
+	// Output
+	Format               string   // -format, see render.Renderers
+	TopoSort             bool     // -topo-sort, same as Format render.FormatTopo
+	Left2Right           bool     // -left2right
+	ClusterRepos         bool     // -cluster-repos
+	GoLabel              bool     // -go-label
+	ScaleNodes           bool     // -scale-nodes
+	Colors               []string // DOT fill colors of the modules by owner (config file colors), nil for the default ones
+	ForkColors           []string // same for the forks (config file fork-colors)
+	Group                string   // -group: name=owner1,owner2[;name2=...] owners sharing a color and DOT cluster
+	NoEdgeLabels         bool     // -no-edge-labels
+	ShortEdgeLabels      bool     // -short-edge-labels
+	ColorEdges           bool     // -color-edges
+	Label                string   // -label: "", render.LabelModule, render.LabelRepo or render.LabelBoth
+	Title                string   // -title of the DOT graph (with the -banner provenance lines)
+	StripLabelPrefix     string   // -strip-label-prefix: comma separated prefixes, or render.LabelPrefixAuto
+	MaxLabelWidth        int      // -max-label-width of the DOT node labels, 0 for no limit
+	JSON                 bool     // -json
+	JSONStats            bool     // -json-stats: include the run's API usage in the JSON output
+	LatestReport         bool     // -latest-report
+	ReplaceReport        bool     // -replace-report
+	ModCheck             bool     // -modcheck
+	CriticalPath         bool     // -critical-path
+	OldGoReport          string   // -old-go-report version
+	Report               string   // -report: scan.ReportLicenses
+	Flows                string   // -flows: FlowsCSV or FlowsHTML
+	Dependents           string   // -dependents of this module
+	DependentsTransitive bool     // -dependents-transitive
+	ReleasePlan          string   // -release-plan for a change of this module
+	FailOnOutdated       bool     // -fail-on-outdated
+	FailOnCycle          bool     // -fail-on-cycle
+	FailOnNewExternal    string   // -fail-on-new-external: baseline graph saved with -json (or snapshot)
+	MaxDepthAllowed      int      // -max-depth-allowed of the dependency chains, -1 for no limit
+
+	// Set by Validate
+	includeModule, excludeModule *regexp.Regexp
+	query                        *Query
+	groups                       map[string]string // owner -> -group name
+	manifests                    map[string]bool
+}
+
+// Values of Config.Flows (-flows).
+const (
+	FlowsCSV  = "csv"
+	FlowsHTML = "html"
+)
+
+// DefaultConfig returns the configuration with the defaults of the command's flags.
+func DefaultConfig() *Config {
+	return &Config{
+		Scan:            scan.Options{Visibility: scan.VisibilityPublic, Concurrency: 8, RateWait: time.Hour},
+		Manifests:       "go",
+		Replace:         scan.ReplaceOff,
+		UseCache:        true,
+		CacheBackend:    scan.CacheBackendFiles,
+		Retries:         scan.DefaultRetryAttempts,
+		RetryDelay:      scan.DefaultRetryDelay,
+		MaxDepth:        -1,
+		MaxDepthAllowed: -1,
+		Format:          render.FormatDOT,
+	}
+}
+
+// Validate checks the configuration, all in one place, without changing it.
+func (c *Config) Validate() error {
+	check := *c
+	return check.resolve()
+}
+
+// Resolve validates the configuration and applies the settings implied by others (e.g.
+// TopoSort sets Format, FailOnOutdated sets CheckLatest), to be called once all the settings
+// are set, before using it (Graph does). Resolving again is a no-op.
+func (c *Config) Resolve() error {
+	return c.resolve()
+}
+
+// resolve is Validate and Resolve: the checks, compiling the filters as they go, and the
+// implied settings.
+func (c *Config) resolve() error {
+	switch c.Replace {
+	case scan.ReplaceOff, scan.ReplaceAnnotate, scan.ReplaceRewrite:
+	default:
+		return fmt.Errorf("invalid -replace %q, expecting annotate or rewrite", c.Replace)
+	}
+	switch c.Scan.Visibility {
+	case scan.VisibilityAll, scan.VisibilityPublic, scan.VisibilityPrivate:
+	default:
+		return fmt.Errorf("invalid -visibility %q, expecting all, public or private", c.Scan.Visibility)
+	}
+	switch c.Flows {
+	case "", FlowsCSV, FlowsHTML:
+	default:
+		return fmt.Errorf("invalid -flows %q, expecting csv or html", c.Flows)
+	}
+	switch c.Report {
+	case "", scan.ReportLicenses:
+	default:
+		return fmt.Errorf("invalid -report %q, expecting licenses", c.Report)
+	}
+	if c.OldGoReport != "" && scan.GoSemver(c.OldGoReport) == "" {
+		return fmt.Errorf("invalid -old-go-report version %q, expecting e.g. 1.22", c.OldGoReport)
+	}
+	var err error
+	if c.includeModule, err = compileOptionalRegexp(c.IncludeModule); err != nil {
+		return fmt.Errorf("invalid -include-module: %w", err)
+	}
+	if c.excludeModule, err = compileOptionalRegexp(c.ExcludeModule); err != nil {
+		return fmt.Errorf("invalid -exclude-module: %w", err)
+	}
+	c.query = nil
+	if c.Query != "" {
+		if c.query, err = ParseQuery(c.Query); err != nil {
+			return fmt.Errorf("invalid -query %q: %w", c.Query, err)
+		}
+	}
+	if c.Reverse && c.Root == "" {
+		return errors.New("-reverse needs -root")
+	}
+	if c.Retries < 0 || c.RetryDelay < 0 {
+		return errors.New("-retries and -retry-delay can't be negative")
+	}
+	if c.Scan.Resume && (!c.UseCache || c.ClearCache) {
+		return errors.New("-resume needs the cache (no -use-cache=false nor -clear-cache)")
+	}
+	if c.Offline && (!c.UseCache || c.ClearCache) {
+		return errors.New("-offline needs the cache (no -use-cache=false nor -clear-cache)")
+	}
+	if _, err = scan.NewCacheBackend(c.CacheBackend); err != nil {
+		return fmt.Errorf("invalid -cache-backend: %w", err)
+	}
+	if _, err = scan.ParseCacheTTL(c.CacheTTL); err != nil {
+		return fmt.Errorf("invalid -cache-ttl: %w", err)
+	}
+	if c.APICoupling && !c.Local {
+		return errors.New("-api-coupling needs local clones (-local)")
+	}
+	if _, found := render.Renderers[c.Format]; !found {
+		return fmt.Errorf("invalid -format %q, must be one of: %s", c.Format, render.FormatNames())
+	}
+	if c.TopoSort {
+		if c.Format != render.FormatDOT && c.Format != render.FormatTopo {
+			return fmt.Errorf("-topo-sort can't be used with -format=%s", c.Format)
+		}
+		c.Format = render.FormatTopo
+	}
+	if c.manifests, err = scan.ParseManifestsFlag(c.Manifests); err != nil {
+		return fmt.Errorf("invalid -manifests: %w", err)
+	}
+	if c.FailOnOutdated {
+		c.CheckLatest = true
+	}
+	switch c.Label {
+	case "", render.LabelModule, render.LabelRepo, render.LabelBoth:
+	default:
+		return fmt.Errorf("invalid -label %q, expecting module, repo or both", c.Label)
+	}
+	if c.MaxLabelWidth < 0 {
+		return fmt.Errorf("invalid -max-label-width %d, must be 0 (no limit) or more", c.MaxLabelWidth)
+	}
+	if c.JSONStats && !c.JSON {
+		return errors.New("-json-stats needs -json")
+	}
+	if c.NoEdgeLabels && c.ShortEdgeLabels {
+		return errors.New("-no-edge-labels and -short-edge-labels are exclusive")
+	}
+	if c.groups, err = parseGroups(c.Group); err != nil {
+		return fmt.Errorf("invalid -group: %w", err)
+	}
+	if c.MaxDepthAllowed < -1 {
+		return fmt.Errorf("invalid -max-depth-allowed %d, must be -1 (no limit) or more", c.MaxDepthAllowed)
+	}
+	if c.Report == scan.ReportLicenses {
+		c.DepsDev = true // licenses of the external dependencies
+	}
+	return nil
+}
+
+// NewCache returns the cache of the (validated) configuration: backend, TTL, offline mode
+// and retries. The caller opens it, once, and closes it when done.
+func (c *Config) NewCache() (*scan.Cache, error) {
+	cache, err := scan.NewCache(c.CacheBackend, c.UseCache)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -cache-backend: %w", err)
+	}
+	if cache.TTL, err = scan.ParseCacheTTL(c.CacheTTL); err != nil {
+		return nil, fmt.Errorf("invalid -cache-ttl: %w", err)
+	}
+	cache.Offline = c.Offline
+	cache.Retries = scan.RetryPolicy{Attempts: c.Retries, Delay: c.RetryDelay}
+	return cache, nil
+}
+
+// NewResult returns an empty scan result with the configuration's scan settings.
+func (c *Config) NewResult() *scan.Result {
+	res := scan.NewResult()
+	res.Transitive = c.Transitive
+	res.ErrorNodes = c.ErrorNodes
+	res.Replace = c.Replace
+	if c.manifests != nil {
+		res.Manifests = c.manifests
+	}
+	return res
+}
+
+// RenderOptions returns the options of the graph renderers (-format) for the configuration.
+func (c *Config) RenderOptions(ann *scan.Annotations, effort *render.EffortTracker) render.Options {
+	return render.Options{NoExt: c.NoExt, Left2Right: c.Left2Right, ClusterByRepo: c.ClusterRepos, Annotations: ann, Effort: effort,
+		Colors: c.Colors, ForkColors: c.ForkColors, Groups: c.groups, NoEdgeLabels: c.NoEdgeLabels, ShortEdgeLabels: c.ShortEdgeLabels, ColorEdges: c.ColorEdges,
+		Label: c.Label, Title: c.Title, StripLabelPrefixes: splitList(c.StripLabelPrefix), MaxLabelWidth: c.MaxLabelWidth}
+}
+
+// splitList returns the non empty values of a comma separated list.
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// --- End Configuration ---
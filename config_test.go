@@ -0,0 +1,42 @@
+package depgraph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// TestValidateResolve checks Validate leaves the configuration as is, and Resolve applies the
+// implied settings, the same when called again.
+func TestValidateResolve(t *testing.T) {
+	conf := DefaultConfig()
+	conf.TopoSort, conf.FailOnOutdated, conf.Report = true, true, scan.ReportLicenses
+	conf.Query, conf.Group = "cycle", "core=acme,bob"
+	before := *conf
+	for range 2 {
+		if err := conf.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(*conf, before) {
+		t.Errorf("Validate changed the configuration:\n%+v\nwas:\n%+v", *conf, before)
+	}
+	for range 2 {
+		if err := conf.Resolve(); err != nil {
+			t.Fatal(err)
+		}
+		if conf.Format != render.FormatTopo || !conf.CheckLatest || !conf.DepsDev || conf.query == nil || conf.groups["bob"] != "core" {
+			t.Errorf("resolved %+v, want the topo format, CheckLatest, DepsDev, the query and the groups", *conf)
+		}
+	}
+	conf = DefaultConfig()
+	conf.Format, conf.TopoSort = render.FormatSQL, true
+	if err := conf.Validate(); err == nil {
+		t.Errorf("-topo-sort with -format=sql: no error")
+	}
+	if err := conf.Resolve(); err == nil || conf.Format != render.FormatSQL {
+		t.Errorf("-topo-sort with -format=sql: error %v, format %s", err, conf.Format)
+	}
+}
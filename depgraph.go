@@ -0,0 +1,57 @@
+// Package depgraph scans and graphs the Go module dependencies of GitHub owners (or local
+// directories) with a Config holding all the settings, the same ones as the depgraph
+// command's flags. See the scan, graph and render packages for the lower level functions:
+//
+//	conf := depgraph.DefaultConfig()
+//	conf.NoExt = true
+//	g, err := depgraph.Graph(ctx, client, conf, []string{"fortio", "grol-io/grol"})
+//	err = render.Render(conf.Format, g, os.Stdout, conf.RenderOptions(nil, nil))
+package depgraph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/provider"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// Graph resolves (and validates) conf, scans the GitHub owners and
+// repositories (owner/repo[@ref]) of args with the provider p (e.g. the GitHub client), or the directories of args with
+// conf.Local, and returns the graph of the modules found, filtered per the configuration.
+func Graph(ctx context.Context, p provider.Provider, conf *Config, args []string) (*graph.Graph, error) {
+	if err := conf.Resolve(); err != nil {
+		return nil, err
+	}
+	res := conf.NewResult()
+	if conf.Local {
+		for i, dir := range args {
+			if err := scan.LocalDir(ctx, dir, i, res); err != nil {
+				return nil, err
+			}
+		}
+		if conf.APICoupling {
+			scan.DetectAPICoupling(res.Modules, res.LocalDirs)
+		}
+	} else {
+		if p == nil {
+			return nil, errors.New("a provider (e.g. GitHub client) is needed to scan owners (or set Local)")
+		}
+		owners, repos, err := scan.SplitOwnersAndRepos(args)
+		if err != nil {
+			return nil, err
+		}
+		scan.OwnersAndRepos(ctx, p, owners, repos, &conf.Scan, res)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	env := graph.DefaultEnv()
+	conf.GroupOwners(res.Modules)
+	nodesToGraph := graph.NodesToGraph(env, res.Modules, res.AllPaths, conf.NoExt)
+	if err := conf.FilterNodes(res.Modules, nodesToGraph); err != nil {
+		return nil, err
+	}
+	return graph.New(env, res.Modules, nodesToGraph), nil
+}
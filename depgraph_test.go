@@ -0,0 +1,196 @@
+package depgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+	"github.com/ldemailly/depgraph/render"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+var update = flag.Bool("update", false, "Update the golden files of testdata/ with the current outputs")
+
+// Outputs of the golden tests besides the -format ones.
+const (
+	outputJSON  = "json"  // -json
+	outputGraph = "graph" // graph.Graph MarshalJSON
+)
+
+// TestGolden scans the fixture end to end (listings, go.mod fetches, graph, rendering) and
+// compares the outputs with testdata/*.golden. Run with -update to regenerate them. Each
+// output is produced serially and with parallel scans, a few times, and must be identical.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string // "" for conf.Format, else outputJSON or outputGraph
+		setup  func(conf *Config)
+	}{
+		{"dot", "", func(*Config) {}},
+		{"dot-noext", "", func(conf *Config) { conf.NoExt = true }},
+		{"dot-left2right-clusters", "", func(conf *Config) { conf.Left2Right, conf.ClusterRepos = true, true }},
+		{"topo", "", func(conf *Config) { conf.TopoSort = true }},
+		{"topo-noext", "", func(conf *Config) { conf.Format, conf.NoExt = render.FormatTopo, true }},
+		{"dot-root-reverse", "", func(conf *Config) { conf.Root, conf.Reverse = "acme/log", true }},
+		{"dot-error-nodes", "", func(conf *Config) { conf.ErrorNodes = true }},
+		{"topo-query", "", func(conf *Config) {
+			conf.Format, conf.Query = render.FormatTopo, `dependents(acme/log) & level<=1 | cycle | path~"^github\.com/ext/"`
+		}},
+		{"json", outputJSON, func(conf *Config) { conf.ErrorNodes = true }},
+		{"graph-json", outputGraph, func(*Config) {}},
+	}
+	s := fakegithub.Fixture()
+	defer s.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var first []byte
+			for i, concurrency := range []int{1, 8, 8, 1, 8} {
+				conf := DefaultConfig()
+				conf.UseCache = false
+				conf.Retries = 0
+				conf.Scan.Concurrency = concurrency
+				tt.setup(conf)
+				got := goldenOutput(t, s, conf, tt.output)
+				if i == 0 {
+					first = got
+				} else if !bytes.Equal(got, first) {
+					t.Fatalf("Output of run %d (concurrency %d) differs from the first one:\n%s\n--- first:\n%s", i+1, concurrency, got, first)
+				}
+			}
+			checkGolden(t, filepath.Join("testdata", tt.name+".golden"), first)
+		})
+	}
+}
+
+// goldenOutput scans the fake GitHub API with conf and returns the output.
+func goldenOutput(t *testing.T, s *fakegithub.Server, conf *Config, output string) []byte {
+	t.Helper()
+	client := scan.NewClientWrapper(s.Client(), nil, nil)
+	g, err := Graph(context.Background(), client, conf, []string{"acme", "bob"})
+	if err != nil {
+		t.Fatalf("Graph() error: %v", err)
+	}
+	var out bytes.Buffer
+	switch output {
+	case outputJSON:
+		err = render.WriteJSON(&out, g, nil, nil, nil)
+	case outputGraph:
+		var data []byte
+		data, err = json.MarshalIndent(g, "", "  ")
+		out.Write(append(data, '\n'))
+	default:
+		err = render.Render(conf.Format, g, &out, conf.RenderOptions(nil, nil))
+	}
+	if err != nil {
+		t.Fatalf("Output error: %v", err)
+	}
+	return out.Bytes()
+}
+
+// checkGolden compares the output with the golden file, or updates it with -update.
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("Failed to update %s: %v", golden, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read %s (run with -update to create it): %v", golden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s (run with -update if expected):\n--- got:\n%s\n--- want:\n%s", golden, got, want)
+	}
+}
+
+// TestMerge checks that merging the graphs of separate scans of the owners gives the same
+// graph as scanning them together (testdata/dot.golden).
+func TestMerge(t *testing.T) {
+	s := fakegithub.Fixture()
+	defer s.Close()
+	client := scan.NewClientWrapper(s.Client(), nil, nil)
+	var graphs []*graph.Graph
+	for _, owner := range []string{"acme", "bob"} {
+		conf := DefaultConfig()
+		conf.UseCache = false
+		conf.Retries = 0
+		g, err := Graph(context.Background(), client, conf, []string{owner})
+		if err != nil {
+			t.Fatalf("Graph(%s) error: %v", owner, err)
+		}
+		graphs = append(graphs, g)
+	}
+	var out bytes.Buffer
+	conf := DefaultConfig()
+	if err := render.Render(conf.Format, graph.Merge(nil, graphs...), &out, conf.RenderOptions(nil, nil)); err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "dot.golden"))
+	if err != nil {
+		t.Fatalf("Failed to read the golden file: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Merged graph differs from testdata/dot.golden:\n--- got:\n%s\n--- want:\n%s", out.Bytes(), want)
+	}
+}
+
+// TestGraphWithCache scans the fixture twice with each cache backend, opened once by the
+// caller as the serve and watch modes do (Graph validating conf again each time): the second
+// scan must be answered from the cache, with the same output.
+func TestGraphWithCache(t *testing.T) {
+	s := fakegithub.Fixture()
+	defer s.Close()
+	for _, backend := range []string{scan.CacheBackendFiles, "bolt"} {
+		t.Run(backend, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			conf := DefaultConfig()
+			conf.CacheBackend = backend
+			conf.Retries = 0
+			if err := conf.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			cache, err := conf.NewCache()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer cache.Close()
+			stats := conf.NewResult().Stats
+			client := scan.NewClientWrapper(s.Client(), cache, stats)
+			var outputs [2]bytes.Buffer
+			var firstMisses, firstHits int
+			for i := range outputs {
+				g, err := Graph(context.Background(), client, conf, []string{"acme", "bob"})
+				if err != nil {
+					t.Fatalf("Graph() run %d error: %v", i+1, err)
+				}
+				if err := render.Render(conf.Format, g, &outputs[i], conf.RenderOptions(nil, nil)); err != nil {
+					t.Fatalf("Render error: %v", err)
+				}
+				if i == 0 {
+					firstMisses, firstHits = stats.CacheMisses, stats.CacheHits
+				}
+			}
+			if firstMisses == 0 {
+				t.Errorf("first scan: no cache miss")
+			}
+			// All but the calls failing (e.g. bob's ListByOrg, bob being a user) are cached
+			if misses, hits := stats.CacheMisses-firstMisses, stats.CacheHits-firstHits; misses > 1 || hits != firstMisses+firstHits-misses {
+				t.Errorf("second scan: %d cache hits and %d misses, want %d hits and at most 1 miss", hits, misses, firstMisses+firstHits-misses)
+			}
+			if !bytes.Equal(outputs[0].Bytes(), outputs[1].Bytes()) {
+				t.Errorf("cached scan output differs:\n%s\n--- first:\n%s", &outputs[1], &outputs[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package depgraph
+
+import (
+	"fmt"
+	"regexp"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Graph Filters ---
+
+// FilterNodes removes from the graph's nodes (nodesToGraph, from graph.NodesToGraph) the
+// ones excluded by the configuration's filters: -include-module, -exclude-module, -root
+// (and -reverse), -max-depth and -query. The configuration must have been validated.
+func (c *Config) FilterNodes(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) error {
+	filterModules(nodesToGraph, c.includeModule, c.excludeModule)
+	if c.Root != "" {
+		if err := limitToRoot(modulesFoundInOwners, nodesToGraph, c.Root, c.Reverse); err != nil {
+			return fmt.Errorf("invalid -root: %w", err)
+		}
+	}
+	limitDepth(modulesFoundInOwners, nodesToGraph, c.MaxDepth, c.Root, c.Reverse)
+	if c.query != nil {
+		if err := c.query.Filter(modulesFoundInOwners, nodesToGraph); err != nil {
+			return fmt.Errorf("invalid -query: %w", err)
+		}
+	}
+	return nil
+}
+
+// depthEdge is a dependency of a node, hops away: 1 for the requirements, 2 for the
+// transitive ones (-transitive), as they are reached through a direct one.
+type depthEdge struct {
+	to   string
+	hops int
+}
+
+// limitDepth removes from the graph the nodes more than maxDepth dependency hops away from
+// the roots, following the requirements (-reverse: the dependents) between the graph's nodes,
+// scanned modules included, so a chain of scanned modules counts. The roots (depth 0) are
+// the -root module if set, else the scanned modules no other scanned module of the graph
+// requires (and, for cycles no other module reaches, their first module). A node reached
+// several ways gets the smallest depth. maxDepth < 0 means no limit.
+func limitDepth(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, maxDepth int, root string, reverse bool) {
+	if maxDepth < 0 {
+		return
+	}
+	adj := make(map[string][]depthEdge)
+	required := make(map[string]bool) // scanned modules required by another one
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[path]
+		if info == nil {
+			continue
+		}
+		for _, deps := range []struct {
+			versions map[string]string
+			hops     int
+		}{{info.Deps, 1}, {info.IgnoredDeps, 1}, {info.IndirectDeps, 2}} {
+			for _, dep := range graph.SortedKeys(deps.versions) {
+				if !nodesToGraph[dep] || dep == path {
+					continue
+				}
+				if modulesFoundInOwners[dep] != nil {
+					required[dep] = true
+				}
+				if reverse {
+					adj[dep] = append(adj[dep], depthEdge{path, deps.hops})
+				} else {
+					adj[path] = append(adj[path], depthEdge{dep, deps.hops})
+				}
+			}
+		}
+	}
+	depth := make(map[string]int, len(nodesToGraph))
+	// walk sets the depth of the nodes reached from start (buckets of nodes by depth, as the
+	// hops are 1 or 2), lowering the ones already reached further away.
+	walk := func(start string) {
+		buckets := [][]string{{start}}
+		depth[start] = 0
+		for d := 0; d < len(buckets); d++ {
+			for _, path := range buckets[d] {
+				if depth[path] != d {
+					continue // reached closer since
+				}
+				for _, e := range adj[path] {
+					if prev, found := depth[e.to]; found && prev <= d+e.hops {
+						continue
+					}
+					depth[e.to] = d + e.hops
+					for len(buckets) <= d+e.hops {
+						buckets = append(buckets, nil)
+					}
+					buckets[d+e.hops] = append(buckets[d+e.hops], e.to)
+				}
+			}
+		}
+	}
+	if root != "" {
+		walk(ResolveModule(root, nodesToGraph))
+	} else {
+		for _, path := range graph.SortedKeys(nodesToGraph) {
+			if modulesFoundInOwners[path] != nil && !required[path] {
+				walk(path)
+			}
+		}
+		for _, path := range graph.SortedKeys(nodesToGraph) {
+			if _, found := depth[path]; !found && modulesFoundInOwners[path] != nil {
+				walk(path) // in a cycle nothing else requires
+			}
+		}
+	}
+	removed := 0
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		if d, found := depth[path]; found && d <= maxDepth {
+			continue
+		}
+		log.LogVf("  Excluding %s (beyond -max-depth %d)", path, maxDepth)
+		delete(nodesToGraph, path)
+		removed++
+	}
+	if removed > 0 {
+		log.Infof("Removed %d nodes beyond -max-depth %d, %d left", removed, maxDepth, len(nodesToGraph))
+	}
+}
+
+// compileOptionalRegexp compiles the regexp, nil for "".
+func compileOptionalRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// filterModules removes from the graph the nodes whose module path doesn't match include
+// or matches exclude (either can be nil). Their edges are then not drawn.
+func filterModules(nodesToGraph map[string]bool, include, exclude *regexp.Regexp) {
+	if include == nil && exclude == nil {
+		return
+	}
+	removed := 0
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		if (include != nil && !include.MatchString(path)) || (exclude != nil && exclude.MatchString(path)) {
+			log.LogVf("  Excluding %s (module filters)", path)
+			delete(nodesToGraph, path)
+			removed++
+		}
+	}
+	log.Infof("Removed %d nodes by module filters, %d left", removed, len(nodesToGraph))
+}
+
+// ResolveModule returns the path (among paths) of the module given by its path or a path
+// suffix (see graph.MatchModule), "" if not found. The first matching one in sorted order wins.
+func ResolveModule(query string, paths map[string]bool) string {
+	if paths[query] {
+		return query
+	}
+	for _, path := range graph.SortedKeys(paths) {
+		if graph.MatchModule(query, path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// limitToRoot keeps only the nodes reachable from the root module (path or suffix) by
+// following the graph's edges, or, if reverse, the ones reaching it.
+func limitToRoot(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, query string, reverse bool) error {
+	root := ResolveModule(query, nodesToGraph)
+	if root == "" {
+		return fmt.Errorf("module %q is not in the graph", query)
+	}
+	adj := make(map[string][]string) // edges (reversed if reverse) between the graph's nodes
+	for path := range nodesToGraph {
+		info := modulesFoundInOwners[path]
+		if info == nil {
+			continue
+		}
+		for _, deps := range []map[string]string{info.Deps, info.IgnoredDeps, info.IndirectDeps} {
+			for dep := range deps {
+				if !nodesToGraph[dep] {
+					continue
+				}
+				if reverse {
+					adj[dep] = append(adj[dep], path)
+				} else {
+					adj[path] = append(adj[path], dep)
+				}
+			}
+		}
+	}
+	keep := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[cur] {
+			if !keep[next] {
+				keep[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for path := range nodesToGraph {
+		if !keep[path] {
+			delete(nodesToGraph, path)
+		}
+	}
+	direction := "reachable from"
+	if reverse {
+		direction = "reaching"
+	}
+	log.Infof("Kept the %d nodes %s %s", len(nodesToGraph), direction, root)
+	return nil
+}
+
+// --- End Graph Filters ---
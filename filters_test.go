@@ -0,0 +1,52 @@
+package depgraph
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// depthModules returns the scanned modules of the -max-depth tests: the chain a -> b -> c,
+// c requiring the external ext, a the external ind transitively (-transitive), and x and y
+// requiring each other (a cycle nothing requires).
+func depthModules() (map[string]*graph.ModuleInfo, map[string]bool) {
+	mods := map[string]*graph.ModuleInfo{
+		"a": {Path: "a", Deps: map[string]string{"b": "v1.0.0"}, IndirectDeps: map[string]string{"ind": "v0.1.0"}},
+		"b": {Path: "b", Deps: map[string]string{"c": "v1.0.0"}},
+		"c": {Path: "c", Deps: map[string]string{"ext": "v1.0.0"}},
+		"x": {Path: "x", Deps: map[string]string{"y": "v1.0.0"}},
+		"y": {Path: "y", Deps: map[string]string{"x": "v1.0.0"}},
+	}
+	nodes := map[string]bool{"ext": true, "ind": true}
+	for path := range mods {
+		nodes[path] = true
+	}
+	return mods, nodes
+}
+
+func TestLimitDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int
+		root     string
+		reverse  bool
+		want     []string
+	}{
+		{name: "no limit", maxDepth: -1, want: []string{"a", "b", "c", "ext", "ind", "x", "y"}},
+		{name: "roots only", maxDepth: 0, want: []string{"a", "x"}},
+		{name: "1 hop", maxDepth: 1, want: []string{"a", "b", "x", "y"}},
+		{name: "chain of scanned modules", maxDepth: 2, want: []string{"a", "b", "c", "ind", "x", "y"}},
+		{name: "3 hops", maxDepth: 3, want: []string{"a", "b", "c", "ext", "ind", "x", "y"}},
+		{name: "from the root", maxDepth: 1, root: "b", want: []string{"b", "c"}},
+		{name: "2 hops from the root", maxDepth: 2, root: "c", want: []string{"c", "ext"}},
+		{name: "reverse", maxDepth: 1, root: "c", reverse: true, want: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		mods, nodes := depthModules()
+		limitDepth(mods, nodes, tt.maxDepth, tt.root, tt.reverse)
+		if got := graph.SortedKeys(nodes); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: nodes %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
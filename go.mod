@@ -5,15 +5,26 @@ go 1.24.2
 require (
 	fortio.org/cli v1.10.0
 	fortio.org/log v1.17.2
+	github.com/gdamore/tcell/v2 v2.8.1
 	github.com/google/go-github/v62 v62.0.0
+	github.com/rivo/tview v0.42.0
+	go.etcd.io/bbolt v1.4.3
 	golang.org/x/mod v0.24.0
 	golang.org/x/oauth2 v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	fortio.org/struct2env v0.4.2 // indirect
 	fortio.org/version v1.0.4 // indirect
+	github.com/gdamore/encoding v1.0.1 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/kortschak/goroutine v1.1.2 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/crypto/x509roots/fallback v0.0.0-20250203165127-fa5273e46196 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/term v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
@@ -0,0 +1,204 @@
+package graph
+
+import "sort"
+
+// --- Graph Construction ---
+
+// These functions are pure: they don't modify their inputs and only log through the
+// (injected) env. NodesToGraph selects the nodes, New builds the Graph of them, whose cycles
+// are its strongly connected components (logged as warnings).
+
+// DetectCycles runs Kahn's algorithm on the reversed graph (dependencies first) to detect
+// cycles, logs warnings, and returns the set of nodes likely involved in cycles: the ones
+// left with a non-zero in-degree, which RefineCycles narrows down.
+func DetectCycles(env *Env, g *Graph) map[string]bool {
+	nodesInSort := g.Paths() // Sorted for deterministic processing
+
+	// --- Kahn's Algorithm for Cycle Detection ---
+	queue := []string{}
+	tempInDegree := make(map[string]int) // In the reversed graph: the number of dependencies
+	for _, node := range nodesInSort {
+		tempInDegree[node] = len(g.Dependencies(node))
+		if tempInDegree[node] == 0 {
+			queue = append(queue, node)
+		}
+	}
+
+	processedCount := 0
+	// Process the queue (Kahn's algorithm)
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		processedCount++
+
+		for _, e := range g.Dependents(u) { // For each node v that depends on u (u -> v in the reversed graph)
+			v := e.From.Path
+			tempInDegree[v]--
+			if tempInDegree[v] == 0 {
+				queue = append(queue, v) // Add newly free node
+			}
+		}
+		sort.Strings(queue) // Keep queue sorted if needed for deterministic level output (though not strictly necessary for cycle detection itself)
+	}
+
+	// Identify nodes likely in cycles (those with remaining tempInDegree > 0)
+	nodesInCycles := make(map[string]bool)
+	if processedCount < len(nodesInSort) {
+		env.logger().Warnf("Cycle detected in dependencies! Processed %d nodes, expected %d.", processedCount, len(nodesInSort))
+		env.logger().Warnf("Nodes likely involved in cycles (remaining in-degree > 0):")
+		for _, node := range nodesInSort {
+			// Use tempInDegree which was modified by Kahn's
+			if tempInDegree[node] > 0 {
+				nodesInCycles[node] = true
+				// Log the remaining degree from the *cycle detection* pass
+				env.logger().Warnf("  - %s (remaining reversed in-degree during cycle check: %d)", node, tempInDegree[node])
+			}
+		}
+	}
+	return nodesInCycles
+}
+
+// isNodeDependedOn returns true if the given node is depended on by any other node
+// *within* the set of nodes currently considered to be in cycles.
+func isNodeDependedOn(g *Graph, node string, currentNodesInCycles map[string]bool) bool {
+	for _, e := range g.Dependents(node) {
+		if currentNodesInCycles[e.From.Path] {
+			return true // Found a node within the cycle set that depends on 'node'
+		}
+	}
+	return false
+}
+
+// RefineCycles removes nodes from the cycle set that are not depended upon
+// by any *other* node *within the cycle set*. This helps refine the cycle detection
+// by removing nodes that might have a non-zero in-degree initially due to dependencies
+// from *outside* the cycle, but aren't actually part of a loop structure themselves.
+// It iteratively removes such nodes until no more can be removed.
+// The input set isn't modified, a refined copy is returned.
+//
+// Deprecated: the result still includes the nodes on a path between two cycles, which
+// aren't in a cycle themselves; use the Graph's Cycles (strongly connected components).
+func RefineCycles(env *Env, g *Graph, candidates map[string]bool) map[string]bool {
+	nodesInCycles := make(map[string]bool, len(candidates))
+	for node := range candidates {
+		nodesInCycles[node] = true
+	}
+	if len(nodesInCycles) == 0 {
+		return nodesInCycles // No cycles detected, nothing to filter
+	}
+	env.logger().LogVf("Refining cycle detection: Initial cycle candidates: %d", len(nodesInCycles))
+	changed := true
+	iteration := 0
+	for changed {
+		iteration++
+		changed = false
+		nodesToRemove := []string{}
+		// Check each node currently marked as potentially in a cycle
+		for node := range nodesInCycles {
+			// Check if this node is depended on by *any other node* currently in the `nodesInCycles` set
+			if !isNodeDependedOn(g, node, nodesInCycles) {
+				// If no other node *in the cycle set* depends on this node,
+				// it might be a sink within the potential cycle components, or only depended upon from outside.
+				// Mark it for removal from the cycle set.
+				nodesToRemove = append(nodesToRemove, node)
+				changed = true
+			}
+		}
+		if changed {
+			env.logger().LogVf("  Iteration %d: Removing %d nodes not depended upon within the cycle set: %v", iteration, len(nodesToRemove), nodesToRemove)
+			for _, node := range nodesToRemove {
+				delete(nodesInCycles, node)
+			}
+		} else {
+			env.logger().LogVf("  Iteration %d: No nodes removed, cycle set stable.", iteration)
+		}
+	}
+	env.logger().LogVf("Refined cycle detection: Final nodes considered in cycles: %d", len(nodesInCycles))
+	return nodesInCycles
+}
+
+// NodesToGraph calculates the set of nodes to include in the final graph
+// from the scanned modules and all the module paths encountered.
+func NodesToGraph(env *Env, modulesFoundInOwners map[string]*ModuleInfo, allModulePaths map[string]bool, noExt bool) map[string]bool {
+	start := env.now()
+	nodesToGraph := make(map[string]bool)
+	referencedModules := make(map[string]bool)       // Modules depended on by included nodes (non-forks or included forks)
+	forksDependingOnNonFork := make(map[string]bool) // Forks (by module path) that depend on an included non-fork
+
+	// Pass 1: Add non-forks and collect their initial dependencies
+	env.logger().Infof("Determining graph nodes: Pass 1 (Non-forks)")
+	for modPath, info := range modulesFoundInOwners {
+		if (info.Fetched && !info.IsFork) || info.Error != "" {
+			env.logger().LogVf("  Including non-fork: %s", modPath)
+			nodesToGraph[modPath] = true
+			for depPath := range info.Deps {
+				env.logger().LogVf("    References: %s", depPath)
+				referencedModules[depPath] = true
+			}
+			for depPath := range info.IndirectDeps { // only set with -transitive
+				referencedModules[depPath] = true
+			}
+		}
+	}
+	// Pass 2: Identify forks that depend on *included* non-forks
+	env.logger().Infof("Determining graph nodes: Pass 2 (Forks depending on Non-forks)")
+	for modPath, info := range modulesFoundInOwners {
+		if info.Fetched && info.IsFork {
+			for depPath := range info.Deps {
+				if nodesToGraph[depPath] { // Check if the dep is an included non-fork
+					if depInfo, found := modulesFoundInOwners[depPath]; found && !depInfo.IsFork {
+						env.logger().LogVf("  Marking fork '%s' (from %s) as depending on non-fork '%s'", modPath, info.RepoPath, depPath)
+						forksDependingOnNonFork[modPath] = true // Mark the fork module path
+						break
+					}
+				}
+			}
+		}
+	}
+	// Pass 3: Add forks if they depend on non-forks OR if their module path is referenced by a non-fork initially
+	env.logger().Infof("Determining graph nodes: Pass 3 (Include qualifying Forks)")
+	for modPath, info := range modulesFoundInOwners {
+		if info.Fetched && info.IsFork {
+			includeReason := ""
+			// Include fork if it depends on a non-fork OR if a non-fork depends on its module path
+			if forksDependingOnNonFork[modPath] {
+				includeReason = "depends on non-fork"
+			} else if referencedModules[modPath] {
+				includeReason = "referenced by included module"
+			}
+
+			if includeReason != "" {
+				env.logger().LogVf("  Including fork '%s' (from %s) because: %s", modPath, info.RepoPath, includeReason)
+				nodesToGraph[modPath] = true
+				// Add dependencies of included forks to referenced set for external inclusion check
+				for depPath := range info.Deps {
+					if !referencedModules[depPath] {
+						env.logger().LogVf("    Now referencing (from included fork): %s", depPath)
+						referencedModules[depPath] = true
+					}
+				}
+				for depPath := range info.IndirectDeps {
+					referencedModules[depPath] = true
+				}
+			}
+		}
+	}
+	// Pass 4: Add external dependencies if needed
+	env.logger().Infof("Determining graph nodes: Pass 4 (External dependencies, noExt=%v)", noExt)
+	if !noExt {
+		for modPath := range allModulePaths {
+			_, foundInOwner := modulesFoundInOwners[modPath]
+			// Add if external and referenced by an included node (non-fork or included fork)
+			if !foundInOwner && referencedModules[modPath] {
+				if !nodesToGraph[modPath] { // Avoid logging duplicates if somehow already added
+					env.logger().LogVf("  Including external: %s (referenced)", modPath)
+					nodesToGraph[modPath] = true
+				}
+			}
+		}
+	}
+	env.logger().Infof("Total nodes included in graph: %d (in %v)", len(nodesToGraph), env.now().Sub(start))
+	return nodesToGraph
+}
+
+// --- End Graph Construction ---
@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	stdlog "log"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+
+	"fortio.org/log"
+)
+
+// randomModules returns n modules with random dependencies between them, each edge
+// present with probability p. If acyclic, the edges only go from a module to a later one
+// of a random order, so there is no cycle.
+func randomModules(r *rand.Rand, n int, p float64, acyclic bool) map[string]*ModuleInfo {
+	order := r.Perm(n)
+	modules := make(map[string]*ModuleInfo, n)
+	name := func(i int) string { return fmt.Sprintf("example.com/m%d", order[i]) }
+	for i := range n {
+		modules[name(i)] = &ModuleInfo{Path: name(i), Deps: map[string]string{}, Fetched: true}
+	}
+	for i := range n {
+		for j := range n {
+			if i == j || (acyclic && j < i) || r.Float64() >= p {
+				continue
+			}
+			modules[name(i)].Deps[name(j)] = "v1.0.0"
+		}
+	}
+	return modules
+}
+
+// newTestGraph builds the graph of all the modules.
+func newTestGraph(modules map[string]*ModuleInfo) *Graph {
+	nodes := make(map[string]bool, len(modules))
+	for path := range modules {
+		nodes[path] = true
+	}
+	return New(nil, modules, nodes)
+}
+
+// reachable returns the nodes reachable from start by at least one edge, only going
+// through the nodes of within (all if nil).
+func reachable(g *Graph, start string, within map[string]bool) map[string]bool {
+	seen := make(map[string]bool)
+	todo := []string{start}
+	for len(todo) > 0 {
+		path := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, e := range g.Dependencies(path) {
+			dep := e.To
+			if seen[dep.Path] || (within != nil && !within[dep.Path]) {
+				continue
+			}
+			seen[dep.Path] = true
+			todo = append(todo, dep.Path)
+		}
+	}
+	return seen
+}
+
+func TestCyclesProperties(t *testing.T) {
+	for seed := range uint64(200) {
+		r := rand.New(rand.NewPCG(seed, 42))
+		n := 1 + r.IntN(20)
+		p := r.Float64() * 0.3
+		g := newTestGraph(randomModules(r, n, p, false))
+		inCycle := make(map[string]bool)
+		for i, c := range g.Cycles {
+			if len(c.Nodes) < 2 {
+				t.Errorf("seed %d: cycle %d has %d node(s)", seed, i, len(c.Nodes))
+			}
+			members := make(map[string]bool, len(c.Nodes))
+			for _, node := range c.Nodes {
+				members[node.Path] = true
+			}
+			for _, node := range c.Nodes {
+				if inCycle[node.Path] {
+					t.Errorf("seed %d: %s is in more than one cycle", seed, node.Path)
+				}
+				inCycle[node.Path] = true
+				// A real cycle: every member reaches all the others (and itself) through the cycle.
+				if got := reachable(g, node.Path, members); len(got) != len(members) {
+					t.Errorf("seed %d: in cycle %d, %s only reaches %d of its %d nodes", seed, i, node.Path, len(got), len(members))
+				}
+			}
+		}
+		for _, path := range g.Paths() {
+			onCycle := reachable(g, path, nil)[path]
+			if onCycle != inCycle[path] {
+				t.Errorf("seed %d: %s is on a cycle: %v, in a reported cycle: %v", seed, path, onCycle, inCycle[path])
+			}
+			if g.Nodes[path].PartOfLoop != onCycle {
+				t.Errorf("seed %d: %s PartOfLoop %v, on a cycle %v", seed, path, g.Nodes[path].PartOfLoop, onCycle)
+			}
+		}
+	}
+}
+
+func TestAcyclicHasNoCycles(t *testing.T) {
+	for seed := range uint64(200) {
+		r := rand.New(rand.NewPCG(seed, 7))
+		g := newTestGraph(randomModules(r, 1+r.IntN(30), r.Float64()*0.5, true))
+		if len(g.Cycles) != 0 {
+			t.Errorf("seed %d: acyclic graph has %d cycle(s), first %v", seed, len(g.Cycles), g.Cycles[0].Nodes)
+		}
+		for _, path := range g.Paths() {
+			if g.Nodes[path].PartOfLoop {
+				t.Errorf("seed %d: %s is PartOfLoop in an acyclic graph", seed, path)
+			}
+		}
+	}
+}
+
+func TestDefaultEnvLogsCallerLocation(t *testing.T) {
+	var buf bytes.Buffer
+	prevFileLine, prevJSON, prevLevel := log.Config.LogFileAndLine, log.Config.JSON, log.GetLogLevel()
+	log.Config.LogFileAndLine, log.Config.JSON = true, false
+	log.SetLogLevelQuiet(log.Info)
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.Config.LogFileAndLine, log.Config.JSON = prevFileLine, prevJSON
+		log.SetLogLevelQuiet(prevLevel)
+		log.SetOutput(os.Stderr)
+		stdlog.SetFlags(stdlog.LstdFlags)
+	})
+	NodesToGraph(DefaultEnv(), map[string]*ModuleInfo{}, map[string]bool{}, false)
+	out := buf.String()
+	if !strings.Contains(out, "build.go:") || strings.Contains(out, "env.go:") {
+		t.Errorf("expected the log lines to point at build.go, got:\n%s", out)
+	}
+}
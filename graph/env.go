@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// Logger is the logging used by the graph functions. Its methods have the same
+// signatures as the fortio.org/log functions.
+type Logger interface {
+	Infof(format string, rest ...any)
+	LogVf(format string, rest ...any)
+	Warnf(format string, rest ...any)
+	Errf(format string, rest ...any)
+}
+
+// Env holds the dependencies of the graph functions (logger, clock), injected so they can
+// run without global state, e.g. in tests or embedded in other services.
+// A nil *Env, or nil fields, means silent and time.Now.
+type Env struct {
+	Log Logger
+	Now func() time.Time
+}
+
+// DefaultEnv logs using fortio.org/log and uses the real clock.
+func DefaultEnv() *Env {
+	return &Env{Log: fortioLogger{}, Now: time.Now}
+}
+
+// logFuncs are the logging functions used by the graph functions.
+type logFuncs struct {
+	Infof, LogVf, Warnf, Errf func(format string, rest ...any)
+}
+
+// logger returns the logging functions of env. For the default fortioLogger they are the
+// fortio.org/log functions themselves, called directly (and not through a wrapper) so
+// that the file:line logged with -logcaller is the caller's.
+func (env *Env) logger() logFuncs {
+	if env == nil || env.Log == nil {
+		nop := func(string, ...any) {}
+		return logFuncs{nop, nop, nop, nop}
+	}
+	if _, ok := env.Log.(fortioLogger); ok {
+		return logFuncs{log.Infof, log.LogVf, log.Warnf, log.Errf}
+	}
+	return logFuncs{env.Log.Infof, env.Log.LogVf, env.Log.Warnf, env.Log.Errf}
+}
+
+func (env *Env) now() time.Time {
+	if env == nil || env.Now == nil {
+		return time.Now()
+	}
+	return env.Now()
+}
+
+// fortioLogger is the Logger backed by fortio.org/log.
+type fortioLogger struct{}
+
+func (fortioLogger) Infof(format string, rest ...any) { log.Infof(format, rest...) }
+func (fortioLogger) LogVf(format string, rest ...any) { log.LogVf(format, rest...) }
+func (fortioLogger) Warnf(format string, rest ...any) { log.Warnf(format, rest...) }
+func (fortioLogger) Errf(format string, rest ...any)  { log.Errf(format, rest...) }
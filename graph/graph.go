@@ -1,26 +1,56 @@
 package graph
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // ModuleInfo stores details about modules found in the scanned owners (orgs or users).
 type ModuleInfo struct {
-	Path               string // Module path from go.mod
-	RepoPath           string // Repository path (owner/repo) where it was found
-	IsFork             bool
-	OriginalModulePath string            // Module path from the parent repo's go.mod (if fork)
-	Owner              string            // Owner (org or user) where the module definition was found
-	OwnerIdx           int               // Index of the owner in the input list (for coloring)
-	Deps               map[string]string // path -> version
-	Fetched            bool              // Indicates if the go.mod was successfully fetched and parsed
+	Path               string              `json:"path"`               // Module path from go.mod
+	RepoPath           string              `json:"repo,omitempty"`     // Repository path (owner/repo) where it was found
+	Dir                string              `json:"dir,omitempty"`      // Directory of the go.mod within the repository ("" for the root)
+	Language           string              `json:"language,omitempty"` // "" for Go modules, else the -manifests type ("npm", "cargo"), Path is then lang:name
+	Ref                string              `json:"ref,omitempty"`      // Git ref (branch, tag) scanned, "" for the default branch
+	License            string              `json:"license,omitempty"`  // SPDX id of the repository's license detected by GitHub ("" if unknown)
+	IsFork             bool                `json:"fork,omitempty"`
+	OriginalModulePath string              `json:"fork_of,omitempty"`        // Module path from the parent repo's go.mod (if fork)
+	Owner              string              `json:"owner,omitempty"`          // Owner (org or user) where the module definition was found
+	OwnerIdx           int                 `json:"owner_idx,omitempty"`      // Index of the owner in the input list (for coloring)
+	Deps               map[string]string   `json:"deps,omitempty"`           // path -> version
+	IndirectDeps       map[string]string   `json:"indirect_deps,omitempty"`  // path -> version, transitive (non direct) dependencies (with -transitive)
+	IgnoredDeps        map[string]string   `json:"ignored_deps,omitempty"`   // path -> version, direct dependencies excluded by the ignore-edges config
+	LocalReplaces      map[string]string   `json:"local_replaces,omitempty"` // path -> local directory, from `replace path => ../dir` directives
+	Replaces           map[string]string   `json:"replaces,omitempty"`       // path -> replacement "path@version" of direct dependencies (with -replace)
+	Excludes           []string            `json:"excludes,omitempty"`       // "path@version" of the exclude directives
+	Retracts           []Retraction        `json:"retracts,omitempty"`       // retract directives
+	GoVersion          string              `json:"go_version,omitempty"`     // go directive ("" if missing)
+	Toolchain          string              `json:"toolchain,omitempty"`      // toolchain directive ("" if missing)
+	ModIssues          []string            `json:"mod_issues,omitempty"`     // go.mod hygiene issues (see -modcheck)
+	APICoupling        map[string][]string `json:"api_coupling,omitempty"`   // dep path -> dep's identifiers in this module's exported API (-api-coupling)
+	Error              string              `json:"error,omitempty"`          // go.mod parse error, for the error nodes (-error-nodes), not Fetched
+	Fetched            bool                `json:"fetched"`                  // Indicates if the go.mod was successfully fetched and parsed
 }
 
-// These are the structures we should have had.
+// Retraction is a retract directive: versions Low to High (same for a single version).
+type Retraction struct {
+	Low       string `json:"low"`
+	High      string `json:"high"`
+	Rationale string `json:"rationale,omitempty"`
+}
 
+// Node is a module of the graph: a scanned one (Module set) or an external dependency.
 type Node struct {
 	Path       string
 	Module     *ModuleInfo // nil for (ext) dependencies
-	PartOfLoop bool
-	SetID      int // 0 for first owner/org, 1 for second, etc. - determines the color (with the fork attribute of the module)
+	PartOfLoop bool        // in a dependency cycle (one of the Graph's Cycles)
+	SetID      int         // 0 for first owner/org, 1 for second, etc. - determines the color (with the fork attribute of the module)
+
+	cycle int // number (from 1) of its cycle in the Graph's Cycles, 0 if none
 }
 
+// Edge is a direct dependency between two nodes of the graph.
 type Edge struct {
 	From *Node // never nil.
 	To   *Node
@@ -28,13 +58,218 @@ type Edge struct {
 	Version string
 }
 
+// InCycle returns true if the edge is part of a cycle: both ends are in the same one (an
+// edge from a cycle to another isn't).
+func (e *Edge) InCycle() bool {
+	return e.From.cycle != 0 && e.From.cycle == e.To.cycle
+}
+
+// Graph is the dependency graph of the selected nodes (see NodesToGraph), with its direct
+// dependency edges and cycles.
 type Graph struct {
-	Nodes  map[string]*Node // path -> Node
-	Edges  []Edge           // Edges
-	Cycles []Cycle          // Cycles as they are discovered
+	Nodes   map[string]*Node       // path -> Node
+	Edges   []*Edge                // sorted by From then To path
+	Cycles  []Cycle                // strongly connected components of more than one node, sorted
+	Modules map[string]*ModuleInfo // all the scanned modules, in the graph or not (e.g. filtered out)
+
+	out map[string][]*Edge // path -> its dependencies
+	in  map[string][]*Edge // path -> its dependents
 }
 
+// Cycle is a set of nodes depending on each other (strongly connected component).
 type Cycle struct {
-	// Nodes in the cycle
+	// Nodes in the cycle, sorted by path
 	Nodes []*Node
 }
+
+// New builds the graph of the nodes of nodesToGraph, using the scanned modules for their
+// dependencies, and detects its cycles (logged as warnings).
+func New(env *Env, modulesFoundInOwners map[string]*ModuleInfo, nodesToGraph map[string]bool) *Graph {
+	g := &Graph{
+		Nodes:   make(map[string]*Node, len(nodesToGraph)),
+		Modules: modulesFoundInOwners,
+		out:     make(map[string][]*Edge),
+		in:      make(map[string][]*Edge),
+	}
+	for path := range nodesToGraph {
+		n := &Node{Path: path, Module: modulesFoundInOwners[path]}
+		if n.Module != nil {
+			n.SetID = n.Module.OwnerIdx
+		}
+		g.Nodes[path] = n
+	}
+	for _, path := range g.Paths() {
+		from := g.Nodes[path]
+		if from.Module == nil {
+			continue
+		}
+		for _, dep := range SortedKeys(from.Module.Deps) {
+			to, found := g.Nodes[dep]
+			if !found {
+				continue
+			}
+			e := &Edge{From: from, To: to, Version: from.Module.Deps[dep]}
+			g.Edges = append(g.Edges, e)
+			g.out[path] = append(g.out[path], e)
+			g.in[dep] = append(g.in[dep], e)
+		}
+	}
+	g.Cycles = g.stronglyConnected()
+	if len(g.Cycles) > 0 {
+		env.logger().Warnf("Cycle detected in dependencies! %d cycles:", len(g.Cycles))
+	}
+	for i, c := range g.Cycles {
+		paths := make([]string, 0, len(c.Nodes))
+		for _, n := range c.Nodes {
+			n.PartOfLoop, n.cycle = true, i+1
+			paths = append(paths, n.Path)
+		}
+		env.logger().Warnf("  - %s", strings.Join(paths, ", "))
+	}
+	return g
+}
+
+// MatchModule returns true if the module path is the pattern or ends with /pattern,
+// so "acme/tools" matches "github.com/acme/tools".
+func MatchModule(pattern, modulePath string) bool {
+	return modulePath == pattern || strings.HasSuffix(modulePath, "/"+pattern)
+}
+
+// SortedKeys returns the keys of a string keyed map, sorted: the deterministic iteration
+// order of the outputs.
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Plural returns "n noun" with noun in the plural form unless n is 1.
+func Plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Paths returns the paths of the nodes, sorted.
+func (g *Graph) Paths() []string {
+	return SortedKeys(g.Nodes)
+}
+
+// Has returns true if the path is a node of the graph.
+func (g *Graph) Has(path string) bool {
+	_, found := g.Nodes[path]
+	return found
+}
+
+// Set returns the paths of the nodes as a set (the nodesToGraph it was built from).
+func (g *Graph) Set() map[string]bool {
+	set := make(map[string]bool, len(g.Nodes))
+	for path := range g.Nodes {
+		set[path] = true
+	}
+	return set
+}
+
+// Dependencies returns the edges from the node to its dependencies, sorted by dependency path.
+func (g *Graph) Dependencies(path string) []*Edge {
+	return g.out[path]
+}
+
+// Dependents returns the edges from the modules depending on the node, sorted by their path.
+func (g *Graph) Dependents(path string) []*Edge {
+	return g.in[path]
+}
+
+// DependsOn returns true if there is an edge from -> to.
+func (g *Graph) DependsOn(from, to string) bool {
+	for _, e := range g.out[from] {
+		if e.To.Path == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary counts what was scanned and what the graph has, for the end of run report and the
+// JSON output.
+type Summary struct {
+	Repos    int `json:"repos"`    // repositories of the scanned modules
+	Modules  int `json:"modules"`  // scanned modules, in the graph or not
+	Nodes    int `json:"nodes"`    // modules in the graph, scanned or external
+	Forks    int `json:"forks"`    // scanned forks in the graph
+	External int `json:"external"` // external dependencies in the graph
+	Cycles   int `json:"cycles"`   // dependency cycles (strongly connected components)
+}
+
+// Summary returns the counts of the graph.
+func (g *Graph) Summary() *Summary {
+	s := &Summary{Modules: len(g.Modules), Nodes: len(g.Nodes), Cycles: len(g.Cycles)}
+	repos := make(map[string]bool)
+	for _, info := range g.Modules {
+		repos[info.RepoPath] = true
+	}
+	s.Repos = len(repos)
+	for _, n := range g.Nodes {
+		switch {
+		case n.Module == nil:
+			s.External++
+		case n.Module.IsFork:
+			s.Forks++
+		}
+	}
+	return s
+}
+
+// stronglyConnected returns the strongly connected components of more than one node
+// (Tarjan's algorithm), sorted by their first path.
+func (g *Graph) stronglyConnected() []Cycle {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles []Cycle
+	var visit func(path string)
+	visit = func(path string) {
+		index[path] = len(index)
+		lowLink[path] = index[path]
+		stack = append(stack, path)
+		onStack[path] = true
+		for _, e := range g.out[path] {
+			dep := e.To.Path
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				lowLink[path] = min(lowLink[path], lowLink[dep])
+			} else if onStack[dep] {
+				lowLink[path] = min(lowLink[path], index[dep])
+			}
+		}
+		if lowLink[path] != index[path] {
+			return
+		}
+		var c Cycle
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			c.Nodes = append(c.Nodes, g.Nodes[top])
+			if top == path {
+				break
+			}
+		}
+		if len(c.Nodes) > 1 {
+			sort.Slice(c.Nodes, func(i, j int) bool { return c.Nodes[i].Path < c.Nodes[j].Path })
+			cycles = append(cycles, c)
+		}
+	}
+	for _, path := range g.Paths() {
+		if _, seen := index[path]; !seen {
+			visit(path)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Nodes[0].Path < cycles[j].Nodes[0].Path })
+	return cycles
+}
@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// testModules returns scanned modules: app requiring lib, the external ext and the fork
+// fref, fdep a fork requiring lib, flone a fork nothing requires (requiring the external
+// ext2) and broken an error node.
+func testModules() map[string]*ModuleInfo {
+	return map[string]*ModuleInfo{
+		"example.com/app": {Path: "example.com/app", RepoPath: "acme/app", Owner: "acme", Fetched: true,
+			Deps: map[string]string{"example.com/lib": "v1.0.0", "example.com/ext": "v0.1.0", "example.com/fref": "v0.2.0"}},
+		"example.com/lib":    {Path: "example.com/lib", RepoPath: "acme/lib", Owner: "acme", Fetched: true},
+		"example.com/fref":   {Path: "example.com/fref", RepoPath: "bob/fref", Owner: "bob", OwnerIdx: 1, IsFork: true, Fetched: true},
+		"example.com/fdep":   {Path: "example.com/fdep", RepoPath: "bob/fdep", Owner: "bob", OwnerIdx: 1, IsFork: true, Fetched: true, Deps: map[string]string{"example.com/lib": "v0.9.0"}},
+		"example.com/flone":  {Path: "example.com/flone", RepoPath: "bob/flone", Owner: "bob", OwnerIdx: 1, IsFork: true, Fetched: true, Deps: map[string]string{"example.com/ext2": "v1.0.0"}},
+		"example.com/broken": {Path: "example.com/broken", RepoPath: "acme/broken", Owner: "acme", Error: "invalid go.mod"},
+	}
+}
+
+func TestNodesToGraph(t *testing.T) {
+	allPaths := map[string]bool{"example.com/ext": true, "example.com/ext2": true}
+	for path := range testModules() {
+		allPaths[path] = true
+	}
+	tests := []struct {
+		name  string
+		noExt bool
+		want  []string
+	}{
+		{
+			name: "with externals",
+			want: []string{"example.com/app", "example.com/broken", "example.com/ext", "example.com/fdep", "example.com/fref", "example.com/lib"},
+		},
+		{
+			name:  "noext",
+			noExt: true,
+			want:  []string{"example.com/app", "example.com/broken", "example.com/fdep", "example.com/fref", "example.com/lib"},
+		},
+	}
+	for _, tt := range tests {
+		got := slices.Sorted(maps.Keys(NodesToGraph(nil, testModules(), allPaths, tt.noExt)))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: nodes %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// edgePaths returns the other ends of the edges, from or to.
+func edgePaths(edges []*Edge, from bool) []string {
+	var res []string
+	for _, e := range edges {
+		if from {
+			res = append(res, e.From.Path)
+		} else {
+			res = append(res, e.To.Path)
+		}
+	}
+	return res
+}
+
+func TestGraphEdges(t *testing.T) {
+	modules := testModules()
+	modules["example.com/lib"].Deps = map[string]string{"example.com/app": "v1.1.0"} // cycle
+	g := newTestGraph(modules)
+	tests := []struct {
+		path             string
+		wantDependencies []string
+		wantDependents   []string
+		wantInCycle      bool
+	}{
+		{path: "example.com/app", wantDependencies: []string{"example.com/fref", "example.com/lib"}, wantDependents: []string{"example.com/lib"}, wantInCycle: true},
+		{path: "example.com/lib", wantDependencies: []string{"example.com/app"}, wantDependents: []string{"example.com/app", "example.com/fdep"}, wantInCycle: true},
+		{path: "example.com/fdep", wantDependencies: []string{"example.com/lib"}},
+		{path: "example.com/flone"}, // ext2 isn't a node
+		{path: "example.com/nope"},
+	}
+	for _, tt := range tests {
+		if got := edgePaths(g.Dependencies(tt.path), false); !slices.Equal(got, tt.wantDependencies) {
+			t.Errorf("%s: dependencies %v, want %v", tt.path, got, tt.wantDependencies)
+		}
+		if got := edgePaths(g.Dependents(tt.path), true); !slices.Equal(got, tt.wantDependents) {
+			t.Errorf("%s: dependents %v, want %v", tt.path, got, tt.wantDependents)
+		}
+		if n := g.Nodes[tt.path]; n != nil && n.PartOfLoop != tt.wantInCycle {
+			t.Errorf("%s: in cycle %v, want %v", tt.path, n.PartOfLoop, tt.wantInCycle)
+		}
+	}
+	if !g.DependsOn("example.com/app", "example.com/lib") || g.DependsOn("example.com/fdep", "example.com/app") {
+		t.Errorf("DependsOn doesn't match the edges")
+	}
+	want := &Summary{Repos: 6, Modules: 6, Nodes: 6, Forks: 3, Cycles: 1}
+	if got := g.Summary(); *got != *want {
+		t.Errorf("summary %+v, want %+v", got, want)
+	}
+}
+
+// TestEdgeInCycle checks the edges of 2 cycles, app <-> lib and fref <-> fdep, and the edge
+// from the first to the second (app -> fref), not in a cycle.
+func TestEdgeInCycle(t *testing.T) {
+	modules := testModules()
+	modules["example.com/lib"].Deps = map[string]string{"example.com/app": "v1.1.0"}
+	modules["example.com/fref"].Deps = map[string]string{"example.com/fdep": "v1.0.0"}
+	modules["example.com/fdep"].Deps = map[string]string{"example.com/fref": "v1.0.0"}
+	g := newTestGraph(modules)
+	if len(g.Cycles) != 2 {
+		t.Fatalf("%d cycles, want 2", len(g.Cycles))
+	}
+	want := map[string]bool{
+		"example.com/app -> example.com/lib":   true,
+		"example.com/lib -> example.com/app":   true,
+		"example.com/app -> example.com/fref":  false, // between the cycles
+		"example.com/fdep -> example.com/fref": true,
+		"example.com/fref -> example.com/fdep": true,
+	}
+	for _, e := range g.Edges {
+		edge := e.From.Path + " -> " + e.To.Path
+		if wantInCycle, found := want[edge]; !found {
+			t.Errorf("unexpected edge %s", edge)
+		} else if e.InCycle() != wantInCycle {
+			t.Errorf("%s: in cycle %v, want %v", edge, e.InCycle(), wantInCycle)
+		}
+	}
+}
+
+func TestGraphJSON(t *testing.T) {
+	modules := testModules()
+	modules["example.com/lib"].Deps = map[string]string{"example.com/app": "v1.1.0"}
+	g := newTestGraph(modules)
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Graph
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !slices.Equal(got.Paths(), g.Paths()) || len(got.Edges) != len(g.Edges) || len(got.Cycles) != len(g.Cycles) {
+		t.Errorf("round trip: %d nodes, %d edges, %d cycles, want %d, %d, %d",
+			len(got.Nodes), len(got.Edges), len(got.Cycles), len(g.Nodes), len(g.Edges), len(g.Cycles))
+	}
+	for _, path := range g.Paths() {
+		if !slices.Equal(edgePaths(got.Dependencies(path), false), edgePaths(g.Dependencies(path), false)) {
+			t.Errorf("round trip: %s dependencies %v, want %v", path, edgePaths(got.Dependencies(path), false), edgePaths(g.Dependencies(path), false))
+		}
+	}
+	for i, e := range got.Edges {
+		if e.InCycle() != g.Edges[i].InCycle() || e.Version != g.Edges[i].Version {
+			t.Errorf("round trip: edge %s -> %s %s, in cycle %v, want %s, %v", e.From.Path, e.To.Path, e.Version, e.InCycle(), g.Edges[i].Version, g.Edges[i].InCycle())
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"version": 2, "nodes": []}`), &got); err == nil {
+		t.Errorf("unsupported version: no error")
+	}
+}
+
+func TestSortedKeysPlural(t *testing.T) {
+	if got := SortedKeys(map[string]int{"b": 1, "c": 2, "a": 3}); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("SortedKeys = %v, want [a b c]", got)
+	}
+	for n, want := range map[int]string{0: "0 modules", 1: "1 module", 2: "2 modules"} {
+		if got := Plural(n, "module"); got != want {
+			t.Errorf("Plural(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
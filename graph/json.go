@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// --- Graph Serialization ---
+
+// jsonVersion is the version of the graph JSON format, increased on incompatible changes.
+const jsonVersion = 1
+
+// jsonEdge is an edge in the graph JSON format.
+type jsonEdge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Version string `json:"version,omitempty"`
+}
+
+// jsonGraph is the graph JSON format: the nodes and the scanned modules are what the graph
+// is built from, the edges and cycles are derived from them (recomputed when loading).
+type jsonGraph struct {
+	Version int                    `json:"version"`
+	Nodes   []string               `json:"nodes"`            // sorted paths
+	Edges   []jsonEdge             `json:"edges,omitempty"`  // sorted by from then to path
+	Cycles  [][]string             `json:"cycles,omitempty"` // sorted paths of each cycle
+	Modules map[string]*ModuleInfo `json:"modules"`          // all the scanned modules, in the graph or not
+}
+
+// MarshalJSON writes the graph as JSON, in a stable order (nodes, edges, cycles and
+// modules sorted by path) so the output of the same graph can be diffed.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	out := jsonGraph{Version: jsonVersion, Nodes: g.Paths(), Modules: g.Modules}
+	if out.Modules == nil {
+		out.Modules = map[string]*ModuleInfo{}
+	}
+	for _, e := range g.Edges {
+		out.Edges = append(out.Edges, jsonEdge{From: e.From.Path, To: e.To.Path, Version: e.Version})
+	}
+	for _, c := range g.Cycles {
+		paths := make([]string, 0, len(c.Nodes))
+		for _, n := range c.Nodes {
+			paths = append(paths, n.Path)
+		}
+		out.Cycles = append(out.Cycles, paths)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads a graph written by MarshalJSON: the graph is rebuilt (see New) from
+// its nodes and modules, silently, so the edges and cycles are always consistent with them.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var in jsonGraph
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Version != jsonVersion {
+		return fmt.Errorf("unsupported graph JSON version %d, expecting %d", in.Version, jsonVersion)
+	}
+	if in.Modules == nil {
+		in.Modules = make(map[string]*ModuleInfo)
+	}
+	nodesToGraph := make(map[string]bool, len(in.Nodes))
+	for _, path := range in.Nodes {
+		nodesToGraph[path] = true
+	}
+	*g = *New(nil, in.Modules, nodesToGraph)
+	return nil
+}
+
+// --- End Graph Serialization ---
@@ -0,0 +1,83 @@
+package graph
+
+import "sort"
+
+// --- Graph Merging ---
+
+// preferModule returns true if cand should replace cur, both defining the same module path
+// in different scans. The same rules as a single scan apply: a module read from a go.mod
+// beats an error node (-error-nodes), a non-fork beats a fork. Otherwise the first one wins.
+func preferModule(cur, cand *ModuleInfo) bool {
+	if (cur.Error != "") != (cand.Error != "") {
+		return cur.Error != ""
+	}
+	return cur.IsFork && !cand.IsFork
+}
+
+// MergeModules returns the union of the scanned modules of several scans (e.g. of different
+// owners, providers or runs), see preferModule for the modules found in more than one.
+// The inputs aren't modified: the modules are copied, with their OwnerIdx renumbered so
+// the owners of the different scans keep distinct colors (the same owner gets the same one).
+func MergeModules(env *Env, scans ...map[string]*ModuleInfo) map[string]*ModuleInfo {
+	res := make(map[string]*ModuleInfo)
+	ownerIdx := make(map[string]int) // owner -> merged OwnerIdx
+	for i, modules := range scans {
+		paths := SortedKeys(modules)
+		// New owners are numbered in the order of their index in this scan, then of their name.
+		idx := make(map[string]int)
+		for _, path := range paths {
+			if _, found := ownerIdx[modules[path].Owner]; !found {
+				idx[modules[path].Owner] = modules[path].OwnerIdx
+			}
+		}
+		owners := SortedKeys(idx)
+		sort.SliceStable(owners, func(a, b int) bool { return idx[owners[a]] < idx[owners[b]] })
+		for _, owner := range owners {
+			ownerIdx[owner] = len(ownerIdx)
+		}
+		for _, path := range paths {
+			info := *modules[path]
+			info.Path = path
+			info.OwnerIdx = ownerIdx[info.Owner]
+			cur := res[path]
+			if cur == nil {
+				res[path] = &info
+				continue
+			}
+			kept := cur
+			if preferModule(cur, &info) {
+				kept = &info
+				res[path] = &info
+			}
+			if location(cur) != location(&info) {
+				env.logger().Infof("Module %s found in %s and in %s (scan %d), keeping the one from %s",
+					path, location(cur), location(&info), i+1, location(kept))
+			}
+		}
+	}
+	return res
+}
+
+// location returns the repository and directory of a module, for logging.
+func location(info *ModuleInfo) string {
+	if info.Dir == "" {
+		return info.RepoPath
+	}
+	return info.RepoPath + "/" + info.Dir
+}
+
+// Merge returns the union of several graphs: their nodes, and their modules merged with
+// MergeModules. The edges and cycles are recomputed, so cycles spanning the scans show.
+func Merge(env *Env, graphs ...*Graph) *Graph {
+	scans := make([]map[string]*ModuleInfo, 0, len(graphs))
+	nodesToGraph := make(map[string]bool)
+	for _, g := range graphs {
+		scans = append(scans, g.Modules)
+		for path := range g.Nodes {
+			nodesToGraph[path] = true
+		}
+	}
+	return New(env, MergeModules(env, scans...), nodesToGraph)
+}
+
+// --- End Graph Merging ---
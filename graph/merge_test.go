@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    *ModuleInfo
+		second   *ModuleInfo
+		wantRepo string
+	}{
+		{
+			name:     "first wins",
+			first:    &ModuleInfo{RepoPath: "acme/m", Owner: "acme", Fetched: true},
+			second:   &ModuleInfo{RepoPath: "bob/m", Owner: "bob", Fetched: true},
+			wantRepo: "acme/m",
+		},
+		{
+			name:     "non-fork over fork",
+			first:    &ModuleInfo{RepoPath: "acme/m", Owner: "acme", IsFork: true, Fetched: true},
+			second:   &ModuleInfo{RepoPath: "bob/m", Owner: "bob", Fetched: true},
+			wantRepo: "bob/m",
+		},
+		{
+			name:     "module over error node",
+			first:    &ModuleInfo{RepoPath: "acme/m", Owner: "acme", Error: "invalid go.mod"},
+			second:   &ModuleInfo{RepoPath: "bob/m", Owner: "bob", IsFork: true, Fetched: true},
+			wantRepo: "bob/m",
+		},
+	}
+	for _, tt := range tests {
+		first := map[string]*ModuleInfo{"example.com/m": tt.first}
+		second := map[string]*ModuleInfo{"example.com/m": tt.second, "example.com/other": {RepoPath: "bob/other", Owner: "bob", Fetched: true}}
+		merged := MergeModules(nil, first, second)
+		if got := merged["example.com/m"].RepoPath; got != tt.wantRepo {
+			t.Errorf("%s: merged module from %s, want %s", tt.name, got, tt.wantRepo)
+		}
+		if len(merged) != 2 || merged["example.com/other"].OwnerIdx != 1 || merged["example.com/other"].Path != "example.com/other" {
+			t.Errorf("%s: merged %+v, want example.com/other with the second owner index", tt.name, merged)
+		}
+		if tt.first.Path != "" || tt.second.OwnerIdx != 0 {
+			t.Errorf("%s: inputs modified", tt.name)
+		}
+	}
+}
+
+// TestMerge merges the graphs of two scans requiring each other's modules: the cycle spanning
+// the scans shows in the merged graph.
+func TestMerge(t *testing.T) {
+	a := New(nil, map[string]*ModuleInfo{
+		"example.com/a": {Path: "example.com/a", RepoPath: "acme/a", Owner: "acme", Fetched: true, Deps: map[string]string{"example.com/b": "v1.0.0"}},
+	}, map[string]bool{"example.com/a": true, "example.com/b": true})
+	b := New(nil, map[string]*ModuleInfo{
+		"example.com/b": {Path: "example.com/b", RepoPath: "bob/b", Owner: "bob", Fetched: true, Deps: map[string]string{"example.com/a": "v1.1.0"}},
+	}, map[string]bool{"example.com/a": true, "example.com/b": true, "example.com/c": true})
+	if len(a.Cycles) != 0 || len(b.Cycles) != 0 {
+		t.Fatalf("cycles before merging: %v, %v", a.Cycles, b.Cycles)
+	}
+	g := Merge(nil, a, b)
+	if got, want := g.Paths(), []string{"example.com/a", "example.com/b", "example.com/c"}; !slices.Equal(got, want) {
+		t.Errorf("merged nodes %v, want %v", got, want)
+	}
+	if len(g.Edges) != 2 || len(g.Cycles) != 1 || !g.Nodes["example.com/a"].PartOfLoop {
+		t.Errorf("merged edges %v, cycles %v, want the 2 edges of a cycle", g.Edges, g.Cycles)
+	}
+	if g.Nodes["example.com/b"].Module.OwnerIdx != 1 {
+		t.Errorf("example.com/b owner index %d, want 1 (second scan)", g.Nodes["example.com/b"].Module.OwnerIdx)
+	}
+}
@@ -0,0 +1,61 @@
+package depgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Owner Groups ---
+
+// parseGroups parses the -group value: name=owner1,owner2 groups separated by ";", e.g.
+// "platform=acme-core,acme-infra;apps=acme-apps". Returns owner -> group name.
+func parseGroups(s string) (map[string]string, error) {
+	groups := make(map[string]string)
+	for _, def := range strings.Split(s, ";") {
+		if def = strings.TrimSpace(def); def == "" {
+			continue
+		}
+		name, owners, found := strings.Cut(def, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.TrimSpace(owners) == "" {
+			return nil, fmt.Errorf("%q, expecting name=owner1,owner2", def)
+		}
+		for _, owner := range strings.Split(owners, ",") {
+			owner, _ = scan.ParseOwner(strings.TrimSpace(owner)) // org:acme is acme
+			if owner == "" {
+				continue
+			}
+			if prev, dup := groups[owner]; dup && prev != name {
+				return nil, fmt.Errorf("owner %s in both the %s and %s groups", owner, prev, name)
+			}
+			groups[owner] = name
+		}
+	}
+	return groups, nil
+}
+
+// GroupOwners gives the modules of the owners of each -group the same OwnerIdx, so they share
+// a color: the lowest one of the group's owners (the first scanned).
+func (c *Config) GroupOwners(modulesFoundInOwners map[string]*graph.ModuleInfo) {
+	if len(c.groups) == 0 {
+		return
+	}
+	groupIdx := make(map[string]int)
+	for _, info := range modulesFoundInOwners {
+		if name, found := c.groups[info.Owner]; found {
+			if idx, seen := groupIdx[name]; !seen || info.OwnerIdx < idx {
+				groupIdx[name] = info.OwnerIdx
+			}
+		}
+	}
+	for _, info := range modulesFoundInOwners {
+		if name, found := c.groups[info.Owner]; found {
+			info.OwnerIdx = groupIdx[name]
+		}
+	}
+}
+
+// --- End Owner Groups ---
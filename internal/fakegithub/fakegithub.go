@@ -0,0 +1,229 @@
+// Package fakegithub is a fake GitHub REST API (httptest server) serving canned repository
+// listings and file contents (go.mod...), with their ETags (answering the conditional
+// requests with 304 Not Modified), to test the scan end to end without network.
+package fakegithub
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// Repo is a repository served by the fake API.
+type Repo struct {
+	Name     string
+	Fork     bool
+	Parent   string // owner/name of the parent repository of a fork
+	Archived bool
+	Files    map[string]string // path (e.g. "go.mod", "sub/go.mod") -> content
+}
+
+// Server is the fake GitHub API. Owners are organizations, unless added with AddUser.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	repos    map[string][]*Repo // owner -> its repositories, in the order added
+	users    map[string]bool    // owners that are users rather than organizations
+	requests int
+}
+
+// New starts a fake GitHub API server, to Close when done.
+func New() *Server {
+	s := &Server{repos: make(map[string][]*Repo), users: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/{owner}/repos", s.listRepos(false))
+	mux.HandleFunc("GET /users/{owner}/repos", s.listRepos(true))
+	mux.HandleFunc("GET /repos/{owner}/{repo}", s.getRepo)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/contents/{path...}", s.getContents)
+	s.Server = httptest.NewServer(s.count(mux))
+	return s
+}
+
+// AddRepo adds a repository to the (organization) owner.
+func (s *Server) AddRepo(owner string, repo *Repo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repos[owner] = append(s.repos[owner], repo)
+}
+
+// SetFile sets (adds or changes) the content of a file of the repository owner/name.
+func (s *Server) SetFile(owner, name, path, content string) {
+	repo := s.find(owner, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo.Files[path] = content
+}
+
+// AddUser makes the owner a user instead of an organization.
+func (s *Server) AddUser(owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[owner] = true
+}
+
+// Requests returns the number of API requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Client returns a go-github client using the fake API.
+func (s *Server) Client() *github.Client {
+	client := github.NewClient(s.Server.Client())
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return client
+}
+
+// Fixture returns a fake GitHub API with test owners: acme (an organization) and
+// bob (a user), with dependencies between them, a cycle, a fork, an external dependency
+// and repositories without or with an invalid go.mod.
+func Fixture() *Server {
+	s := New()
+	s.AddRepo("acme", &Repo{Name: "log", Files: map[string]string{
+		"go.mod": "module github.com/acme/log\n\ngo 1.22\n",
+	}})
+	s.AddRepo("acme", &Repo{Name: "tools", Files: map[string]string{
+		"go.mod": "module github.com/acme/tools\n\ngo 1.22\n\nrequire (\n\tgithub.com/acme/log v1.2.0\n\tgithub.com/ext/lib v0.3.0\n)\n",
+	}})
+	s.AddRepo("acme", &Repo{Name: "app", Files: map[string]string{
+		"go.mod": "module github.com/acme/app\n\ngo 1.23\n\nrequire (\n\tgithub.com/acme/tools v0.5.0\n\tgithub.com/bob/util v1.0.0\n)\n",
+	}})
+	s.AddRepo("acme", &Repo{Name: "docs"})
+	s.AddRepo("acme", &Repo{Name: "old", Archived: true, Files: map[string]string{
+		"go.mod": "module github.com/acme/old\n",
+	}})
+	s.AddRepo("acme", &Repo{Name: "broken", Files: map[string]string{
+		"go.mod": "module\n",
+	}})
+	s.AddUser("bob")
+	s.AddRepo("bob", &Repo{Name: "util", Files: map[string]string{
+		"go.mod": "module github.com/bob/util\n\ngo 1.21\n\nrequire github.com/bob/extra v0.1.0\n",
+	}})
+	s.AddRepo("bob", &Repo{Name: "extra", Files: map[string]string{
+		"go.mod": "module github.com/bob/extra\n\ngo 1.21\n\nrequire github.com/bob/util v1.0.0\n",
+	}})
+	s.AddRepo("bob", &Repo{Name: "log", Fork: true, Parent: "acme/log", Files: map[string]string{
+		"go.mod": "module github.com/acme/log\n\ngo 1.22\n",
+	}})
+	return s
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// find returns the repository owner/name, nil if not found.
+func (s *Server) find(owner, name string) *Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, repo := range s.repos[owner] {
+		if repo.Name == name {
+			return repo
+		}
+	}
+	return nil
+}
+
+// toGitHub returns the API representation of the repository, with its parent's for forks.
+func (s *Server) toGitHub(owner string, repo *Repo) *github.Repository {
+	res := &github.Repository{
+		Name:     github.String(repo.Name),
+		FullName: github.String(owner + "/" + repo.Name),
+		Owner:    &github.User{Login: github.String(owner)},
+		Fork:     github.Bool(repo.Fork),
+		Archived: github.Bool(repo.Archived),
+	}
+	if parentOwner, parentName, found := strings.Cut(repo.Parent, "/"); found {
+		if parent := s.find(parentOwner, parentName); parent != nil {
+			res.Parent = s.toGitHub(parentOwner, parent)
+		}
+	}
+	return res
+}
+
+// listRepos serves the (single page) listing of the organizations, or of the users.
+func (s *Server) listRepos(users bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner := r.PathValue("owner")
+		s.mu.Lock()
+		repos, found := s.repos[owner]
+		found = found && s.users[owner] == users
+		s.mu.Unlock()
+		if !found {
+			notFound(w)
+			return
+		}
+		list := make([]*github.Repository, 0, len(repos))
+		for _, repo := range repos {
+			list = append(list, s.toGitHub(owner, repo))
+		}
+		writeJSON(w, r, list)
+	}
+}
+
+func (s *Server) getRepo(w http.ResponseWriter, r *http.Request) {
+	owner := r.PathValue("owner")
+	repo := s.find(owner, r.PathValue("repo"))
+	if repo == nil {
+		notFound(w)
+		return
+	}
+	writeJSON(w, r, s.toGitHub(owner, repo))
+}
+
+func (s *Server) getContents(w http.ResponseWriter, r *http.Request) {
+	repo := s.find(r.PathValue("owner"), r.PathValue("repo"))
+	if repo == nil {
+		notFound(w)
+		return
+	}
+	path := r.PathValue("path")
+	s.mu.Lock()
+	content, found := repo.Files[path]
+	s.mu.Unlock()
+	if !found {
+		notFound(w)
+		return
+	}
+	writeJSON(w, r, &github.RepositoryContent{
+		Type:     github.String("file"),
+		Path:     github.String(path),
+		Encoding: github.String("base64"),
+		Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+	})
+}
+
+// writeJSON writes the response with its ETag, or a 304 Not Modified if the request's
+// If-None-Match is that ETag, as GitHub does.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	data, _ := json.Marshal(v)
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(data))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func notFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+}
@@ -0,0 +1,54 @@
+// Package provider defines the sources of the repositories scanned by depgraph: GitHub (the
+// cached client of the depgraph command), and any other forge or fake source implementing
+// Provider (e.g. GitLab, Gitea, a local file tree, or an in-memory one in tests).
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Repo is a repository, with what the scan uses of it.
+type Repo struct {
+	Owner         string // owner (org or user) login
+	Name          string
+	DefaultBranch string // "" if unknown
+	Fork          bool
+	Archived      bool
+	Private       bool
+	License       string    // SPDX id of the repository's license, "" if unknown
+	PushedAt      time.Time // last push, zero if unknown
+	Parent        *Repo     // the repository forked, for forks (only set by GetRepo, nil if unknown)
+}
+
+// FullName returns owner/name.
+func (r *Repo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// Provider is a source of repositories and of their files.
+type Provider interface {
+	// ListRepos lists the repositories of owner (an organization or a user, possibly typed as
+	// org:name or user:name) with the given visibility (the -visibility flag: "all", "public"
+	// or "private", see the scan package's Visibility constants), calling page for each page
+	// (batch) of them, so they can be scanned while the next ones are listed.
+	ListRepos(ctx context.Context, owner, visibility string, page func(repos []*Repo)) error
+	// GetFileContents returns the content of the file at path in the repository, at ref ("" for
+	// the default branch). Returns nil, nil if there is no such file (or ref).
+	GetFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+	// GetRepo returns the repository's details, with its Parent for forks.
+	GetRepo(ctx context.Context, owner, repo string) (*Repo, error)
+}
+
+// TreeLister is implemented by the providers able to list the files of a repository in one
+// call (e.g. GitHub's git trees), instead of trying to get each of them: used to find the
+// go.mod files of monorepos (-all-modules) and to skip the missing files (-tree).
+type TreeLister interface {
+	Provider
+	// GoModPaths returns the paths of all the go.mod files of the repository at ref (a branch
+	// or tag, not ""), nil if the repository or ref isn't found.
+	GoModPaths(ctx context.Context, owner, repo, ref string) ([]string, error)
+	// RootFiles returns the files depgraph reads (go.mod, go.sum, manifests) that are at the
+	// root of the repository at ref (not "").
+	RootFiles(ctx context.Context, owner, repo, ref string) ([]string, error)
+}
@@ -0,0 +1,390 @@
+package depgraph
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+	"golang.org/x/mod/semver"
+)
+
+// --- Graph Queries ---
+
+// Query is a parsed -query expression selecting the nodes of the graph, e.g.
+// `dependents(acme/log) & level<=2 & !external`. Terms:
+//
+//	dependents(m), deps(m)      m and the nodes depending on it / it depends on, directly or not
+//	level OP n                  dependency hops from the m of the dependents()/deps() terms
+//	path|owner|repo|license OP  string comparisons, OP one of = != ~ (regexp match)
+//	go OP version               go directive comparisons, OP one of = != < <= > >=
+//	external, fork, cycle, error
+//
+// combined with ! (not), & (and), | (or) and parentheses, & binding tighter than |.
+type Query struct {
+	expr  queryExpr
+	walks []*walkTerm // the dependents() and deps() terms, which level uses
+	level bool        // level is used
+}
+
+// queryNode is what the terms are evaluated on.
+type queryNode struct {
+	node  *graph.Node
+	level int // -1 when not reached by the walks
+}
+
+type queryExpr interface {
+	match(n *queryNode) bool
+}
+
+type (
+	notExpr struct{ e queryExpr }
+	andExpr struct{ a, b queryExpr }
+	orExpr  struct{ a, b queryExpr }
+)
+
+func (e notExpr) match(n *queryNode) bool { return !e.e.match(n) }
+func (e andExpr) match(n *queryNode) bool { return e.a.match(n) && e.b.match(n) }
+func (e orExpr) match(n *queryNode) bool  { return e.a.match(n) || e.b.match(n) }
+
+// walkTerm is dependents(m) or deps(m): the nodes reached (with their distance) from m.
+type walkTerm struct {
+	reverse bool // dependents
+	module  string
+	reached map[string]int // set by Query.Filter
+}
+
+func (t *walkTerm) match(n *queryNode) bool {
+	_, found := t.reached[n.node.Path]
+	return found
+}
+
+// funcTerm is a term evaluated by a function of the node.
+type funcTerm func(n *queryNode) bool
+
+func (t funcTerm) match(n *queryNode) bool { return t(n) }
+
+// queryFlags are the boolean terms.
+var queryFlags = map[string]funcTerm{
+	"external": func(n *queryNode) bool { return n.node.Module == nil },
+	"fork":     func(n *queryNode) bool { return n.node.Module != nil && n.node.Module.IsFork },
+	"cycle":    func(n *queryNode) bool { return n.node.PartOfLoop },
+	"error":    func(n *queryNode) bool { return n.node.Module != nil && n.node.Module.Error != "" },
+}
+
+// queryStrings are the string attributes ("" for the external nodes but the path).
+var queryStrings = map[string]func(n *graph.Node) string{
+	"path":  func(n *graph.Node) string { return n.Path },
+	"owner": func(n *graph.Node) string { return moduleField(n, func(m *graph.ModuleInfo) string { return m.Owner }) },
+	"repo": func(n *graph.Node) string {
+		return moduleField(n, func(m *graph.ModuleInfo) string { return m.RepoPath })
+	},
+	"license": func(n *graph.Node) string {
+		return moduleField(n, func(m *graph.ModuleInfo) string { return m.License })
+	},
+}
+
+func moduleField(n *graph.Node, field func(m *graph.ModuleInfo) string) string {
+	if n.Module == nil {
+		return ""
+	}
+	return field(n.Module)
+}
+
+// compare returns whether cmp (-1, 0, 1: a compared to b) satisfies op.
+func compare(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+// ParseQuery parses a query expression, see Query.
+func ParseQuery(s string) (*Query, error) {
+	p := &queryParser{tokens: tokenizeQuery(s), q: &Query{}}
+	expr, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.q.level && len(p.q.walks) == 0 {
+		return nil, errors.New("level needs a dependents() or deps() term")
+	}
+	p.q.expr = expr
+	return p.q, nil
+}
+
+// queryOperators are the operator tokens, longest first.
+var queryOperators = []string{"!=", "<=", ">=", "=", "~", "<", ">", "!", "&", "|", "(", ")"}
+
+// tokenizeQuery splits the query into operators, words (module paths, values...) and
+// "quoted" strings, kept with their quotes.
+func tokenizeQuery(s string) []string {
+	var tokens []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"') + 2
+			if end == 1 {
+				end = len(s) // unterminated, reported by the parser
+			}
+			tokens = append(tokens, s[:end])
+			s = s[end:]
+			continue
+		}
+		op := ""
+		for _, o := range queryOperators {
+			if strings.HasPrefix(s, o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, op)
+			s = s[len(op):]
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(`"!=<>~&|()`, r) })
+		if end < 0 {
+			end = len(s)
+		}
+		tokens = append(tokens, s[:end])
+		s = s[end:]
+	}
+	return tokens
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+	q      *Query
+}
+
+// next returns the next token, "" at the end.
+func (p *queryParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expecting %q, got %q", token, got)
+	}
+	return nil
+}
+
+// value returns the next token as a value (unquoted).
+func (p *queryParser) value() (string, error) {
+	v := p.next()
+	switch {
+	case v == "" || slices.Contains(queryOperators, v):
+		return "", fmt.Errorf("expecting a value, got %q", v)
+	case v[0] != '"':
+		return v, nil
+	case len(v) < 2 || v[len(v)-1] != '"':
+		return "", fmt.Errorf("unterminated string %s", v)
+	}
+	return v[1 : len(v)-1], nil
+}
+
+func (p *queryParser) or() (queryExpr, error) {
+	a, err := p.and()
+	for err == nil && p.peek() == "|" {
+		p.next()
+		var b queryExpr
+		if b, err = p.and(); err == nil {
+			a = orExpr{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *queryParser) and() (queryExpr, error) {
+	a, err := p.unary()
+	for err == nil && p.peek() == "&" {
+		p.next()
+		var b queryExpr
+		if b, err = p.unary(); err == nil {
+			a = andExpr{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *queryParser) unary() (queryExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		e, err := p.unary()
+		return notExpr{e}, err
+	case "(":
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return p.term()
+}
+
+// term parses a function, comparison or flag term.
+func (p *queryParser) term() (queryExpr, error) {
+	name := p.next()
+	switch name {
+	case "dependents", "deps":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		module, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		t := &walkTerm{reverse: name == "dependents", module: module}
+		p.q.walks = append(p.q.walks, t)
+		return t, p.expect(")")
+	case "level", "go":
+		return p.ordered(name)
+	}
+	if attr := queryStrings[name]; attr != nil {
+		op := p.next()
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "=", "!=":
+			return funcTerm(func(n *queryNode) bool { return (attr(n.node) == value) == (op == "=") }), nil
+		case "~":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, err
+			}
+			return funcTerm(func(n *queryNode) bool { return re.MatchString(attr(n.node)) }), nil
+		}
+		return nil, fmt.Errorf("expecting =, != or ~ after %s, got %q", name, op)
+	}
+	if flag := queryFlags[name]; flag != nil {
+		return flag, nil
+	}
+	if name == "" {
+		return nil, errors.New("unexpected end")
+	}
+	return nil, fmt.Errorf("unknown term %q", name)
+}
+
+// ordered parses the level and go comparisons.
+func (p *queryParser) ordered(name string) (queryExpr, error) {
+	op := p.next()
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("expecting a comparison after %s, got %q", name, op)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if name == "level" {
+		p.q.level = true
+		level, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level %q", value)
+		}
+		return funcTerm(func(n *queryNode) bool { return n.level >= 0 && compare(op, n.level-level) }), nil
+	}
+	version := scan.GoSemver(value)
+	if version == "" {
+		return nil, fmt.Errorf("invalid go version %q", value)
+	}
+	return funcTerm(func(n *queryNode) bool {
+		v := ""
+		if n.node.Module != nil {
+			v = scan.GoSemver(n.node.Module.GoVersion)
+		}
+		return v != "" && compare(op, semver.Compare(v, version))
+	}), nil
+}
+
+// walk returns the nodes reached from the module following the dependencies (or the
+// dependents if reverse), with their distance.
+func walk(g *graph.Graph, from string, reverse bool) map[string]int {
+	reached := map[string]int{from: 0}
+	queue := []string{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		edges := g.Dependencies(cur)
+		if reverse {
+			edges = g.Dependents(cur)
+		}
+		for _, e := range edges {
+			next := e.To.Path
+			if reverse {
+				next = e.From.Path
+			}
+			if _, found := reached[next]; !found {
+				reached[next] = reached[cur] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// Filter removes from the graph's nodes (nodesToGraph) the ones not matching the query.
+func (q *Query) Filter(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) error {
+	g := graph.New(nil, modulesFoundInOwners, nodesToGraph)
+	for _, t := range q.walks {
+		from := ResolveModule(t.module, nodesToGraph)
+		if from == "" {
+			return fmt.Errorf("module %q is not in the graph", t.module)
+		}
+		t.reached = walk(g, from, t.reverse)
+	}
+	removed := 0
+	for _, path := range g.Paths() {
+		n := &queryNode{node: g.Nodes[path], level: -1}
+		for _, t := range q.walks {
+			if d, found := t.reached[path]; found && (n.level < 0 || d < n.level) {
+				n.level = d
+			}
+		}
+		if !q.expr.match(n) {
+			log.LogVf("  Excluding %s (-query)", path)
+			delete(nodesToGraph, path)
+			removed++
+		}
+	}
+	log.Infof("Removed %d nodes not matching the query, %d left", removed, len(nodesToGraph))
+	return nil
+}
+
+// --- End Graph Queries ---
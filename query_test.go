@@ -0,0 +1,108 @@
+package depgraph
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// queryModules returns the scanned modules of the query tests: app requiring lib and the
+// external ext, lib and the fork util requiring each other (a cycle).
+func queryModules() map[string]*graph.ModuleInfo {
+	return map[string]*graph.ModuleInfo{
+		"example.com/app": {Path: "example.com/app", Owner: "acme", License: "MIT", GoVersion: "1.22",
+			Deps: map[string]string{"example.com/lib": "v1.0.0", "example.com/ext": "v0.1.0"}},
+		"example.com/lib": {Path: "example.com/lib", Owner: "acme", License: "Apache-2.0", GoVersion: "1.21",
+			Deps: map[string]string{"example.com/util": "v1.0.0"}},
+		"example.com/util": {Path: "example.com/util", Owner: "bob", IsFork: true, GoVersion: "1.20",
+			Deps: map[string]string{"example.com/lib": "v1.0.0"}},
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string // nodes left, by name
+	}{
+		{"and before or", "fork | cycle & owner=acme", []string{"lib", "util"}},
+		{"parentheses", "(fork | cycle) & owner=acme", []string{"lib"}},
+		{"not", "!external", []string{"app", "lib", "util"}},
+		{"double not", "!!external", []string{"ext"}},
+		{"not parentheses", "!(fork | external)", []string{"app", "lib"}},
+		{"not binds tighter than and", "!fork & cycle", []string{"lib"}},
+		{"quoted regexp", `path~"^example\.com/(app|lib)$"`, []string{"app", "lib"}},
+		{"quoted value", `license = "Apache-2.0"`, []string{"lib"}},
+		{"not equal", "license!=MIT", []string{"ext", "lib", "util"}},
+		{"level equal", "deps(app) & level=1", []string{"ext", "lib"}},
+		{"level greater", "deps(app) & level>=2", []string{"util"}},
+		{"level less", "dependents(lib) & level<1", []string{"lib"}},
+		{"level of the closest walk", "(dependents(lib) | deps(util)) & level=1", []string{"app"}},
+		{"level not reached", "deps(lib) & level!=0", []string{"util"}},
+		{"go version", "go>=1.21", []string{"app", "lib"}},
+		{"go version less", "go<1.21 | go=1.22", []string{"app", "util"}},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: ParseQuery(%q) error: %v", tt.name, tt.query, err)
+			continue
+		}
+		nodes := map[string]bool{"example.com/ext": true}
+		for path := range queryModules() {
+			nodes[path] = true
+		}
+		if err := q.Filter(queryModules(), nodes); err != nil {
+			t.Errorf("%s: Filter error: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, path := range slices.Sorted(maps.Keys(nodes)) {
+			got = append(got, strings.TrimPrefix(path, "example.com/"))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: %q selected %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr string
+	}{
+		{"", "unexpected end"},
+		{"fork &", "unexpected end"},
+		{"unknown", `unknown term "unknown"`},
+		{"fork & archived", `unknown term "archived"`},
+		{"(fork | cycle", `expecting ")", got ""`},
+		{"fork)", `unexpected ")"`},
+		{"(fork))", `unexpected ")"`},
+		{"deps(app", `expecting ")", got ""`},
+		{"owner", "expecting a value"},
+		{"owner<acme", "expecting =, != or ~ after owner"},
+		{"owner=", "expecting a value"},
+		{`owner="acme`, "unterminated string"},
+		{`path~"("`, "error parsing regexp"},
+		{"level=1", "level needs a dependents() or deps() term"},
+		{"deps(app) & level~1", "expecting a comparison after level"},
+		{"deps(app) & level<=x", `invalid level "x"`},
+		{"go>=abc", `invalid go version "abc"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseQuery(%q) error %v, want %q", tt.query, err, tt.wantErr)
+		}
+	}
+	q, err := ParseQuery("deps(nope)")
+	if err != nil {
+		t.Fatalf("ParseQuery error: %v", err)
+	}
+	if err := q.Filter(queryModules(), map[string]bool{"example.com/app": true}); err == nil {
+		t.Errorf("Filter with a module not in the graph: no error")
+	}
+}
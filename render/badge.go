@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Badges ---
+
+// Badge kinds, the name of their SVG file (with .svg) for each module.
+const (
+	BadgeDependents = "dependents" // "3 internal dependents"
+	BadgeExternals  = "externals"  // "depends on 12 externals"
+	BadgeCycle      = "cycle"      // "in cycle!" or "no cycle"
+)
+
+// BadgeKinds are the badges of each module, in order.
+var BadgeKinds = []string{BadgeDependents, BadgeExternals, BadgeCycle}
+
+// Badge is a small SVG image (flat, shields.io style) of a module's dependency information,
+// to embed in its README.
+type Badge struct {
+	Label   string
+	Message string
+	Color   string // of the message part
+}
+
+// NewBadge returns the badge of the kind for the scanned module of the graph.
+func NewBadge(g *graph.Graph, path, kind string) (*Badge, error) {
+	n := g.Nodes[path]
+	if n == nil || n.Module == nil {
+		return nil, fmt.Errorf("module %q not scanned or not in the graph", path)
+	}
+	b := &Badge{Label: "depgraph", Color: "#007ec6"}
+	switch kind {
+	case BadgeDependents:
+		b.Message = graph.Plural(len(g.Dependents(path)), "internal dependent")
+	case BadgeExternals:
+		externals := 0
+		for _, e := range g.Dependencies(path) {
+			if e.To.Module == nil {
+				externals++
+			}
+		}
+		b.Message = "depends on " + graph.Plural(externals, "external")
+	case BadgeCycle:
+		b.Message, b.Color = "no cycle", "#4c1"
+		if n.PartOfLoop {
+			b.Message, b.Color = "in cycle!", "#e05d44"
+		}
+	default:
+		return nil, fmt.Errorf("unknown badge %q (%v)", kind, BadgeKinds)
+	}
+	return b, nil
+}
+
+// badgeTextWidth estimates the width in pixels of the text in 11px Verdana, with padding.
+func badgeTextWidth(s string) int {
+	return 7*len([]rune(s)) + 10
+}
+
+// WriteSVG writes the badge as an SVG image.
+func (b *Badge) WriteSVG(w io.Writer) error {
+	lw, mw := badgeTextWidth(b.Label), badgeTextWidth(b.Message)
+	label, msg := html.EscapeString(b.Label), html.EscapeString(b.Message)
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+
+		`<title>%s: %s</title><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`+"\n",
+		lw+mw, label, msg, label, msg, lw, lw, mw, html.EscapeString(b.Color), lw/2, label, lw+mw/2, msg)
+	return err
+}
+
+// --- End Badges ---
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBadges(t *testing.T) {
+	tests := []struct {
+		path, kind  string
+		wantMessage string
+		wantColor   string
+		wantErr     bool
+	}{
+		{path: "example.com/a", kind: BadgeDependents, wantMessage: "2 internal dependents", wantColor: "#007ec6"},
+		{path: "example.com/c", kind: BadgeDependents, wantMessage: "0 internal dependents", wantColor: "#007ec6"},
+		{path: "example.com/o'b", kind: BadgeDependents, wantMessage: "1 internal dependent", wantColor: "#007ec6"},
+		{path: "example.com/a", kind: BadgeExternals, wantMessage: "depends on 1 external", wantColor: "#007ec6"},
+		{path: "example.com/c", kind: BadgeExternals, wantMessage: "depends on 0 externals", wantColor: "#007ec6"},
+		{path: "example.com/a", kind: BadgeCycle, wantMessage: "in cycle!", wantColor: "#e05d44"},
+		{path: "example.com/c", kind: BadgeCycle, wantMessage: "no cycle", wantColor: "#4c1"},
+		{path: "example.com/ext", kind: BadgeCycle, wantErr: true}, // not scanned
+		{path: "example.com/nope", kind: BadgeCycle, wantErr: true},
+		{path: "example.com/a", kind: "stars", wantErr: true},
+	}
+	g := testGraph()
+	for _, tt := range tests {
+		b, err := NewBadge(g, tt.path, tt.kind)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewBadge(%s, %s) error %v, want error %v", tt.path, tt.kind, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if b.Message != tt.wantMessage || b.Color != tt.wantColor {
+			t.Errorf("NewBadge(%s, %s) = %q %s, want %q %s", tt.path, tt.kind, b.Message, b.Color, tt.wantMessage, tt.wantColor)
+		}
+		var buf bytes.Buffer
+		if err := b.WriteSVG(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if svg := buf.String(); !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, "<title>depgraph: "+tt.wantMessage+"</title>") {
+			t.Errorf("NewBadge(%s, %s) SVG: %s", tt.path, tt.kind, svg)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Cypher Output ---
+
+// cypherRenderer is the Neo4j output (-format=cypher): Cypher statements loading the graph,
+// one per line, e.g. with cypher-shell < deps.cypher. The modules are (:Module {path}) nodes,
+// also labeled External for the external dependencies, and the dependencies DEPENDS_ON
+// relationships with their version. Loading a new scan updates the same nodes (MERGE),
+// replacing their properties and External label, and replaces the dependencies of the scanned
+// modules: removed requirements don't linger.
+type cypherRenderer struct{}
+
+func (cypherRenderer) Render(g *graph.Graph, w io.Writer, opts Options) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "CREATE CONSTRAINT depgraph_module_path IF NOT EXISTS FOR (m:Module) REQUIRE m.path IS UNIQUE;")
+	for _, path := range g.Paths() {
+		n := g.Nodes[path]
+		props := []string{"path: " + cypherString(path), "in_cycle: " + fmt.Sprint(n.PartOfLoop)}
+		prop := func(name, value string) {
+			if value != "" {
+				props = append(props, name+": "+cypherString(value))
+			}
+		}
+		if info := n.Module; info != nil {
+			prop("repo", info.RepoPath)
+			prop("dir", info.Dir)
+			prop("owner", info.Owner)
+			prop("fork_of", info.OriginalModulePath)
+			prop("go_version", info.GoVersion)
+			prop("license", info.License)
+			prop("error", info.Error)
+			props = append(props, "fork: "+fmt.Sprint(info.IsFork))
+		}
+		if latest := opts.Annotations.LatestFor(path); latest != nil {
+			prop("latest", latest.Latest)
+		}
+		prop("deprecated", opts.Annotations.Deprecation(path))
+		label := "REMOVE m:External"
+		if n.Module == nil {
+			label = "SET m:External"
+		}
+		fmt.Fprintf(bw, "MERGE (m:Module {path: %s}) SET m = {%s} %s;\n", cypherString(path), strings.Join(props, ", "), label)
+	}
+	for _, path := range g.Paths() {
+		if g.Nodes[path].Module != nil { // the dependencies of the external ones aren't known
+			fmt.Fprintf(bw, "MATCH (:Module {path: %s})-[r:DEPENDS_ON]->() DELETE r;\n", cypherString(path))
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "MATCH (a:Module {path: %s}), (b:Module {path: %s}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.version = %s;\n",
+			cypherString(e.From.Path), cypherString(e.To.Path), cypherString(e.Version))
+	}
+	return bw.Flush()
+}
+
+// cypherString returns s as a single quoted Cypher string literal.
+func cypherString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\r", `\r`).Replace(s) + "'"
+}
+
+// --- End Cypher Output ---
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/scan"
+)
+
+func TestCypherOutput(t *testing.T) {
+	ann := &scan.Annotations{
+		Latest:     map[string]*scan.ProxyModuleInfo{"example.com/ext": {Latest: "v0.2.0", Found: true}},
+		Deprecated: map[string]string{"example.com/ext": "use example.com/ext2"},
+	}
+	checkRender(t, []renderTest{
+		{
+			name:   "cypher",
+			format: FormatCypher,
+			opts:   Options{Annotations: ann},
+			want: []string{
+				"CREATE CONSTRAINT depgraph_module_path IF NOT EXISTS FOR (m:Module) REQUIRE m.path IS UNIQUE;",
+				"MERGE (m:Module {path: 'example.com/a'}) SET m = {path: 'example.com/a', in_cycle: true, repo: 'acme/a', owner: 'acme', go_version: '1.22', license: 'MIT', fork: false} REMOVE m:External;",
+				`MERGE (m:Module {path: 'example.com/o\'b'}) SET m = {path: 'example.com/o\'b', in_cycle: true, repo: 'acme/b', owner: 'acme', fork_of: 'example.com/up', fork: true} REMOVE m:External;`,
+				"MERGE (m:Module {path: 'example.com/ext'}) SET m = {path: 'example.com/ext', in_cycle: false, latest: 'v0.2.0', deprecated: 'use example.com/ext2'} SET m:External;",
+				"MATCH (:Module {path: 'example.com/a'})-[r:DEPENDS_ON]->() DELETE r;",
+				`MATCH (a:Module {path: 'example.com/a'}), (b:Module {path: 'example.com/o\'b'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.version = 'v1.0.0';`,
+			},
+		},
+		{
+			name:     "cypher without annotations",
+			format:   FormatCypher,
+			want:     []string{"MERGE (m:Module {path: 'example.com/ext'}) SET m = {path: 'example.com/ext', in_cycle: false} SET m:External;"},
+			dontWant: []string{"latest:", "deprecated:", "(:Module {path: 'example.com/ext'})-[r:DEPENDS_ON]->() DELETE r"},
+		},
+	})
+	// The dependencies of the scanned modules are replaced: deleted, then merged again.
+	var buf strings.Builder
+	if err := Render(FormatCypher, testGraph(), &buf, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if del, merge := strings.LastIndex(buf.String(), "DELETE r;"), strings.Index(buf.String(), "MERGE (a)-[r:DEPENDS_ON]"); del < 0 || merge < del {
+		t.Errorf("dependencies merged before being deleted:\n%s", &buf)
+	}
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Release Effort Weights ---
+
+// EffortTracker computes the (estimated) release effort per topological level, from the
+// weights of the configuration file. A nil *EffortTracker means no effort annotations.
+type EffortTracker struct {
+	weights    map[string]float64 // module path (or suffix, like ignore-edges) -> weight
+	def        float64            // weight of the scanned modules not listed
+	cumulative float64
+}
+
+// NewEffortTracker returns nil when there are no effort weights.
+func NewEffortTracker(weights map[string]float64, def float64) *EffortTracker {
+	if len(weights) == 0 {
+		return nil
+	}
+	return &EffortTracker{weights: weights, def: def}
+}
+
+// Of returns the effort weight of a module: 0 for external modules (we don't release them),
+// the most specific matching configured weight, else the default.
+func (e *EffortTracker) Of(modPath string, modulesFoundInOwners map[string]*graph.ModuleInfo) float64 {
+	if _, internal := modulesFoundInOwners[modPath]; !internal {
+		return 0
+	}
+	best, weight := "", e.def
+	for pattern, w := range e.weights {
+		if graph.MatchModule(pattern, modPath) && len(pattern) > len(best) {
+			best, weight = pattern, w
+		}
+	}
+	return weight
+}
+
+// levelSuffix returns the " (effort X, cumulative Y)" suffix for a topological level and
+// updates the cumulative effort. "" when there are no effort weights.
+func (e *EffortTracker) levelSuffix(levelNodes []string, modulesFoundInOwners map[string]*graph.ModuleInfo) string {
+	if e == nil || len(levelNodes) == 0 {
+		return ""
+	}
+	level := 0.
+	for _, node := range levelNodes {
+		level += e.Of(node, modulesFoundInOwners)
+	}
+	e.cumulative += level
+	return fmt.Sprintf(" (effort %s, cumulative %s)", FormatEffort(level), FormatEffort(e.cumulative))
+}
+
+// printTotal prints the total effort to w, if there are effort weights.
+func (e *EffortTracker) printTotal(w io.Writer) {
+	if e != nil {
+		fmt.Fprintf(w, "Total effort: %s\n", FormatEffort(e.cumulative))
+	}
+}
+
+func FormatEffort(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// --- End Release Effort Weights ---
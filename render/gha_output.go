@@ -0,0 +1,113 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- GitHub Actions Output ---
+
+// StepSummaryEnv is the environment variable set by GitHub Actions to the job summary file.
+const StepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// ghaRenderer is the GitHub Actions output (-format=gha): workflow command annotations of the
+// graph's issues, and the job summary (Markdown), appended to the GITHUB_STEP_SUMMARY file in
+// Actions, written after the annotations otherwise.
+type ghaRenderer struct{}
+
+func (ghaRenderer) Render(g *graph.Graph, w io.Writer, opts Options) error {
+	bw := bufio.NewWriter(w)
+	issues := writeGHAAnnotations(bw, g, opts.Annotations)
+	file := os.Getenv(StepSummaryEnv)
+	if file == "" {
+		fmt.Fprintln(bw)
+		writeGHASummary(bw, g, issues)
+		return bw.Flush()
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	sw := bufio.NewWriter(f)
+	writeGHASummary(sw, g, issues)
+	err = sw.Flush()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ghaEscape escapes a workflow command message (% and line breaks).
+func ghaEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// writeGHAAnnotations writes the annotations (::error, ::warning, ::notice workflow commands)
+// of the graph's issues: invalid go.mod, cycles, retracted and, with ann, deprecated and
+// outdated requirements. Returns their number by level.
+func writeGHAAnnotations(w io.Writer, g *graph.Graph, ann *scan.Annotations) map[string]int {
+	issues := make(map[string]int)
+	annotate := func(level, title, format string, args ...any) {
+		issues[level]++
+		fmt.Fprintf(w, "::%s title=%s::%s\n", level, title, ghaEscape(fmt.Sprintf(format, args...)))
+	}
+	for _, path := range g.Paths() {
+		if info := g.Nodes[path].Module; info != nil && info.Error != "" {
+			annotate("error", "Invalid go.mod", "%s (%s): %s", path, info.RepoPath, info.Error)
+		}
+	}
+	for _, c := range g.Cycles {
+		paths := make([]string, 0, len(c.Nodes))
+		for _, n := range c.Nodes {
+			paths = append(paths, n.Path)
+		}
+		annotate("warning", "Dependency cycle", "cycle detected between %s", strings.Join(paths, ", "))
+	}
+	for _, e := range g.Edges {
+		switch {
+		case scan.Retraction(e.To.Module, e.Version) != nil:
+			annotate("warning", "Retracted requirement", "%s requires %s %s, which is retracted", e.From.Path, e.To.Path, e.Version)
+		case ann.Deprecation(e.To.Path) != "":
+			annotate("warning", "Deprecated requirement", "%s requires %s, deprecated: %s", e.From.Path, e.To.Path, ann.Deprecation(e.To.Path))
+		case scan.IsOutdated(e.Version, ann.LatestFor(e.To.Path)):
+			annotate("notice", "Outdated requirement", "%s requires %s %s, latest is %s", e.From.Path, e.To.Path, e.Version, ann.LatestFor(e.To.Path).Latest)
+		}
+	}
+	return issues
+}
+
+// writeGHASummary writes the job summary: the graph's counts, issues and cycles, as Markdown.
+func writeGHASummary(w io.Writer, g *graph.Graph, issues map[string]int) {
+	s := g.Summary()
+	fmt.Fprintln(w, "## Dependency graph")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Repositories | Modules | Nodes | Dependencies | Forks | External | Cycles |")
+	fmt.Fprintln(w, "|---:|---:|---:|---:|---:|---:|---:|")
+	fmt.Fprintf(w, "| %d | %d | %d | %d | %d | %d | %d |\n", s.Repos, s.Modules, s.Nodes, len(g.Edges), s.Forks, s.External, s.Cycles)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d errors, %d warnings, %d notices.\n", issues["error"], issues["warning"], issues["notice"])
+	if len(g.Cycles) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "### Cycles")
+	fmt.Fprintln(w)
+	for i, c := range g.Cycles {
+		paths := make([]string, 0, len(c.Nodes))
+		for _, n := range c.Nodes {
+			paths = append(paths, "`"+n.Path+"`")
+		}
+		fmt.Fprintf(w, "%d. %s\n", i+1, strings.Join(paths, ", "))
+	}
+}
+
+// --- End GitHub Actions Output ---
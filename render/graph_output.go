@@ -0,0 +1,702 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+	"golang.org/x/mod/semver"
+)
+
+// --- Color Palettes ---
+var (
+	orgNonForkColors  = []string{"lightblue", "lightgreen", "lightsalmon", "lightgoldenrodyellow", "lightpink"}
+	orgForkColors     = []string{"steelblue", "darkseagreen", "coral", "darkkhaki", "mediumvioletred"}
+	externalColor     = "lightgrey"
+	cycleColor        = "red"       // Color for node border in cycles
+	indirectEdgeColor = "grey50"    // Color for transitive (indirect) dependency edges
+	ignoredEdgeColor  = "grey70"    // Color for dependency edges excluded by the ignore-edges config
+	localReplaceColor = "orangered" // Color for dependency edges replaced by a local path (warning)
+	outdatedColor     = "orange"    // Color for dependency edges requiring an older version than the latest (-check-latest)
+	retractedColor    = "red3"      // Label color for dependency edges requiring a retracted version
+	apiCouplingColor  = "purple"    // Color for API-coupling edges (dependency's types in the exported API)
+	errorNodeColor    = "mistyrose" // Fill color for the modules whose go.mod failed to parse (-error-nodes)
+	errorBorderColor  = "red3"      // Border color of those error nodes
+)
+
+// --- End Color Palettes ---
+
+// --- Graph Generation Logic ---
+
+// generateDotOutput generates the DOT graph representation of g and writes it to w (buffered).
+// The options' Annotations hold the optional (module proxy, deps.dev...) information shown in
+// tooltips.
+func generateDotOutput(w io.Writer, g *graph.Graph, opts Options) error {
+	ann := opts.Annotations
+	bw := bufio.NewWriter(w)
+	var dependentCounts map[string]int // for -scale-nodes
+	if ann != nil && ann.ScaleNodes {
+		dependentCounts = internalDependentCounts(g)
+	}
+
+	// --- Generate DOT Output ---
+	fmt.Fprintln(bw, "digraph dependencies {")
+	rankDir := "TB"
+	if opts.Left2Right {
+		rankDir = "LR"
+	}
+	fmt.Fprintf(bw, "  rankdir=\"%s\";\n", rankDir)
+	fmt.Fprintln(bw, "  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];")
+	fmt.Fprintln(bw, "  edge [fontname=\"Helvetica\", fontsize=10];") // Default edge style
+	if opts.Title != "" {
+		title := strings.ReplaceAll(strings.ReplaceAll(opts.Title, "\"", "\\\""), "\n", "\\n")
+		fmt.Fprintf(bw, "  labelloc=\"t\";\n  fontname=\"Helvetica\";\n  label=\"%s\";\n", title)
+	}
+
+	// Define nodes with appropriate colors and labels
+	fmt.Fprintln(bw, "\n  // Node Definitions")
+	sortedNodes := g.Paths()
+	nodeDefs := make(map[string]string, len(sortedNodes)) // nodePath -> DOT node definition line
+	stripPrefixes := opts.labelPrefixes(g)
+	for _, nodePath := range sortedNodes {
+		node := g.Nodes[nodePath]
+		label := nodePath // Default label is the node path (module path)
+		color := externalColor
+		nodeAttrs := []string{}
+
+		info, foundInScanned := node.Module, node.Module != nil
+		if foundInScanned {
+			ownerIdx := node.SetID
+			color = opts.ownerColor(ownerIdx, info.IsFork)
+			label = opts.nodeLabel(nodePath, info)
+		} else if opts.NoExt {
+			continue // Skip external nodes with -noext
+		}
+		if foundInScanned && info.Error != "" {
+			// Broken module definition: distinct style, error in the tooltip
+			color = errorNodeColor
+			label = fmt.Sprintf("%s\\n(invalid go.mod)", nodePath)
+			nodeAttrs = append(nodeAttrs, "style=\"rounded,filled,dashed\"", fmt.Sprintf("color=\"%s\"", errorBorderColor))
+		}
+
+		fullLabel := label
+		label = shortenLabel(label, stripPrefixes, opts.MaxLabelWidth)
+		label += ann.LabelSuffix(nodePath, info)
+		if ann.Deprecation(nodePath) != "" {
+			color = scan.DeprecatedColor
+		}
+
+		// Escape label for DOT format AFTER generating it.
+		// Only escape double quotes. The \\n from Sprintf should remain as \n.
+		escapedLabel := strings.ReplaceAll(label, "\"", "\\\"")
+		nodeAttrs = append(nodeAttrs, fmt.Sprintf("label=\"%s\"", escapedLabel))
+		nodeAttrs = append(nodeAttrs, fmt.Sprintf("fillcolor=\"%s\"", color))
+		tooltip := ann.Tooltip(nodePath)
+		if foundInScanned && info.Error != "" {
+			tooltip = info.Error
+		}
+		if foundInScanned && info.License != "" {
+			tooltip = strings.TrimPrefix(tooltip+"\nlicense: "+info.License, "\n")
+		}
+		if directives := scan.DirectivesTooltip(info); directives != "" {
+			tooltip = strings.TrimPrefix(tooltip+"\n"+directives, "\n")
+		}
+		if !strings.HasPrefix(label, fullLabel) { // shortened: full path in the tooltip
+			tooltip = strings.TrimSuffix(nodePath+"\n"+tooltip, "\n")
+		}
+		if tooltip != "" {
+			escapedTooltip := strings.ReplaceAll(strings.ReplaceAll(tooltip, "\"", "\\\""), "\n", "\\n")
+			nodeAttrs = append(nodeAttrs, fmt.Sprintf("tooltip=\"%s\"", escapedTooltip))
+		}
+
+		if n := dependentCounts[nodePath]; n > 0 {
+			// Heavily relied on modules stand out (cycle border width takes precedence)
+			scale := math.Log2(float64(1 + n))
+			nodeAttrs = append(nodeAttrs, fmt.Sprintf("fontsize=%.1f", 14+4*scale))
+			if !node.PartOfLoop {
+				nodeAttrs = append(nodeAttrs, fmt.Sprintf("penwidth=%.1f", 1+scale))
+			}
+		}
+
+		// Highlight border if node is part of a refined cycle
+		if node.PartOfLoop {
+			log.LogVf("Highlighting cycle node in DOT: %s", nodePath)
+			nodeAttrs = append(nodeAttrs, fmt.Sprintf("color=\"%s\"", cycleColor)) // Set border color
+			nodeAttrs = append(nodeAttrs, "penwidth=2")
+		}
+
+		nodeDefs[nodePath] = fmt.Sprintf("\"%s\" [%s];", nodePath, strings.Join(nodeAttrs, ", "))
+	}
+	printNodeDefinitions(bw, g, sortedNodes, nodeDefs, opts.ClusterByRepo, opts.Groups)
+
+	fmt.Fprintln(bw, "\n  // Edges (Dependencies)")
+	// Print edges
+	for _, sourceModPath := range sortedNodes {
+		info := g.Nodes[sourceModPath].Module
+		if info == nil {
+			continue
+		}
+
+		for _, e := range g.Dependencies(sourceModPath) {
+			depPath := e.To.Path
+			version := opts.edgeVersion(e.Version)
+			outdated := false
+			if pi := ann.LatestFor(depPath); scan.IsOutdated(e.Version, pi) {
+				version += " (" + opts.edgeVersion(pi.Latest) + ")" // Show the latest available version
+				outdated = true
+			}
+			if dir, found := info.LocalReplaces[depPath]; found {
+				version += " => " + dir
+			} else if repl, found := info.Replaces[depPath]; found {
+				version += " => " + repl // -replace=annotate
+			} else if orig := scan.ReplacedFrom(info, depPath); orig != "" {
+				version += " (replaces " + orig + ")" // -replace=rewrite
+			}
+			retracted := scan.Retraction(e.To.Module, e.Version) != nil
+			if retracted {
+				version += " (retracted)"
+			}
+			if _, found := info.APICoupling[depPath]; found {
+				version += " (API)"
+			}
+			edgeAttrs := opts.edgeLabel(version)              // Start with label attribute
+			if opts.ColorEdges && !outdated && !e.InCycle() { // lowest precedence
+				edgeAttrs = append(edgeAttrs, fmt.Sprintf("color=\"%s\"", opts.ownerColor(g.Nodes[sourceModPath].SetID, true)))
+			}
+			if retracted {
+				edgeAttrs = append(edgeAttrs, fmt.Sprintf("fontcolor=\"%s\"", retractedColor))
+			}
+			// Behind the latest version (lowest precedence: later colors win)
+			if outdated && !e.InCycle() {
+				edgeAttrs = append(edgeAttrs, fmt.Sprintf("color=\"%s\"", outdatedColor))
+			}
+
+			// Highlight edge if both source and destination are in the refined cycle set
+			if e.InCycle() {
+				edgeAttrs = append(edgeAttrs, fmt.Sprintf("color=\"%s\"", cycleColor)) // Add red color for cycle edge
+				edgeAttrs = append(edgeAttrs, "penwidth=1.5")                          // Slightly thicker edge for cycle
+			}
+			if ids, found := info.APICoupling[depPath]; found {
+				// Hardest dependencies to break: the dep's types are part of our API
+				edgeAttrs = append(edgeAttrs, "penwidth=2.5", fmt.Sprintf("tooltip=\"API: %s\"", strings.Join(ids, ", ")))
+				if !e.InCycle() {
+					edgeAttrs = append(edgeAttrs, fmt.Sprintf("color=\"%s\"", apiCouplingColor))
+				}
+			}
+			if _, found := info.LocalReplaces[depPath]; found {
+				// Warning style: only works on the developer's machine (cycle color takes precedence)
+				edgeAttrs = append(edgeAttrs, "style=\"bold\"", fmt.Sprintf("fontcolor=\"%s\"", localReplaceColor))
+				if !e.InCycle() {
+					edgeAttrs = append(edgeAttrs, fmt.Sprintf("color=\"%s\"", localReplaceColor))
+				}
+			}
+
+			fmt.Fprintf(bw, "  \"%s\" -> \"%s\" [%s];\n", sourceModPath, depPath, strings.Join(edgeAttrs, ", "))
+		}
+		// Dependencies excluded by the ignore-edges config: dotted grey edges
+		for _, depPath := range graph.SortedKeys(info.IgnoredDeps) {
+			if !g.Has(depPath) {
+				continue
+			}
+			edgeAttrs := append(opts.edgeLabel(opts.edgeVersion(info.IgnoredDeps[depPath])), "style=\"dotted\"",
+				fmt.Sprintf("color=\"%s\"", ignoredEdgeColor), fmt.Sprintf("fontcolor=\"%s\"", ignoredEdgeColor))
+			fmt.Fprintf(bw, "  \"%s\" -> \"%s\" [%s];\n", sourceModPath, depPath, strings.Join(edgeAttrs, ", "))
+		}
+		// Indirect (transitive) dependencies, with -transitive: dashed grey edges
+		indirectPaths := make([]string, 0, len(info.IndirectDeps))
+		for depPath := range info.IndirectDeps {
+			if g.Has(depPath) {
+				indirectPaths = append(indirectPaths, depPath)
+			}
+		}
+		sort.Strings(indirectPaths)
+		for _, depPath := range indirectPaths {
+			edgeAttrs := append(opts.edgeLabel(opts.edgeVersion(info.IndirectDeps[depPath])), "style=\"dashed\"",
+				fmt.Sprintf("color=\"%s\"", indirectEdgeColor), fmt.Sprintf("fontcolor=\"%s\"", indirectEdgeColor))
+			fmt.Fprintf(bw, "  \"%s\" -> \"%s\" [%s];\n", sourceModPath, depPath, strings.Join(edgeAttrs, ", "))
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+	// --- End Generate DOT Output ---
+	return bw.Flush()
+}
+
+// nodeLabel returns the DOT label (with \n line breaks) of a scanned module per -label: by
+// default the module path, or the repository for forks, followed by their fork of line.
+func (o Options) nodeLabel(path string, info *graph.ModuleInfo) string {
+	repo := info.RepoPath
+	if info.Dir != "" {
+		repo += "/" + info.Dir // module in a subdirectory (monorepo)
+	}
+	var label string
+	switch o.Label {
+	case LabelModule:
+		label = path
+	case LabelRepo:
+		label = repo
+	case LabelBoth:
+		label = path + "\\n" + repo
+	default:
+		if !info.IsFork {
+			return path
+		}
+		label = info.RepoPath
+	}
+	switch {
+	case !info.IsFork:
+	case info.OriginalModulePath != "":
+		label += fmt.Sprintf("\\n(fork of %s)", info.OriginalModulePath)
+	default:
+		label += "\\n(fork)" // original path couldn't be found
+	}
+	return label
+}
+
+// labelPrefixes returns the prefixes to strip from the labels (-strip-label-prefix), auto
+// being the common prefix (up to a /) of the scanned modules of the graph.
+func (o Options) labelPrefixes(g *graph.Graph) []string {
+	var res []string
+	for _, prefix := range o.StripLabelPrefixes {
+		if prefix != LabelPrefixAuto {
+			res = append(res, prefix)
+			continue
+		}
+		common, first := "", true
+		for _, path := range g.Paths() {
+			if g.Nodes[path].Module == nil {
+				continue
+			}
+			if first {
+				common, first = path, false
+				continue
+			}
+			for !strings.HasPrefix(path, common) {
+				common = common[:len(common)-1]
+			}
+		}
+		if i := strings.LastIndex(common, "/"); i >= 0 {
+			res = append(res, common[:i+1])
+		}
+	}
+	return res
+}
+
+// shortenLabel strips the first matching prefix from each line of the label (keeping lines
+// that are only the prefix) and, if maxWidth isn't 0, wraps the lines longer than that on /,
+// truncating the parts still too long with an ellipsis. Lines are separated by \n (DOT).
+func shortenLabel(label string, prefixes []string, maxWidth int) string {
+	if len(prefixes) == 0 && maxWidth <= 0 {
+		return label
+	}
+	var res []string
+	for _, line := range strings.Split(label, "\\n") {
+		for _, prefix := range prefixes {
+			if rest, found := strings.CutPrefix(line, prefix); found && rest != "" {
+				line = rest
+				break
+			}
+		}
+		if maxWidth <= 0 || len(line) <= maxWidth {
+			res = append(res, line)
+			continue
+		}
+		cur := ""
+		for _, part := range strings.SplitAfter(line, "/") {
+			if cur != "" && len(cur)+len(part) > maxWidth {
+				res = append(res, cur)
+				cur = ""
+			}
+			cur += part
+		}
+		res = append(res, cur)
+	}
+	for i, line := range res {
+		if maxWidth > 0 && len(line) > maxWidth {
+			res[i] = line[:max(maxWidth-1, 1)] + "…"
+		}
+	}
+	return strings.Join(res, "\\n")
+}
+
+// edgeVersion returns the version as shown in the edge labels: major.minor with
+// -short-edge-labels (v1.2.3 -> 1.2), as is otherwise (and for non semver versions).
+func (o Options) edgeVersion(version string) string {
+	if !o.ShortEdgeLabels || !semver.IsValid(version) {
+		return version
+	}
+	return strings.TrimPrefix(semver.MajorMinor(version), "v")
+}
+
+// edgeLabel returns the (escaped) label attribute of an edge, none with -no-edge-labels.
+func (o Options) edgeLabel(label string) []string {
+	if o.NoEdgeLabels {
+		return nil
+	}
+	return []string{fmt.Sprintf("label=\"%s\"", strings.ReplaceAll(label, "\"", "\\\""))}
+}
+
+// internalDependentCounts returns the number of scanned modules of the graph directly
+// depending on each node.
+func internalDependentCounts(g *graph.Graph) map[string]int {
+	counts := make(map[string]int)
+	for path := range g.Nodes {
+		if n := len(g.Dependents(path)); n > 0 {
+			counts[path] = n
+		}
+	}
+	return counts
+}
+
+// printNodeDefinitions prints the DOT node lines: the scanned modules of each owner group
+// (-group) in a cluster subgraph labeled with the group name, and, when clusterByRepo is set,
+// the modules of the same repository (with more than one module in the graph) in a cluster.
+func printNodeDefinitions(bw io.Writer, g *graph.Graph, sortedNodes []string, nodeDefs map[string]string, clusterByRepo bool, groups map[string]string) {
+	byGroup := make(map[string][]string) // group name, "" for none -> its nodes
+	for _, nodePath := range sortedNodes {
+		group := ""
+		if info := g.Nodes[nodePath].Module; info != nil {
+			group = groups[info.Owner]
+		}
+		byGroup[group] = append(byGroup[group], nodePath)
+	}
+	for i, group := range graph.SortedKeys(byGroup) {
+		if group == "" {
+			printRepoClusters(bw, g, byGroup[group], nodeDefs, clusterByRepo, "  ", "cluster_")
+			continue
+		}
+		fmt.Fprintf(bw, "  subgraph \"cluster_group_%d\" {\n", i)
+		fmt.Fprintf(bw, "    label=\"%s\";\n    style=\"bold\";\n    fontname=\"Helvetica\";\n", strings.ReplaceAll(group, "\"", "\\\""))
+		printRepoClusters(bw, g, byGroup[group], nodeDefs, clusterByRepo, "    ", fmt.Sprintf("cluster_%d_", i))
+		fmt.Fprintln(bw, "  }")
+	}
+}
+
+// printRepoClusters prints the DOT node lines of nodes, indented, with the modules of the same
+// repository in a cluster subgraph (named with the prefix) when clusterByRepo is set.
+func printRepoClusters(bw io.Writer, g *graph.Graph, nodes []string, nodeDefs map[string]string, clusterByRepo bool, indent, prefix string) {
+	byRepo := make(map[string][]string)
+	repos := []string{}
+	if clusterByRepo {
+		for _, nodePath := range nodes {
+			info := g.Nodes[nodePath].Module
+			if info == nil || nodeDefs[nodePath] == "" {
+				continue
+			}
+			if byRepo[info.RepoPath] == nil {
+				repos = append(repos, info.RepoPath)
+			}
+			byRepo[info.RepoPath] = append(byRepo[info.RepoPath], nodePath)
+		}
+		sort.Strings(repos)
+	}
+	inCluster := make(map[string]bool)
+	for i, repo := range repos {
+		repoNodes := byRepo[repo]
+		if len(repoNodes) < 2 {
+			continue
+		}
+		fmt.Fprintf(bw, "%ssubgraph \"%s%d\" {\n", indent, prefix, i)
+		fmt.Fprintf(bw, "%s  label=\"%s\";\n%s  style=\"dashed\";\n%s  fontname=\"Helvetica\";\n", indent, strings.ReplaceAll(repo, "\"", "\\\""), indent, indent)
+		for _, nodePath := range repoNodes {
+			fmt.Fprintf(bw, "%s  %s\n", indent, nodeDefs[nodePath])
+			inCluster[nodePath] = true
+		}
+		fmt.Fprintf(bw, "%s}\n", indent)
+	}
+	for _, nodePath := range nodes {
+		if def := nodeDefs[nodePath]; def != "" && !inCluster[nodePath] {
+			fmt.Fprintf(bw, "%s%s\n", indent, def)
+		}
+	}
+}
+
+// --- Topological Sort Logic ---
+
+// Helper function to format node output for topo sort (SINGLE LINE format)
+func formatNodeForTopo(nodePath string, modulesFoundInOwners map[string]*graph.ModuleInfo) string {
+	// Default output is module path
+	outputStr := nodePath
+	// Look up info to customize output for forks
+	if info, found := modulesFoundInOwners[nodePath]; found && info.IsFork {
+		// Always start with the repo path for forks
+		outputStr = info.RepoPath
+		// Append original module path if it was found and differs from the fork's declared path
+		if info.OriginalModulePath != "" {
+			if info.Path == info.OriginalModulePath {
+				// Path matches original: RepoPath (fork of OriginalPath)
+				outputStr = fmt.Sprintf("%s (fork of %s)", info.RepoPath, info.OriginalModulePath)
+			} else {
+				// Path differs: RepoPath (DeclaredPath fork of OriginalPath)
+				outputStr = fmt.Sprintf("%s (%s fork of %s)", info.RepoPath, info.Path, info.OriginalModulePath)
+			}
+		} else {
+			// Fork, but couldn't find original module path
+			outputStr = fmt.Sprintf("%s (fork)", info.RepoPath)
+		}
+	}
+	return outputStr
+}
+
+// printLevel prints a single level of the topological sort to w, handling A<->B pairs.
+func printLevel(w io.Writer, levelNodes []string, levelIndex int, indent string, modulesFoundInOwners map[string]*graph.ModuleInfo, bidirPairs map[string]string, isBidirNode map[string]bool, processedForOutput map[string]bool, levelName string) {
+	if len(levelNodes) == 0 {
+		return // Don't print empty levels
+	}
+	fmt.Fprintf(w, "%sLevel %d%s:\n", indent, levelIndex, levelName)
+	levelSet := make(map[string]bool)
+	for _, node := range levelNodes {
+		levelSet[node] = true
+	} // For quick lookup
+
+	// Sort level nodes for consistent processing order
+	sortedLevelNodes := make([]string, len(levelNodes))
+	copy(sortedLevelNodes, levelNodes)
+	sort.Strings(sortedLevelNodes)
+
+	for _, nodePath := range sortedLevelNodes {
+		if processedForOutput[nodePath] {
+			continue // Skip if already printed as part of a pair
+		}
+
+		partner, isPairStart := bidirPairs[nodePath] // Is nodePath the 'A' in an A<->B pair (where A<B)?
+		_, partnerInLevel := levelSet[partner]       // Is the partner B also in this level?
+
+		if isPairStart && partnerInLevel { // Is it A in A<->B and B is also in this level?
+			// Print combined format using the text-based helper
+			formattedA := formatNodeForTopo(nodePath, modulesFoundInOwners)
+			formattedB := formatNodeForTopo(partner, modulesFoundInOwners)
+			fmt.Fprintf(w, "%s  - %s <-> %s\n", indent, formattedA, formattedB)
+			processedForOutput[nodePath] = true
+			processedForOutput[partner] = true
+		} else {
+			// Print individually using the text-based helper
+			marker := ""
+			outputStr := formatNodeForTopo(nodePath, modulesFoundInOwners) // Format fork info
+			fmt.Fprintf(w, "%s  - %s%s\n", indent, outputStr, marker)
+			processedForOutput[nodePath] = true
+		}
+	}
+}
+
+// performTopologicalSortAndPrint performs Kahn's algorithm on the REVERSE graph
+// printing levels starting with leaves to w (buffered), grouping cycles into their own level.
+// effort (nil for none) adds the release effort per level.
+func performTopologicalSortAndPrint(w io.Writer, g *graph.Graph, effort *EffortTracker) error {
+	bw := bufio.NewWriter(w)
+	// --- Initial Setup ---
+	log.Infof("Starting topological sort (leaves first)...")
+
+	bidirPairs := make(map[string]string) // Store A -> B if A < B
+	isBidirNode := make(map[string]bool)  // Mark nodes involved in any A<->B pair
+	for _, e := range g.Edges {
+		sourceMod, dep := e.From.Path, e.To.Path
+		// Check for bidirectional link (B depends on A)
+		if g.DependsOn(dep, sourceMod) {
+			isBidirNode[sourceMod] = true
+			isBidirNode[dep] = true
+			// Store pair consistently (e.g., always store A->B where A < B)
+			if sourceMod < dep {
+				bidirPairs[sourceMod] = dep
+			} else {
+				bidirPairs[dep] = sourceMod
+			}
+		}
+	}
+
+	// Reverse graph: a node's in-degree is its number of dependencies, its neighbors its dependents
+	nodesInCycles := make(map[string]bool)
+	runningInDegree := make(map[string]int, len(g.Nodes))
+	reverseAdj := make(map[string][]string, len(g.Nodes))
+	for path, node := range g.Nodes {
+		if node.PartOfLoop {
+			nodesInCycles[path] = true
+		}
+		runningInDegree[path] = len(g.Dependencies(path))
+		for _, e := range g.Dependents(path) {
+			reverseAdj[path] = append(reverseAdj[path], e.From.Path)
+		}
+	}
+
+	// --- Kahn's Algorithm for Leveling ---
+
+	queue := []string{}
+	for node, degree := range runningInDegree {
+		// Start with nodes that have no dependencies *and* are not part of a detected cycle
+		if degree == 0 && !nodesInCycles[node] {
+			queue = append(queue, node)
+		}
+	}
+	sort.Strings(queue)
+
+	processedNodes := make(map[string]bool)     // Track processed nodes (acyclic, cycle, post-cycle)
+	processedForOutput := make(map[string]bool) // Track nodes printed to avoid duplicates in A<->B pairs
+	levelCounter := 0
+	fmt.Fprintln(bw, "Topological Sort Levels (Leaves First):")
+
+	// 1. Process Acyclic Levels Before Cycles
+	log.LogVf("Processing pre-cycle levels...")
+	for len(queue) > 0 {
+		currentLevelSize := len(queue)
+		currentLevelNodes := make([]string, 0, currentLevelSize)
+		nextQueue := []string{}
+
+		log.LogVf("  Queue for Level %d: %v", levelCounter, queue)
+
+		for i := 0; i < currentLevelSize; i++ {
+			u := queue[i]
+			// Double check it's not a cycle node (shouldn't be, based on initial queue population)
+			if nodesInCycles[u] {
+				log.Warnf("Node %s found in queue but marked as cycle node. Skipping for now.", u)
+				continue
+			}
+			currentLevelNodes = append(currentLevelNodes, u)
+			processedNodes[u] = true
+
+			// Process nodes that depend on u
+			neighbors := reverseAdj[u] // Nodes that depend on u
+			sort.Strings(neighbors)
+			for _, v := range neighbors {
+				// Only decrement degree if the dependent node v hasn't been processed yet
+				if !processedNodes[v] && !nodesInCycles[v] { // Don't process nodes already processed or known cycle nodes yet
+					runningInDegree[v]--
+					if runningInDegree[v] == 0 {
+						nextQueue = append(nextQueue, v)
+					} else if runningInDegree[v] < 0 {
+						log.Errf("BUG: Negative in-degree for %s after processing %s", v, u)
+					}
+				}
+			}
+		}
+
+		// Print the completed level
+		printLevel(bw, currentLevelNodes, levelCounter, "", g.Modules, bidirPairs, isBidirNode, processedForOutput, effort.levelSuffix(currentLevelNodes, g.Modules))
+
+		// Prepare for next level
+		sort.Strings(nextQueue)
+		queue = nextQueue
+		levelCounter++
+	} // End of pre-cycle levels loop
+
+	// 2. Process the Cycle Level
+	log.LogVf("Processing cycle level...")
+	cycleNodesList := make([]string, 0, len(nodesInCycles))
+	for node := range nodesInCycles {
+		cycleNodesList = append(cycleNodesList, node)
+		processedNodes[node] = true // Mark cycle nodes as processed
+	}
+	sort.Strings(cycleNodesList)
+
+	if len(cycleNodesList) > 0 {
+		// Print the cycle level
+		printLevel(bw, cycleNodesList, levelCounter, "", g.Modules, bidirPairs, isBidirNode, processedForOutput, " (Cycles)"+effort.levelSuffix(cycleNodesList, g.Modules))
+
+		// Prepare queue for post-cycle levels:
+		// Iterate through cycle nodes and decrement the degrees of their dependents.
+		// Add dependents to the queue if their degree becomes 0.
+		queue = []string{} // Reset queue
+		for _, cycleNode := range cycleNodesList {
+			dependents := reverseAdj[cycleNode] // Nodes that depend on this cycleNode
+			sort.Strings(dependents)            // Ensure deterministic order
+			for _, dependent := range dependents {
+				if !processedNodes[dependent] { // If the dependent hasn't been processed
+					runningInDegree[dependent]--
+					if runningInDegree[dependent] == 0 {
+						// Check if it's already queued to avoid duplicates
+						alreadyQueued := false
+						for _, qn := range queue {
+							if qn == dependent {
+								alreadyQueued = true
+								break
+							}
+						}
+						if !alreadyQueued {
+							queue = append(queue, dependent)
+						}
+					} else if runningInDegree[dependent] < 0 {
+						// This might happen if a node depends on multiple cycle nodes.
+						// It should have been caught earlier if it depended only on cycle nodes,
+						// but log it just in case.
+						log.Warnf("In-degree for %s became negative after processing cycle node %s. Current degree: %d", dependent, cycleNode, runningInDegree[dependent])
+					}
+				}
+			}
+		}
+		sort.Strings(queue)
+		levelCounter++ // Increment level counter *after* cycle level
+	} else {
+		log.LogVf("No cycle nodes detected or remaining.")
+	}
+
+	// 3. Process Post-Cycle Levels
+	log.LogVf("Processing post-cycle levels...")
+	for len(queue) > 0 {
+		currentLevelSize := len(queue)
+		currentLevelNodes := make([]string, 0, currentLevelSize)
+		nextQueue := []string{}
+
+		log.LogVf("  Queue for Level %d: %v", levelCounter, queue)
+
+		for i := 0; i < currentLevelSize; i++ {
+			u := queue[i]
+			// Ensure it hasn't been processed somehow (e.g., added to queue multiple times)
+			if processedNodes[u] {
+				log.Warnf("Node %s found in post-cycle queue but already processed. Skipping.", u)
+				continue
+			}
+			currentLevelNodes = append(currentLevelNodes, u)
+			processedNodes[u] = true
+
+			// Process nodes that depend on u
+			neighbors := reverseAdj[u] // Nodes that depend on u
+			sort.Strings(neighbors)
+			for _, v := range neighbors {
+				// Only decrement degree if the dependent node v hasn't been processed yet
+				if !processedNodes[v] {
+					runningInDegree[v]--
+					if runningInDegree[v] == 0 {
+						nextQueue = append(nextQueue, v)
+					} else if runningInDegree[v] < 0 {
+						log.Errf("BUG: Negative in-degree for %s after processing %s in post-cycle", v, u)
+					}
+				}
+			}
+		}
+
+		// Print the completed level
+		printLevel(bw, currentLevelNodes, levelCounter, "", g.Modules, bidirPairs, isBidirNode, processedForOutput, effort.levelSuffix(currentLevelNodes, g.Modules))
+
+		// Prepare for next level
+		sort.Strings(nextQueue)
+		queue = nextQueue
+		levelCounter++
+	} // End of post-cycle levels loop
+
+	effort.printTotal(bw)
+
+	// Final Check: Ensure all nodes were processed
+	if len(processedNodes) != len(g.Nodes) {
+		log.Warnf("Processed %d nodes, but expected %d. Some nodes might be unreachable or part of unhandled graph structures.", len(processedNodes), len(g.Nodes))
+		unprocessed := []string{}
+		for node := range g.Nodes {
+			if !processedNodes[node] {
+				unprocessed = append(unprocessed, node)
+			}
+		}
+		sort.Strings(unprocessed)
+		log.Warnf("Unprocessed nodes: %v", unprocessed)
+	} else {
+		log.Infof("Topological sort processed all %d nodes.", len(processedNodes))
+	}
+	return bw.Flush()
+}
+
+// --- End Topological Sort Logic ---
+
+// --- End Graph Generation Logic ---
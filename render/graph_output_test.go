@@ -0,0 +1,21 @@
+package render
+
+import "testing"
+
+func TestDOTOutput(t *testing.T) {
+	checkRender(t, []renderTest{
+		{
+			name:   "cycle edges",
+			format: FormatDOT,
+			opts:   Options{NoEdgeLabels: true},
+			graph:  twoCyclesGraph(),
+			want: []string{
+				`  "example.com/a" -> "example.com/b" [color="red", penwidth=1.5];`,
+				`  "example.com/b" -> "example.com/a" [color="red", penwidth=1.5];`,
+				`  "example.com/b" -> "example.com/c" [];`, // between the 2 cycles
+				`  "example.com/c" -> "example.com/d" [color="red", penwidth=1.5];`,
+				`  "example.com/d" -> "example.com/c" [color="red", penwidth=1.5];`,
+			},
+		},
+	})
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- JSON Output ---
+
+// JSONNode is a module (node of the graph) in the JSON output.
+type JSONNode struct {
+	Path         string                `json:"path"`
+	RepoPath     string                `json:"repo,omitempty"`
+	Dir          string                `json:"dir,omitempty"`
+	Language     string                `json:"language,omitempty"` // npm, cargo with -manifests ("" for go)
+	Ref          string                `json:"ref,omitempty"`
+	Owner        string                `json:"owner,omitempty"`
+	External     bool                  `json:"external,omitempty"`
+	Fork         bool                  `json:"fork,omitempty"`
+	ForkOf       string                `json:"fork_of,omitempty"`
+	InCycle      bool                  `json:"in_cycle,omitempty"`
+	Deps         map[string]string     `json:"deps,omitempty"`           // path -> version, only deps in the graph
+	IndirectDeps map[string]string     `json:"indirect_deps,omitempty"`  // with -transitive
+	IgnoredDeps  map[string]string     `json:"ignored_deps,omitempty"`   // excluded by the ignore-edges config
+	LocalReplace map[string]string     `json:"local_replaces,omitempty"` // path -> local directory
+	Replaces     map[string]string     `json:"replaces,omitempty"`       // path -> "path@version" replacement (with -replace)
+	ModIssues    []string              `json:"mod_issues,omitempty"`     // go.mod hygiene issues
+	Excludes     []string              `json:"excludes,omitempty"`       // "path@version" exclude directives
+	Retracts     []graph.Retraction    `json:"retracts,omitempty"`       // retract directives
+	Retracted    map[string]string     `json:"retracted_deps,omitempty"` // dep path -> required version retracted by that dep
+	APICoupling  map[string][]string   `json:"api_coupling,omitempty"`   // dep path -> dep's identifiers in the exported API
+	Error        string                `json:"error,omitempty"`          // go.mod parse error (-error-nodes)
+	GoVersion    string                `json:"go_version,omitempty"`     // go directive
+	License      string                `json:"license,omitempty"`        // repository license (SPDX id) detected by GitHub
+	Deprecated   string                `json:"deprecated,omitempty"`     // deprecation message (-check-deprecated)
+	Toolchain    string                `json:"toolchain,omitempty"`      // toolchain directive
+	Latest       *scan.ProxyModuleInfo `json:"latest,omitempty"`         // with -check-latest
+	DepsDev      *scan.DepsDevInfo     `json:"deps_dev,omitempty"`       // with -depsdev
+}
+
+// JSONOutput is the top level JSON document. Unless the opt-in Stats are included, it only
+// depends on the scanned data (no timings nor API usage), so it can be committed and diffed.
+type JSONOutput struct {
+	Summary *graph.Summary     `json:"summary,omitempty"` // counts of the scan and graph
+	Stats   *scan.APIStats     `json:"stats,omitempty"`   // API calls, cache and rate limit usage of the run (-json-stats)
+	Nodes   []JSONNode         `json:"nodes"`
+	Errors  *scan.ErrorSummary `json:"scan_errors,omitempty"` // scan_errors.partial is true if repositories couldn't be scanned
+}
+
+// GraphDeps returns the subset of deps whose target is in the graph (nil if none).
+func GraphDeps(deps map[string]string, nodesToGraph map[string]bool) map[string]string {
+	var res map[string]string
+	for path, version := range deps {
+		if nodesToGraph[path] {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[path] = version
+		}
+	}
+	return res
+}
+
+// WriteJSON writes the graph as indented JSON to w, nodes sorted by path.
+func WriteJSON(w io.Writer, g *graph.Graph, ann *scan.Annotations, stats *scan.APIStats, errs *scan.ErrorSummary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(BuildJSON(g, ann, stats, errs))
+}
+
+// BuildJSON returns the JSON output structure of the graph. ann, stats (only included on
+// demand, as they differ from run to run) and errs can be nil.
+func BuildJSON(g *graph.Graph, ann *scan.Annotations, stats *scan.APIStats, errs *scan.ErrorSummary) *JSONOutput {
+	nodesToGraph := g.Set()
+	out := JSONOutput{Summary: g.Summary(), Stats: stats, Nodes: make([]JSONNode, 0, len(g.Nodes)), Errors: errs}
+	for _, path := range g.Paths() {
+		node := g.Nodes[path]
+		n := JSONNode{Path: path, InCycle: node.PartOfLoop, Latest: ann.LatestFor(path), Deprecated: ann.Deprecation(path)}
+		if ann != nil {
+			n.DepsDev = ann.DepsDev[path]
+		}
+		if info := node.Module; info != nil {
+			n.RepoPath = info.RepoPath
+			n.Dir = info.Dir
+			n.Language = info.Language
+			n.Ref = info.Ref
+			n.Owner = info.Owner
+			n.Fork = info.IsFork
+			n.ForkOf = info.OriginalModulePath
+			n.Deps = GraphDeps(info.Deps, nodesToGraph)
+			n.IndirectDeps = GraphDeps(info.IndirectDeps, nodesToGraph)
+			n.IgnoredDeps = GraphDeps(info.IgnoredDeps, nodesToGraph)
+			n.LocalReplace = info.LocalReplaces
+			n.Replaces = info.Replaces
+			n.ModIssues = info.ModIssues
+			n.Excludes = info.Excludes
+			n.Retracts = info.Retracts
+			n.APICoupling = info.APICoupling
+			n.Error = info.Error
+			n.GoVersion = info.GoVersion
+			n.License = info.License
+			n.Toolchain = info.Toolchain
+			for dep, version := range n.Deps {
+				if scan.Retraction(g.Modules[dep], version) != nil {
+					if n.Retracted == nil {
+						n.Retracted = make(map[string]string)
+					}
+					n.Retracted[dep] = version
+				}
+			}
+		} else {
+			n.External = true
+		}
+		out.Nodes = append(out.Nodes, n)
+	}
+	return &out
+}
+
+// --- End JSON Output ---
@@ -0,0 +1,110 @@
+// Package render writes a depgraph graph.Graph in the output formats: Graphviz DOT, topological
+// sort levels, GitHub Actions annotations, Neo4j Cypher and SQL statements, and JSON.
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// --- Output Formats ---
+
+// Output formats (-format).
+const (
+	FormatDOT    = "dot"
+	FormatTopo   = "topo"
+	FormatGHA    = "gha"
+	FormatCypher = "cypher"
+	FormatSQL    = "sql"
+)
+
+// Options are the settings of the output formats (each uses the ones relevant to it).
+type Options struct {
+	NoExt              bool              // skip the external dependencies (-noext)
+	Left2Right         bool              // left to right DOT graph (-left2right)
+	ClusterByRepo      bool              // group the modules of a repository (-cluster-repos)
+	Annotations        *scan.Annotations // module proxy, deps.dev... information (nil for none)
+	Effort             *EffortTracker    // release effort per topological level (nil for none)
+	Colors             []string          // DOT fill colors of the (non-fork) modules, by owner index (nil for the default palette)
+	ForkColors         []string          // same for the forks
+	Groups             map[string]string // owner -> group name (-group), clustered in the DOT output
+	NoEdgeLabels       bool              // no version labels on the DOT edges (-no-edge-labels)
+	ShortEdgeLabels    bool              // major.minor edge labels (-short-edge-labels)
+	ColorEdges         bool              // DOT edges colored by their source's owner (-color-edges)
+	Label              string            // DOT labels of the scanned modules (-label): "" (default), LabelModule, LabelRepo or LabelBoth
+	Title              string            // DOT graph label, shown at the top (-title, -banner), lines separated by \n
+	StripLabelPrefixes []string          // prefixes removed from the DOT node labels (-strip-label-prefix), LabelPrefixAuto for the common one
+	MaxLabelWidth      int               // DOT node label lines longer than this are wrapped on / (-max-label-width), 0 for no limit
+	ScanTime           time.Time         // time of the scan, the key of its SQL rows
+}
+
+// Values of Options.Label (-label), "" being module paths but repositories for forks.
+const (
+	LabelModule = "module" // module path, forks included
+	LabelRepo   = "repo"   // repository (with the go.mod directory for modules in a subdirectory)
+	LabelBoth   = "both"   // module path and repository
+)
+
+// LabelPrefixAuto in Options.StripLabelPrefixes is the common prefix of the scanned modules.
+const LabelPrefixAuto = "auto"
+
+// ownerColor returns the fill color of the modules of the owner (index), forks or not.
+func (o Options) ownerColor(ownerIdx int, fork bool) string {
+	palette, def := o.Colors, orgNonForkColors
+	if fork {
+		palette, def = o.ForkColors, orgForkColors
+	}
+	if len(palette) == 0 {
+		palette = def
+	}
+	return palette[ownerIdx%len(palette)]
+}
+
+// Renderer writes a graph in an output format.
+type Renderer interface {
+	Render(g *graph.Graph, w io.Writer, opts Options) error
+}
+
+// Renderers is the registry of the output formats, by -format name.
+var Renderers = map[string]Renderer{
+	FormatDOT:    dotRenderer{},
+	FormatTopo:   topoRenderer{},
+	FormatGHA:    ghaRenderer{},
+	FormatCypher: cypherRenderer{},
+	FormatSQL:    sqlRenderer{},
+}
+
+// FormatNames returns the -format values, sorted.
+func FormatNames() string {
+	return strings.Join(graph.SortedKeys(Renderers), "|")
+}
+
+// Render writes g to w in the given format.
+func Render(format string, g *graph.Graph, w io.Writer, opts Options) error {
+	r, found := Renderers[format]
+	if !found {
+		return fmt.Errorf("unknown format %q (%s)", format, FormatNames())
+	}
+	return r.Render(g, w, opts)
+}
+
+// dotRenderer is the Graphviz DOT format (default).
+type dotRenderer struct{}
+
+func (dotRenderer) Render(g *graph.Graph, w io.Writer, opts Options) error {
+	return generateDotOutput(w, g, opts)
+}
+
+// topoRenderer is the text of the topological sort levels, leaves first (-topo-sort).
+type topoRenderer struct{}
+
+func (topoRenderer) Render(g *graph.Graph, w io.Writer, opts Options) error {
+	return performTopologicalSortAndPrint(w, g, opts.Effort)
+}
+
+// --- End Output Formats ---
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/scan"
+)
+
+// testGraph returns a small graph: example.com/a and example.com/o'b (a quote to escape)
+// requiring each other (a cycle), a also requiring the external example.com/ext, and
+// example.com/c requiring a without a version.
+func testGraph() *graph.Graph {
+	modules := map[string]*graph.ModuleInfo{
+		"example.com/a": {Path: "example.com/a", RepoPath: "acme/a", Owner: "acme", GoVersion: "1.22", License: "MIT", Fetched: true,
+			Deps: map[string]string{"example.com/o'b": "v1.0.0", "example.com/ext": "v0.1.0"}},
+		"example.com/o'b": {Path: "example.com/o'b", RepoPath: "acme/b", Owner: "acme", IsFork: true, OriginalModulePath: "example.com/up", Fetched: true,
+			Deps: map[string]string{"example.com/a": "v1.1.0"}},
+		"example.com/c": {Path: "example.com/c", RepoPath: "acme/c", Owner: "acme", Fetched: true,
+			Deps: map[string]string{"example.com/a": ""}},
+	}
+	nodes := map[string]bool{"example.com/a": true, "example.com/o'b": true, "example.com/c": true, "example.com/ext": true}
+	return graph.New(nil, modules, nodes)
+}
+
+// twoCyclesGraph returns a graph of 2 cycles, example.com/a <-> example.com/b and
+// example.com/c <-> example.com/d, and b requiring c: an edge between 2 cycles, not in one.
+func twoCyclesGraph() *graph.Graph {
+	modules := map[string]*graph.ModuleInfo{}
+	nodes := map[string]bool{}
+	for path, dep := range map[string]string{"a": "b", "b": "a", "c": "d", "d": "c"} {
+		path = "example.com/" + path
+		modules[path] = &graph.ModuleInfo{Path: path, Owner: "acme", Fetched: true, Deps: map[string]string{"example.com/" + dep: "v1.0.0"}}
+		nodes[path] = true
+	}
+	modules["example.com/b"].Deps["example.com/c"] = "v1.0.0"
+	return graph.New(nil, modules, nodes)
+}
+
+// renderTest is a test case of a renderer: the lines expected in its output, and the
+// substrings not expected, for testGraph (or the given graph).
+type renderTest struct {
+	name     string
+	format   string
+	opts     Options
+	graph    *graph.Graph // testGraph() if nil
+	want     []string     // lines of the output
+	dontWant []string     // substrings not in the output
+}
+
+// checkRender renders the graph of each test case and checks its output.
+func checkRender(t *testing.T, tests []renderTest) {
+	t.Helper()
+	for _, tt := range tests {
+		g := tt.graph
+		if g == nil {
+			g = testGraph()
+		}
+		var buf bytes.Buffer
+		if err := Render(tt.format, g, &buf, tt.opts); err != nil {
+			t.Errorf("%s: render error: %v", tt.name, err)
+			continue
+		}
+		lines := strings.Split(buf.String(), "\n")
+		for _, want := range tt.want {
+			if !slices.Contains(lines, want) {
+				t.Errorf("%s: missing line\n%s\nin:\n%s", tt.name, want, &buf)
+			}
+		}
+		for _, dontWant := range tt.dontWant {
+			if strings.Contains(buf.String(), dontWant) {
+				t.Errorf("%s: unexpected %q in:\n%s", tt.name, dontWant, &buf)
+			}
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	stats := &scan.APIStats{Calls: map[string]int{"ListByOrg": 1, "GetContents": 3}, CacheHits: 1, CacheMisses: 3}
+	ann := &scan.Annotations{Latest: map[string]*scan.ProxyModuleInfo{"example.com/ext": {Latest: "v0.2.0", Found: true}}}
+	tests := []struct {
+		name       string
+		ann        *scan.Annotations
+		stats      *scan.APIStats
+		wantStats  bool
+		wantLatest string // of example.com/ext
+	}{
+		{name: "plain"},
+		{name: "annotated", ann: ann, wantLatest: "v0.2.0"},
+		{name: "with stats", stats: stats, wantStats: true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteJSON(&buf, testGraph(), tt.ann, tt.stats, nil); err != nil {
+			t.Fatalf("%s: WriteJSON error: %v", tt.name, err)
+		}
+		var out struct {
+			Stats map[string]any `json:"stats"`
+			Nodes []JSONNode     `json:"nodes"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("%s: invalid JSON %v:\n%s", tt.name, err, &buf)
+		}
+		if (out.Stats != nil) != tt.wantStats {
+			t.Errorf("%s: stats %v, want stats %v", tt.name, out.Stats, tt.wantStats)
+		}
+		if tt.wantStats && (out.Stats["total_calls"] != 4.0 || out.Stats["cache_hit_ratio"] != 25.0) {
+			t.Errorf("%s: stats %v, want 4 total calls and a 25%% cache hit ratio", tt.name, out.Stats)
+		}
+		var paths []string
+		for _, n := range out.Nodes {
+			paths = append(paths, n.Path)
+			switch n.Path {
+			case "example.com/a":
+				if !n.InCycle || n.Deps["example.com/o'b"] != "v1.0.0" || n.Deps["example.com/ext"] != "v0.1.0" || n.GoVersion != "1.22" {
+					t.Errorf("%s: example.com/a %+v", tt.name, n)
+				}
+			case "example.com/ext":
+				latest := ""
+				if n.Latest != nil {
+					latest = n.Latest.Latest
+				}
+				if !n.External || latest != tt.wantLatest {
+					t.Errorf("%s: example.com/ext %+v, want external, latest %q", tt.name, n, tt.wantLatest)
+				}
+			}
+		}
+		if got, want := strings.Join(paths, " "), "example.com/a example.com/c example.com/ext example.com/o'b"; got != want {
+			t.Errorf("%s: nodes %s, want sorted %s", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- SQL Output ---
+
+// sqlSchema creates the tables of the SQL output, if missing. Each scan's rows are keyed by its
+// time (RFC 3339 UTC, so they sort chronologically) to warehouse the scans over time.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS depgraph_modules (
+  scanned_at TEXT NOT NULL,
+  path TEXT NOT NULL,
+  repo TEXT,
+  dir TEXT,
+  owner TEXT,
+  external BOOLEAN NOT NULL,
+  fork BOOLEAN NOT NULL,
+  fork_of TEXT,
+  in_cycle BOOLEAN NOT NULL,
+  go_version TEXT,
+  license TEXT,
+  PRIMARY KEY (scanned_at, path)
+);
+CREATE TABLE IF NOT EXISTS depgraph_dependencies (
+  scanned_at TEXT NOT NULL,
+  from_path TEXT NOT NULL,
+  to_path TEXT NOT NULL,
+  version TEXT NOT NULL,
+  in_cycle BOOLEAN NOT NULL,
+  PRIMARY KEY (scanned_at, from_path, to_path)
+);
+`
+
+// sqlRenderer is the SQL output (-format=sql): a transaction creating the depgraph_modules and
+// depgraph_dependencies tables if needed, replacing the rows of the scan (Options.ScanTime,
+// 0001-01-01T00:00:00Z if zero, so the output stays deterministic) and inserting the graph's
+// nodes and edges. Portable to SQLite and PostgreSQL.
+type sqlRenderer struct{}
+
+func (sqlRenderer) Render(g *graph.Graph, w io.Writer, opts Options) error {
+	scan := sqlString(opts.ScanTime.UTC().Format(time.RFC3339))
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN;")
+	fmt.Fprint(bw, sqlSchema)
+	fmt.Fprintf(bw, "DELETE FROM depgraph_dependencies WHERE scanned_at = %s;\n", scan)
+	fmt.Fprintf(bw, "DELETE FROM depgraph_modules WHERE scanned_at = %s;\n", scan)
+	for _, path := range g.Paths() {
+		n := g.Nodes[path]
+		info := n.Module
+		if info == nil {
+			info = &graph.ModuleInfo{}
+		}
+		fmt.Fprintf(bw, "INSERT INTO depgraph_modules VALUES (%s, %s, %s, %s, %s, %t, %t, %s, %t, %s, %s);\n",
+			scan, sqlString(path), sqlString(info.RepoPath), sqlString(info.Dir), sqlString(info.Owner), n.Module == nil,
+			info.IsFork, sqlString(info.OriginalModulePath), n.PartOfLoop, sqlString(info.GoVersion), sqlString(info.License))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "INSERT INTO depgraph_dependencies VALUES (%s, %s, %s, %s, %t);\n",
+			scan, sqlString(e.From.Path), sqlString(e.To.Path), sqlText(e.Version), e.InCycle())
+	}
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+// sqlString returns s as a SQL string literal, NULL if empty.
+func sqlString(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	return sqlText(s)
+}
+
+// sqlText returns s as a SQL string literal, the empty string literal (two single quotes)
+// if empty, for the NOT NULL columns.
+func sqlText(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// --- End SQL Output ---
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSQLOutput(t *testing.T) {
+	scanTime := time.Date(2026, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	checkRender(t, []renderTest{
+		{
+			name:   "sql",
+			format: FormatSQL,
+			opts:   Options{ScanTime: scanTime},
+			want: []string{
+				"BEGIN;",
+				"DELETE FROM depgraph_modules WHERE scanned_at = '2026-03-04T04:06:07Z';",
+				"INSERT INTO depgraph_modules VALUES ('2026-03-04T04:06:07Z', 'example.com/a', 'acme/a', NULL, 'acme', false, false, NULL, true, '1.22', 'MIT');",
+				"INSERT INTO depgraph_modules VALUES ('2026-03-04T04:06:07Z', 'example.com/o''b', 'acme/b', NULL, 'acme', false, true, 'example.com/up', true, NULL, NULL);",
+				"INSERT INTO depgraph_modules VALUES ('2026-03-04T04:06:07Z', 'example.com/ext', NULL, NULL, NULL, true, false, NULL, false, NULL, NULL);",
+				"INSERT INTO depgraph_dependencies VALUES ('2026-03-04T04:06:07Z', 'example.com/a', 'example.com/o''b', 'v1.0.0', true);",
+				"INSERT INTO depgraph_dependencies VALUES ('2026-03-04T04:06:07Z', 'example.com/c', 'example.com/a', '', false);",
+				"COMMIT;",
+			},
+		},
+		{
+			name:   "sql edge between cycles",
+			format: FormatSQL,
+			graph:  twoCyclesGraph(),
+			want: []string{
+				"INSERT INTO depgraph_dependencies VALUES ('0001-01-01T00:00:00Z', 'example.com/a', 'example.com/b', 'v1.0.0', true);",
+				"INSERT INTO depgraph_dependencies VALUES ('0001-01-01T00:00:00Z', 'example.com/b', 'example.com/c', 'v1.0.0', false);",
+				"INSERT INTO depgraph_dependencies VALUES ('0001-01-01T00:00:00Z', 'example.com/c', 'example.com/d', 'v1.0.0', true);",
+			},
+		},
+		{
+			name:   "sql zero scan time",
+			format: FormatSQL,
+			want:   []string{"DELETE FROM depgraph_dependencies WHERE scanned_at = '0001-01-01T00:00:00Z';"},
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"strings"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// Annotations holds the optional extra per module information (from the module proxy,
+// deps.dev...) used to decorate the outputs. Maps are nil when the corresponding flag isn't set.
+type Annotations struct {
+	Latest     map[string]*ProxyModuleInfo // -check-latest
+	DepsDev    map[string]*DepsDevInfo     // -depsdev
+	Deprecated map[string]string           // -check-deprecated: path -> deprecation message
+	ScaleNodes bool                        // -scale-nodes: node size by number of internal dependents
+	GoLabel    bool                        // -go-label: go/toolchain directives in the node labels
+}
+
+// LatestFor returns the proxy info for the module (nil if unknown or not enabled).
+func (a *Annotations) LatestFor(path string) *ProxyModuleInfo {
+	if a == nil {
+		return nil
+	}
+	return a.Latest[path]
+}
+
+// Deprecation returns the deprecation message of the module, "" if not deprecated (or unknown).
+func (a *Annotations) Deprecation(path string) string {
+	if a == nil {
+		return ""
+	}
+	return a.Deprecated[path]
+}
+
+// LabelSuffix returns the extra DOT label line(s) for a module (info is nil for external
+// ones), "" for none.
+func (a *Annotations) LabelSuffix(path string, info *graph.ModuleInfo) string {
+	if a == nil {
+		return ""
+	}
+	suffix := ""
+	if goLine := goDirectives(info); a.GoLabel && goLine != "" {
+		suffix += "\\n" + goLine
+	}
+	if a.Deprecation(path) != "" {
+		suffix += "\\n(deprecated)"
+	}
+	return suffix
+}
+
+// Tooltip returns the DOT tooltip for a node, "" if there are no annotations.
+func (a *Annotations) Tooltip(path string) string {
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	if a.Latest != nil {
+		parts = append(parts, latestTooltip(a.Latest[path]))
+	}
+	if a.DepsDev != nil {
+		parts = append(parts, depsDevTooltip(a.DepsDev[path]))
+	}
+	if msg := a.Deprecation(path); msg != "" {
+		parts = append(parts, "Deprecated: "+msg)
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,228 @@
+package scan
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- API Coupling (-api-coupling, local clones only) ---
+
+// majorVersionRE matches the /vN suffix of module paths, which isn't the package name.
+var majorVersionRE = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the name a package is referred to by in a file: the alias if any,
+// else the (guessed, without type checking) package name from the import path.
+func importName(spec *ast.ImportSpec) (string, string) {
+	importPath, _ := strconv.Unquote(spec.Path.Value)
+	if spec.Name != nil {
+		return spec.Name.Name, importPath
+	}
+	name := path.Base(importPath)
+	if majorVersionRE.MatchString(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	name, _, _ = strings.Cut(name, ".") // gopkg.in/yaml.v3 -> yaml
+	return strings.ReplaceAll(name, "-", ""), importPath
+}
+
+// exportedAPIExprs returns the type expressions of the exported API of a file: exported
+// functions and methods signatures, exported types (and their exported fields/methods),
+// exported variables and constants types.
+func exportedAPIExprs(file *ast.File) []ast.Node {
+	var res []ast.Node
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() || (d.Recv != nil && !exportedReceiver(d.Recv)) {
+				continue
+			}
+			res = append(res, d.Type)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						res = append(res, exportedTypeParts(s.Type)...)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() && s.Type != nil {
+							res = append(res, s.Type)
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// exportedReceiver returns true if the method's receiver type is exported.
+func exportedReceiver(recv *ast.FieldList) bool {
+	if len(recv.List) == 0 {
+		return false
+	}
+	t := recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch r := t.(type) {
+	case *ast.Ident:
+		return r.IsExported()
+	case *ast.IndexExpr: // generic receiver
+		if id, ok := r.X.(*ast.Ident); ok {
+			return id.IsExported()
+		}
+	case *ast.IndexListExpr:
+		if id, ok := r.X.(*ast.Ident); ok {
+			return id.IsExported()
+		}
+	}
+	return false
+}
+
+// exportedTypeParts returns the parts of a type definition visible to other packages:
+// exported (and embedded) fields of structs, exported methods of interfaces, else the whole type.
+func exportedTypeParts(t ast.Expr) []ast.Node {
+	var fields *ast.FieldList
+	switch s := t.(type) {
+	case *ast.StructType:
+		fields = s.Fields
+	case *ast.InterfaceType:
+		fields = s.Methods
+	default:
+		return []ast.Node{t}
+	}
+	var res []ast.Node
+	for _, f := range fields.List {
+		if len(f.Names) == 0 {
+			res = append(res, f.Type) // embedded
+			continue
+		}
+		for _, name := range f.Names {
+			if name.IsExported() {
+				res = append(res, f.Type)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// ownerModule returns the module (among candidates) containing the package importPath, "" if none.
+func ownerModule(importPath string, candidates []string) string {
+	best := ""
+	for _, m := range candidates {
+		if (importPath == m || strings.HasPrefix(importPath, m+"/")) && len(m) > len(best) {
+			best = m
+		}
+	}
+	return best
+}
+
+// apiCoupling parses the (non test, non internal) Go files of the module in dir and returns,
+// for each of the candidate dependency modules, the identifiers of that module appearing in
+// the exported API (e.g. "graph.ModuleInfo"). This is syntax based (no type checking).
+func apiCoupling(dir string, candidates []string) map[string][]string {
+	res := make(map[string]map[string]bool)
+	fset := token.NewFileSet()
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir {
+				if skipLocalDir(d.Name()) || d.Name() == "internal" {
+					return fs.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+					return fs.SkipDir // nested module
+				}
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		if err != nil {
+			log.LogVf("      Skipping %s: %v", p, err)
+			return nil
+		}
+		if file.Name.Name == "main" {
+			return nil // no importable API
+		}
+		imports := make(map[string]string) // name -> dependency module
+		for _, spec := range file.Imports {
+			name, importPath := importName(spec)
+			if m := ownerModule(importPath, candidates); m != "" {
+				imports[name] = m
+			}
+		}
+		if len(imports) == 0 {
+			return nil
+		}
+		for _, n := range exportedAPIExprs(file) {
+			ast.Inspect(n, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if id, ok := sel.X.(*ast.Ident); ok && imports[id.Name] != "" {
+					m := imports[id.Name]
+					if res[m] == nil {
+						res[m] = make(map[string]bool)
+					}
+					res[m][id.Name+"."+sel.Sel.Name] = true
+				}
+				return true
+			})
+		}
+		return nil
+	})
+	coupled := make(map[string][]string, len(res))
+	for m, ids := range res {
+		coupled[m] = graph.SortedKeys(ids)
+	}
+	return coupled
+}
+
+// DetectAPICoupling sets APICoupling on the locally scanned modules whose exported API uses
+// types of other scanned (internal) modules they depend on, and logs them.
+func DetectAPICoupling(modulesFoundInOwners map[string]*graph.ModuleInfo, localDirs map[string]string) {
+	count := 0
+	for _, modPath := range graph.SortedKeys(localDirs) {
+		info := modulesFoundInOwners[modPath]
+		var candidates []string
+		for dep := range info.Deps {
+			if _, internal := modulesFoundInOwners[dep]; internal {
+				candidates = append(candidates, dep)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.Strings(candidates)
+		info.APICoupling = apiCoupling(localDirs[modPath], candidates)
+		for _, dep := range graph.SortedKeys(info.APICoupling) {
+			count++
+			log.Infof("API coupling: %s exposes %s types: %s", modPath, dep, strings.Join(info.APICoupling[dep], ", "))
+		}
+	}
+	log.Infof("Found %d API-coupling dependencies", count)
+}
+
+// --- End API Coupling ---
@@ -0,0 +1,338 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Caching Data Structures ---
+type CachedListResponse struct {
+	Repos    []*github.Repository
+	NextPage int
+	ETag     string `json:",omitempty"` // for -revalidate
+}
+type CachedContentResponse struct {
+	Found       bool
+	FileContent *github.RepositoryContent
+	ETag        string    `json:",omitempty"`
+	PushedAt    time.Time `json:",omitzero"` // repository's pushed_at when fetched, for -incremental
+}
+
+// Structure for caching full repository details
+type CachedRepoResponse struct {
+	Repo *github.Repository
+	ETag string `json:",omitempty"`
+}
+
+// Structure for caching the go.mod (or other) files found in a repository's git tree
+// (only the paths we need, not the whole tree).
+type CachedTreeResponse struct {
+	Found     bool
+	Paths     []string `json:"GoModPath"` // historical name
+	Truncated bool
+	ETag      string    `json:",omitempty"`
+	PushedAt  time.Time `json:",omitzero"`
+}
+
+// slimRepo returns a copy of the repository with only the fields depgraph uses (name, owner,
+// fork and its parent, archived, private, default branch, pushed_at, license), so the listings
+// of very large organizations don't take much memory nor cache space.
+func slimRepo(r *github.Repository) *github.Repository {
+	if r == nil {
+		return nil
+	}
+	slim := &github.Repository{
+		Name: r.Name, Fork: r.Fork, Archived: r.Archived, Private: r.Private,
+		DefaultBranch: r.DefaultBranch, PushedAt: r.PushedAt,
+	}
+	if r.Owner != nil {
+		slim.Owner = &github.User{Login: r.Owner.Login}
+	}
+	if r.License != nil {
+		slim.License = &github.License{SPDXID: r.License.SPDXID}
+	}
+	if r.Parent != nil {
+		slim.Parent = slimRepo(&github.Repository{Name: r.Parent.Name, Owner: r.Parent.Owner})
+	}
+	return slim
+}
+
+// slimRepos applies slimRepo to a listing.
+func slimRepos(repos []*github.Repository) []*github.Repository {
+	for i, r := range repos {
+		repos[i] = slimRepo(r)
+	}
+	return repos
+}
+
+// slimContent returns a copy of the file with only what is needed to decode it (no URLs, links...).
+func slimContent(c *github.RepositoryContent) *github.RepositoryContent {
+	return &github.RepositoryContent{Type: c.Type, Encoding: c.Encoding, Size: c.Size, Name: c.Name, Path: c.Path, Content: c.Content}
+}
+
+// --- End Caching Data Structures ---
+
+// --- Cache Handling Functions ---
+
+// Cache is the cache of the API calls (GitHub, module proxy, deps.dev) and the settings of
+// the clients sharing it. The caller creates it (see NewCache), opens it once and closes it
+// when done. The methods are safe on a nil *Cache, which caches nothing.
+type Cache struct {
+	Store   CacheBackend // -cache-backend
+	Use     bool         // -use-cache: read and write the entries
+	TTL     CacheTTLs    // -cache-ttl
+	Offline bool         // -offline: no requests are made, everything is answered from the cache, expired entries included
+	Retries RetryPolicy  // -retries and -retry-delay of the http clients (GitHub, module proxy, deps.dev, remote cache)
+	Dir     string       // cache directory, set by Open
+
+	resumeFrom time.Time // start of the interrupted scan resumed with -resume, see StartCheckpoint
+}
+
+// NewCache returns the (not yet opened) cache of the -cache-backend value, with the default
+// retries.
+func NewCache(backend string, use bool) (*Cache, error) {
+	store, err := NewCacheBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Store: store, Use: use, Retries: RetryPolicy{Attempts: DefaultRetryAttempts, Delay: DefaultRetryDelay}}, nil
+}
+
+// Open sets up the cache directory and opens the cache backend.
+func (c *Cache) Open() error {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	c.Dir = filepath.Join(userCacheDir, "depgraph_cache")
+	log.LogVf("Using cache directory: %s", c.Dir) // Verbose log
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	return c.Store.open(c)
+}
+
+// Close releases the cache backend opened by Open.
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.Store.close()
+}
+
+// Clear removes the cache directory, and opens the (empty) cache again.
+func (c *Cache) Clear() error {
+	if c.Dir == "" {
+		return errors.New("cache directory not initialized")
+	}
+	log.Infof("Clearing cache directory: %s", c.Dir)
+	if err := c.Store.close(); err != nil {
+		log.Warnf("Error closing the cache: %v", err)
+	}
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return err
+	}
+	return c.Open()
+}
+
+// used returns true if the cache entries are read and written.
+func (c *Cache) used() bool {
+	return c != nil && c.Use
+}
+
+// offline returns true with -offline.
+func (c *Cache) offline() bool {
+	return c != nil && c.Offline
+}
+
+// key generates a key (a filename for the default files backend) for the cache based on
+// input parameters.
+func (c *Cache) key(parts ...string) string {
+	if c == nil {
+		return joinKeyParts(parts)
+	}
+	return c.Store.key(c.Dir, parts)
+}
+
+// read attempts to read and unmarshal data from a cache entry. Entries older than the
+// TTL of their kind (the endpoint, first key part) are misses, but are still unmarshaled into
+// target so they can be revalidated (ETag).
+func (c *Cache) read(ctx context.Context, key, kind string, target interface{}) (bool, error) {
+	log.Debugf("Reading cache for key: %s for %T and useCache = %t", key, target, c.used())
+	if !c.used() {
+		return false, nil
+	}
+	data, modTime, err := c.Store.read(ctx, key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil // Cache miss - normal
+		}
+		// Log actual file read errors
+		return false, fmt.Errorf("error reading cache entry %s: %w", key, err)
+	}
+	if c.expired(key, kind, modTime) {
+		_ = json.Unmarshal(data, target)
+		return false, nil
+	}
+
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		// Log unmarshal errors clearly
+		log.Warnf("Error unmarshaling cache entry %s, ignoring cache: %v", key, err)
+		return false, nil // Treat as cache miss
+	}
+
+	// Debug log (Verbose level) to check the 'Found' status after unmarshal
+	if contentCache, ok := target.(*CachedContentResponse); ok {
+		log.LogVf("Cache read successful for %s - Cached Found status: %v", key, contentCache.Found)
+	} // Add similar check for CachedRepoResponse if needed
+
+	return true, nil
+}
+
+// write marshals and writes data to a cache entry
+func (c *Cache) write(ctx context.Context, key string, data interface{}) error {
+	if !c.used() {
+		return nil
+	}
+	jsonData, err := json.Marshal(data) // compact: the cache of a large org is big enough
+	if err != nil {
+		// Log marshal errors clearly
+		log.Errf("Error marshaling data for cache key %s: %v", key, err)
+		return fmt.Errorf("failed to marshal data for cache key %s: %w", key, err)
+	}
+
+	err = c.Store.write(ctx, key, jsonData)
+	if err != nil {
+		// Log write errors clearly
+		log.Errf("Error writing cache entry %s: %v", key, err)
+		return fmt.Errorf("failed to write cache entry %s: %w", key, err)
+	}
+	log.LogVf("Cache write: %s", key)
+	return nil
+}
+
+// touch marks a cache entry revalidated (still up to date) as fresh again.
+func (c *Cache) touch(ctx context.Context, key string) {
+	if !c.used() {
+		return
+	}
+	if err := c.Store.touch(ctx, key); err != nil {
+		log.Warnf("Error refreshing cache entry %s: %v", key, err)
+	}
+}
+
+// --- End Cache Handling Functions ---
+
+// --- Cache Expiry (TTL) ---
+
+// cacheKinds groups the cache entries by kind of data, for per kind TTLs.
+var cacheKinds = map[string]string{
+	"ListByOrg":               "lists",
+	"ListByUser":              "lists",
+	"ListByAuthenticatedUser": "lists",
+	"ListGists":               "lists",
+	"GetRepo":                 "repos",
+	"GetContents":             "contents",
+	"GetTreeGoMods":           "contents",
+	"GetTreeRoot":             "contents",
+	"GetGist":                 "contents",
+	"Proxy":                   "proxy",
+	"ProxyVersion":            "proxy",
+	"ProxyDeprecated":         "proxy",
+	"DepsDev":                 "depsdev",
+}
+
+// CacheTTLs are the cache entries time to live: the default one and the per kind ones.
+// 0 means entries never expire.
+type CacheTTLs struct {
+	def    time.Duration
+	byKind map[string]time.Duration
+}
+
+// ParseAge parses durations like 90d, 8w, 18mo or 2y (a month being 30 days, a year 365).
+func ParseAge(s string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		days   int
+	}{{"mo", 30}, {"d", 1}, {"w", 7}, {"y", 365}}
+	for _, u := range units {
+		if num, found := strings.CutSuffix(s, u.suffix); found {
+			n, err := strconv.Atoi(num)
+			if err != nil || n <= 0 {
+				break
+			}
+			return time.Duration(n*u.days) * 24 * time.Hour, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid age %q, expecting a number of days, weeks, months or years e.g. 90d, 8w, 18mo, 2y", s)
+}
+
+// ParseCacheTTL parses the -cache-ttl value: comma separated default TTL and/or kind=TTL
+// entries (e.g. "24h,lists=1h,contents=7d"). TTLs are Go durations or days, weeks... (see ParseAge).
+func ParseCacheTTL(value string) (CacheTTLs, error) {
+	res := CacheTTLs{byKind: make(map[string]time.Duration)}
+	if value == "" {
+		return res, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		kind, ttlStr, hasKind := strings.Cut(strings.TrimSpace(entry), "=")
+		if !hasKind {
+			ttlStr, kind = kind, ""
+		}
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			if ttl, err = ParseAge(ttlStr); err != nil {
+				return res, fmt.Errorf("invalid cache TTL %q", entry)
+			}
+		}
+		if !hasKind {
+			res.def = ttl
+			continue
+		}
+		if kinds := cacheKindNames(); !kinds[kind] {
+			return res, fmt.Errorf("unknown cache kind %q, expecting one of %s", kind, strings.Join(graph.SortedKeys(kinds), ", "))
+		}
+		res.byKind[kind] = ttl
+	}
+	return res, nil
+}
+
+// cacheKindNames returns the set of kinds of cacheKinds.
+func cacheKindNames() map[string]bool {
+	res := make(map[string]bool)
+	for _, kind := range cacheKinds {
+		res[kind] = true
+	}
+	return res
+}
+
+// expired returns true if the cache entry, written at modTime, is older than the TTL of its
+// kind of entry (never with -offline: better stale than nothing).
+func (c *Cache) expired(key, endpoint string, modTime time.Time) bool {
+	ttl, found := c.TTL.byKind[cacheKinds[endpoint]]
+	if !found {
+		ttl = c.TTL.def
+	}
+	if ttl <= 0 || c.Offline || (!c.resumeFrom.IsZero() && modTime.After(c.resumeFrom)) {
+		return false // no expiry, or fetched by the scan being resumed
+	}
+	if age := time.Since(modTime); age > ttl {
+		log.LogVf("Cache entry %s for %s expired (%v old, ttl %v)", key, endpoint, age.Round(time.Second), ttl)
+		return true
+	}
+	return false
+}
+
+// --- End Cache Expiry (TTL) ---
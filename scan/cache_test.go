@@ -0,0 +1,127 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+)
+
+// newTestCache returns the opened cache of the backend, with the ttl (see ParseCacheTTL), in
+// a new temporary cache directory.
+func newTestCache(t *testing.T, backend, ttl string) *Cache {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	c, err := NewCache(backend, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Retries = RetryPolicy{} // the fake API doesn't fail
+	if c.TTL, err = ParseCacheTTL(ttl); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// newTestClient returns the client of the fake GitHub API using the cache, sending the
+// conditional requests as the depgraph command does.
+func newTestClient(s *fakegithub.Server, cache *Cache, stats *APIStats) *ClientWrapper {
+	client := github.NewClient(&http.Client{Transport: &ETagTransport{Base: s.Server.Client().Transport}})
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return NewClientWrapper(client, cache, stats)
+}
+
+// scanAcme scans the acme owner of the fake API with the client, returning the modules and
+// the number of API requests made.
+func scanAcme(s *fakegithub.Server, cw *ClientWrapper, concurrency int) (map[string]*graph.ModuleInfo, int) {
+	before := s.Requests()
+	res := NewResult()
+	OwnersAndRepos(context.Background(), cw, []string{"acme"}, nil, &Options{Visibility: VisibilityPublic, Concurrency: concurrency}, res)
+	return res.Modules, s.Requests() - before
+}
+
+func TestParseCacheTTL(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantDef   time.Duration
+		wantKinds map[string]time.Duration
+		wantErr   bool
+	}{
+		{value: ""},
+		{value: "24h", wantDef: 24 * time.Hour},
+		{value: "7d,lists=1h", wantDef: 7 * 24 * time.Hour, wantKinds: map[string]time.Duration{"lists": time.Hour}},
+		{value: "lists=1h, contents=2w", wantKinds: map[string]time.Duration{"lists": time.Hour, "contents": 14 * 24 * time.Hour}},
+		{value: "proxy=30m,depsdev=1mo,repos=1y", wantKinds: map[string]time.Duration{
+			"proxy": 30 * time.Minute, "depsdev": 30 * 24 * time.Hour, "repos": 365 * 24 * time.Hour,
+		}},
+		{value: "1x", wantErr: true},
+		{value: "0d", wantErr: true},
+		{value: "files=1h", wantErr: true},
+		{value: "lists=", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCacheTTL(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCacheTTL(%q) error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.def != tt.wantDef {
+			t.Errorf("ParseCacheTTL(%q) default %v, want %v", tt.value, got.def, tt.wantDef)
+		}
+		if len(got.byKind) != len(tt.wantKinds) {
+			t.Errorf("ParseCacheTTL(%q) kinds %v, want %v", tt.value, got.byKind, tt.wantKinds)
+		}
+		for kind, want := range tt.wantKinds {
+			if got.byKind[kind] != want {
+				t.Errorf("ParseCacheTTL(%q) %s TTL %v, want %v", tt.value, kind, got.byKind[kind], want)
+			}
+		}
+	}
+}
+
+func TestCacheExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      string
+		endpoint string
+		age      time.Duration
+		offline  bool
+		resumed  time.Duration // resumeFrom that long ago, if not 0
+		want     bool
+	}{
+		{name: "no ttl", endpoint: "GetContents", age: 1000 * time.Hour},
+		{name: "fresh", ttl: "24h", endpoint: "GetContents", age: time.Hour},
+		{name: "expired", ttl: "24h", endpoint: "GetContents", age: 25 * time.Hour, want: true},
+		{name: "kind ttl", ttl: "24h,lists=1h", endpoint: "ListByOrg", age: 2 * time.Hour, want: true},
+		{name: "default ttl of other kinds", ttl: "24h,lists=1h", endpoint: "GetContents", age: 2 * time.Hour},
+		{name: "ttl in days", ttl: "contents=7d", endpoint: "GetTreeGoMods", age: 8 * 24 * time.Hour, want: true},
+		{name: "offline", ttl: "1h", endpoint: "GetContents", age: 2 * time.Hour, offline: true},
+		{name: "written by the resumed scan", ttl: "1h", endpoint: "GetContents", age: 2 * time.Hour, resumed: 3 * time.Hour},
+		{name: "written before the resumed scan", ttl: "1h", endpoint: "GetContents", age: 2 * time.Hour, resumed: time.Hour, want: true},
+	}
+	for _, tt := range tests {
+		ttl, err := ParseCacheTTL(tt.ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &Cache{TTL: ttl, Offline: tt.offline}
+		if tt.resumed != 0 {
+			c.resumeFrom = time.Now().Add(-tt.resumed)
+		}
+		if got := c.expired("key", tt.endpoint, time.Now().Add(-tt.age)); got != tt.want {
+			t.Errorf("%s: expired %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Cache Inspection ---
+
+// CacheEntry describes a cache entry, see Cache.Entries.
+type CacheEntry struct {
+	Key   string   // backend key
+	Parts []string // key parts (endpoint, owner, repo...), nil if unknown
+	Size  int
+	Time  time.Time
+}
+
+// UnknownCacheKind is the kind of the entries whose key parts aren't known (files written
+// before the keys index existed).
+const UnknownCacheKind = "unknown"
+
+// Kind returns the kind of data of the entry, as the -cache-ttl kinds (lists, repos,
+// contents, proxy...), or UnknownCacheKind.
+func (e CacheEntry) Kind() string {
+	if len(e.Parts) == 0 {
+		return UnknownCacheKind
+	}
+	if kind, found := cacheKinds[e.Parts[0]]; found {
+		return kind
+	}
+	return UnknownCacheKind
+}
+
+// Name returns the readable key of the entry, or its backend key if unknown.
+func (e CacheEntry) Name() string {
+	if e.Parts == nil {
+		return e.Key + " (key parts unknown)"
+	}
+	return joinKeyParts(e.Parts)
+}
+
+// Matches returns true if the entry is about owner (and repo if not empty): GitHub
+// entries have the owner (user) as second key part and, but for the listings, the
+// repository as third.
+func (e CacheEntry) Matches(owner, repo string) bool {
+	if len(e.Parts) < 2 || !strings.EqualFold(e.Parts[1], owner) {
+		return false
+	}
+	switch {
+	case e.Kind() == "lists":
+		return repo == ""
+	case e.Parts[0] == "GetRepo", e.Parts[0] == "GetContents", e.Parts[0] == "GetTreeGoMods", e.Parts[0] == "GetTreeRoot":
+		return len(e.Parts) > 2 && (repo == "" || strings.EqualFold(e.Parts[2], repo))
+	default:
+		return false
+	}
+}
+
+// HasKey returns true if key is the entry's, as its backend key (or its last path element
+// for the files backend) or as its readable Name.
+func (e CacheEntry) HasKey(key string) bool {
+	return e.Key == key || joinKeyParts(e.Parts) == key || strings.HasSuffix(e.Key, "/"+key)
+}
+
+// Entries returns the entries of the (opened) cache, sorted by Name.
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	var entries []CacheEntry
+	if err := c.Store.list(c.Dir, func(e CacheEntry) { entries = append(entries, e) }); err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Remove removes the entry of the given backend key (CacheEntry.Key).
+func (c *Cache) Remove(key string) error {
+	return c.Store.remove(key)
+}
+
+// --- End Cache Inspection ---
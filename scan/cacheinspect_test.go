@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCacheEntries(t *testing.T) {
+	keys := [][]string{
+		{"ListByOrg", "acme", "1"},
+		{"GetContents", "acme", "log", "go.mod", ""},
+		{"GetRepo", "acme", "tools"},
+		{"GetContents", "bob", "util", "go.mod", ""},
+	}
+	tests := []struct {
+		owner, repo string
+		want        []string // names of the matching entries
+	}{
+		{owner: "acme", want: []string{"GetContents|acme|log|go.mod||", "GetRepo|acme|tools|", "ListByOrg|acme|1|"}},
+		{owner: "ACME", repo: "log", want: []string{"GetContents|acme|log|go.mod||"}},
+		{owner: "bob", want: []string{"GetContents|bob|util|go.mod||"}},
+		{owner: "carol"},
+	}
+	for _, backend := range []string{CacheBackendFiles, cacheBackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			c := newTestCache(t, backend, "")
+			ctx := context.Background()
+			for _, parts := range keys {
+				if err := c.write(ctx, c.key(parts...), CachedContentResponse{Found: true}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			entries, err := c.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(keys) || entries[0].Kind() != "contents" || entries[3].Kind() != "lists" {
+				t.Fatalf("entries %+v, want the %d written, sorted by name", entries, len(keys))
+			}
+			for _, tt := range tests {
+				var got []string
+				for _, e := range entries {
+					if e.Matches(tt.owner, tt.repo) {
+						got = append(got, e.Name())
+					}
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("entries of %s/%s: %v, want %v", tt.owner, tt.repo, got, tt.want)
+				}
+			}
+			if !entries[2].HasKey("GetRepo|acme|tools|") || !entries[2].HasKey(entries[2].Key) {
+				t.Errorf("%+v doesn't have its name or backend key", entries[2])
+			}
+			if err := c.Remove(entries[2].Key); err != nil {
+				t.Fatalf("remove error: %v", err)
+			}
+			var got CachedRepoResponse
+			if hit, _ := c.read(ctx, entries[2].Key, "GetRepo", &got); hit {
+				t.Errorf("read after remove: hit")
+			}
+			if entries, _ = c.Entries(); len(entries) != len(keys)-1 {
+				t.Errorf("%d entries after remove, want %d", len(entries), len(keys)-1)
+			}
+		})
+	}
+}
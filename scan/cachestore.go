@@ -0,0 +1,392 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// --- Cache Backends ---
+
+// Values of the -cache-backend flag.
+const (
+	CacheBackendFiles = "files"
+	cacheBackendBolt  = "bolt"
+)
+
+// CacheBackend stores the cache entries (JSON data), by key.
+type CacheBackend interface {
+	// key returns the key of the entry for the key parts (endpoint, owner, repo...).
+	key(cacheDir string, parts []string) string
+	// read returns the entry's data and when it was written (or revalidated), os.ErrNotExist
+	// if there is none.
+	read(ctx context.Context, key string) ([]byte, time.Time, error)
+	write(ctx context.Context, key string, data []byte) error
+	// touch sets the entry's time to now.
+	touch(ctx context.Context, key string) error
+	// open prepares the backend for the cache (its directory, settings), close releases it
+	// (e.g. before clearing the directory).
+	open(c *Cache) error
+	close() error
+	// list calls fn for each entry of the cache directory.
+	list(cacheDir string, fn func(e CacheEntry)) error
+	remove(key string) error
+}
+
+// joinKeyParts returns the readable form of key parts: "GetContents|owner|repo|go.mod|ref|".
+func joinKeyParts(parts []string) string {
+	return strings.Join(parts, "|") + "|"
+}
+
+// splitKeyParts is the reverse of joinKeyParts.
+func splitKeyParts(name string) []string {
+	return strings.Split(strings.TrimSuffix(name, "|"), "|")
+}
+
+// NewCacheBackend returns the backend for the -cache-backend value.
+func NewCacheBackend(name string) (CacheBackend, error) {
+	switch name {
+	case CacheBackendFiles:
+		return newFileBackend(), nil
+	case cacheBackendBolt:
+		return &boltBackend{}, nil
+	default:
+		if isRemoteCache(name) {
+			return newHTTPBackend(name), nil
+		}
+		return nil, fmt.Errorf("invalid cache backend %q, expecting %s, %s or an http(s):// URL", name, CacheBackendFiles, cacheBackendBolt)
+	}
+}
+
+// fileKeysIndex is the file, in the cache directory, recording the key parts of the entries
+// files ("<sha1>\t<key parts>" lines, appended), for the cache subcommand.
+const fileKeysIndex = "keys.tsv"
+
+// fileBackend stores each entry in a JSON file named after the sha1 of its key parts.
+type fileBackend struct {
+	mu      sync.Mutex
+	parts   map[string][]string // path -> key parts, of the keys computed in this run
+	indexed map[string]bool     // paths recorded in the keys index in this run
+}
+
+func newFileBackend() *fileBackend {
+	return &fileBackend{parts: make(map[string][]string), indexed: make(map[string]bool)}
+}
+
+func (f *fileBackend) key(cacheDir string, parts []string) string {
+	h := sha1.New()
+	for _, p := range parts {
+		io.WriteString(h, p)
+		io.WriteString(h, "|") // Separator
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+	key := filepath.Join(cacheDir, hash+".json")
+	f.mu.Lock()
+	f.parts[key] = parts
+	f.mu.Unlock()
+	return key
+}
+
+func (f *fileBackend) read(ctx context.Context, key string) ([]byte, time.Time, error) {
+	file, err := os.Open(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+	fi, err := file.Stat() // of the file read, even if replaced meanwhile
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, fi.ModTime(), nil
+}
+
+// WriteFileAtomic writes the file through a temporary file renamed over it, so readers, in
+// this or other depgraph processes (e.g. sharing the cache directory), never see a partial
+// content, and concurrent writers of the same file don't mix their data (the last one wins).
+func WriteFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// tmpSuffix is the suffix of the temporary files of WriteFileAtomic (ignored by list).
+const tmpSuffix = ".tmp"
+
+func (f *fileBackend) write(ctx context.Context, key string, data []byte) error {
+	if err := WriteFileAtomic(key, data); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts, known := f.parts[key]
+	if !known || f.indexed[key] {
+		return nil
+	}
+	f.indexed[key] = true
+	// Appended with a single write (O_APPEND), so lines of concurrent processes don't interleave.
+	index, err := os.OpenFile(filepath.Join(filepath.Dir(key), fileKeysIndex), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(index, "%s\t%s\n", strings.TrimSuffix(filepath.Base(key), ".json"), joinKeyParts(parts))
+	if closeErr := index.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (f *fileBackend) touch(ctx context.Context, key string) error {
+	now := time.Now()
+	return os.Chtimes(key, now, now)
+}
+
+func (f *fileBackend) open(*Cache) error { return nil }
+
+func (f *fileBackend) close() error { return nil }
+
+func (f *fileBackend) list(cacheDir string, fn func(e CacheEntry)) error {
+	names := make(map[string]string) // sha1 -> key parts
+	if content, err := os.ReadFile(filepath.Join(cacheDir, fileKeysIndex)); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if hash, name, found := strings.Cut(line, "\t"); found {
+				names[hash] = name
+			}
+		}
+	}
+	dirEntries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	for _, de := range dirEntries {
+		hash, isEntry := strings.CutSuffix(de.Name(), ".json")
+		if !isEntry || de.IsDir() {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		e := CacheEntry{Key: filepath.Join(cacheDir, de.Name()), Size: int(fi.Size()), Time: fi.ModTime()}
+		if name, found := names[hash]; found {
+			e.Parts = splitKeyParts(name)
+		}
+		fn(e)
+	}
+	return nil
+}
+
+func (f *fileBackend) remove(key string) error {
+	return os.Remove(key)
+}
+
+// boltFile is the name of the bbolt database in the cache directory.
+const boltFile = "cache.db"
+
+// boltLockTimeout is how long to wait for another depgraph to release the database.
+const boltLockTimeout = 30 * time.Second
+
+// Buckets of the bbolt database.
+var (
+	boltEntries = []byte("entries") // key -> write time (unix nanoseconds, 8 bytes) + JSON data
+	boltByTime  = []byte("by_time") // write time + key -> nothing: index of the entries by age
+)
+
+// boltBackend stores the entries in a single bbolt database file. Keys are the readable
+// key parts ("GetContents|owner|repo|go.mod|ref|"), so they are ordered (indexed) by
+// endpoint then owner and repo, and the by_time bucket indexes them by write time.
+type boltBackend struct {
+	mu   sync.Mutex
+	path string
+	db   *bolt.DB
+}
+
+func (b *boltBackend) key(_ string, parts []string) string {
+	return joinKeyParts(parts)
+}
+
+func (b *boltBackend) open(c *Cache) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path := filepath.Join(c.Dir, boltFile)
+	if b.db != nil && b.path == path {
+		return nil
+	}
+	// The database is locked (flock) while open: another depgraph using it makes us wait.
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: boltLockTimeout})
+	if err != nil {
+		return fmt.Errorf("opening cache database %s (used by another depgraph? use -cache-backend=files to share the cache): %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltEntries, boltByTime} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	b.path, b.db = path, db
+	return nil
+}
+
+func (b *boltBackend) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db == nil {
+		return nil
+	}
+	err := b.db.Close()
+	b.db = nil
+	return err
+}
+
+// database returns the open database, an error if it isn't (see Cache.Open).
+func (b *boltBackend) database() (*bolt.DB, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db == nil {
+		return nil, errors.New("cache database not open")
+	}
+	return b.db, nil
+}
+
+// timeKey returns the by_time index key of an entry written at t.
+func timeKey(t time.Time, key string) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())), key...)
+}
+
+// decodeBoltEntry splits a stored entry in its time and data.
+func decodeBoltEntry(value []byte) (time.Time, []byte, error) {
+	if len(value) < 8 {
+		return time.Time{}, nil, errors.New("corrupted cache entry")
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value))), value[8:], nil
+}
+
+func (b *boltBackend) read(ctx context.Context, key string) ([]byte, time.Time, error) {
+	db, err := b.database()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var data []byte
+	var modTime time.Time
+	err = db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltEntries).Get([]byte(key))
+		if value == nil {
+			return os.ErrNotExist
+		}
+		var entryData []byte
+		modTime, entryData, err = decodeBoltEntry(value)
+		data = append([]byte(nil), entryData...) // only valid during the transaction
+		return err
+	})
+	return data, modTime, err
+}
+
+// put stores the entry's data (nil to keep the current one) with the time now, updating the index.
+func (b *boltBackend) put(key string, data []byte, now time.Time) error {
+	db, err := b.database()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		entries, byTime := tx.Bucket(boltEntries), tx.Bucket(boltByTime)
+		if old := entries.Get([]byte(key)); old != nil {
+			oldTime, oldData, err := decodeBoltEntry(old)
+			if err == nil {
+				if err := byTime.Delete(timeKey(oldTime, key)); err != nil {
+					return err
+				}
+			}
+			if data == nil {
+				data = append([]byte(nil), oldData...)
+			}
+		} else if data == nil {
+			return os.ErrNotExist
+		}
+		value := append(binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano())), data...)
+		if err := entries.Put([]byte(key), value); err != nil {
+			return err
+		}
+		return byTime.Put(timeKey(now, key), nil)
+	})
+}
+
+func (b *boltBackend) write(ctx context.Context, key string, data []byte) error {
+	return b.put(key, data, time.Now())
+}
+
+func (b *boltBackend) touch(ctx context.Context, key string) error {
+	return b.put(key, nil, time.Now())
+}
+
+func (b *boltBackend) list(_ string, fn func(e CacheEntry)) error {
+	db, err := b.database()
+	if err != nil {
+		return err
+	}
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltEntries).ForEach(func(k, v []byte) error {
+			t, data, err := decodeBoltEntry(v)
+			if err != nil {
+				return err
+			}
+			fn(CacheEntry{Key: string(k), Parts: splitKeyParts(string(k)), Size: len(data), Time: t})
+			return nil
+		})
+	})
+}
+
+func (b *boltBackend) remove(key string) error {
+	db, err := b.database()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(boltEntries)
+		value := entries.Get([]byte(key))
+		if value == nil {
+			return os.ErrNotExist
+		}
+		if t, _, err := decodeBoltEntry(value); err == nil {
+			if err := tx.Bucket(boltByTime).Delete(timeKey(t, key)); err != nil {
+				return err
+			}
+		}
+		return entries.Delete([]byte(key))
+	})
+}
+
+// --- End Cache Backends ---
@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheBackends(t *testing.T) {
+	for _, backend := range []string{CacheBackendFiles, cacheBackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			c := newTestCache(t, backend, "")
+			ctx := context.Background()
+			parts := []string{"GetContents", "acme", "log", "go.mod", ""}
+			key := c.key(parts...)
+			var got CachedContentResponse
+			if hit, err := c.read(ctx, key, parts[0], &got); hit || err != nil {
+				t.Fatalf("read before write: hit %v, error %v", hit, err)
+			}
+			want := CachedContentResponse{Found: true, ETag: `"abc"`}
+			if err := c.write(ctx, key, want); err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			if hit, err := c.read(ctx, key, parts[0], &got); !hit || err != nil || got.Found != want.Found || got.ETag != want.ETag {
+				t.Fatalf("read after write: hit %v, error %v, %+v, want %+v", hit, err, got, want)
+			}
+			_, written, err := c.Store.read(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+			c.touch(ctx, key)
+			if _, touched, _ := c.Store.read(ctx, key); !touched.After(written) {
+				t.Errorf("touch didn't refresh the entry: written %v, touched %v", written, touched)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+	"context"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// --- Deprecated Modules ---
+
+// DeprecatedColor is the fill color of the deprecated modules (-check-deprecated).
+const DeprecatedColor = "khaki"
+
+// Deprecation returns the "// Deprecated:" comment message of the module's go.mod at the
+// given version (cached), "" if it isn't deprecated (or the proxy doesn't know it).
+func (pc *ProxyClient) Deprecation(ctx context.Context, modPath, version string) (string, error) {
+	keyParts := []string{"ProxyDeprecated", pc.baseURL, modPath, version}
+	cacheKey := pc.cache.key(keyParts...)
+	var cachedData string
+	hit, readErr := pc.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	if hit {
+		pc.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for proxy go.mod module=%s@%s", modPath, version)
+		return cachedData, nil
+	}
+	log.Infof("Cache miss for proxy go.mod module=%s@%s, calling %s", modPath, version, pc.baseURL)
+	pc.stats.miss()
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	body, err := pc.get(ctx, modPath, "@v/"+escapedVersion+".mod")
+	if err != nil {
+		return "", err
+	}
+	deprecated := ""
+	if body != nil {
+		modFile, err := modfile.ParseLax(modPath+"@"+version+"/go.mod", body, nil)
+		if err != nil {
+			return "", err
+		}
+		if modFile.Module != nil {
+			deprecated = modFile.Module.Deprecated
+		}
+	}
+	writeErr := pc.cache.write(ctx, cacheKey, deprecated)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return deprecated, nil
+}
+
+// FetchDeprecations returns the deprecation message of the graph's modules that are
+// deprecated, as declared in the go.mod of their latest version.
+func FetchDeprecations(ctx context.Context, pc *ProxyClient, latest map[string]*ProxyModuleInfo) map[string]string {
+	res := make(map[string]string)
+	for _, path := range graph.SortedKeys(latest) {
+		if ctx.Err() != nil {
+			break // canceled (Ctrl-C, -timeout)
+		}
+		pi := latest[path]
+		if !pi.Found || pi.Latest == "" {
+			continue
+		}
+		msg, err := pc.Deprecation(ctx, path, pi.Latest)
+		if err != nil {
+			log.Warnf("Error getting go.mod of %s@%s: %v", path, pi.Latest, err)
+			continue
+		}
+		if msg != "" {
+			res[path] = msg
+		}
+	}
+	return res
+}
+
+// WarnDeprecatedDependencies logs a warning for each dependency of a scanned module on a
+// deprecated module.
+func WarnDeprecatedDependencies(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, deprecated map[string]string) {
+	for _, src := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[src]
+		if info == nil {
+			continue
+		}
+		for _, dep := range graph.SortedKeys(info.Deps) {
+			if msg, found := deprecated[dep]; found {
+				log.Warnf("%s depends on deprecated module %s: %s", src, dep, msg)
+			}
+		}
+	}
+}
+
+// --- End Deprecated Modules ---
@@ -0,0 +1,202 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/semver"
+)
+
+// --- deps.dev Enrichment ---
+
+const depsDevBaseURL = "https://api.deps.dev"
+
+// DepsDevInfo is the deps.dev data we attach to a module (for a given version).
+type DepsDevInfo struct {
+	Found          bool     `json:"found"`
+	Version        string   `json:"version,omitempty"`
+	Licenses       []string `json:"licenses,omitempty"`
+	Advisories     []string `json:"advisories,omitempty"` // OSV/GHSA ids
+	DependentCount int      `json:"dependent_count,omitempty"`
+}
+
+// DepsDevClient queries the deps.dev API, caching results like the GitHub calls.
+type DepsDevClient struct {
+	baseURL    string
+	httpClient *http.Client
+	cache      *Cache
+	stats      *APIStats // can be nil
+}
+
+func NewDepsDevClient(cache *Cache, stats *APIStats) *DepsDevClient {
+	return &DepsDevClient{
+		baseURL:    depsDevBaseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: cache.transport(stats)},
+		cache:      cache,
+		stats:      stats,
+	}
+}
+
+// getJSON fetches the deps.dev api path into target, returns false for not found.
+func (dc *DepsDevClient) getJSON(ctx context.Context, path string, target any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dc.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := dc.httpClient.Do(req)
+	dc.stats.call("deps.dev", nil, err)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("deps.dev %s: %s", path, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(body, target)
+}
+
+// defaultVersion returns the deps.dev default version of the package ("" if not found).
+func (dc *DepsDevClient) defaultVersion(ctx context.Context, modPath string) (string, error) {
+	var pkg struct {
+		Versions []struct {
+			VersionKey struct{ Version string }
+			IsDefault  bool
+		}
+	}
+	found, err := dc.getJSON(ctx, "/v3/systems/go/packages/"+url.PathEscape(modPath), &pkg)
+	if !found || err != nil {
+		return "", err
+	}
+	for _, v := range pkg.Versions {
+		if v.IsDefault {
+			return v.VersionKey.Version, nil
+		}
+	}
+	return "", nil
+}
+
+// Info returns the deps.dev information for the module at version (the default version if empty).
+func (dc *DepsDevClient) Info(ctx context.Context, modPath, version string) (*DepsDevInfo, error) {
+	keyParts := []string{"DepsDev", modPath, version}
+	cacheKey := dc.cache.key(keyParts...)
+	var cachedData DepsDevInfo
+	hit, readErr := dc.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	if hit {
+		dc.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for deps.dev module=%s version=%s", modPath, version)
+		return &cachedData, nil
+	}
+	log.Infof("Cache miss for deps.dev module=%s version=%s, calling API", modPath, version)
+	dc.stats.miss()
+	info := &DepsDevInfo{}
+	if version == "" {
+		v, err := dc.defaultVersion(ctx, modPath)
+		if err != nil {
+			return nil, err
+		}
+		version = v
+	}
+	if version != "" {
+		var ver struct {
+			Licenses     []string
+			AdvisoryKeys []struct{ ID string }
+		}
+		versionPath := "/systems/go/packages/" + url.PathEscape(modPath) + "/versions/" + url.PathEscape(version)
+		found, err := dc.getJSON(ctx, "/v3"+versionPath, &ver)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			info.Found = true
+			info.Version = version
+			info.Licenses = ver.Licenses
+			for _, a := range ver.AdvisoryKeys {
+				info.Advisories = append(info.Advisories, a.ID)
+			}
+			var dependents struct{ DependentCount int }
+			// Dependents are only in the alpha API, so errors there aren't fatal.
+			if _, err := dc.getJSON(ctx, "/v3alpha"+versionPath+":dependents", &dependents); err != nil {
+				log.LogVf("No dependents count for %s@%s: %v", modPath, version, err)
+			}
+			info.DependentCount = dependents.DependentCount
+		}
+	}
+	writeErr := dc.cache.write(ctx, cacheKey, info)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return info, nil
+}
+
+// requiredVersions returns, for each node, the highest version required by the scanned modules.
+func requiredVersions(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) map[string]string {
+	res := make(map[string]string)
+	for src, info := range modulesFoundInOwners {
+		if !nodesToGraph[src] {
+			continue
+		}
+		for dep, version := range info.Deps {
+			if prev, found := res[dep]; !found || semver.Compare(version, prev) > 0 {
+				res[dep] = version
+			}
+		}
+	}
+	return res
+}
+
+// FetchDepsDevInfo queries deps.dev for every node of the graph, at the highest version
+// required in the graph (or the default version for modules nobody requires).
+func FetchDepsDevInfo(ctx context.Context, dc *DepsDevClient, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) map[string]*DepsDevInfo {
+	versions := requiredVersions(modulesFoundInOwners, nodesToGraph)
+	res := make(map[string]*DepsDevInfo, len(nodesToGraph))
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		if ctx.Err() != nil {
+			break // canceled (Ctrl-C, -timeout)
+		}
+		if !isGoModule(path) {
+			continue // -manifests npm/cargo packages
+		}
+		info, err := dc.Info(ctx, path, versions[path])
+		if err != nil {
+			log.Warnf("Error getting deps.dev info for %s: %v", path, err)
+			continue
+		}
+		if len(info.Advisories) > 0 {
+			log.Warnf("Module %s@%s has %d advisories: %s", path, info.Version, len(info.Advisories), strings.Join(info.Advisories, ", "))
+		}
+		res[path] = info
+	}
+	return res
+}
+
+// depsDevTooltip is the DOT tooltip text for a node with deps.dev information.
+func depsDevTooltip(di *DepsDevInfo) string {
+	if di == nil || !di.Found {
+		return "not found on deps.dev"
+	}
+	licenses := "unknown license"
+	if len(di.Licenses) > 0 {
+		licenses = strings.Join(di.Licenses, ", ")
+	}
+	return fmt.Sprintf("%s: %s, %d advisories, %d dependents", di.Version, licenses, len(di.Advisories), di.DependentCount)
+}
+
+// --- End deps.dev Enrichment ---
@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Scan Errors ---
+
+// ErrorKind classifies the errors that made the scan skip a repository (or one of its files).
+type ErrorKind string
+
+const (
+	ErrorParse   ErrorKind = "parse"     // invalid go.mod (or manifest)
+	ErrorAPI     ErrorKind = "api"       // failed to get the repository, its listing or its files
+	ErrorNoGoMod ErrorKind = "no_go_mod" // the repository has no go.mod (at the scanned ref)
+)
+
+// RepoError is a typed scan error, recorded in Result.Errors so partial scans can be
+// detected (see ErrorSummary) instead of only being logged.
+type RepoError struct {
+	Repo string    // owner/repo (gist:owner/id, local directory), or the owner for listing errors
+	File string    // file concerned (e.g. "go.mod", "sub/go.mod", "go.sum"), "" for the repository
+	Kind ErrorKind // ErrorParse, ErrorAPI or ErrorNoGoMod
+	Err  error     // underlying error, nil for ErrorNoGoMod
+}
+
+func (e *RepoError) Error() string {
+	switch {
+	case e.Kind == ErrorNoGoMod:
+		return "no go.mod in " + e.Repo
+	case e.Kind == ErrorParse:
+		return fmt.Sprintf("error parsing %s for %s: %v", e.File, e.Repo, e.Err)
+	case e.File != "":
+		return fmt.Sprintf("error getting %s for %s: %v", e.File, e.Repo, e.Err)
+	default:
+		return fmt.Sprintf("error getting %s: %v", e.Repo, e.Err)
+	}
+}
+
+func (e *RepoError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON writes the error as {"repo", "file", "kind", "error"}.
+func (e *RepoError) MarshalJSON() ([]byte, error) {
+	msg := ""
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Repo  string    `json:"repo"`
+		File  string    `json:"file,omitempty"`
+		Kind  ErrorKind `json:"kind"`
+		Error string    `json:"error,omitempty"`
+	}{e.Repo, e.File, e.Kind, msg})
+}
+
+// addError logs and records a scan error, notifying the context's hooks of the API errors.
+// Errors due to the run being canceled (Ctrl-C, -timeout) aren't recorded. Missing go.mod
+// are only logged in verbose mode.
+func (sr *Result) addError(ctx context.Context, e *RepoError) {
+	if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+		return
+	}
+	switch e.Kind {
+	case ErrorNoGoMod:
+		log.LogVf("      %v", e)
+	case ErrorAPI:
+		ContextHooks(ctx).apiError(e)
+		log.Warnf("      %v", e)
+	default:
+		log.Warnf("      %v", e)
+	}
+	sr.Errors = append(sr.Errors, e)
+}
+
+// ErrorSummary is the end-of-run summary of the scan errors, also in the JSON output.
+type ErrorSummary struct {
+	Partial bool              `json:"partial"` // some repositories (or files) couldn't be scanned: parse or API errors
+	Skipped int               `json:"skipped"` // number of repositories (or owners) with errors
+	Counts  map[ErrorKind]int `json:"counts,omitempty"`
+	Errors  []*RepoError      `json:"errors,omitempty"`
+}
+
+// ErrorSummary returns the summary of the errors recorded during the scan.
+func (sr *Result) ErrorSummary() *ErrorSummary {
+	s := &ErrorSummary{Errors: sr.Errors}
+	repos := make(map[string]bool)
+	for _, e := range sr.Errors {
+		if s.Counts == nil {
+			s.Counts = make(map[ErrorKind]int)
+		}
+		s.Counts[e.Kind]++
+		repos[e.Repo] = true
+		s.Partial = s.Partial || e.Kind != ErrorNoGoMod
+	}
+	s.Skipped = len(repos)
+	return s
+}
+
+// LogReport logs the error summary (nothing if there were no errors).
+func (s *ErrorSummary) LogReport() {
+	if s == nil || s.Skipped == 0 {
+		return
+	}
+	msg := fmt.Sprintf("%d repositories skipped: %d parse errors, %d API errors, %d without go.mod",
+		s.Skipped, s.Counts[ErrorParse], s.Counts[ErrorAPI], s.Counts[ErrorNoGoMod])
+	if s.Partial {
+		log.Warnf("Partial scan, %s", msg)
+		return
+	}
+	log.Infof("%s", msg)
+}
+
+// --- End Scan Errors ---
@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+)
+
+// --- Conditional Requests (ETag revalidation) ---
+
+// ifNoneMatchKey is the context key of the ETag to send as If-None-Match.
+type ifNoneMatchKey struct{}
+
+// ETagTransport adds the If-None-Match header of the request's context (see conditional):
+// go-github has no per-call headers.
+type ETagTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *ETagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	etag, _ := req.Context().Value(ifNoneMatchKey{}).(string)
+	if etag == "" {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("If-None-Match", etag)
+	return base.RoundTrip(req)
+}
+
+// conditional returns the context for the API call of a cached method and whether it
+// revalidates the cached entry: when the entry has an ETag and is either expired (not a hit,
+// see -cache-ttl) or a hit with -revalidate set, the call is conditional and answered with
+// a 304 Not Modified (not counted in the rate limit) if the entry is still up to date.
+func (cw *ClientWrapper) conditional(ctx context.Context, hit bool, etag string, keyParts []string) (context.Context, bool) {
+	if etag == "" || (hit && (!cw.Revalidate || cw.resumed(ctx, keyParts))) {
+		return ctx, false
+	}
+	log.LogVf("Revalidating cache entry %v (ETag %s)", keyParts, etag)
+	return context.WithValue(ctx, ifNoneMatchKey{}, etag), true
+}
+
+// notModified returns true if the conditional call (revalidating) found the cached entry
+// still up to date, counting it as a cache hit (and making it fresh again); as a miss otherwise.
+func (cw *ClientWrapper) notModified(ctx context.Context, revalidating bool, resp *github.Response, cacheKey string, keyParts []string) bool {
+	if !revalidating {
+		return false
+	}
+	if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotModified {
+		cw.stats.hit(ctx, keyParts)
+		cw.stats.revalidated()
+		cw.cache.touch(ctx, cacheKey)
+		return true
+	}
+	cw.stats.miss()
+	return false
+}
+
+// responseETag returns the ETag of the API response, to store with the cached entry.
+func responseETag(resp *github.Response) string {
+	if resp == nil || resp.Response == nil {
+		return ""
+	}
+	return resp.Header.Get("ETag")
+}
+
+// --- End Conditional Requests (ETag revalidation) ---
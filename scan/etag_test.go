@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+)
+
+// TestRevalidation scans the fake API twice: the second scan is answered from the cache, or
+// with 304 Not Modified for the revalidated entries, unless the file changed.
+func TestRevalidation(t *testing.T) {
+	tests := []struct {
+		name            string
+		ttl             string
+		revalidate      bool
+		change          bool // acme/log's go.mod changes between the scans
+		wantRequests    bool
+		wantNotModified bool
+		wantGo          string // of github.com/acme/log after the second scan
+	}{
+		{name: "fresh hits", wantGo: "1.22"},
+		{name: "fresh hits of a changed file", change: true, wantGo: "1.22"},
+		{name: "revalidated hits", revalidate: true, wantRequests: true, wantNotModified: true, wantGo: "1.22"},
+		{name: "revalidated hits of a changed file", revalidate: true, change: true, wantRequests: true, wantNotModified: true, wantGo: "1.24"},
+		{name: "expired entries", ttl: "1ns", wantRequests: true, wantNotModified: true, wantGo: "1.22"},
+		{name: "expired entry of a changed file", ttl: "1ns", change: true, wantRequests: true, wantNotModified: true, wantGo: "1.24"},
+	}
+	for _, tt := range tests {
+		s := fakegithub.Fixture()
+		stats := newAPIStats()
+		cw := newTestClient(s, newTestCache(t, CacheBackendFiles, tt.ttl), stats)
+		cw.Revalidate = tt.revalidate
+		scanAcme(s, cw, 4)
+		if tt.change {
+			s.SetFile("acme", "log", "go.mod", "module github.com/acme/log\n\ngo 1.24\n")
+		}
+		notModified := stats.NotModified
+		modules, requests := scanAcme(s, cw, 4)
+		s.Close()
+		if (requests > 0) != tt.wantRequests {
+			t.Errorf("%s: %d requests, want requests %v", tt.name, requests, tt.wantRequests)
+		}
+		if got := stats.NotModified - notModified; (got > 0) != tt.wantNotModified {
+			t.Errorf("%s: %d not modified, want some %v", tt.name, got, tt.wantNotModified)
+		}
+		if log := modules["github.com/acme/log"]; log == nil || log.GoVersion != tt.wantGo {
+			t.Errorf("%s: github.com/acme/log %+v, want go %s", tt.name, log, tt.wantGo)
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestConditional(t *testing.T) {
+	tests := []struct {
+		name       string
+		hit        bool
+		etag       string
+		revalidate bool
+		want       bool
+	}{
+		{name: "miss without etag"},
+		{name: "miss with etag (expired)", etag: `"e"`, want: true},
+		{name: "hit", hit: true, etag: `"e"`},
+		{name: "hit with -revalidate", hit: true, etag: `"e"`, revalidate: true, want: true},
+		{name: "hit without etag with -revalidate", hit: true, revalidate: true},
+	}
+	for _, tt := range tests {
+		seen := ""
+		transport := &ETagTransport{Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			seen = req.Header.Get("If-None-Match")
+			return &http.Response{StatusCode: http.StatusNotModified, Body: http.NoBody}, nil
+		})}
+		cw := &ClientWrapper{Revalidate: tt.revalidate}
+		ctx, got := cw.conditional(context.Background(), tt.hit, tt.etag, []string{"GetContents", "acme", "log", "go.mod", ""})
+		if got != tt.want {
+			t.Errorf("%s: revalidating %v, want %v", tt.name, got, tt.want)
+		}
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]string{true: tt.etag}[tt.want]; seen != want {
+			t.Errorf("%s: If-None-Match %q, want %q", tt.name, seen, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package scan
+
+import (
+	"context"
+	"strconv"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- Gists Scanning ---
+
+// CachedGistListResponse caches a page of a user's gists listing.
+type CachedGistListResponse struct {
+	Gists    []*github.Gist
+	NextPage int
+	ETag     string `json:",omitempty"` // for -revalidate
+}
+
+// CachedGistResponse caches a single gist (with its files content).
+type CachedGistResponse struct {
+	Gist *github.Gist
+	ETag string `json:",omitempty"`
+}
+
+func (cw *ClientWrapper) getCachedListGists(ctx context.Context, user string, opt *github.GistListOptions) ([]*github.Gist, *github.Response, error) {
+	keyParts := []string{"ListGists", user, strconv.Itoa(opt.Page)}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedGistListResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for ListGists user=%s page=%d", user, opt.Page)
+		return cachedData.Gists, &github.Response{NextPage: cachedData.NextPage}, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for ListGists user=%s page=%d, calling API", user, opt.Page)
+		cw.stats.miss()
+	}
+	gists, resp, apiErr := cw.client.Gists.List(ctx, user, opt)
+	cw.stats.call("ListGists", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Gists, &github.Response{NextPage: cachedData.NextPage}, nil
+	}
+	if apiErr != nil {
+		return nil, resp, apiErr
+	}
+	writeErr := cw.cache.write(ctx, cacheKey, CachedGistListResponse{Gists: gists, NextPage: resp.NextPage, ETag: responseETag(resp)})
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return gists, resp, nil
+}
+
+func (cw *ClientWrapper) getCachedGetGist(ctx context.Context, id string) (*github.Gist, error) {
+	keyParts := []string{"GetGist", id}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedGistResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for GetGist id=%s", id)
+		return cachedData.Gist, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for GetGist id=%s, calling API", id)
+		cw.stats.miss()
+	}
+	gist, resp, apiErr := cw.client.Gists.Get(ctx, id)
+	cw.stats.call("GetGist", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Gist, nil
+	}
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	writeErr := cw.cache.write(ctx, cacheKey, CachedGistResponse{Gist: gist, ETag: responseETag(resp)})
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return gist, nil
+}
+
+// Gists lists the public gists of owner and adds the modules of those containing a go.mod.
+// The repo path of such modules is gist:<owner>/<gist id>.
+func Gists(ctx context.Context, client *ClientWrapper, owner string, ownerIdx int, res *Result) {
+	opt := &github.GistListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		gists, resp, err := client.getCachedListGists(ctx, owner, opt)
+		if err != nil {
+			log.Errf("Error listing gists for %s: %v", owner, err)
+			return
+		}
+		log.Infof("    Processing gists page %d for %s, %d gists", max(opt.Page, 1), owner, len(gists))
+		for _, g := range gists {
+			if ctx.Err() != nil {
+				return // canceled (Ctrl-C, -timeout)
+			}
+			if _, found := g.Files["go.mod"]; !found {
+				continue
+			}
+			scanGist(ctx, client, g.GetID(), owner, ownerIdx, res)
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// scanGist fetches a gist's go.mod content and records its module.
+func scanGist(ctx context.Context, client *ClientWrapper, id, owner string, ownerIdx int, res *Result) {
+	repoPath := "gist:" + owner + "/" + id
+	gist, err := client.getCachedGetGist(ctx, id)
+	if err != nil {
+		res.addError(ctx, &RepoError{Repo: repoPath, Kind: ErrorAPI, Err: err})
+		return
+	}
+	goMod, found := gist.Files["go.mod"]
+	if !found {
+		return
+	}
+	modFile, err := parseGoMod(repoPath+"/go.mod", []byte(goMod.GetContent()))
+	if err != nil {
+		res.addError(ctx, &RepoError{Repo: repoPath, File: "go.mod", Kind: ErrorParse, Err: err})
+		res.addErrorModule(&graph.ModuleInfo{RepoPath: repoPath, Owner: owner, OwnerIdx: ownerIdx}, err)
+		return
+	}
+	log.Infof("      Found module %s in %s", modFile.Module.Mod.Path, repoPath)
+	info := &graph.ModuleInfo{Path: modFile.Module.Mod.Path, RepoPath: repoPath, Owner: owner, OwnerIdx: ownerIdx}
+	res.addModule(info, modFile)
+	if goSum, found := gist.Files["go.sum"]; found {
+		res.addTransitive(info, parseGoSum([]byte(goSum.GetContent())))
+	}
+}
+
+// --- End Gists Scanning ---
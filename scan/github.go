@@ -0,0 +1,961 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/provider"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// --- Utility Functions ---
+// isNotFoundError checks if an error is a GitHub API 404 Not Found error
+func isNotFoundError(err error) bool {
+	var ge *github.ErrorResponse
+	if errors.As(err, &ge) {
+		return ge.Response.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// --- End Utility Functions ---
+
+// --- GitHub Client Wrapper ---
+
+// ClientWrapper wraps the GitHub client and cache settings
+type ClientWrapper struct {
+	client      *github.Client
+	cache       *Cache    // nil for no caching
+	stats       *APIStats // API calls and cache usage (can be nil)
+	login       string    // token's user, see authenticatedLogin()
+	loginErr    error
+	GraphQL     bool                 // prefetch go.mod files and fork parents with GraphQL batches (-graphql)
+	Revalidate  bool                 // revalidate the cache hits having an ETag with conditional calls (-revalidate)
+	Incremental bool                 // refetch the contents of the repositories pushed to since cached (-incremental)
+	pushedAt    map[string]time.Time // lowercase owner/repo -> pushed_at, see recordPushedAt()
+	Progress    *Progress            // repositories scanned, see NewProgress() (nil with -quiet)
+	Checkpoint  *Checkpoint          // repositories scanned, for -resume (nil if not recorded)
+
+	mu         sync.Mutex     // guards prefetched and pushedAt (parallel scans)
+	prefetched map[string]any // cache key -> GraphQL batch result, see setPrefetched()
+}
+
+// NewClientWrapper creates a new GitHub client wrapper, using the (opened) cache, if not nil.
+func NewClientWrapper(client *github.Client, cache *Cache, stats *APIStats) *ClientWrapper {
+	return &ClientWrapper{
+		client: client,
+		cache:  cache,
+		stats:  stats,
+	}
+}
+
+// --- Cached GitHub API Methods ---
+
+func (cw *ClientWrapper) getCachedListByOrg(ctx context.Context, owner string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+	keyParts := []string{"ListByOrg", owner, strconv.Itoa(opt.Page)}
+	if opt.Type != VisibilityPublic {
+		keyParts = append(keyParts, opt.Type) // keeps the historical key for public listings
+	}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedListResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	hit, _ = cw.incrementalHit(ctx, hit, keyParts, time.Time{})
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for ListByOrg owner=%s page=%d", owner, opt.Page)
+		resp := &github.Response{NextPage: cachedData.NextPage}
+		return cachedData.Repos, resp, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for ListByOrg owner=%s page=%d, calling API", owner, opt.Page)
+		cw.stats.miss()
+	}
+	repos, resp, apiErr := cw.client.Repositories.ListByOrg(ctx, owner, opt)
+	cw.stats.call("ListByOrg", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Repos, &github.Response{NextPage: cachedData.NextPage}, nil
+	}
+	if apiErr != nil {
+		return nil, resp, apiErr
+	}
+	repos = slimRepos(repos)
+	dataToCache := CachedListResponse{Repos: repos, NextPage: resp.NextPage, ETag: responseETag(resp)}
+	writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return repos, resp, nil
+}
+
+func (cw *ClientWrapper) getCachedListByUser(ctx context.Context, user string, opt *github.RepositoryListByUserOptions) ([]*github.Repository, *github.Response, error) {
+	keyParts := []string{"ListByUser", user, opt.Type, strconv.Itoa(opt.Page)}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedListResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	hit, _ = cw.incrementalHit(ctx, hit, keyParts, time.Time{})
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for ListByUser user=%s type=%s page=%d", user, opt.Type, opt.Page)
+		resp := &github.Response{NextPage: cachedData.NextPage}
+		return cachedData.Repos, resp, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for ListByUser user=%s type=%s page=%d, calling API", user, opt.Type, opt.Page)
+		cw.stats.miss()
+	}
+	repos, resp, apiErr := cw.client.Repositories.ListByUser(ctx, user, opt)
+	cw.stats.call("ListByUser", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Repos, &github.Response{NextPage: cachedData.NextPage}, nil
+	}
+	if apiErr != nil {
+		return nil, resp, apiErr
+	}
+	repos = slimRepos(repos)
+	dataToCache := CachedListResponse{Repos: repos, NextPage: resp.NextPage, ETag: responseETag(resp)}
+	writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return repos, resp, nil
+}
+
+func (cw *ClientWrapper) getCachedListByAuthenticatedUser(ctx context.Context, opt *github.RepositoryListByAuthenticatedUserOptions) ([]*github.Repository, *github.Response, error) {
+	login, _ := cw.authenticatedLogin(ctx)
+	keyParts := []string{"ListByAuthenticatedUser", login, opt.Visibility, strconv.Itoa(opt.Page)}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedListResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	hit, _ = cw.incrementalHit(ctx, hit, keyParts, time.Time{})
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for ListByAuthenticatedUser user=%s visibility=%s page=%d", login, opt.Visibility, opt.Page)
+		resp := &github.Response{NextPage: cachedData.NextPage}
+		return cachedData.Repos, resp, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for ListByAuthenticatedUser user=%s visibility=%s page=%d, calling API", login, opt.Visibility, opt.Page)
+		cw.stats.miss()
+	}
+	repos, resp, apiErr := cw.client.Repositories.ListByAuthenticatedUser(ctx, opt)
+	cw.stats.call("ListByAuthenticatedUser", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Repos, &github.Response{NextPage: cachedData.NextPage}, nil
+	}
+	if apiErr != nil {
+		return nil, resp, apiErr
+	}
+	repos = slimRepos(repos)
+	dataToCache := CachedListResponse{Repos: repos, NextPage: resp.NextPage, ETag: responseETag(resp)}
+	writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return repos, resp, nil
+}
+
+// authenticatedLogin returns the login of the token's user (not cached as it depends on
+// the token, but only fetched once per run).
+func (cw *ClientWrapper) authenticatedLogin(ctx context.Context) (string, error) {
+	if cw.login != "" || cw.loginErr != nil {
+		return cw.login, cw.loginErr
+	}
+	user, resp, err := cw.client.Users.Get(ctx, "")
+	cw.stats.call("GetUser", resp, err)
+	if err != nil {
+		cw.loginErr = err
+		return "", err
+	}
+	cw.login = user.GetLogin()
+	return cw.login, nil
+}
+
+func (cw *ClientWrapper) getCachedGetContents(ctx context.Context, owner, repo, path string, opt *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	ref := ""
+	if opt != nil {
+		ref = opt.Ref
+	}
+	keyParts := []string{"GetContents", owner, repo, path, ref}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedContentResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+
+	hit, fresh := cw.incrementalHit(ctx, hit, keyParts, cachedData.PushedAt)
+	etag := cachedData.ETag
+	if fresh {
+		etag = "" // no revalidation needed (or useful if pushed to since)
+	}
+	ctx, revalidating := cw.conditional(ctx, hit, etag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		if !cachedData.Found {
+			log.LogVf("Cache hit indicates Not Found for GetContents repo=%s/%s path=%s ref=%s", owner, repo, path, ref)
+			return nil, nil, &github.Response{}, nil
+		} else {
+			log.LogVf("Cache hit indicates Found for GetContents repo=%s/%s path=%s ref=%s", owner, repo, path, ref)
+			return cachedData.FileContent, nil, &github.Response{}, nil
+		}
+	}
+
+	if !revalidating {
+		cw.stats.miss()
+		if p, ok := cw.getPrefetched(cacheKey).(*CachedContentResponse); ok {
+			log.LogVf("Prefetched (GraphQL) GetContents repo=%s/%s path=%s ref=%s, found=%v", owner, repo, path, ref, p.Found)
+			p.PushedAt = cw.repoPushedAt(owner, repo)
+			writeErr := cw.cache.write(ctx, cacheKey, p)
+			if writeErr != nil {
+				log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+			}
+			return p.FileContent, nil, &github.Response{}, nil
+		}
+		log.Infof("Cache miss for GetContents repo=%s/%s path=%s ref=%s, calling API", owner, repo, path, ref)
+	}
+	fileContent, dirContent, resp, apiErr := cw.client.Repositories.GetContents(ctx, owner, repo, path, opt)
+	cw.stats.call("GetContents", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		log.LogVf("Not modified: GetContents repo=%s/%s path=%s ref=%s", owner, repo, path, ref)
+		return cachedData.FileContent, nil, resp, nil
+	}
+
+	if apiErr != nil {
+		if isNotFoundError(apiErr) {
+			log.LogVf("API reported Not Found for GetContents repo=%s/%s path=%s ref=%s. Caching result.", owner, repo, path, ref)
+			dataToCache := CachedContentResponse{Found: false, PushedAt: cw.repoPushedAt(owner, repo)}
+			writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+			if writeErr != nil {
+				log.Errf("Error writing 'Not Found' cache for %v: %v", keyParts, writeErr)
+			}
+			return nil, nil, resp, nil
+		} else {
+			return nil, nil, resp, apiErr
+		}
+	}
+	if fileContent != nil {
+		fileContent = slimContent(fileContent)
+		dataToCache := CachedContentResponse{Found: true, FileContent: fileContent, ETag: responseETag(resp), PushedAt: cw.repoPushedAt(owner, repo)}
+		writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+		if writeErr != nil {
+			log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+		}
+	} else {
+		log.LogVf("Skipping cache write for directory listing: %v", keyParts)
+	}
+
+	return fileContent, dirContent, resp, nil
+}
+
+// Cached wrapper for getting full repo details
+func (cw *ClientWrapper) getCachedGetRepo(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	keyParts := []string{"GetRepo", owner, repo}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedRepoResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	hit, _ = cw.incrementalHit(ctx, hit, keyParts, time.Time{})
+	ctx, revalidating := cw.conditional(ctx, hit, cachedData.ETag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for GetRepo owner=%s repo=%s", owner, repo)
+		return cachedData.Repo, &github.Response{}, nil // Return minimal response on hit
+	}
+
+	if !revalidating {
+		cw.stats.miss()
+		if p, ok := cw.getPrefetched(cacheKey).(*github.Repository); ok {
+			log.LogVf("Prefetched (GraphQL) GetRepo owner=%s repo=%s", owner, repo)
+			return p, &github.Response{}, nil // partial repository, not cached
+		}
+		log.Infof("Cache miss for GetRepo owner=%s repo=%s, calling API", owner, repo)
+	}
+	fullRepo, resp, apiErr := cw.client.Repositories.Get(ctx, owner, repo)
+	cw.stats.call("GetRepo", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Repo, resp, nil
+	}
+	if apiErr != nil {
+		return nil, resp, apiErr
+	}
+
+	fullRepo = slimRepo(fullRepo)
+	dataToCache := CachedRepoResponse{Repo: fullRepo, ETag: responseETag(resp)}
+	writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return fullRepo, resp, nil
+}
+
+// Cached wrapper for finding all the go.mod files in a repository's (recursive) git tree.
+// Returns the go.mod paths (nil if the repo or ref isn't found).
+func (cw *ClientWrapper) getCachedGoModPaths(ctx context.Context, owner, repo, ref string) ([]string, error) {
+	return cw.getCachedTreePaths(ctx, "GetTreeGoMods", owner, repo, ref, true, func(p string) bool {
+		return (p == "go.mod" || strings.HasSuffix(p, "/go.mod")) && !skipModulePath(p)
+	})
+}
+
+// Cached wrapper for finding which of the files depgraph reads (go.mod, go.sum, -manifests)
+// are at the root of a repository, from its (non recursive) git tree, so only those are fetched.
+// Returns their names (nil if the repo or ref isn't found).
+func (cw *ClientWrapper) getCachedRootFiles(ctx context.Context, owner, repo, ref string) ([]string, error) {
+	return cw.getCachedTreePaths(ctx, "GetTreeRoot", owner, repo, ref, false, func(p string) bool {
+		if p == "go.mod" || p == "go.sum" {
+			return true
+		}
+		for _, name := range manifestFiles {
+			if p == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// getCachedTreePaths returns (cached, under endpoint) the paths of the files (blobs) of the
+// repository's git tree, recursive or not, that match keep.
+func (cw *ClientWrapper) getCachedTreePaths(ctx context.Context, endpoint, owner, repo, ref string, recursive bool, keep func(p string) bool) ([]string, error) {
+	keyParts := []string{endpoint, owner, repo, ref}
+	cacheKey := cw.cache.key(keyParts...)
+	var cachedData CachedTreeResponse
+	hit, readErr := cw.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	hit, fresh := cw.incrementalHit(ctx, hit, keyParts, cachedData.PushedAt)
+	etag := cachedData.ETag
+	if fresh {
+		etag = ""
+	}
+	ctx, revalidating := cw.conditional(ctx, hit, etag, keyParts)
+	if hit && !revalidating {
+		cw.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for %s repo=%s/%s ref=%s (%d files)", endpoint, owner, repo, ref, len(cachedData.Paths))
+		return cachedData.Paths, nil
+	}
+	if !revalidating {
+		log.Infof("Cache miss for %s repo=%s/%s ref=%s, calling API", endpoint, owner, repo, ref)
+		cw.stats.miss()
+	}
+	tree, resp, apiErr := cw.client.Git.GetTree(ctx, owner, repo, ref, recursive)
+	cw.stats.call("GetTree", resp, apiErr)
+	if cw.notModified(ctx, revalidating, resp, cacheKey, keyParts) {
+		return cachedData.Paths, nil
+	}
+	if apiErr != nil {
+		if !isNotFoundError(apiErr) {
+			return nil, apiErr
+		}
+		log.LogVf("API reported Not Found for GetTree repo=%s/%s ref=%s (empty repo?)", owner, repo, ref)
+		tree = nil
+	}
+	dataToCache := CachedTreeResponse{Found: tree != nil, ETag: responseETag(resp), PushedAt: cw.repoPushedAt(owner, repo)}
+	if tree != nil {
+		dataToCache.Truncated = tree.GetTruncated()
+		if dataToCache.Truncated {
+			log.Warnf("Git tree for %s/%s is truncated (too big), some go.mod files may be missed", owner, repo)
+		}
+		for _, entry := range tree.Entries {
+			if p := entry.GetPath(); entry.GetType() == "blob" && keep(p) {
+				dataToCache.Paths = append(dataToCache.Paths, p)
+			}
+		}
+	}
+	writeErr := cw.cache.write(ctx, cacheKey, dataToCache)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return dataToCache.Paths, nil
+}
+
+// --- End Cached GitHub API Methods ---
+
+// --- GitHub Provider ---
+
+// The ClientWrapper is the GitHub provider, with caching, and its GitHub only features.
+var (
+	_ provider.TreeLister = (*ClientWrapper)(nil)
+	_ scanHooks           = (*ClientWrapper)(nil)
+)
+
+// toRepo converts a GitHub repository (as listed, or fetched) to a provider.Repo.
+func toRepo(r *github.Repository) *provider.Repo {
+	repo := &provider.Repo{
+		Owner:         r.GetOwner().GetLogin(),
+		Name:          r.GetName(),
+		DefaultBranch: r.GetDefaultBranch(),
+		Fork:          r.GetFork(),
+		Archived:      r.GetArchived(),
+		Private:       r.GetPrivate(),
+		License:       repoLicense(r),
+		PushedAt:      r.GetPushedAt().Time,
+	}
+	if r.Parent != nil {
+		repo.Parent = toRepo(r.Parent)
+	}
+	return repo
+}
+
+// ListRepos lists the repositories of an org, or of a user if not found as an org, a page
+// (of 100) at a time. A typed owner (org:acme, user:alice, see ParseOwner) is only listed as
+// that kind, saving the org probe of users.
+func (cw *ClientWrapper) ListRepos(ctx context.Context, owner, visibility string, page func(repos []*provider.Repo)) error {
+	owner, kind := ParseOwner(owner)
+	var listPage func(page int) ([]*github.Repository, *github.Response, error)
+	if kind == OwnerUser {
+		listPage = userRepoLister(ctx, cw, owner, visibility)
+	} else {
+		orgOpt := &github.RepositoryListByOrgOptions{Type: visibility, ListOptions: github.ListOptions{PerPage: 100}}
+		listPage = func(page int) ([]*github.Repository, *github.Response, error) {
+			orgOpt.Page = page
+			return cw.getCachedListByOrg(ctx, owner, orgOpt)
+		}
+	}
+	repos, resp, err := listPage(0)
+	if err != nil && isNotFoundError(err) && kind == "" {
+		log.Infof("  Owner %s not found as an organization, trying as a user (user:%s skips this)...", owner, owner)
+		listPage = userRepoLister(ctx, cw, owner, visibility)
+		kind = OwnerUser
+		repos, resp, err = listPage(0)
+	}
+	if kind == "" {
+		kind = OwnerOrg
+	}
+	if err != nil {
+		return err
+	}
+	currentPage := 1
+	for { // Pagination loop
+		if repos == nil {
+			log.Warnf("    No repositories found or error occurred for page %d for %s", currentPage, owner)
+			return nil
+		}
+		log.Infof("    Processing page %d for %s (as %s), %d repos", currentPage, owner, kind, len(repos))
+		converted := make([]*provider.Repo, 0, len(repos))
+		for _, repo := range repos {
+			converted = append(converted, toRepo(repo))
+		}
+		page(converted)
+
+		if resp == nil || resp.NextPage == 0 {
+			return nil
+		}
+		nextPage := resp.NextPage
+		log.LogVf("    Fetching next page (%d) for %s", nextPage, owner)
+		repos, resp, err = listPage(nextPage)
+		if err != nil {
+			return fmt.Errorf("error fetching page %d: %w", nextPage, err)
+		}
+		currentPage++
+	} // End pagination loop
+}
+
+// GetFileContents returns the content of a file of the repository, nil if not found.
+func (cw *ClientWrapper) GetFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	fileContent, _, _, err := cw.getCachedGetContents(ctx, owner, repo, path, contentOptions(ref))
+	if err != nil || fileContent == nil {
+		return nil, err
+	}
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s content: %w", path, err)
+	}
+	return []byte(content), nil
+}
+
+// GetRepo returns the repository's details (and its parent for forks).
+func (cw *ClientWrapper) GetRepo(ctx context.Context, owner, repo string) (*provider.Repo, error) {
+	r, _, err := cw.getCachedGetRepo(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return toRepo(r), nil
+}
+
+// GoModPaths returns the go.mod files of the repository's git tree.
+func (cw *ClientWrapper) GoModPaths(ctx context.Context, owner, repo, ref string) ([]string, error) {
+	return cw.getCachedGoModPaths(ctx, owner, repo, ref)
+}
+
+// RootFiles returns the files depgraph reads at the root of the repository's git tree.
+func (cw *ClientWrapper) RootFiles(ctx context.Context, owner, repo, ref string) ([]string, error) {
+	return cw.getCachedRootFiles(ctx, owner, repo, ref)
+}
+
+// --- End GitHub Provider ---
+
+// --- Owner Scanning ---
+
+// scanHooks is the optional interface of the providers having the GitHub only features of
+// the scan, implemented by ClientWrapper: gists, GraphQL prefetching, progress and -resume
+// checkpoint.
+type scanHooks interface {
+	// beforeScan is called with the repositories about to be scanned.
+	beforeScan(ctx context.Context, jobs []repoJob, res *Result)
+	// repoScanned is called after each repository is scanned.
+	repoScanned(ctx context.Context, repo *provider.Repo)
+	// scanGists adds the modules of the owner's gists (opts.Gists).
+	scanGists(ctx context.Context, owner string, ownerIdx int, res *Result)
+}
+
+// beforeScan records the repositories' push times (-incremental), adds them to the
+// progress and, with -graphql, prefetches their go.mod and fork parents.
+func (cw *ClientWrapper) beforeScan(ctx context.Context, jobs []repoJob, res *Result) {
+	cw.recordPushedAt(jobs)
+	cw.Progress.add(len(jobs))
+	if cw.GraphQL {
+		prefetchRepos(ctx, cw, jobs, res)
+	}
+}
+
+// repoScanned updates the progress and the -resume checkpoint.
+func (cw *ClientWrapper) repoScanned(ctx context.Context, repo *provider.Repo) {
+	cw.Progress.scanned()
+	if ctx.Err() == nil { // else possibly incomplete: not recorded, to be scanned again by -resume
+		cw.Checkpoint.record(repo.Owner, repo.Name)
+	}
+}
+
+func (cw *ClientWrapper) scanGists(ctx context.Context, owner string, ownerIdx int, res *Result) {
+	Gists(ctx, cw, owner, ownerIdx, res)
+}
+
+// Owner lists the repositories of an owner (org, or user if not found as org, unless typed
+// as org:name or user:name) with the provider p and adds the modules found in their go.mod to res.
+func Owner(ctx context.Context, p provider.Provider, typedOwner string, ownerIdx int, opts *Options, res *Result) {
+	owner, _ := ParseOwner(typedOwner)
+	err := p.ListRepos(ctx, typedOwner, opts.Visibility, func(repos []*provider.Repo) {
+		jobs := make([]repoJob, 0, len(repos))
+		for _, repo := range repos { // Repo loop
+			if repo.Archived || !visible(repo, opts.Visibility) || (opts.NoForks && repo.Fork) {
+				continue
+			}
+			jobs = append(jobs, repoJob{repo: repo, owner: owner, ownerIdx: ownerIdx, opts: opts})
+		} // End repo loop
+		scanRepos(ctx, p, jobs, opts.Concurrency, res)
+	})
+	if err != nil && ctx.Err() == nil {
+		res.addError(ctx, &RepoError{Repo: owner, Kind: ErrorAPI, Err: err})
+	}
+}
+
+// repoJob is a repository to scan with scanRepo.
+type repoJob struct {
+	repo     *provider.Repo
+	owner    string
+	ownerIdx int
+	opts     *Options
+}
+
+// scanRepos scans the repositories with up to concurrency of them in parallel (fetching
+// their go.mod, fork parent details...). The results are recorded in res in the jobs order,
+// so they don't depend on the timing. With -graphql, their go.mod and fork parents are
+// first fetched in batches (see scanHooks).
+func scanRepos(ctx context.Context, p provider.Provider, jobs []repoJob, concurrency int, res *Result) {
+	hooks, _ := p.(scanHooks)
+	if hooks != nil {
+		hooks.beforeScan(ctx, jobs, res)
+	}
+	scanJob := func(j repoJob, r *Result) {
+		if ctx.Err() != nil {
+			return // canceled (Ctrl-C, -timeout)
+		}
+		ctxHooks := ContextHooks(ctx)
+		repoPath, modules := j.repo.FullName(), len(r.Modules)
+		ctxHooks.repoStart(repoPath)
+		scanRepo(ctx, p, j.repo, j.owner, j.ownerIdx, j.opts, r)
+		ctxHooks.repoDone(repoPath, len(r.Modules)-modules)
+		if hooks != nil {
+			hooks.repoScanned(ctx, j.repo)
+		}
+	}
+	if concurrency <= 1 {
+		for _, j := range jobs {
+			scanJob(j, res)
+		}
+		return
+	}
+	results := make([]*Result, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = res.child()
+				scanJob(jobs[i], results[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, r := range results {
+		res.merge(r)
+	}
+}
+
+// userRepoLister returns the function listing a page of a user's repositories. Private
+// repositories can only be listed for the user owning the token (authenticated user).
+func userRepoLister(ctx context.Context, client *ClientWrapper, user, visibility string) func(page int) ([]*github.Repository, *github.Response, error) {
+	if visibility != VisibilityPublic {
+		login, err := client.authenticatedLogin(ctx)
+		if err != nil {
+			log.Warnf("  Can't get the token's user (%v), only public repositories of %s will be listed", err, user)
+		}
+		if strings.EqualFold(login, user) {
+			authOpt := &github.RepositoryListByAuthenticatedUserOptions{
+				Visibility: visibility, Affiliation: "owner", ListOptions: github.ListOptions{PerPage: 100},
+			}
+			return func(page int) ([]*github.Repository, *github.Response, error) {
+				authOpt.Page = page
+				return client.getCachedListByAuthenticatedUser(ctx, authOpt)
+			}
+		}
+		if err == nil {
+			log.Warnf("  %s isn't the token's user (%s), only public repositories can be listed", user, login)
+		}
+	}
+	userOpt := &github.RepositoryListByUserOptions{Type: "owner", ListOptions: github.ListOptions{PerPage: 100}}
+	return func(page int) ([]*github.Repository, *github.Response, error) {
+		userOpt.Page = page
+		return client.getCachedListByUser(ctx, user, userOpt)
+	}
+}
+
+// visible returns true if the repository matches the -visibility setting.
+func visible(repo *provider.Repo, visibility string) bool {
+	switch visibility {
+	case VisibilityPublic:
+		return !repo.Private
+	case VisibilityPrivate:
+		return repo.Private
+	default:
+		return true
+	}
+}
+
+// fetchGoMod fetches and parses the go.mod at the root of the given repo, at the given ref
+// ("" for the default branch). Returns nil, nil if there is no go.mod.
+func fetchGoMod(ctx context.Context, p provider.Provider, owner, repoName, ref string) (*modfile.File, *RepoError) {
+	return fetchGoModAt(ctx, p, owner, repoName, "go.mod", ref)
+}
+
+// contentOptions returns the GetContents options for the given ref ("" for the default branch).
+func contentOptions(ref string) *github.RepositoryContentGetOptions {
+	if ref == "" {
+		return nil
+	}
+	return &github.RepositoryContentGetOptions{Ref: ref}
+}
+
+// fetchGoModAt fetches and parses the go.mod at the given path in the repo.
+// The error kind is ErrorAPI or ErrorParse.
+func fetchGoModAt(ctx context.Context, p provider.Provider, owner, repoName, goModPath, ref string) (*modfile.File, *RepoError) {
+	repoPath := owner + "/" + repoName
+	content, err := p.GetFileContents(ctx, owner, repoName, goModPath, ref)
+	if err != nil {
+		return nil, &RepoError{Repo: repoPath, File: goModPath, Kind: ErrorAPI, Err: err}
+	}
+	if content == nil {
+		return nil, nil // go.mod not found
+	}
+	modFile, err := parseGoMod(repoPath+"/"+goModPath, content)
+	if err != nil {
+		return nil, &RepoError{Repo: repoPath, File: goModPath, Kind: ErrorParse, Err: err}
+	}
+	return modFile, nil
+}
+
+// forkParentModulePath returns the module path declared by the parent of a fork, or "" if
+// it can't be determined.
+func forkParentModulePath(ctx context.Context, p provider.Provider, repoOwnerLogin, repoName string) string {
+	repoPath := repoOwnerLogin + "/" + repoName
+	log.LogVf("      Repo %s is a fork. Fetching full repo details...", repoPath)
+	fullRepo, errGet := p.GetRepo(ctx, repoOwnerLogin, repoName) // Fetch full details
+	if errGet != nil {
+		log.Warnf("      Failed to get full repo details for fork %s: %v", repoPath, errGet)
+		return ""
+	}
+	if fullRepo == nil || fullRepo.Parent == nil { // Check parent from full details
+		log.LogVf("      Fork %s has no parent info in full details.", repoPath)
+		return ""
+	}
+	parentOwner := fullRepo.Parent.Owner
+	parentRepoName := fullRepo.Parent.Name
+	parentRepoPath := fullRepo.Parent.FullName()
+	log.LogVf("      Fork parent is %s. Checking for original module path", parentRepoPath)
+	parentModFile, err := fetchGoMod(ctx, p, parentOwner, parentRepoName, "")
+	if err != nil {
+		log.Warnf("        Parent %v", err)
+		return ""
+	}
+	if parentModFile == nil {
+		log.LogVf("        Parent go.mod not found for %s", parentRepoPath)
+		return ""
+	}
+	return parentModFile.Module.Mod.Path
+}
+
+// scanRepo checks a single repository for a go.mod (at opts.ref if set) and records the module it defines.
+// -all-modules and -tree need a provider.TreeLister (are ignored otherwise).
+func scanRepo(ctx context.Context, p provider.Provider, repo *provider.Repo, owner string, ownerIdx int, opts *Options, res *Result) {
+	isFork := repo.Fork
+	repoName := repo.Name
+	repoOwnerLogin := repo.Owner
+	repoPath := repo.FullName()
+	lister, canList := p.(provider.TreeLister)
+	var rootFiles map[string]bool // files at the root of the repo with -tree, nil if unknown (fetch them all)
+	if canList && opts.Tree && !(opts.AllModules && !isFork) {
+		rootFiles = listRootFiles(ctx, lister, repo, opts.Ref)
+	}
+	if !isFork && (len(res.Manifests) > 1 || !res.Manifests["go"]) {
+		repoInfo := graph.ModuleInfo{RepoPath: repoPath, Ref: opts.Ref, License: repo.License, Owner: owner, OwnerIdx: ownerIdx}
+		scanManifests(ctx, p, repoOwnerLogin, repoName, repoInfo, rootFiles, opts, res)
+	}
+	if !res.Manifests["go"] {
+		return
+	}
+	if canList && opts.AllModules && !isFork {
+		scanRepoModules(ctx, lister, repo, owner, ownerIdx, opts.Ref, res)
+		return
+	}
+	if rootFiles != nil && !rootFiles["go.mod"] {
+		log.LogVf("      No go.mod in %s (git tree)", repoPath)
+		res.addError(ctx, &RepoError{Repo: repoPath, Kind: ErrorNoGoMod})
+		return
+	}
+
+	modFile, err := fetchGoMod(ctx, p, repoOwnerLogin, repoName, opts.Ref)
+	if err != nil {
+		res.addError(ctx, err)
+		res.addErrorModule(&graph.ModuleInfo{RepoPath: repoPath, Ref: opts.Ref, IsFork: isFork, Owner: owner, OwnerIdx: ownerIdx}, err)
+		return
+	}
+	if modFile == nil {
+		if opts.Ref != "" {
+			log.LogVf("      No go.mod in %s at ref %s (or no such ref)", repoPath, opts.Ref)
+		}
+		res.addError(ctx, &RepoError{Repo: repoPath, Kind: ErrorNoGoMod})
+		return // Skip repo if go.mod not found
+	}
+	modulePath := modFile.Module.Mod.Path
+	originalModulePath := ""
+	// --- Fetch Parent Info for Forks ---
+	if isFork {
+		originalModulePath = forkParentModulePath(ctx, p, repoOwnerLogin, repoName)
+		if originalModulePath != "" {
+			// TODO: propbably best to not ignore the ok bool
+			forkBasePath, _, _ := module.SplitPathVersion(modulePath)
+			parentBasePath, _, _ := module.SplitPathVersion(originalModulePath)
+			log.LogVf("          Found parent module path: %s (%s)", originalModulePath, parentBasePath)
+			if forkBasePath == parentBasePath { // Compare base paths
+				log.Infof("		  Skipping fork %s same module path as its parent %s", repoPath, originalModulePath)
+				return
+			}
+			log.Infof("		  Keeping fork %s: %s module changed from parent %s", repoPath, modulePath, originalModulePath)
+		}
+	}
+	// --- End Fetch Parent Info ---
+	info := &graph.ModuleInfo{Path: modulePath, RepoPath: repoPath, Ref: opts.Ref, License: repo.License, IsFork: isFork, OriginalModulePath: originalModulePath, Owner: owner, OwnerIdx: ownerIdx}
+	res.addModule(info, modFile)
+	addGoSumDeps(ctx, p, repoOwnerLogin, repoName, "", info, rootFiles, res)
+}
+
+// addGoSumDeps fetches the go.sum next to the go.mod (in dir) and records its modules as
+// transitive dependencies, when -transitive is set. Uses the same ref as the module (info.Ref).
+// files, if not nil, are the files known to exist (-tree): go.sum isn't fetched if it isn't one of them.
+func addGoSumDeps(ctx context.Context, p provider.Provider, owner, repoName, dir string, info *graph.ModuleInfo, files map[string]bool, res *Result) {
+	if !res.Transitive {
+		return
+	}
+	goSumPath := path.Join(dir, "go.sum")
+	if files != nil && !files[goSumPath] {
+		log.LogVf("      No %s in %s/%s (git tree)", goSumPath, owner, repoName)
+		return
+	}
+	content, err := p.GetFileContents(ctx, owner, repoName, goSumPath, info.Ref)
+	if err != nil {
+		res.addError(ctx, &RepoError{Repo: owner + "/" + repoName, File: goSumPath, Kind: ErrorAPI, Err: err})
+		return
+	}
+	if content == nil {
+		log.LogVf("      No %s in %s/%s", goSumPath, owner, repoName)
+		return
+	}
+	res.addTransitive(info, parseGoSum(content))
+}
+
+// treeRef returns the ref of the git tree to get: ref, or the repository's default branch if "".
+func treeRef(repo *provider.Repo, ref string) string {
+	if ref != "" {
+		return ref
+	}
+	if branch := repo.DefaultBranch; branch != "" {
+		return branch
+	}
+	return "HEAD"
+}
+
+// listRootFiles returns the files depgraph reads (go.mod, go.sum, manifests) that are at the
+// root of the repository, from its git tree (-tree). Returns nil, i.e. unknown, on error.
+func listRootFiles(ctx context.Context, lister provider.TreeLister, repo *provider.Repo, ref string) map[string]bool {
+	owner, repoName := repo.Owner, repo.Name
+	paths, err := lister.RootFiles(ctx, owner, repoName, treeRef(repo, ref))
+	if err != nil {
+		log.Warnf("      Error getting git tree for %s/%s, fetching the files directly: %v", owner, repoName, err)
+		return nil
+	}
+	files := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		files[p] = true
+	}
+	return files
+}
+
+// scanRepoModules finds every go.mod in the repository's git tree (monorepos) and records
+// one module per go.mod found. ref is the branch or tag to scan, "" for the default branch.
+func scanRepoModules(ctx context.Context, lister provider.TreeLister, repo *provider.Repo, owner string, ownerIdx int, ref string, res *Result) {
+	repoName := repo.Name
+	repoOwnerLogin := repo.Owner
+	repoPath := repo.FullName()
+	goModPaths, err := lister.GoModPaths(ctx, repoOwnerLogin, repoName, treeRef(repo, ref))
+	if err != nil {
+		res.addError(ctx, &RepoError{Repo: repoPath, File: "git tree", Kind: ErrorAPI, Err: err})
+		return
+	}
+	if len(goModPaths) == 0 {
+		res.addError(ctx, &RepoError{Repo: repoPath, Kind: ErrorNoGoMod})
+	}
+	if len(goModPaths) > 1 {
+		log.Infof("      Found %d go.mod files in %s", len(goModPaths), repoPath)
+	}
+	for _, goModPath := range goModPaths {
+		dir := path.Dir(goModPath)
+		if dir == "." {
+			dir = ""
+		}
+		modFile, err := fetchGoModAt(ctx, lister, repoOwnerLogin, repoName, goModPath, ref)
+		if err != nil {
+			res.addError(ctx, err)
+			res.addErrorModule(&graph.ModuleInfo{RepoPath: repoPath, Dir: dir, Ref: ref, Owner: owner, OwnerIdx: ownerIdx}, err)
+			continue
+		}
+		if modFile == nil {
+			continue
+		}
+		info := &graph.ModuleInfo{Path: modFile.Module.Mod.Path, RepoPath: repoPath, Dir: dir, Ref: ref, License: repo.License, Owner: owner, OwnerIdx: ownerIdx}
+		res.addModule(info, modFile)
+		addGoSumDeps(ctx, lister, repoOwnerLogin, repoName, dir, info, nil, res)
+	}
+}
+
+// RepoList scans an explicit list of repositories. ownerIndex maps owners to their
+// index (color) and is extended as new owners are encountered. A ref in the spec (owner/repo@ref)
+// overrides the global one (-ref).
+func RepoList(ctx context.Context, p provider.Provider, repos []RepoSpec, ownerIndex map[string]int, opts *Options, res *Result) {
+	jobs := make([]repoJob, 0, len(repos))
+	for _, spec := range repos {
+		if ctx.Err() != nil {
+			return // canceled (Ctrl-C, -timeout)
+		}
+		idx, found := ownerIndex[spec.Owner]
+		if !found {
+			idx = len(ownerIndex)
+			ownerIndex[spec.Owner] = idx
+		}
+		log.Infof("Processing repository %s", spec)
+		repo, err := p.GetRepo(ctx, spec.Owner, spec.Repo)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: spec.String(), Kind: ErrorAPI, Err: err})
+			continue
+		}
+		if repo.Fork && opts.NoForks {
+			log.Infof("  Repository %s is a fork, skipped (-no-forks)", spec)
+			continue
+		}
+		if repo.Archived {
+			log.Infof("  Repository %s is archived, including it anyway as it was explicitly listed", spec)
+		}
+		repoOpts := opts
+		if spec.Ref != "" {
+			o := *opts
+			o.Ref = spec.Ref
+			repoOpts = &o
+		}
+		jobs = append(jobs, repoJob{repo: repo, owner: spec.Owner, ownerIdx: idx, opts: repoOpts})
+	}
+	scanRepos(ctx, p, jobs, opts.Concurrency, res)
+}
+
+// OwnersAndRepos scans with the provider p the owners (organizations or users), with their
+// gists if opts.Gists (GitHub only), then the explicit repositories, into res.
+func OwnersAndRepos(ctx context.Context, p provider.Provider, owners []string, repos []RepoSpec, opts *Options, res *Result) {
+	ownerIndex := make(map[string]int) // owner -> index (color)
+	for i, owner := range owners {
+		if ctx.Err() != nil {
+			return // canceled (Ctrl-C, -timeout)
+		}
+		log.Infof("Processing owner %d: %s", i+1, owner)
+		name, _ := ParseOwner(owner)
+		ownerIndex[name] = i
+		Owner(ctx, p, owner, i, opts, res)
+		if opts.Gists {
+			if hooks, ok := p.(scanHooks); ok {
+				hooks.scanGists(ctx, name, i, res)
+			} else {
+				log.Warnf("  Gists aren't supported by this provider, skipped for %s", name)
+			}
+		}
+	}
+	RepoList(ctx, p, repos, ownerIndex, opts, res)
+}
+
+// Owners scans the repositories of the owners (organizations or users, or owner/repo[@ref]
+// for single repositories) with the provider p (e.g. the GitHub ClientWrapper) and returns the graph of the modules found and of their
+// dependencies, the external ones (not found in the owners) having a nil Module. The errors
+// on individual repositories are logged and skip them, as with the depgraph command.
+func Owners(ctx context.Context, p provider.Provider, owners []string, opts Options) (*graph.Graph, error) {
+	owners, repos, err := SplitOwnersAndRepos(owners)
+	if err != nil {
+		return nil, err
+	}
+	res := NewResult()
+	OwnersAndRepos(ctx, p, owners, repos, &opts, res)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	env := graph.DefaultEnv()
+	return graph.New(env, res.Modules, graph.NodesToGraph(env, res.Modules, res.AllPaths, false)), nil
+}
+
+// --- End Owner Scanning ---
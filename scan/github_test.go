@@ -0,0 +1,219 @@
+package scan
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+	"github.com/ldemailly/depgraph/provider"
+)
+
+// fakeProvider is an in-memory provider.Provider (without the TreeLister nor the GitHub
+// only scanHooks), listing its repositories in pages of 2.
+type fakeProvider struct {
+	repos []*provider.Repo
+	files map[string]string // owner/repo/path or owner/repo/path@ref -> content
+
+	mu      sync.Mutex
+	fetched []string // GetFileContents calls, as owner/repo/path@ref
+}
+
+func (f *fakeProvider) ListRepos(_ context.Context, owner, _ string, page func(repos []*provider.Repo)) error {
+	var repos []*provider.Repo
+	for _, r := range f.repos {
+		if r.Owner == owner {
+			repos = append(repos, r)
+		}
+	}
+	for batch := range slices.Chunk(repos, 2) {
+		page(batch)
+	}
+	return nil
+}
+
+func (f *fakeProvider) GetFileContents(_ context.Context, owner, repo, path, ref string) ([]byte, error) {
+	key := owner + "/" + repo + "/" + path
+	f.mu.Lock()
+	f.fetched = append(f.fetched, key+"@"+ref)
+	f.mu.Unlock()
+	if ref != "" {
+		key += "@" + ref
+	}
+	content, found := f.files[key]
+	if !found {
+		return nil, nil
+	}
+	return []byte(content), nil
+}
+
+func (f *fakeProvider) GetRepo(_ context.Context, owner, repo string) (*provider.Repo, error) {
+	for _, r := range f.repos {
+		if r.Owner == owner && r.Name == repo {
+			return r, nil
+		}
+	}
+	return nil, &RepoError{Repo: owner + "/" + repo, Kind: ErrorAPI}
+}
+
+func newFakeProvider() *fakeProvider {
+	parent := &provider.Repo{Owner: "up", Name: "lib"}
+	return &fakeProvider{
+		repos: []*provider.Repo{
+			{Owner: "acme", Name: "app"},
+			{Owner: "acme", Name: "log"},
+			{Owner: "acme", Name: "secret", Private: true},
+			{Owner: "acme", Name: "old", Archived: true},
+			{Owner: "acme", Name: "nogomod"},
+			{Owner: "acme", Name: "lib-fork", Fork: true, Parent: parent},
+			{Owner: "acme", Name: "lib-same", Fork: true, Parent: parent},
+			parent,
+		},
+		files: map[string]string{
+			"acme/app/go.mod":        "module example.com/app\n\nrequire (\n\texample.com/log v1.2.0\n\texample.com/ext v0.1.0\n)\n",
+			"acme/log/go.mod":        "module example.com/log\n",
+			"acme/log/go.mod@v2":     "module example.com/log/v2\n",
+			"acme/secret/go.mod":     "module example.com/secret\n",
+			"acme/old/go.mod":        "module example.com/old\n",
+			"acme/lib-fork/go.mod":   "module example.com/myfork\n\nrequire example.com/log v1.0.0\n",
+			"acme/lib-same/go.mod":   "module example.com/upstream\n",
+			"up/lib/go.mod":          "module example.com/upstream\n",
+			"acme/nogomod/README.md": "# no go.mod\n",
+		},
+	}
+}
+
+func TestScanWithProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		owners      []string
+		repos       []RepoSpec
+		opts        Options
+		wantModules []string
+		wantForkOf  map[string]string // module -> OriginalModulePath
+	}{
+		{
+			name:        "public",
+			owners:      []string{"acme"},
+			opts:        Options{Visibility: VisibilityPublic},
+			wantModules: []string{"example.com/app", "example.com/log", "example.com/myfork"},
+			wantForkOf:  map[string]string{"example.com/myfork": "example.com/upstream"},
+		},
+		{
+			name:        "all visibility",
+			owners:      []string{"acme"},
+			opts:        Options{Visibility: VisibilityAll},
+			wantModules: []string{"example.com/app", "example.com/log", "example.com/myfork", "example.com/secret"},
+		},
+		{
+			name:        "private",
+			owners:      []string{"acme"},
+			opts:        Options{Visibility: VisibilityPrivate},
+			wantModules: []string{"example.com/secret"},
+		},
+		{
+			name:        "no forks",
+			owners:      []string{"acme"},
+			opts:        Options{Visibility: VisibilityPublic, NoForks: true},
+			wantModules: []string{"example.com/app", "example.com/log"},
+		},
+		{
+			name:        "explicit repos with ref",
+			repos:       []RepoSpec{{Owner: "acme", Repo: "log", Ref: "v2"}, {Owner: "acme", Repo: "old"}, {Owner: "acme", Repo: "missing"}},
+			opts:        Options{Visibility: VisibilityPublic},
+			wantModules: []string{"example.com/log/v2", "example.com/old"},
+		},
+		{
+			name:        "gists unsupported",
+			owners:      []string{"acme"},
+			opts:        Options{Visibility: VisibilityPrivate, Gists: true},
+			wantModules: []string{"example.com/secret"},
+		},
+	}
+	for _, tt := range tests {
+		var first map[string]*graph.ModuleInfo
+		for _, concurrency := range []int{1, 4} {
+			opts := tt.opts
+			opts.Concurrency = concurrency
+			res := NewResult()
+			OwnersAndRepos(context.Background(), newFakeProvider(), tt.owners, tt.repos, &opts, res)
+			if got := slices.Sorted(maps.Keys(res.Modules)); !slices.Equal(got, tt.wantModules) {
+				t.Errorf("%s (concurrency %d): modules %v, want %v", tt.name, concurrency, got, tt.wantModules)
+				continue
+			}
+			for mod, parent := range tt.wantForkOf {
+				if got := res.Modules[mod].OriginalModulePath; got != parent {
+					t.Errorf("%s: %s is a fork of %q, want %q", tt.name, mod, got, parent)
+				}
+			}
+			if first == nil {
+				first = res.Modules
+				continue
+			}
+			for path, m := range res.Modules {
+				if !maps.Equal(m.Deps, first[path].Deps) || m.RepoPath != first[path].RepoPath {
+					t.Errorf("%s: %s differs between the serial and parallel scans", tt.name, path)
+				}
+			}
+		}
+	}
+}
+
+func TestOwnersWithProvider(t *testing.T) {
+	f := newFakeProvider()
+	g, err := Owners(context.Background(), f, []string{"acme", "up/lib"}, Options{Visibility: VisibilityPublic, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := g.Nodes["example.com/app"]
+	if app == nil || app.Module == nil {
+		t.Fatalf("example.com/app not scanned, nodes: %v", g.Paths())
+	}
+	var deps []string
+	for _, e := range g.Dependencies("example.com/app") {
+		deps = append(deps, e.To.Path+"@"+e.Version)
+	}
+	if want := []string{"example.com/ext@v0.1.0", "example.com/log@v1.2.0"}; !slices.Equal(deps, want) {
+		t.Errorf("app dependencies %v, want %v", deps, want)
+	}
+	if ext := g.Nodes["example.com/ext"]; ext == nil || ext.Module != nil {
+		t.Errorf("example.com/ext should be an external node: %+v", ext)
+	}
+	if up := g.Nodes["example.com/upstream"]; up == nil || up.Module == nil || up.Module.RepoPath != "up/lib" {
+		t.Errorf("example.com/upstream should be scanned from up/lib: %+v", up)
+	}
+	for _, fetched := range f.fetched {
+		if strings.HasPrefix(fetched, "acme/secret/") || strings.HasPrefix(fetched, "acme/old/") {
+			t.Errorf("fetched %s of a skipped repository", fetched)
+		}
+	}
+}
+
+// TestParallelScanWithCache checks the parallel scans share the cache like the serial one:
+// same modules, and nothing left to request once cached.
+func TestParallelScanWithCache(t *testing.T) {
+	for _, backend := range []string{CacheBackendFiles, cacheBackendBolt} {
+		s := fakegithub.Fixture()
+		serial, _ := scanAcme(s, newTestClient(s, nil, nil), 1)
+		cw := newTestClient(s, newTestCache(t, backend, ""), nil)
+		for run, wantRequests := range []bool{true, false} {
+			modules, requests := scanAcme(s, cw, 8)
+			if (requests > 0) != wantRequests {
+				t.Errorf("%s run %d: %d requests, want requests %v", backend, run+1, requests, wantRequests)
+			}
+			for path, m := range serial {
+				if got := modules[path]; got == nil || !maps.Equal(got.Deps, m.Deps) {
+					t.Errorf("%s run %d: %s %+v, want %+v", backend, run+1, path, got, m)
+				}
+			}
+			if len(modules) != len(serial) {
+				t.Errorf("%s run %d: %d modules, want %d", backend, run+1, len(modules), len(serial))
+			}
+		}
+		s.Close()
+	}
+}
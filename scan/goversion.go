@@ -0,0 +1,58 @@
+package scan
+
+import (
+	"io"
+	"sort"
+
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/semver"
+)
+
+// --- Go Version (go and toolchain directives) ---
+
+// goDirectives returns the "go 1.22, toolchain go1.23.1" summary of the module's go and
+// toolchain directives, "" if it has none (or isn't a Go module).
+func goDirectives(info *graph.ModuleInfo) string {
+	if info == nil || info.GoVersion == "" {
+		return ""
+	}
+	res := "go " + info.GoVersion
+	if info.Toolchain != "" {
+		res += ", toolchain " + info.Toolchain
+	}
+	return res
+}
+
+// WriteOldGoReport writes the scanned Go modules whose go directive is older than minGo
+// (or missing), oldest first.
+func WriteOldGoReport(w io.Writer, modulesFoundInOwners map[string]*graph.ModuleInfo, minGo string) error {
+	r := &reportWriter{w: w}
+	minV := GoSemver(minGo)
+	type pinned struct {
+		path, goVersion, semver string
+	}
+	var old []pinned
+	for _, modPath := range graph.SortedKeys(modulesFoundInOwners) {
+		info := modulesFoundInOwners[modPath]
+		if info.Language != "" || info.Error != "" {
+			continue // -manifests npm/cargo package or invalid go.mod
+		}
+		v := GoSemver(info.GoVersion)
+		if info.GoVersion == "" || (v != "" && semver.Compare(v, minV) < 0) {
+			old = append(old, pinned{path: modPath, goVersion: info.GoVersion, semver: v})
+		}
+	}
+	sort.SliceStable(old, func(i, j int) bool { return semver.Compare(old[i].semver, old[j].semver) < 0 })
+	r.printf("Modules pinned to Go versions older than %s:\n", minGo)
+	for _, p := range old {
+		goVersion := p.goVersion
+		if goVersion == "" {
+			goVersion = "no go directive"
+		}
+		r.printf("  - %s: %s\n", p.path, goVersion)
+	}
+	r.printf("%d of %d modules.\n", len(old), len(modulesFoundInOwners))
+	return r.err
+}
+
+// --- End Go Version (go and toolchain directives) ---
@@ -0,0 +1,183 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+)
+
+// --- GraphQL Batching ---
+
+// graphqlBatchSize is the number of repositories fetched per GraphQL query.
+const graphqlBatchSize = 50
+
+// graphqlBlob is a file (git blob) of a GraphQL repository object. Text is nil for binary
+// or too large files.
+type graphqlBlob struct {
+	Text *string `json:"text"`
+}
+
+// graphqlRepo is what we query for each repository: its go.mod and fork parent (with its go.mod).
+type graphqlRepo struct {
+	GoMod  *graphqlBlob `json:"gomod"`
+	Parent *struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+		GoMod *graphqlBlob `json:"gomod"`
+	} `json:"parent"`
+}
+
+// graphqlGoModFields selects the go.mod of the expression's ref as "gomod".
+const graphqlGoModFields = `gomod: object(expression: $%s) { ... on Blob { text } }`
+
+// graphqlEndpoint returns the GraphQL API URL matching the client's REST API base URL
+// (https://api.github.com/graphql, or https://host/api/graphql for GitHub Enterprise).
+func (cw *ClientWrapper) graphqlEndpoint() string {
+	u := *cw.client.BaseURL
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/v3") + "/graphql"
+	return u.String()
+}
+
+// inCache returns true if the cache has a (not expired) entry for keyParts.
+func (cw *ClientWrapper) inCache(ctx context.Context, keyParts ...string) bool {
+	if !cw.cache.used() {
+		return false
+	}
+	key := cw.cache.key(keyParts...)
+	_, modTime, err := cw.cache.Store.read(ctx, key)
+	return err == nil && !cw.cache.expired(key, keyParts[0], modTime)
+}
+
+// setPrefetched records a result fetched by a GraphQL batch, for the cached method of the
+// same cache key to use instead of calling the REST API.
+func (cw *ClientWrapper) setPrefetched(value any, keyParts ...string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.prefetched == nil {
+		cw.prefetched = make(map[string]any)
+	}
+	cw.prefetched[cw.cache.key(keyParts...)] = value
+}
+
+// getPrefetched returns the GraphQL batch result for the cache key, nil if none.
+func (cw *ClientWrapper) getPrefetched(cacheKey string) any {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.prefetched[cacheKey]
+}
+
+// prefetchRepos fetches, with one GraphQL query per graphqlBatchSize repositories, the root
+// go.mod of the jobs' repositories and, for forks, their parent and its go.mod: what
+// scanRepo would otherwise get with one or more REST calls per repository. Repositories
+// already in the cache are skipped; failures are only logged, the REST API being the fallback.
+func prefetchRepos(ctx context.Context, client *ClientWrapper, jobs []repoJob, res *Result) {
+	if !res.Manifests["go"] {
+		return
+	}
+	var todo []repoJob
+	for _, j := range jobs {
+		if j.opts.AllModules && !j.repo.Fork {
+			continue // go.mod files found with the git tree
+		}
+		owner, name := j.repo.Owner, j.repo.Name
+		if client.inCache(ctx, "GetContents", owner, name, "go.mod", j.opts.Ref) &&
+			(!j.repo.Fork || client.inCache(ctx, "GetRepo", owner, name)) {
+			continue
+		}
+		todo = append(todo, j)
+	}
+	for start := 0; start < len(todo) && ctx.Err() == nil; start += graphqlBatchSize {
+		batch := todo[start:min(start+graphqlBatchSize, len(todo))]
+		if err := client.graphqlBatch(ctx, batch); err != nil {
+			log.Warnf("GraphQL batch of %d repositories failed, using the REST API: %v", len(batch), err)
+		}
+	}
+}
+
+// graphqlBatch runs the GraphQL query for a batch of repositories and records the results
+// with setPrefetched.
+func (cw *ClientWrapper) graphqlBatch(ctx context.Context, batch []repoJob) error {
+	var params, fields []string
+	variables := make(map[string]any)
+	for i, j := range batch {
+		owner, name := j.repo.Owner, j.repo.Name
+		ref := j.opts.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		o, n, e := fmt.Sprintf("o%d", i), fmt.Sprintf("n%d", i), fmt.Sprintf("e%d", i)
+		variables[o], variables[n], variables[e] = owner, name, ref+":go.mod"
+		params = append(params, fmt.Sprintf("$%s: String!, $%s: String!, $%s: String!", o, n, e))
+		fields = append(fields, fmt.Sprintf(`r%d: repository(owner: $%s, name: $%s) { %s parent { name owner { login } %s } }`,
+			i, o, n, fmt.Sprintf(graphqlGoModFields, e), fmt.Sprintf(graphqlGoModFields, "head")))
+	}
+	variables["head"] = "HEAD:go.mod"
+	query := fmt.Sprintf("query(%s, $head: String!) {\n%s\n}", strings.Join(params, ", "), strings.Join(fields, "\n"))
+	req, err := cw.client.NewRequest("POST", cw.graphqlEndpoint(), map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	var out struct {
+		Data   map[string]*graphqlRepo `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	log.Infof("GraphQL query for %d repositories", len(batch))
+	resp, err := cw.client.Do(ctx, req, &out)
+	cw.stats.call("GraphQL", resp, err)
+	if err != nil {
+		return err
+	}
+	for _, e := range out.Errors { // e.g. a repository not found: partial data, REST fallback
+		log.LogVf("GraphQL error: %s", e.Message)
+	}
+	if out.Data == nil && len(out.Errors) > 0 {
+		return fmt.Errorf("%s", out.Errors[0].Message)
+	}
+	for i, j := range batch {
+		r := out.Data[fmt.Sprintf("r%d", i)]
+		if r == nil {
+			continue
+		}
+		owner, name := j.repo.Owner, j.repo.Name
+		if content := prefetchedContent(r.GoMod); content != nil {
+			cw.setPrefetched(content, "GetContents", owner, name, "go.mod", j.opts.Ref)
+		}
+		if r.Parent == nil {
+			continue
+		}
+		if j.repo.Fork {
+			// Partial repository (the fields used for forks), kept in memory only.
+			cw.setPrefetched(&github.Repository{
+				Owner: &github.User{Login: github.String(owner)}, Name: github.String(name), Fork: github.Bool(true),
+				Parent: &github.Repository{Owner: &github.User{Login: github.String(r.Parent.Owner.Login)}, Name: github.String(r.Parent.Name)},
+			}, "GetRepo", owner, name)
+		}
+		if content := prefetchedContent(r.Parent.GoMod); content != nil {
+			cw.setPrefetched(content, "GetContents", r.Parent.Owner.Login, r.Parent.Name, "go.mod", "")
+		}
+	}
+	return nil
+}
+
+// prefetchedContent converts a GraphQL blob to the GetContents cache entry: not found for a
+// nil blob (no go.mod, or no such ref). Returns nil if its text isn't available.
+func prefetchedContent(blob *graphqlBlob) *CachedContentResponse {
+	if blob == nil {
+		return &CachedContentResponse{Found: false}
+	}
+	if blob.Text == nil {
+		return nil // binary, too large or not a file: let the REST API handle it
+	}
+	return &CachedContentResponse{Found: true, FileContent: &github.RepositoryContent{
+		Type: github.String("file"), Name: github.String("go.mod"), Path: github.String("go.mod"), Content: blob.Text,
+	}}
+}
+
+// --- End GraphQL Batching ---
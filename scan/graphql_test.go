@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+)
+
+func TestGraphQLEndpoint(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{"https://api.github.com/", "https://api.github.com/graphql"},
+		{"https://ghe.example.com/api/v3/", "https://ghe.example.com/api/graphql"},
+		{"http://127.0.0.1:1234/", "http://127.0.0.1:1234/graphql"},
+	}
+	for _, tt := range tests {
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(tt.baseURL)
+		if got := NewClientWrapper(client, nil, nil).graphqlEndpoint(); got != tt.want {
+			t.Errorf("%s: GraphQL endpoint %s, want %s", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+// graphqlServer serves the fake GitHub API with a GraphQL endpoint answering the repository
+// queries of graphqlBatch with blobs[owner/name] (omitted if not found), recording the REST
+// requests' paths. The endpoint fails if failing.
+type graphqlServer struct {
+	*httptest.Server
+	blobs   map[string]*graphqlBlob
+	failing bool
+
+	mu      sync.Mutex
+	queries int
+	rest    []string
+}
+
+func newGraphQLServer(t *testing.T, blobs map[string]*graphqlBlob, failing bool) *graphqlServer {
+	s := fakegithub.Fixture()
+	t.Cleanup(s.Close)
+	g := &graphqlServer{blobs: blobs, failing: failing}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graphql", g.graphql)
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		g.rest = append(g.rest, r.URL.Path)
+		g.mu.Unlock()
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	g.Server = httptest.NewServer(mux)
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *graphqlServer) graphql(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	g.queries++
+	g.mu.Unlock()
+	if g.failing {
+		http.Error(w, "boom", http.StatusBadGateway)
+		return
+	}
+	var in struct {
+		Variables map[string]string `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data := make(map[string]*graphqlRepo)
+	var errs []map[string]string
+	for i := 0; in.Variables[fmt.Sprintf("o%d", i)] != ""; i++ {
+		repo := in.Variables[fmt.Sprintf("o%d", i)] + "/" + in.Variables[fmt.Sprintf("n%d", i)]
+		if blob, found := g.blobs[repo]; found {
+			data[fmt.Sprintf("r%d", i)] = &graphqlRepo{GoMod: blob}
+		} else {
+			errs = append(errs, map[string]string{"message": "Could not resolve to a Repository with the name '" + repo + "'."})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": data, "errors": errs})
+}
+
+// TestGraphQLPrefetch scans acme with -graphql: the go.mod files prefetched in one query
+// aren't requested from the REST API, the others (unresolved, or without text) are.
+func TestGraphQLPrefetch(t *testing.T) {
+	text := "module github.com/acme/log\n\ngo 1.99\n" // differs from the REST API's
+	blobs := map[string]*graphqlBlob{
+		"acme/log":  {Text: &text},
+		"acme/docs": nil, // no go.mod
+		"acme/app":  {},  // binary or too large
+		// acme/tools and acme/broken unresolved
+	}
+	tests := []struct {
+		name        string
+		failing     bool
+		wantLogGo   string
+		wantFetched []string // REST go.mod requests
+	}{
+		{name: "prefetched", wantLogGo: "1.99", wantFetched: []string{"app", "broken", "tools"}},
+		{name: "failing GraphQL API", failing: true, wantLogGo: "1.22", wantFetched: []string{"app", "broken", "docs", "log", "tools"}},
+	}
+	for _, tt := range tests {
+		g := newGraphQLServer(t, blobs, tt.failing)
+		client := github.NewClient(g.Client())
+		client.BaseURL, _ = url.Parse(g.URL + "/")
+		cw := NewClientWrapper(client, nil, newAPIStats())
+		cw.GraphQL = true
+		res := NewResult()
+		OwnersAndRepos(context.Background(), cw, []string{"acme"}, nil, &Options{Visibility: VisibilityPublic, Concurrency: 2}, res)
+		if g.queries != 1 {
+			t.Errorf("%s: %d GraphQL queries, want 1", tt.name, g.queries)
+		}
+		if log := res.Modules["github.com/acme/log"]; log == nil || log.GoVersion != tt.wantLogGo {
+			t.Errorf("%s: github.com/acme/log %+v, want go %s", tt.name, log, tt.wantLogGo)
+		}
+		if res.Modules["github.com/acme/app"] == nil || res.Modules["github.com/acme/tools"] == nil {
+			t.Errorf("%s: app or tools not scanned with the REST API fallback", tt.name)
+		}
+		var fetched []string
+		for _, path := range g.rest {
+			repo, inAcme := strings.CutPrefix(path, "/repos/acme/")
+			if name, found := strings.CutSuffix(repo, "/contents/go.mod"); inAcme && found {
+				fetched = append(fetched, name)
+			}
+		}
+		slices.Sort(fetched)
+		if !slices.Equal(fetched, tt.wantFetched) {
+			t.Errorf("%s: REST go.mod requests %v, want %v", tt.name, fetched, tt.wantFetched)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"context"
+)
+
+// --- Scan Event Hooks ---
+
+// Hooks are callbacks notified of the scan events, so embedders (and interactive modes) can
+// display live progress without parsing the logs. They are attached to the context of the
+// scan with WithHooks, like net/http/httptrace. Nil fields are ignored. The callbacks are
+// called from the scanning goroutines (see Options.Concurrency): they must be safe for
+// concurrent use and should return quickly.
+type Hooks struct {
+	OnRepoStart func(repo string)              // a GitHub repository (owner/repo) starts being scanned
+	OnRepoDone  func(repo string, modules int) // its scan is done, with the number of modules found in it
+	OnAPIError  func(err *RepoError)           // an API error made the scan skip a repository or file (see Result.Errors)
+	OnCacheHit  func(keyParts []string)        // an API call was answered from the cache, keyParts[0] is its kind (e.g. GetContents, Proxy)
+}
+
+// hooksKey is the context key of the Hooks.
+type hooksKey struct{}
+
+// WithHooks returns a copy of ctx with the hooks to notify of the scan events.
+func WithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+// ContextHooks returns the hooks attached to ctx by WithHooks, nil if none. The methods
+// notifying them are no-ops on a nil *Hooks.
+func ContextHooks(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return hooks
+}
+
+func (h *Hooks) repoStart(repo string) {
+	if h != nil && h.OnRepoStart != nil {
+		h.OnRepoStart(repo)
+	}
+}
+
+func (h *Hooks) repoDone(repo string, modules int) {
+	if h != nil && h.OnRepoDone != nil {
+		h.OnRepoDone(repo, modules)
+	}
+}
+
+func (h *Hooks) apiError(err *RepoError) {
+	if h != nil && h.OnAPIError != nil {
+		h.OnAPIError(err)
+	}
+}
+
+func (h *Hooks) cacheHit(keyParts []string) {
+	if h != nil && h.OnCacheHit != nil {
+		h.OnCacheHit(keyParts)
+	}
+}
+
+// --- End Scan Event Hooks ---
@@ -0,0 +1,66 @@
+package scan
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Incremental Rescans (pushed_at) ---
+
+// recordPushedAt remembers when the jobs' repositories were last pushed to, per their
+// listing (or details), for -incremental.
+func (cw *ClientWrapper) recordPushedAt(jobs []repoJob) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.pushedAt == nil {
+		cw.pushedAt = make(map[string]time.Time)
+	}
+	for _, j := range jobs {
+		if t := j.repo.PushedAt; !t.IsZero() {
+			cw.pushedAt[strings.ToLower(j.repo.FullName())] = t
+		}
+	}
+}
+
+// repoPushedAt returns when the repository was last pushed to, zero if unknown (not listed).
+func (cw *ClientWrapper) repoPushedAt(owner, repo string) time.Time {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.pushedAt[strings.ToLower(owner+"/"+repo)]
+}
+
+// incrementalHit adjusts a cache read (hit or not, the entry being fetched when its
+// repository was last pushed at entryPushedAt) for -incremental: the listings and repository
+// details are always refreshed (revalidated if they have an ETag), so the pushed_at times
+// are current, and the contents of a repository are fresh, even if expired, unless the
+// repository was pushed to since they were fetched, in which case they are fetched again.
+// Returns the adjusted hit and whether the entry must be used as is (no revalidation).
+func (cw *ClientWrapper) incrementalHit(ctx context.Context, hit bool, keyParts []string, entryPushedAt time.Time) (bool, bool) {
+	if !cw.Incremental || cw.cache.offline() || cw.resumed(ctx, keyParts) {
+		return hit, false
+	}
+	switch cacheKinds[keyParts[0]] {
+	case "lists", "repos":
+		return false, false
+	case "contents":
+		if len(keyParts) < 3 || entryPushedAt.IsZero() {
+			return hit, false // gists, or entry written before -incremental
+		}
+		pushedAt := cw.repoPushedAt(keyParts[1], keyParts[2])
+		switch {
+		case pushedAt.IsZero():
+			return hit, false // not listed, e.g. a fork's parent
+		case pushedAt.After(entryPushedAt):
+			log.LogVf("Repository %s/%s pushed to since %v was cached, fetching again", keyParts[1], keyParts[2], keyParts)
+			return false, true
+		default:
+			return true, true
+		}
+	}
+	return hit, false
+}
+
+// --- End Incremental Rescans (pushed_at) ---
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"io"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- License Inventory ---
+
+// ReportLicenses is the -report value for the license inventory.
+const ReportLicenses = "licenses"
+
+// unknownLicense is the group of the modules without a detected license.
+const unknownLicense = "unknown"
+
+// copyleftPrefixes are the SPDX id prefixes of the (weak or strong) copyleft licenses.
+var copyleftPrefixes = []string{"AGPL-", "GPL-", "LGPL-", "MPL-", "EPL-", "EUPL-", "CDDL-", "OSL-", "SSPL-", "CC-BY-SA-"}
+
+// repoLicense returns the SPDX id of the repository's license as detected by GitHub (part
+// of the repository listing, no extra call), "" when unknown or not a standard license.
+func repoLicense(repo *github.Repository) string {
+	spdx := repo.GetLicense().GetSPDXID()
+	if spdx == "NOASSERTION" {
+		return "" // GitHub found a license file but not a known license
+	}
+	return spdx
+}
+
+// isCopyleft returns true for copyleft licenses (SPDX ids).
+func isCopyleft(license string) bool {
+	for _, prefix := range copyleftPrefixes {
+		if strings.HasPrefix(license, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleLicense returns the license of a module: the repository's one for scanned modules,
+// else from deps.dev (-depsdev) if known, unknownLicense otherwise.
+func moduleLicense(path string, info *graph.ModuleInfo, ann *Annotations) string {
+	if info != nil && info.License != "" {
+		return info.License
+	}
+	if ann != nil {
+		if di := ann.DepsDev[path]; di != nil && len(di.Licenses) > 0 {
+			return strings.Join(di.Licenses, " AND ")
+		}
+	}
+	return unknownLicense
+}
+
+// WriteLicenseReport writes the external dependencies of the graph grouped by license,
+// flagging the unknown and copyleft ones, then the same for the scanned modules.
+func WriteLicenseReport(w io.Writer, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, ann *Annotations) error {
+	r := &reportWriter{w: w}
+	external := make(map[string][]string) // license -> modules
+	internal := make(map[string][]string)
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[path]
+		license := moduleLicense(path, info, ann)
+		if info == nil {
+			external[license] = append(external[license], path)
+		} else {
+			internal[license] = append(internal[license], path)
+		}
+	}
+	copyleft, unknown := 0, 0
+	for _, group := range []struct {
+		title    string
+		licenses map[string][]string
+	}{{"External Dependencies by License", external}, {"Scanned Modules by License", internal}} {
+		r.printf("%s:\n", group.title)
+		for _, license := range graph.SortedKeys(group.licenses) {
+			modules := group.licenses[license]
+			mark := ""
+			switch {
+			case license == unknownLicense:
+				mark = " [unknown]"
+				unknown += len(modules)
+			case isCopyleft(license):
+				mark = " [copyleft]"
+				copyleft += len(modules)
+			}
+			r.printf("  %s (%d)%s:\n", license, len(modules), mark)
+			for _, path := range modules {
+				r.printf("    - %s\n", path)
+			}
+		}
+	}
+	r.printf("%d modules with a copyleft license, %d with an unknown license.\n", copyleft, unknown)
+	return r.err
+}
+
+// --- End License Inventory ---
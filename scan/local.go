@@ -0,0 +1,178 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/semver"
+)
+
+// --- Local Filesystem Scanning ---
+
+// skipLocalDir returns true for directories that can't contain relevant go.mod files
+// (same rules as the go tool uses for ./... plus vendor and node_modules).
+func skipLocalDir(name string) bool {
+	return name == "vendor" || name == "testdata" || name == "node_modules" ||
+		(len(name) > 1 && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")))
+}
+
+// findRepoRoot returns the closest directory, from dir up to root, containing a .git
+// (i.e. the repository the module belongs to), or root if there is none.
+func findRepoRoot(root, dir string) string {
+	for d := dir; len(d) >= len(root); d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if d == root {
+			break
+		}
+	}
+	return root
+}
+
+// LocalDir walks the directory tree rooted at root and adds every module found
+// (any go.mod file) to res, without any GitHub API call.
+// The repo path of each module is the root's base name followed by the relative directory
+// of the git repository containing it (and Dir is the module's directory within that repository).
+func LocalDir(ctx context.Context, root string, ownerIdx int, res *Result) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	base := filepath.Base(absRoot)
+	return filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err() // canceled (Ctrl-C, -timeout)
+		}
+		if err != nil {
+			log.Warnf("      Error walking %s: %v", path, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != absRoot && skipLocalDir(d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" {
+			scanLocalManifest(ctx, absRoot, base, path, d.Name(), root, ownerIdx, res)
+			return nil
+		}
+		if !res.Manifests["go"] {
+			return nil
+		}
+		modDir := filepath.Dir(path)
+		repoRoot := findRepoRoot(absRoot, modDir)
+		rel, _ := filepath.Rel(absRoot, repoRoot)
+		repoPath := base
+		if rel != "." {
+			repoPath = base + "/" + filepath.ToSlash(rel)
+		}
+		dir, _ := filepath.Rel(repoRoot, modDir)
+		if dir == "." {
+			dir = ""
+		}
+		goModPath := filepath.ToSlash(filepath.Join(dir, "go.mod"))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: repoPath, File: goModPath, Kind: ErrorAPI, Err: err})
+			return nil
+		}
+		modFile, err := parseGoMod(path, content)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: repoPath, File: goModPath, Kind: ErrorParse, Err: err})
+			res.addErrorModule(&graph.ModuleInfo{RepoPath: repoPath, Dir: filepath.ToSlash(dir), Owner: root, OwnerIdx: ownerIdx}, err)
+			return nil
+		}
+		modulePath := modFile.Module.Mod.Path
+		if prev, found := res.Modules[modulePath]; found {
+			log.Warnf("      Module %s found in both %s and %s, keeping the first one", modulePath, prev.RepoPath, repoPath)
+			return nil
+		}
+		log.LogVf("      Found module %s in %s (%s)", modulePath, repoPath, dir)
+		info := &graph.ModuleInfo{Path: modulePath, RepoPath: repoPath, Dir: filepath.ToSlash(dir), Owner: root, OwnerIdx: ownerIdx}
+		res.addModule(info, modFile)
+		res.LocalDirs[modulePath] = modDir
+		if res.Transitive {
+			res.addTransitive(info, localTransitiveDeps(ctx, modDir))
+		}
+		return nil
+	})
+}
+
+// scanLocalManifest records the package of a non Go manifest file (package.json,
+// Cargo.toml) when its type is enabled by -manifests.
+func scanLocalManifest(ctx context.Context, absRoot, base, path, name, root string, ownerIdx int, res *Result) {
+	for lang := range res.Manifests {
+		if lang == "go" || manifestFiles[lang] != name {
+			continue
+		}
+		dir := filepath.Dir(path)
+		repoRoot := findRepoRoot(absRoot, dir)
+		repoPath := base
+		if rel, _ := filepath.Rel(absRoot, repoRoot); rel != "." {
+			repoPath = base + "/" + filepath.ToSlash(rel)
+		}
+		rel, _ := filepath.Rel(repoRoot, dir)
+		if rel == "." {
+			rel = ""
+		}
+		file := filepath.ToSlash(filepath.Join(rel, name))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: repoPath, File: file, Kind: ErrorAPI, Err: err})
+			return
+		}
+		pkgName, deps, err := parseManifest(lang, content)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: repoPath, File: file, Kind: ErrorParse, Err: err})
+			return
+		}
+		info := &graph.ModuleInfo{RepoPath: repoPath, Dir: filepath.ToSlash(rel), Owner: root, OwnerIdx: ownerIdx}
+		res.addManifest(info, lang, pkgName, deps)
+	}
+}
+
+// localTransitiveDeps returns the transitive dependencies of the module in dir using
+// `go mod graph` (highest version seen for each module, as MVS would select), falling back
+// to the go.sum file if the go command fails (e.g. offline with an empty module cache).
+func localTransitiveDeps(ctx context.Context, dir string) map[string]string {
+	cmd := exec.CommandContext(ctx, "go", "mod", "graph")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		log.Warnf("      go mod graph failed in %s, using go.sum instead: %v", dir, err)
+		content, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+		if err != nil {
+			log.LogVf("      No go.sum in %s: %v", dir, err)
+			return nil
+		}
+		return parseGoSum(content)
+	}
+	res := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		path, version, _ := strings.Cut(fields[1], "@")
+		if path == "go" || path == "toolchain" {
+			continue
+		}
+		if prev, found := res[path]; !found || semver.Compare(version, prev) > 0 {
+			res[path] = version
+		}
+	}
+	return res
+}
+
+// --- End Local Filesystem Scanning ---
@@ -0,0 +1,179 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"github.com/ldemailly/depgraph/provider"
+)
+
+// --- Other Languages Manifests (-manifests, experimental) ---
+
+// Languages (manifest types) supported by -manifests, and their manifest file name.
+var manifestFiles = map[string]string{
+	"go":    "go.mod",
+	"npm":   "package.json",
+	"cargo": "Cargo.toml",
+}
+
+// ParseManifestsFlag parses the comma separated -manifests value.
+func ParseManifestsFlag(value string) (map[string]bool, error) {
+	res := make(map[string]bool)
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		if _, ok := manifestFiles[lang]; !ok {
+			return nil, fmt.Errorf("unknown manifest type %q, expecting go, npm or cargo", lang)
+		}
+		res[lang] = true
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no manifest type in %q", value)
+	}
+	return res, nil
+}
+
+// isGoModule returns false for the nodes of other languages ("npm:name"), as a
+// Go module path can't contain a colon.
+func isGoModule(path string) bool {
+	return !strings.Contains(path, ":")
+}
+
+// parsePackageJSON returns the name and the (non dev) dependencies of a package.json.
+func parsePackageJSON(content []byte) (string, map[string]string, error) {
+	var pkg struct {
+		Name         string
+		Dependencies map[string]string
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return "", nil, err
+	}
+	return pkg.Name, pkg.Dependencies, nil
+}
+
+// parseCargoToml returns the package name and the dependencies of a Cargo.toml. This is a
+// minimal parser for the [package] name and [dependencies] entries (`name = "1.0"` or
+// `name = { version = "1.0", ... }`, path and workspace dependencies being reported as such).
+func parseCargoToml(content []byte) (string, map[string]string, error) {
+	name := ""
+	deps := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key, value = strings.Trim(strings.TrimSpace(key), `"`), strings.TrimSpace(value)
+		switch section {
+		case "package":
+			if key == "name" {
+				name = strings.Trim(value, `"`)
+			}
+		case "dependencies":
+			deps[key] = cargoDepVersion(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	return name, deps, nil
+}
+
+// cargoDepVersion extracts the version of a Cargo dependency value.
+func cargoDepVersion(value string) string {
+	if !strings.HasPrefix(value, "{") {
+		return strings.Trim(value, `"`)
+	}
+	fields := map[string]string{}
+	for _, kv := range strings.Split(strings.Trim(value, "{} "), ",") {
+		k, v, found := strings.Cut(kv, "=")
+		if found {
+			fields[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	switch {
+	case fields["version"] != "":
+		return fields["version"]
+	case fields["path"] != "":
+		return "path:" + fields["path"]
+	case fields["workspace"] == "true":
+		return "workspace"
+	case fields["git"] != "":
+		return "git"
+	}
+	return ""
+}
+
+// parseManifest parses a non Go manifest content.
+func parseManifest(lang string, content []byte) (string, map[string]string, error) {
+	if lang == "npm" {
+		return parsePackageJSON(content)
+	}
+	return parseCargoToml(content)
+}
+
+// addManifest records a non Go package (node lang:name) and its dependencies. info has
+// the repository information filled.
+func (sr *Result) addManifest(info *graph.ModuleInfo, lang, name string, deps map[string]string) {
+	if name == "" {
+		log.LogVf("      Skipping %s manifest without a name in %s", lang, info.RepoPath)
+		return
+	}
+	info.Path = lang + ":" + name
+	info.Language = lang
+	info.Deps = make(map[string]string, len(deps))
+	for dep, version := range deps {
+		info.Deps[lang+":"+dep] = version
+		sr.AllPaths[lang+":"+dep] = true
+	}
+	info.Fetched = true
+	if prev, found := sr.Modules[info.Path]; found {
+		log.Warnf("      Package %s found in both %s and %s, keeping the first one", info.Path, prev.RepoPath, info.RepoPath)
+		return
+	}
+	sr.AllPaths[info.Path] = true
+	sr.Modules[info.Path] = info
+}
+
+// scanManifests fetches and records the non Go manifests (at the root) of a GitHub repository.
+// files, if not nil, are the files at the root (-tree): the missing manifests aren't fetched.
+func scanManifests(ctx context.Context, p provider.Provider, owner, repoName string, info graph.ModuleInfo, files map[string]bool, opts *Options, res *Result) {
+	for _, lang := range graph.SortedKeys(res.Manifests) {
+		if lang == "go" || (files != nil && !files[manifestFiles[lang]]) {
+			continue
+		}
+		content, err := p.GetFileContents(ctx, owner, repoName, manifestFiles[lang], opts.Ref)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: info.RepoPath, File: manifestFiles[lang], Kind: ErrorAPI, Err: err})
+			continue
+		}
+		if content == nil {
+			continue
+		}
+		name, deps, err := parseManifest(lang, content)
+		if err != nil {
+			res.addError(ctx, &RepoError{Repo: info.RepoPath, File: manifestFiles[lang], Kind: ErrorParse, Err: err})
+			continue
+		}
+		langInfo := info // copy
+		res.addManifest(&langInfo, lang, name, deps)
+	}
+}
+
+// --- End Other Languages Manifests ---
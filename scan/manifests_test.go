@@ -0,0 +1,119 @@
+package scan
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseManifestsFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "go", want: []string{"go"}},
+		{value: " npm, cargo ,", want: []string{"cargo", "npm"}},
+		{value: "go,npm,go", want: []string{"go", "npm"}},
+		{value: "maven", wantErr: true},
+		{value: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseManifestsFlag(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want one %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if keys := slices.Sorted(maps.Keys(got)); !tt.wantErr && !slices.Equal(keys, tt.want) {
+			t.Errorf("%q: %v, want %v", tt.value, keys, tt.want)
+		}
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     string
+		content  string
+		wantName string
+		wantDeps map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "package.json",
+			lang:     "npm",
+			content:  `{"name": "web", "dependencies": {"react": "^18.2.0"}, "devDependencies": {"jest": "^29.0.0"}}`,
+			wantName: "web",
+			wantDeps: map[string]string{"react": "^18.2.0"},
+		},
+		{
+			name:    "invalid package.json",
+			lang:    "npm",
+			content: `{"name": `,
+			wantErr: true,
+		},
+		{
+			name: "Cargo.toml",
+			lang: "cargo",
+			content: `# a comment
+[package]
+name = "engine"
+version = "0.1.0"
+
+[dependencies]
+serde = "1.0"
+tokio = { version = "1.36", features = ["full"] }
+"quoted" = "2"
+local = { path = "../local" }
+shared = { workspace = true }
+fromgit = { git = "https://example.com/fromgit" }
+
+[dev-dependencies]
+criterion = "0.5"
+`,
+			wantName: "engine",
+			wantDeps: map[string]string{"serde": "1.0", "tokio": "1.36", "quoted": "2", "local": "path:../local",
+				"shared": "workspace", "fromgit": "git"},
+		},
+	}
+	for _, tt := range tests {
+		name, deps, err := parseManifest(tt.lang, []byte(tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want one %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if name != tt.wantName || !maps.Equal(deps, tt.wantDeps) {
+			t.Errorf("%s: %q %v, want %q %v", tt.name, name, deps, tt.wantName, tt.wantDeps)
+		}
+	}
+}
+
+// TestScanManifests scans the fake provider with package.json and Cargo.toml files: their
+// packages are nodes prefixed by their language, next to the Go modules.
+func TestScanManifests(t *testing.T) {
+	f := newFakeProvider()
+	f.files["acme/app/package.json"] = `{"name": "web", "dependencies": {"react": "^18.2.0"}}`
+	f.files["acme/nogomod/Cargo.toml"] = "[package]\nname = \"engine\"\n\n[dependencies]\nserde = \"1.0\"\n"
+	f.files["acme/log/package.json"] = `{"name": "web"}` // duplicate, the first one is kept
+	res := NewResult()
+	res.Manifests = map[string]bool{"go": true, "npm": true, "cargo": true}
+	OwnersAndRepos(context.Background(), f, []string{"acme"}, nil, &Options{Visibility: VisibilityPublic, Concurrency: 1}, res)
+	want := []string{"cargo:engine", "example.com/app", "example.com/log", "example.com/myfork", "npm:web"}
+	if got := slices.Sorted(maps.Keys(res.Modules)); !slices.Equal(got, want) {
+		t.Fatalf("modules %v, want %v", got, want)
+	}
+	web := res.Modules["npm:web"]
+	if web.Language != "npm" || web.RepoPath != "acme/app" || !maps.Equal(web.Deps, map[string]string{"npm:react": "^18.2.0"}) {
+		t.Errorf("npm:web %+v", web)
+	}
+	if engine := res.Modules["cargo:engine"]; engine.Language != "cargo" || engine.Deps["cargo:serde"] != "1.0" {
+		t.Errorf("cargo:engine %+v", engine)
+	}
+	if !res.AllPaths["npm:react"] || !res.AllPaths["cargo:serde"] {
+		t.Errorf("dependencies not in all paths")
+	}
+	if isGoModule("npm:web") || !isGoModule("example.com/app") {
+		t.Errorf("isGoModule doesn't tell the other languages apart")
+	}
+}
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// --- go.mod Hygiene Checks ---
+
+// GoSemver converts a go directive ("1.22", "1.22.1") or toolchain ("go1.22.1") version to
+// a semver string for comparisons, "" if it can't be (e.g. release candidates).
+func GoSemver(v string) string {
+	v = "v" + strings.TrimPrefix(v, "go")
+	if !semver.IsValid(v) || semver.Prerelease(v) != "" {
+		return ""
+	}
+	return v
+}
+
+// requireBlockPaths returns the module paths of each require block, in file order.
+func requireBlockPaths(modFile *modfile.File) [][]string {
+	var res [][]string
+	for _, stmt := range modFile.Syntax.Stmt {
+		block, ok := stmt.(*modfile.LineBlock)
+		if !ok || len(block.Token) == 0 || block.Token[0] != "require" {
+			continue
+		}
+		paths := make([]string, 0, len(block.Line))
+		for _, line := range block.Line {
+			if len(line.Token) == 0 {
+				continue
+			}
+			p, err := strconv.Unquote(line.Token[0])
+			if err != nil {
+				p = line.Token[0] // not quoted
+			}
+			paths = append(paths, p)
+		}
+		res = append(res, paths)
+	}
+	return res
+}
+
+// modCheck returns the hygiene issues of a go.mod: missing go directive, unsorted require
+// blocks, duplicate or redundant indirect requires, toolchain older than the go directive.
+func modCheck(modFile *modfile.File) []string {
+	var issues []string
+	if modFile.Go == nil {
+		issues = append(issues, "missing go directive")
+	}
+	for _, paths := range requireBlockPaths(modFile) {
+		for i := 1; i < len(paths); i++ {
+			if paths[i] < paths[i-1] {
+				issues = append(issues, fmt.Sprintf("unsorted require block (%s before %s)", paths[i-1], paths[i]))
+				break
+			}
+		}
+	}
+	directCount := make(map[string]int)
+	for _, req := range modFile.Require {
+		if !req.Indirect {
+			directCount[req.Mod.Path]++
+		}
+	}
+	for _, req := range modFile.Require {
+		switch {
+		case req.Indirect && directCount[req.Mod.Path] > 0:
+			issues = append(issues, fmt.Sprintf("redundant // indirect require of %s (also required directly)", req.Mod.Path))
+		case !req.Indirect && directCount[req.Mod.Path] > 1:
+			issues = append(issues, fmt.Sprintf("%s is required %d times", req.Mod.Path, directCount[req.Mod.Path]))
+			directCount[req.Mod.Path] = 1 // report once
+		}
+	}
+	if modFile.Go != nil && modFile.Toolchain != nil {
+		goV, toolV := GoSemver(modFile.Go.Version), GoSemver(modFile.Toolchain.Name)
+		if goV != "" && toolV != "" && semver.Compare(toolV, goV) < 0 {
+			issues = append(issues, fmt.Sprintf("toolchain %s is older than go %s", modFile.Toolchain.Name, modFile.Go.Version))
+		}
+	}
+	return issues
+}
+
+// WriteModCheckReport writes the go.mod hygiene issues of the scanned modules and the
+// go/toolchain versions in use, flagging the modules that are behind the most recent ones.
+func WriteModCheckReport(w io.Writer, modulesFoundInOwners map[string]*graph.ModuleInfo) error {
+	r := &reportWriter{w: w}
+	r.printf("go.mod Checks:\n")
+	count := 0
+	goVersions := make(map[string][]string) // go directive -> modules
+	toolchains := make(map[string][]string) // toolchain -> modules
+	maxGo, maxToolchain := "", ""
+	for _, modPath := range graph.SortedKeys(modulesFoundInOwners) {
+		info := modulesFoundInOwners[modPath]
+		if info.Language != "" {
+			continue // -manifests npm/cargo package
+		}
+		goVersions[info.GoVersion] = append(goVersions[info.GoVersion], modPath)
+		if v := GoSemver(info.GoVersion); v != "" && (maxGo == "" || semver.Compare(v, GoSemver(maxGo)) > 0) {
+			maxGo = info.GoVersion
+		}
+		if info.Toolchain != "" {
+			toolchains[info.Toolchain] = append(toolchains[info.Toolchain], modPath)
+			if v := GoSemver(info.Toolchain); v != "" && (maxToolchain == "" || semver.Compare(v, GoSemver(maxToolchain)) > 0) {
+				maxToolchain = info.Toolchain
+			}
+		}
+		if len(info.ModIssues) == 0 {
+			continue
+		}
+		r.printf("  - %s (%s):\n", modPath, info.RepoPath)
+		for _, issue := range info.ModIssues {
+			r.printf("      %s\n", issue)
+			count++
+		}
+	}
+	writeVersionsInUse(r, "go directives", goVersions, maxGo)
+	if len(toolchains) > 0 {
+		writeVersionsInUse(r, "toolchain directives", toolchains, maxToolchain)
+	}
+	r.printf("%d go.mod issues in %d modules.\n", count, len(modulesFoundInOwners))
+	return r.err
+}
+
+// writeVersionsInUse writes the modules by version, when there is more than one version in use.
+func writeVersionsInUse(r *reportWriter, what string, byVersion map[string][]string, latest string) {
+	if len(byVersion) < 2 {
+		return
+	}
+	r.printf("Mismatched %s (latest is %s):\n", what, latest)
+	for _, v := range graph.SortedKeys(byVersion) {
+		if v == latest {
+			r.printf("  - %s: %s\n", v, graph.Plural(len(byVersion[v]), "module"))
+			continue
+		}
+		name := v
+		if name == "" {
+			name = "(none)"
+		}
+		r.printf("  - %s: %s\n", name, strings.Join(byVersion[v], ", "))
+	}
+}
+
+// --- End go.mod Hygiene Checks ---
@@ -0,0 +1,34 @@
+package scan
+
+import (
+	"errors"
+	"net/http"
+)
+
+// --- Offline Mode ---
+
+// errOffline is the error of the requests that aren't answered by the cache with -offline.
+var errOffline = errors.New("not in the cache (-offline)")
+
+// OfflineTransport refuses all the requests.
+type OfflineTransport struct{}
+
+func (OfflineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errOffline
+}
+
+// transport returns the transport of the (non GitHub) http clients using the cache: the base
+// one (retrying transient errors per Retries, recording the requests in stats if not nil),
+// or OfflineTransport with -offline.
+func (c *Cache) transport(stats *APIStats) http.RoundTripper {
+	if c.offline() {
+		return OfflineTransport{}
+	}
+	var retries RetryPolicy
+	if c != nil {
+		retries = c.Retries
+	}
+	return NewRetryTransport(BaseTransport(stats), retries, stats)
+}
+
+// --- End Offline Mode ---
@@ -0,0 +1,140 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Progress Reporting ---
+
+// Intervals between progress reports: on a terminal the status line is redrawn in place,
+// otherwise (CI logs...) a log line is emitted from time to time.
+const (
+	progressTerminalInterval = 500 * time.Millisecond
+	progressLogInterval      = 30 * time.Second
+)
+
+// Progress reports, on stderr, the repositories scanned out of the ones listed so far, the API
+// calls made, the cache hit ratio and an estimated time to completion, so long scans don't look
+// hung. The methods are no-ops on a nil *Progress (-quiet) and safe for concurrent use.
+type Progress struct {
+	mu       sync.Mutex
+	stats    *APIStats
+	start    time.Time
+	total    int // repositories to scan, known so far (listing pages are fetched as we go)
+	done     int
+	terminal bool // stderr is a terminal (and logs aren't JSON): redraw the status line
+	lineLen  int  // length of the status line drawn, to erase it
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewProgress starts the progress reporting (not done with -quiet: leave the *Progress nil).
+// Call Finish when done.
+func NewProgress(stats *APIStats) *Progress {
+	p := &Progress{stats: stats, start: time.Now(), terminal: isTerminal(os.Stderr) && !log.Config.JSON, stop: make(chan struct{}), stopped: make(chan struct{})}
+	interval := progressLogInterval
+	if p.terminal {
+		interval = progressTerminalInterval
+	}
+	go p.run(interval)
+	return p
+}
+
+// isTerminal returns true if f is a terminal (character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// add records n more repositories to scan.
+func (p *Progress) add(n int) {
+	if p != nil {
+		p.mu.Lock()
+		p.total += n
+		p.mu.Unlock()
+	}
+}
+
+// scanned records a repository scanned.
+func (p *Progress) scanned() {
+	if p != nil {
+		p.mu.Lock()
+		p.done++
+		p.mu.Unlock()
+	}
+}
+
+func (p *Progress) run(interval time.Duration) {
+	defer close(p.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.report()
+		}
+	}
+}
+
+// status returns the progress line, e.g. "120/340 repos (35%), 210 API calls, 62.0% cache hits, ETA 1m20s".
+func (p *Progress) status() string {
+	p.mu.Lock()
+	done, total := p.done, p.total
+	p.mu.Unlock()
+	calls, hitRatio := 0, 0.
+	if p.stats != nil {
+		p.stats.mu.Lock()
+		calls, hitRatio = p.stats.totalCalls(), p.stats.hitRatio()
+		p.stats.mu.Unlock()
+	}
+	line := fmt.Sprintf("%d/%d repos", done, total)
+	if total > 0 {
+		line += fmt.Sprintf(" (%d%%)", 100*done/total)
+	}
+	line += fmt.Sprintf(", %d API calls, %.1f%% cache hits", calls, hitRatio)
+	if done > 0 && done < total {
+		elapsed := time.Since(p.start)
+		eta := time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+		line += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+	}
+	return line
+}
+
+func (p *Progress) report() {
+	p.mu.Lock()
+	started := p.total > 0
+	p.mu.Unlock()
+	if !started {
+		return // still listing
+	}
+	line := p.status()
+	if !p.terminal {
+		log.Infof("Progress: %s", line)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r%-*s\r", p.lineLen, line)
+	p.lineLen = len(line)
+}
+
+// Finish stops the reporting and erases the status line.
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	if p.terminal && p.lineLen > 0 {
+		fmt.Fprintf(os.Stderr, "\r%*s\r", p.lineLen, "")
+	}
+}
+
+// --- End Progress Reporting ---
@@ -0,0 +1,249 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// --- Module Proxy (GOPROXY) Client ---
+
+// defaultProxy is used when GOPROXY isn't set or has no usable (http) entry.
+const defaultProxy = "https://proxy.golang.org"
+
+// ProxyModuleInfo is what we learn about a module from the module proxy.
+type ProxyModuleInfo struct {
+	Latest     string    // Latest version (@latest)
+	LatestTime time.Time // Publication time of Latest
+	Versions   []string  // Published (tagged) versions, semver sorted (@v/list)
+	Found      bool      // false if the proxy doesn't know the module (private, not go-gettable...)
+}
+
+// ProxyClient queries the module proxy, caching results like the GitHub calls.
+type ProxyClient struct {
+	baseURL    string
+	httpClient *http.Client
+	cache      *Cache
+	stats      *APIStats // can be nil
+}
+
+// proxyURL returns the first http(s) entry of GOPROXY, or the default proxy.
+func proxyURL() string {
+	for _, p := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+			return strings.TrimSuffix(p, "/")
+		}
+	}
+	return defaultProxy
+}
+
+func NewProxyClient(cache *Cache, stats *APIStats) *ProxyClient {
+	return &ProxyClient{
+		baseURL:    proxyURL(),
+		httpClient: &http.Client{Timeout: 30 * time.Second, Transport: cache.transport(stats)},
+		cache:      cache,
+		stats:      stats,
+	}
+}
+
+// get fetches path (relative to the module's proxy base) and returns the body, or nil for not found.
+func (pc *ProxyClient) get(ctx context.Context, modPath, suffix string) ([]byte, error) {
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return nil, err
+	}
+	url := pc.baseURL + "/" + escaped + "/" + suffix
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pc.httpClient.Do(req)
+	pc.stats.call("proxy", nil, err)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Info returns the proxy information for the module (cached).
+func (pc *ProxyClient) Info(ctx context.Context, modPath string) (*ProxyModuleInfo, error) {
+	keyParts := []string{"Proxy", pc.baseURL, modPath}
+	cacheKey := pc.cache.key(keyParts...)
+	var cachedData ProxyModuleInfo
+	hit, readErr := pc.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	if hit {
+		pc.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for proxy module=%s", modPath)
+		return &cachedData, nil
+	}
+	log.Infof("Cache miss for proxy module=%s, calling %s", modPath, pc.baseURL)
+	pc.stats.miss()
+	info := &ProxyModuleInfo{}
+	body, err := pc.get(ctx, modPath, "@latest")
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		var latest struct {
+			Version string
+			Time    time.Time
+		}
+		if err := json.Unmarshal(body, &latest); err != nil {
+			return nil, fmt.Errorf("proxy @latest for %s: %w", modPath, err)
+		}
+		info.Found = true
+		info.Latest = latest.Version
+		info.LatestTime = latest.Time
+		body, err = pc.get(ctx, modPath, "@v/list")
+		if err != nil {
+			return nil, err
+		}
+		info.Versions = strings.Fields(string(body))
+		semver.Sort(info.Versions)
+	}
+	writeErr := pc.cache.write(ctx, cacheKey, info)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return info, nil
+}
+
+// VersionTime returns the publication time of the given version of the module (cached),
+// or the zero time if the proxy doesn't know it.
+func (pc *ProxyClient) VersionTime(ctx context.Context, modPath, version string) (time.Time, error) {
+	keyParts := []string{"ProxyVersion", pc.baseURL, modPath, version}
+	cacheKey := pc.cache.key(keyParts...)
+	var cachedData time.Time
+	hit, readErr := pc.cache.read(ctx, cacheKey, keyParts[0], &cachedData)
+	if readErr != nil {
+		log.Errf("Error reading cache for %v: %v", keyParts, readErr)
+	}
+	if hit {
+		pc.stats.hit(ctx, keyParts)
+		log.LogVf("Cache hit for proxy module=%s@%s", modPath, version)
+		return cachedData, nil
+	}
+	log.Infof("Cache miss for proxy module=%s@%s, calling %s", modPath, version, pc.baseURL)
+	pc.stats.miss()
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return time.Time{}, err
+	}
+	body, err := pc.get(ctx, modPath, "@v/"+escapedVersion+".info")
+	if err != nil {
+		return time.Time{}, err
+	}
+	var info struct {
+		Version string
+		Time    time.Time
+	}
+	if body != nil {
+		if err := json.Unmarshal(body, &info); err != nil {
+			return time.Time{}, fmt.Errorf("proxy %s@%s info: %w", modPath, version, err)
+		}
+	}
+	writeErr := pc.cache.write(ctx, cacheKey, info.Time)
+	if writeErr != nil {
+		log.Errf("Error writing cache for %v: %v", keyParts, writeErr)
+	}
+	return info.Time, nil
+}
+
+// FetchProxyInfo queries the proxy for every node of the graph.
+func FetchProxyInfo(ctx context.Context, pc *ProxyClient, nodesToGraph map[string]bool) map[string]*ProxyModuleInfo {
+	res := make(map[string]*ProxyModuleInfo, len(nodesToGraph))
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		if ctx.Err() != nil {
+			break // canceled (Ctrl-C, -timeout)
+		}
+		if !isGoModule(path) {
+			continue // -manifests npm/cargo packages
+		}
+		info, err := pc.Info(ctx, path)
+		if err != nil {
+			log.Warnf("Error getting proxy info for %s: %v", path, err)
+			continue
+		}
+		if !info.Found {
+			log.LogVf("Module %s not found on the proxy", path)
+		}
+		res[path] = info
+	}
+	return res
+}
+
+// IsOutdated returns true if the required version is older than the latest known one.
+func IsOutdated(required string, pi *ProxyModuleInfo) bool {
+	return pi != nil && pi.Found && pi.Latest != "" && semver.Compare(required, pi.Latest) < 0
+}
+
+// latestTooltip is the DOT tooltip text for a node with proxy information.
+func latestTooltip(pi *ProxyModuleInfo) string {
+	if pi == nil || !pi.Found {
+		return "not found on module proxy"
+	}
+	return fmt.Sprintf("latest %s (%s), %d versions", pi.Latest, pi.LatestTime.Format(time.DateOnly), len(pi.Versions))
+}
+
+// WriteLatestReport writes, for each module in the graph, its latest version and the
+// (internal) modules requiring an older version.
+func WriteLatestReport(w io.Writer, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, latest map[string]*ProxyModuleInfo) error {
+	r := &reportWriter{w: w}
+	requiredBy := OutdatedRequirements(modulesFoundInOwners, nodesToGraph, latest)
+	r.printf("Latest Versions (from module proxy):\n")
+	outdated := 0
+	for _, path := range graph.SortedKeys(nodesToGraph) {
+		pi := latest[path]
+		if pi == nil || !pi.Found {
+			r.printf("  - %s: unknown to proxy\n", path)
+			continue
+		}
+		r.printf("  - %s: %s (%s)\n", path, pi.Latest, pi.LatestTime.Format(time.DateOnly))
+		for _, req := range requiredBy[path] {
+			r.printf("      outdated: %s\n", req)
+			outdated++
+		}
+	}
+	r.printf("%d outdated requirements.\n", outdated)
+	return r.err
+}
+
+// OutdatedRequirements returns, for each dependency, the "module requires version" of the
+// graph's requirements behind its latest version.
+func OutdatedRequirements(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool, latest map[string]*ProxyModuleInfo) map[string][]string {
+	requiredBy := make(map[string][]string) // dep -> "module requires version", when outdated
+	for _, src := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[src]
+		if info == nil {
+			continue
+		}
+		for _, dep := range graph.SortedKeys(info.Deps) {
+			if version := info.Deps[dep]; nodesToGraph[dep] && IsOutdated(version, latest[dep]) {
+				requiredBy[dep] = append(requiredBy[dep], fmt.Sprintf("%s requires %s", src, version))
+			}
+		}
+	}
+	return requiredBy
+}
+
+// --- End Module Proxy (GOPROXY) Client ---
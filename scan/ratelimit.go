@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Rate Limit Backoff ---
+
+// maxRateLimitRetries is the number of times a rate limited request is retried after waiting.
+const maxRateLimitRetries = 3
+
+// rateLimitProgressInterval is how often the remaining wait is logged while paused.
+const rateLimitProgressInterval = time.Minute
+
+// RateLimitTransport pauses the GitHub API calls when the (primary or secondary) rate limit
+// is reached, until it resets, and retries the rate limited requests, instead of failing the
+// scan midway. Waits longer than maxWait aren't done: the rate limit error is returned as before.
+// The pause is shared by all the requests (parallel scans).
+type RateLimitTransport struct {
+	base    http.RoundTripper
+	maxWait time.Duration
+	stats   *APIStats // can be nil
+
+	mu    sync.Mutex
+	until time.Time // no requests before
+}
+
+// NewRateLimitTransport wraps base (http.DefaultTransport if nil).
+func NewRateLimitTransport(base http.RoundTripper, maxWait time.Duration, stats *APIStats) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{base: base, maxWait: maxWait, stats: stats}
+}
+
+// rateLimitWait returns how long to wait before the next request after resp, 0 if the rate
+// limit isn't reached, and which limit it is. Secondary rate limits come with a Retry-After
+// (seconds); the primary one with X-RateLimit-Remaining 0 until X-RateLimit-Reset (epoch).
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, string) {
+	limited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	if retryAfter := resp.Header.Get("Retry-After"); limited && retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(secs) * time.Second, "secondary rate limit"
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, ""
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	wait := time.Unix(reset, 0).Sub(now) + time.Second // margin for clock differences
+	if wait <= 0 {
+		return 0, ""
+	}
+	return wait, "rate limit"
+}
+
+// pause extends the shared pause to now+wait, logging it if it's a new (longer) one.
+func (t *RateLimitTransport) pause(wait time.Duration, reason string) {
+	until := time.Now().Add(wait)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !until.After(t.until) {
+		return
+	}
+	if time.Until(t.until) < time.Second { // not already paused (same limit seen by a parallel request)
+		log.Warnf("GitHub %s reached, pausing for %v (resuming at %s)", reason, wait.Round(time.Second), until.Format(time.TimeOnly))
+	}
+	t.until = until
+}
+
+// waitPaused waits until the end of the current pause, logging the remaining time
+// periodically. Returns the context's error if it's canceled while waiting.
+func (t *RateLimitTransport) waitPaused(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		left := time.Until(t.until)
+		t.mu.Unlock()
+		if left <= 0 {
+			return nil
+		}
+		wait := min(left, rateLimitProgressInterval)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		t.stats.rateWaited(wait)
+		if left > rateLimitProgressInterval {
+			log.Infof("  Waiting for the GitHub rate limit to reset: %v left", (left - rateLimitProgressInterval).Round(time.Second))
+		}
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.waitPaused(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		wait, reason := rateLimitWait(resp, time.Now())
+		if wait <= 0 || wait > t.maxWait {
+			if wait > t.maxWait && t.maxWait > 0 {
+				log.Warnf("GitHub %s reached, not waiting %v (more than -rate-limit-wait %v)", reason, wait.Round(time.Second), t.maxWait)
+			}
+			return resp, nil
+		}
+		t.pause(wait, reason)
+		limited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+		if !limited {
+			// Last call of the quota: the next requests wait for the reset (else go-github
+			// would refuse them without even trying).
+			if err := t.waitPaused(req.Context()); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+		if attempt >= maxRateLimitRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil // can't retry
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		req = retry
+		log.LogVf("Retrying %s %s after the rate limit wait", req.Method, req.URL.Path)
+	}
+}
+
+// --- End Rate Limit Backoff ---
@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := strconv.FormatInt(now.Unix()+60, 10)
+	tests := []struct {
+		name       string
+		status     int
+		headers    []string
+		wantWait   time.Duration
+		wantReason string
+	}{
+		{name: "ok", status: http.StatusOK, headers: []string{"X-RateLimit-Remaining", "4999", "X-RateLimit-Reset", reset}},
+		{name: "secondary", status: http.StatusForbidden, headers: []string{"Retry-After", "30"}, wantWait: 30 * time.Second, wantReason: "secondary rate limit"},
+		{name: "too many requests", status: http.StatusTooManyRequests, headers: []string{"Retry-After", "5"}, wantWait: 5 * time.Second, wantReason: "secondary rate limit"},
+		{name: "retry after on success", status: http.StatusOK, headers: []string{"Retry-After", "30"}},
+		{name: "primary", status: http.StatusForbidden, headers: []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", reset}, wantWait: 61 * time.Second, wantReason: "rate limit"},
+		{name: "last call of the quota", status: http.StatusOK, headers: []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", reset}, wantWait: 61 * time.Second, wantReason: "rate limit"},
+		{name: "already reset", status: http.StatusForbidden, headers: []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", strconv.FormatInt(now.Unix()-10, 10)}},
+		{name: "invalid reset", status: http.StatusForbidden, headers: []string{"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", "soon"}},
+	}
+	for _, tt := range tests {
+		wait, reason := rateLimitWait(tokenResponse(nil, tt.status, tt.headers...), now)
+		if wait != tt.wantWait || reason != tt.wantReason {
+			t.Errorf("%s: wait %v (%q), want %v (%q)", tt.name, wait, reason, tt.wantWait, tt.wantReason)
+		}
+	}
+}
+
+// TestRateLimitTransport checks a secondary rate limited request is retried after the pause,
+// and that longer waits than the maximum return the rate limit error right away.
+func TestRateLimitTransport(t *testing.T) {
+	calls := 0
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return tokenResponse(req, http.StatusForbidden, "Retry-After", "1"), nil
+		}
+		return tokenResponse(req, http.StatusOK), nil
+	})
+	stats := newAPIStats()
+	transport := NewRateLimitTransport(base, time.Minute, stats)
+	req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/acme/log", nil)
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %v, error %v, want 200 after the retry", resp, err)
+	}
+	if calls != 2 || time.Since(start) < 900*time.Millisecond || stats.RateWaitSecs < 0.9 {
+		t.Errorf("%d calls in %v, waited %vs, want 2 after a 1s pause", calls, time.Since(start), stats.RateWaitSecs)
+	}
+
+	calls = 0
+	transport = NewRateLimitTransport(base, 500*time.Millisecond, nil)
+	if resp, err := transport.RoundTrip(req); err != nil || resp.StatusCode != http.StatusForbidden || calls != 1 {
+		t.Errorf("over the maximum wait: status %v, error %v, %d calls, want the 403 of the only call", resp, err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport = NewRateLimitTransport(base, time.Minute, nil)
+	transport.pause(time.Hour, "rate limit")
+	cancel()
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled while paused: error %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,184 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Remote Cache Backend ---
+
+// RemoteCacheAuthEnv is the environment variable with the Authorization header value sent to
+// the remote cache, e.g. "Bearer $(gcloud auth print-access-token)" for a GCS bucket.
+const RemoteCacheAuthEnv = "DEPGRAPH_CACHE_AUTH"
+
+// isRemoteCache returns true if the -cache-backend value is a remote cache URL.
+func isRemoteCache(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// httpBackend is a cache shared through an HTTP server (a simple cache service, a WebDAV
+// directory, a GCS bucket through its XML API...): entries are the files backend's JSON
+// files, at <url>/<sha1>.json, read with GET (the Last-Modified header being their time),
+// written with PUT and removed with DELETE. The local files backend stays in front of it:
+// entries are read locally first, and those found remotely are kept locally.
+type httpBackend struct {
+	*fileBackend // local cache, for the key, list and local reads
+	base         string
+	auth         string       // Authorization header value, if any
+	client       *http.Client // set by open, per the cache settings
+	offline      bool         // -offline: the remote cache isn't used
+	warnOnce     sync.Once
+}
+
+func newHTTPBackend(base string) *httpBackend {
+	return &httpBackend{
+		fileBackend: newFileBackend(),
+		base:        strings.TrimSuffix(base, "/"),
+		auth:        os.Getenv(RemoteCacheAuthEnv),
+	}
+}
+
+func (h *httpBackend) open(c *Cache) error {
+	h.client = &http.Client{Timeout: 30 * time.Second, Transport: c.transport(nil)}
+	h.offline = c.Offline
+	return nil
+}
+
+// url returns the remote URL of the entry of the (local) key.
+func (h *httpBackend) url(key string) string {
+	return h.base + "/" + filepath.Base(key)
+}
+
+// do makes the request to the remote cache, with the authorization if any.
+func (h *httpBackend) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.url(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.auth != "" {
+		req.Header.Set("Authorization", h.auth)
+	}
+	return h.client.Do(req)
+}
+
+// remoteError logs (once) that the remote cache failed: it is only a cache, the scan goes on
+// with the local one.
+func (h *httpBackend) remoteError(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return // the scan is canceled (Ctrl-C, -timeout)
+	}
+	h.warnOnce.Do(func() {
+		log.Warnf("Remote cache %s error, using the local cache only for the failing requests: %v", h.base, err)
+	})
+	log.LogVf("Remote cache error: %v", err)
+}
+
+func (h *httpBackend) read(ctx context.Context, key string) ([]byte, time.Time, error) {
+	data, modTime, err := h.fileBackend.read(ctx, key)
+	if !errors.Is(err, os.ErrNotExist) || h.offline || ctx.Err() != nil {
+		return data, modTime, err
+	}
+	resp, err := h.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		h.remoteError(err)
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden: // GCS and S3 answer 403 for missing objects without list access
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, time.Time{}, os.ErrNotExist
+	default:
+		h.remoteError(fmt.Errorf("GET %s: %s", h.url(key), resp.Status))
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		h.remoteError(err)
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	modTime, err = http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modTime = time.Now()
+	}
+	log.LogVf("Remote cache hit for %s (%v old)", key, time.Since(modTime).Round(time.Second))
+	// Kept locally, with the remote time (for -cache-ttl)
+	if err := h.fileBackend.write(ctx, key, data); err == nil {
+		_ = os.Chtimes(key, modTime, modTime)
+	}
+	return data, modTime, nil
+}
+
+// put uploads the entry's data to the remote cache.
+func (h *httpBackend) put(ctx context.Context, key string, data []byte) error {
+	if h.offline {
+		return nil
+	}
+	resp, err := h.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		h.remoteError(err)
+		return nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		h.remoteError(fmt.Errorf("PUT %s: %s", h.url(key), resp.Status))
+	}
+	return nil
+}
+
+func (h *httpBackend) write(ctx context.Context, key string, data []byte) error {
+	if err := h.fileBackend.write(ctx, key, data); err != nil {
+		return err
+	}
+	return h.put(ctx, key, data)
+}
+
+// touch refreshes the entry locally and uploads it again, for its remote time to be refreshed too.
+func (h *httpBackend) touch(ctx context.Context, key string) error {
+	if err := h.fileBackend.touch(ctx, key); err != nil {
+		return err
+	}
+	data, _, err := h.fileBackend.read(ctx, key)
+	if err != nil {
+		return err
+	}
+	return h.put(ctx, key, data)
+}
+
+func (h *httpBackend) remove(key string) error {
+	err := h.fileBackend.remove(key)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil // may still be remote
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := h.do(context.Background(), http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("DELETE %s: %s", h.url(key), resp.Status)
+	}
+	return nil
+}
+
+// --- End Remote Cache Backend ---
@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// remoteCache is a fake remote cache server, keeping the entries in memory.
+type remoteCache struct {
+	*httptest.Server
+	mu       sync.Mutex
+	entries  map[string][]byte
+	modTime  time.Time // Last-Modified of the entries
+	requests []string  // "<method> <name>"
+	status   int       // answered to every request if not 0
+}
+
+func newRemoteCache(t *testing.T) *remoteCache {
+	r := &remoteCache{entries: make(map[string][]byte), modTime: time.Now().Add(-time.Hour).Truncate(time.Second)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		name := path.Base(req.URL.Path)
+		r.requests = append(r.requests, req.Method+" "+name)
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.status != 0 {
+			w.WriteHeader(r.status)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			data, ok := r.entries[name]
+			if !ok {
+				w.WriteHeader(http.StatusForbidden) // as S3 and GCS
+				return
+			}
+			w.Header().Set("Last-Modified", r.modTime.UTC().Format(http.TimeFormat))
+			_, _ = w.Write(data)
+		case http.MethodPut:
+			r.entries[name], _ = io.ReadAll(req.Body)
+		case http.MethodDelete:
+			delete(r.entries, name)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// calls returns the requests received since the last call.
+func (r *remoteCache) calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.requests
+	r.requests = nil
+	return calls
+}
+
+// TestRemoteCache shares entries between two local caches through the remote one.
+func TestRemoteCache(t *testing.T) {
+	t.Setenv(RemoteCacheAuthEnv, "Bearer secret")
+	remote := newRemoteCache(t)
+	ctx := context.Background()
+	writer, reader := newTestCache(t, remote.URL+"/", ""), newTestCache(t, remote.URL, "")
+	parts := []string{"GetContents", "acme", "log", "go.mod", ""}
+	key := writer.key(parts...)
+	name := path.Base(key)
+	if err := writer.write(ctx, key, CachedContentResponse{Found: true, ETag: `"abc"`}); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if got, want := remote.calls(), []string{"PUT " + name}; !slices.Equal(got, want) {
+		t.Errorf("write: requests %v, want %v", got, want)
+	}
+
+	key = reader.key(parts...)
+	var got CachedContentResponse
+	if hit, err := reader.read(ctx, key, parts[0], &got); !hit || err != nil || got.ETag != `"abc"` {
+		t.Fatalf("remote read: hit %v, error %v, %+v", hit, err, got)
+	}
+	if info, err := os.Stat(key); err != nil || !info.ModTime().Equal(remote.modTime) {
+		t.Errorf("local copy: %v, error %v, want the remote time %v", info, err, remote.modTime)
+	}
+	if hit, _ := reader.read(ctx, key, parts[0], &got); !hit {
+		t.Errorf("local read: miss")
+	}
+	if got, want := remote.calls(), []string{"GET " + name}; !slices.Equal(got, want) {
+		t.Errorf("reads: requests %v, want %v (the second one local)", got, want)
+	}
+
+	if err := reader.Store.remove(key); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	if hit, err := reader.read(ctx, key, parts[0], &got); hit || err != nil {
+		t.Errorf("read after remove: hit %v, error %v", hit, err)
+	}
+	if got, want := remote.calls(), []string{"DELETE " + name, "GET " + name}; !slices.Equal(got, want) {
+		t.Errorf("remove: requests %v, want %v", got, want)
+	}
+}
+
+// TestRemoteCacheErrors checks the scan goes on with the local cache when the remote one
+// fails, and that it isn't used at all with -offline.
+func TestRemoteCacheErrors(t *testing.T) {
+	t.Setenv(RemoteCacheAuthEnv, "Bearer secret")
+	remote := newRemoteCache(t)
+	remote.status = http.StatusInternalServerError
+	ctx := context.Background()
+	c := newTestCache(t, remote.URL, "")
+	parts := []string{"GetContents", "acme", "tools", "go.mod", ""}
+	key := c.key(parts...)
+	var got CachedContentResponse
+	if hit, err := c.read(ctx, key, parts[0], &got); hit || err != nil {
+		t.Errorf("read: hit %v, error %v, want a miss", hit, err)
+	}
+	if err := c.write(ctx, key, CachedContentResponse{Found: true}); err != nil {
+		t.Errorf("write error: %v, want the local write only", err)
+	}
+	if hit, err := c.read(ctx, key, parts[0], &got); !hit || err != nil {
+		t.Errorf("local read: hit %v, error %v", hit, err)
+	}
+	if err := c.Store.remove(key); err == nil {
+		t.Errorf("remove: no error")
+	}
+	if got := remote.calls(); len(got) != 3 {
+		t.Errorf("requests %v, want a GET, a PUT and a DELETE", got)
+	}
+
+	offline := newTestCache(t, remote.URL, "")
+	offline.Offline = true
+	if err := offline.Open(); err != nil {
+		t.Fatal(err)
+	}
+	key = offline.key(parts...)
+	if hit, _ := offline.read(ctx, key, parts[0], &got); hit {
+		t.Errorf("offline read: hit")
+	}
+	if err := offline.write(ctx, key, CachedContentResponse{Found: true}); err != nil {
+		t.Errorf("offline write error: %v", err)
+	}
+	if got := remote.calls(); len(got) != 0 {
+		t.Errorf("offline: requests %v, want none", got)
+	}
+}
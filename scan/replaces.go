@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"io"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/modfile"
+)
+
+// --- Local Path Replaces ---
+
+// localReplaces returns the `replace foo => ../foo` directives (module path -> local directory)
+// of a go.mod: they only work on the developer's machine and break consumers and CI.
+func localReplaces(modFile *modfile.File) map[string]string {
+	var res map[string]string
+	for _, r := range modFile.Replace {
+		if r.New.Version != "" || !modfile.IsDirectoryPath(r.New.Path) {
+			continue // module => module@version replace, fine
+		}
+		if res == nil {
+			res = make(map[string]string)
+		}
+		res[r.Old.Path] = r.New.Path
+	}
+	return res
+}
+
+// WriteLocalReplaceReport writes the modules using filesystem path replaces.
+func WriteLocalReplaceReport(w io.Writer, modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) error {
+	r := &reportWriter{w: w}
+	r.printf("Local Path Replaces (break consumers and CI outside of the developer's machine):\n")
+	count := 0
+	for _, modPath := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[modPath]
+		if info == nil || len(info.LocalReplaces) == 0 {
+			continue
+		}
+		r.printf("  - %s (%s):\n", modPath, info.RepoPath)
+		for _, dep := range graph.SortedKeys(info.LocalReplaces) {
+			r.printf("      %s => %s\n", dep, info.LocalReplaces[dep])
+			count++
+		}
+	}
+	r.printf("%d local path replaces.\n", count)
+	return r.err
+}
+
+// warnLocalReplaces logs a warning for each local path replace of a just scanned module.
+func warnLocalReplaces(info *graph.ModuleInfo) {
+	for _, dep := range graph.SortedKeys(info.LocalReplaces) {
+		log.Warnf("Module %s (%s) has a local path replace: %s => %s", info.Path, info.RepoPath, dep, info.LocalReplaces[dep])
+	}
+}
+
+// --- End Local Path Replaces ---
+
+// --- Module Replaces (-replace) ---
+
+// Values of the -replace flag.
+const (
+	ReplaceOff      = ""         // replace directives (other than local paths) are ignored
+	ReplaceAnnotate = "annotate" // edges are labeled with the replacement
+	ReplaceRewrite  = "rewrite"  // edges point to the replacement module
+)
+
+// moduleReplace returns the replacement ("path@version") of a required module version,
+// "" if it isn't replaced (or replaced by a local path, see localReplaces).
+func moduleReplace(modFile *modfile.File, path, version string) string {
+	res := ""
+	for _, r := range modFile.Replace {
+		if r.Old.Path != path || r.New.Version == "" {
+			continue
+		}
+		if r.Old.Version == version {
+			return r.New.Path + "@" + r.New.Version // version specific replace wins
+		}
+		if r.Old.Version == "" {
+			res = r.New.Path + "@" + r.New.Version
+		}
+	}
+	return res
+}
+
+// ReplacedFrom returns the original dependency replaced (with -replace=rewrite) by depPath, if any.
+func ReplacedFrom(info *graph.ModuleInfo, depPath string) string {
+	for _, orig := range graph.SortedKeys(info.Replaces) {
+		if p, _, _ := strings.Cut(info.Replaces[orig], "@"); p == depPath {
+			if _, stillThere := info.Deps[orig]; !stillThere {
+				return orig
+			}
+		}
+	}
+	return ""
+}
+
+// --- End Module Replaces ---
@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Repository Lists ---
+
+// RepoSpec identifies a single GitHub repository, and optionally the ref (branch, tag) to scan.
+type RepoSpec struct {
+	Owner string
+	Repo  string
+	Ref   string // "" for the default (or -ref) branch
+}
+
+func (r RepoSpec) String() string {
+	if r.Ref != "" {
+		return r.Owner + "/" + r.Repo + "@" + r.Ref
+	}
+	return r.Owner + "/" + r.Repo
+}
+
+// Owner kinds, the prefixes of typed owner arguments: org:acme or user:alice.
+const (
+	OwnerOrg  = "org"
+	OwnerUser = "user"
+)
+
+// ParseOwner splits a typed owner argument (org:acme, user:alice) into the owner's name and
+// kind, "" for a plain owner (tried as an organization, then as a user if not found).
+func ParseOwner(arg string) (name, kind string) {
+	for _, k := range []string{OwnerOrg, OwnerUser} {
+		if rest, found := strings.CutPrefix(arg, k+":"); found {
+			return rest, k
+		}
+	}
+	return arg, ""
+}
+
+// parseRepoSpec parses `owner/repo[@ref]` or a GitHub URL (https://github.com/owner/repo[.git],
+// github.com/owner/repo, git@github.com:owner/repo.git, https://github.com/owner/repo/tree/branch)
+// into a RepoSpec.
+func parseRepoSpec(s string) (RepoSpec, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "git@github.com:")
+	for _, prefix := range []string{"https://", "http://", "ssh://git@", "git://"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.TrimPrefix(s, "www.")
+	ref := ""
+	if idx := strings.LastIndex(s, "@"); idx >= 0 {
+		s, ref = s[:idx], s[idx+1:]
+		if ref == "" {
+			return RepoSpec{}, fmt.Errorf("invalid repository %q, empty ref after @", orig)
+		}
+	}
+	s = strings.TrimPrefix(s, "github.com/")
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+	parts := strings.Split(s, "/")
+	// Allow trailing url bits like /tree/main but not a different host.
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], ".") {
+		return RepoSpec{}, fmt.Errorf("invalid repository %q, expecting owner/repo[@ref] or a github.com URL", orig)
+	}
+	if ref == "" && len(parts) > 3 && parts[2] == "tree" {
+		ref = strings.Join(parts[3:], "/") // .../tree/branch URL
+	}
+	return RepoSpec{Owner: parts[0], Repo: parts[1], Ref: ref}, nil
+}
+
+// ReadReposFile reads a file with one repository (owner/repo[@ref] or URL) per line.
+// Empty lines and lines starting with # are ignored.
+func ReadReposFile(filename string) ([]RepoSpec, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var repos []RepoSpec
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spec, err := parseRepoSpec(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNum, err)
+		}
+		repos = append(repos, spec)
+	}
+	return repos, scanner.Err()
+}
+
+// SplitOwnersAndRepos separates the owner arguments (plain or typed, see ParseOwner) from
+// `owner/repo` (or URL) ones. It is an error if an argument is invalid.
+func SplitOwnersAndRepos(args []string) ([]string, []RepoSpec, error) {
+	var owners []string
+	var repos []RepoSpec
+	for _, arg := range args {
+		name, kind := ParseOwner(arg)
+		if kind != "" && name == "" {
+			return nil, nil, fmt.Errorf("invalid owner %q, expecting a name after %s:", arg, kind)
+		}
+		if !strings.Contains(name, "/") && (kind != "" || !strings.Contains(arg, ":")) {
+			owners = append(owners, arg)
+			continue
+		}
+		spec, err := parseRepoSpec(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		repos = append(repos, spec)
+	}
+	return owners, repos, nil
+}
+
+// --- End Repository Lists ---
@@ -0,0 +1,35 @@
+package scan
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitOwnersAndRepos(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantOwners []string
+		wantRepos  []RepoSpec
+		wantErr    bool
+	}{
+		{args: []string{"acme", "user:bob", "org:corp"}, wantOwners: []string{"acme", "user:bob", "org:corp"}},
+		{
+			args:       []string{"acme", "acme/log@v2", "https://github.com/bob/util/tree/dev", "git@github.com:corp/app.git"},
+			wantOwners: []string{"acme"},
+			wantRepos:  []RepoSpec{{Owner: "acme", Repo: "log", Ref: "v2"}, {Owner: "bob", Repo: "util", Ref: "dev"}, {Owner: "corp", Repo: "app"}},
+		},
+		{args: []string{"acme", "user:"}, wantErr: true},
+		{args: []string{"acme/log@"}, wantErr: true},
+		{args: []string{"gitlab.com/acme/log"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		owners, repos, err := SplitOwnersAndRepos(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitOwnersAndRepos(%q) error %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(owners, tt.wantOwners) || !slices.Equal(repos, tt.wantRepos) {
+			t.Errorf("SplitOwnersAndRepos(%q) = %q, %+v, want %q, %+v", tt.args, owners, repos, tt.wantOwners, tt.wantRepos)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+)
+
+// --- Text Reports ---
+
+// reportWriter writes a text report (the Write*Report functions), keeping the first error.
+type reportWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (r *reportWriter) printf(format string, args ...any) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+// --- End Text Reports ---
@@ -0,0 +1,126 @@
+package scan
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// reportModules returns the scanned modules of the report tests: a (with a go.mod issue and
+// a local replace) requiring b and the external ext, b (copyleft, latest go directive), c
+// (without go directive nor license) and an npm package.
+func reportModules() map[string]*graph.ModuleInfo {
+	return map[string]*graph.ModuleInfo{
+		"example.com/a": {Path: "example.com/a", RepoPath: "acme/a", GoVersion: "1.21", Toolchain: "go1.22.1", License: "MIT",
+			ModIssues:     []string{"go 1.21 without patch version"},
+			Deps:          map[string]string{"example.com/b": "v0.9.0", "example.com/ext": "v0.1.0"},
+			LocalReplaces: map[string]string{"example.com/b": "../b"}},
+		"example.com/b":   {Path: "example.com/b", RepoPath: "acme/b", GoVersion: "1.23.0", Toolchain: "go1.23.4", License: "GPL-3.0-only"},
+		"example.com/c":   {Path: "example.com/c", RepoPath: "acme/c"},
+		"example.com/npm": {Path: "example.com/npm", RepoPath: "acme/npm", Language: "npm"},
+	}
+}
+
+// failingWriter fails after n bytes.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestReports(t *testing.T) {
+	nodes := map[string]bool{"example.com/a": true, "example.com/b": true, "example.com/c": true, "example.com/ext": true}
+	latest := map[string]*ProxyModuleInfo{
+		"example.com/b":   {Latest: "v1.0.0", LatestTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Found: true},
+		"example.com/ext": {Latest: "v0.1.0", LatestTime: time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC), Found: true},
+	}
+	ann := &Annotations{DepsDev: map[string]*DepsDevInfo{"example.com/ext": {Licenses: []string{"Apache-2.0"}}}}
+	tests := []struct {
+		name  string
+		write func(w io.Writer) error
+		want  string
+	}{
+		{
+			name:  "latest",
+			write: func(w io.Writer) error { return WriteLatestReport(w, reportModules(), nodes, latest) },
+			want: `Latest Versions (from module proxy):
+  - example.com/a: unknown to proxy
+  - example.com/b: v1.0.0 (2026-01-02)
+      outdated: example.com/a requires v0.9.0
+  - example.com/c: unknown to proxy
+  - example.com/ext: v0.1.0 (2025-06-07)
+1 outdated requirements.
+`,
+		},
+		{
+			name:  "licenses",
+			write: func(w io.Writer) error { return WriteLicenseReport(w, reportModules(), nodes, ann) },
+			want: `External Dependencies by License:
+  Apache-2.0 (1):
+    - example.com/ext
+Scanned Modules by License:
+  GPL-3.0-only (1) [copyleft]:
+    - example.com/b
+  MIT (1):
+    - example.com/a
+  unknown (1) [unknown]:
+    - example.com/c
+1 modules with a copyleft license, 1 with an unknown license.
+`,
+		},
+		{
+			name:  "old go",
+			write: func(w io.Writer) error { return WriteOldGoReport(w, reportModules(), "1.22") },
+			want: `Modules pinned to Go versions older than 1.22:
+  - example.com/c: no go directive
+  - example.com/a: 1.21
+2 of 4 modules.
+`,
+		},
+		{
+			name:  "go.mod checks",
+			write: func(w io.Writer) error { return WriteModCheckReport(w, reportModules()) },
+			want: `go.mod Checks:
+  - example.com/a (acme/a):
+      go 1.21 without patch version
+Mismatched go directives (latest is 1.23.0):
+  - (none): example.com/c
+  - 1.21: example.com/a
+  - 1.23.0: 1 module
+Mismatched toolchain directives (latest is go1.23.4):
+  - go1.22.1: example.com/a
+  - go1.23.4: 1 module
+1 go.mod issues in 4 modules.
+`,
+		},
+		{
+			name:  "local replaces",
+			write: func(w io.Writer) error { return WriteLocalReplaceReport(w, reportModules(), nodes) },
+			want: `Local Path Replaces (break consumers and CI outside of the developer's machine):
+  - example.com/a (acme/a):
+      example.com/b => ../b
+1 local path replaces.
+`,
+		},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := tt.write(&b); err != nil {
+			t.Errorf("%s: error %v", tt.name, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+		if err := tt.write(&failingWriter{n: 50}); err == nil || err.Error() != "disk full" {
+			t.Errorf("%s: write error %v, want disk full", tt.name, err)
+		}
+	}
+}
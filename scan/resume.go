@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Resumable Scans (-resume) ---
+
+// checkpointFile returns the file, in the cache directory, recording the progress of the
+// current GitHub scan: a "start\t<time>\t<arguments>" header then one line per repository
+// scanned. It is named after the arguments, so scans of other owners sharing the cache
+// directory (e.g. CI jobs) don't mix, and removed when the scan completes: it is only left
+// behind by an interrupted run.
+func checkpointFile(cacheDir, args string) string {
+	return filepath.Join(cacheDir, fmt.Sprintf("checkpoint-%x.tsv", sha1.Sum([]byte(args))))
+}
+
+// Checkpoint records the repositories scanned, for an interrupted scan to be resumed.
+// The methods are no-ops on a nil *Checkpoint and safe for concurrent use.
+type Checkpoint struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	resumed map[string]bool // lowercase owner/repo scanned by the interrupted run (-resume)
+}
+
+// StartCheckpoint starts recording the scan's progress in the (opened) cache's directory.
+// args identify the scan (e.g. the command line arguments but -resume). With resume, the
+// checkpoint of the interrupted run with the same args, if any, is continued: the
+// repositories it scanned are reused from the cache without any API call (see resumed),
+// and the cache entries written since it started are used as is (never expired).
+func StartCheckpoint(cache *Cache, args string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{path: checkpointFile(cache.Dir, args), resumed: make(map[string]bool)}
+	if resume {
+		start, err := cp.load(args)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Warnf("-resume: no interrupted scan to resume, starting a new one")
+		case err != nil:
+			log.Warnf("-resume: can't resume, starting a new scan: %v", err)
+		default:
+			cache.resumeFrom = start
+			log.Infof("Resuming the scan started at %s: %d repositories already scanned", start.Format(time.DateTime), len(cp.resumed))
+			f, err := os.OpenFile(cp.path, os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			cp.f = f
+			return cp, nil
+		}
+	}
+	f, err := os.Create(cp.path)
+	if err != nil {
+		return nil, err
+	}
+	cp.f = f
+	if _, err := fmt.Fprintf(f, "start\t%s\t%s\n", time.Now().Format(time.RFC3339Nano), args); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// load reads the checkpoint of an interrupted scan, returning when it started. It is an
+// error if that scan had other arguments.
+func (cp *Checkpoint) load(args string) (time.Time, error) {
+	f, err := os.Open(cp.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return time.Time{}, fmt.Errorf("empty %s", cp.path)
+	}
+	header := strings.SplitN(scanner.Text(), "\t", 3)
+	if len(header) != 3 || header[0] != "start" {
+		return time.Time{}, fmt.Errorf("invalid %s header %q", cp.path, scanner.Text())
+	}
+	start, err := time.Parse(time.RFC3339Nano, header[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s start time: %w", cp.path, err)
+	}
+	if header[2] != args {
+		return time.Time{}, fmt.Errorf("the interrupted scan had other arguments: %s", header[2])
+	}
+	for scanner.Scan() {
+		if repo := scanner.Text(); repo != "" {
+			cp.resumed[repo] = true
+		}
+	}
+	return start, scanner.Err()
+}
+
+// record records a repository as scanned.
+func (cp *Checkpoint) record(owner, repo string) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if _, err := fmt.Fprintln(cp.f, strings.ToLower(owner+"/"+repo)); err != nil {
+		log.Warnf("Error recording the scan progress in %s: %v", cp.path, err)
+	}
+}
+
+// scanned returns true if the repository was scanned by the interrupted run being resumed.
+func (cp *Checkpoint) scanned(owner, repo string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.resumed[strings.ToLower(owner+"/"+repo)]
+}
+
+// Finish removes the checkpoint: the scan completed, there is nothing to resume.
+func (cp *Checkpoint) Finish() {
+	if cp == nil {
+		return
+	}
+	cp.f.Close()
+	if err := os.Remove(cp.path); err != nil {
+		log.Warnf("Error removing %s: %v", cp.path, err)
+	}
+}
+
+// resumed returns true if the cache entry was fetched by the interrupted scan resumed with
+// -resume, to be used as is (no -revalidate nor -incremental refresh): the entries of the
+// repositories it scanned, and the other ones (listings, gists) written since it started.
+func (cw *ClientWrapper) resumed(ctx context.Context, keyParts []string) bool {
+	if !cw.cache.used() || cw.cache.resumeFrom.IsZero() {
+		return false
+	}
+	if kind := cacheKinds[keyParts[0]]; (kind == "repos" || kind == "contents") && len(keyParts) >= 3 && cw.Checkpoint.scanned(keyParts[1], keyParts[2]) {
+		return true
+	}
+	_, modTime, err := cw.cache.Store.read(ctx, cw.cache.key(keyParts...))
+	return err == nil && modTime.After(cw.cache.resumeFrom)
+}
+
+// --- End Resumable Scans (-resume) ---
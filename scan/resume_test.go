@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ldemailly/depgraph/internal/fakegithub"
+)
+
+// TestResume interrupts a scan (its checkpoint isn't finished) then scans again, all the
+// cache entries being expired: only the resumed scan reuses them, without any request.
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name         string
+		finished     bool   // the first scan completed
+		args         string // of the second scan
+		resume       bool
+		wantRequests bool
+	}{
+		{name: "resumed", args: "acme", resume: true},
+		{name: "not resumed", args: "acme", wantRequests: true},
+		{name: "other arguments", args: "acme bob", resume: true, wantRequests: true},
+		{name: "completed scan", finished: true, args: "acme", resume: true, wantRequests: true},
+	}
+	for _, tt := range tests {
+		s := fakegithub.Fixture()
+		cache := newTestCache(t, CacheBackendFiles, "1ns")
+		cw := newTestClient(s, cache, nil)
+		cp, err := StartCheckpoint(cache, "acme", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cw.Checkpoint = cp
+		time.Sleep(10 * time.Millisecond) // files times are coarser than time.Now()
+		first, _ := scanAcme(s, cw, 4)
+		if tt.finished {
+			cp.Finish()
+		}
+		cache.resumeFrom = time.Time{} // as a new run
+		if cw.Checkpoint, err = StartCheckpoint(cache, tt.args, tt.resume); err != nil {
+			t.Fatal(err)
+		}
+		modules, requests := scanAcme(s, cw, 4)
+		s.Close()
+		if (requests > 0) != tt.wantRequests {
+			t.Errorf("%s: %d requests, want requests %v", tt.name, requests, tt.wantRequests)
+		}
+		if got, want := slices.Sorted(maps.Keys(modules)), slices.Sorted(maps.Keys(first)); !slices.Equal(got, want) {
+			t.Errorf("%s: modules %v, want %v", tt.name, got, want)
+		}
+	}
+}
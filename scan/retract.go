@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// --- Exclude and Retract Directives ---
+
+// excludesAndRetracts returns the exclude ("path@version") and retract directives of a go.mod.
+func excludesAndRetracts(modFile *modfile.File) ([]string, []graph.Retraction) {
+	var excludes []string
+	for _, e := range modFile.Exclude {
+		excludes = append(excludes, e.Mod.Path+"@"+e.Mod.Version)
+	}
+	var retracts []graph.Retraction
+	for _, r := range modFile.Retract {
+		retracts = append(retracts, graph.Retraction{Low: r.Low, High: r.High, Rationale: r.Rationale})
+	}
+	return excludes, retracts
+}
+
+// Retraction returns the retraction of the module covering version, nil if not retracted.
+func Retraction(info *graph.ModuleInfo, version string) *graph.Retraction {
+	if info == nil {
+		return nil
+	}
+	for i, r := range info.Retracts {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			return &info.Retracts[i]
+		}
+	}
+	return nil
+}
+
+// retractionString returns "v1.0.0" or "[v1.0.0, v1.2.0]" for a range, with the rationale if any.
+func retractionString(r *graph.Retraction) string {
+	s := r.Low
+	if r.High != r.Low {
+		s = fmt.Sprintf("[%s, %s]", r.Low, r.High)
+	}
+	if r.Rationale != "" {
+		s += " (" + r.Rationale + ")"
+	}
+	return s
+}
+
+// WarnRetractedRequirements logs a warning for each scanned module requiring a version
+// retracted by another scanned module.
+func WarnRetractedRequirements(modulesFoundInOwners map[string]*graph.ModuleInfo, nodesToGraph map[string]bool) {
+	for _, src := range graph.SortedKeys(nodesToGraph) {
+		info := modulesFoundInOwners[src]
+		if info == nil {
+			continue
+		}
+		for _, dep := range graph.SortedKeys(info.Deps) {
+			if r := Retraction(modulesFoundInOwners[dep], info.Deps[dep]); r != nil {
+				log.Warnf("%s requires %s %s which is retracted: %s", src, dep, info.Deps[dep], retractionString(r))
+			}
+		}
+	}
+}
+
+// DirectivesTooltip returns the tooltip lines for the go, toolchain, exclude and retract directives of a module.
+func DirectivesTooltip(info *graph.ModuleInfo) string {
+	if info == nil {
+		return ""
+	}
+	var lines []string
+	if goLine := goDirectives(info); goLine != "" {
+		lines = append(lines, goLine)
+	}
+	for i := range info.Retracts {
+		lines = append(lines, "retracted: "+retractionString(&info.Retracts[i]))
+	}
+	for _, e := range info.Excludes {
+		lines = append(lines, "excludes: "+e)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// --- End Exclude and Retract Directives ---
@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+)
+
+// --- Retries With Backoff ---
+
+// RetryPolicy is how the requests failing with a transient error (network error, 5xx server
+// error) are retried: up to attempts times, after delay, doubled at each retry, with jitter.
+type RetryPolicy struct {
+	Attempts int
+	Delay    time.Duration
+}
+
+// Default retries of the requests failing with a transient error (-retries and -retry-delay).
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryDelay    = time.Second
+)
+
+// backoff returns the wait before the retry number attempt (0 for the first one): delay*2^attempt,
+// with a random jitter of up to -50%, so parallel requests failing together don't retry together.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Delay << attempt
+	return d - time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+// RetryTransport retries the requests failing with a transient error, per its policy, so a
+// single flaky request doesn't drop a repository, or a whole owner, from the graph.
+type RetryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	stats  *APIStats // can be nil
+}
+
+// NewRetryTransport wraps base (http.DefaultTransport if nil).
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy, stats *APIStats) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{base: base, policy: policy, stats: stats}
+}
+
+// transient returns true for the errors and responses worth retrying.
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, errOffline)
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if !transient(resp, err) || attempt >= t.policy.Attempts || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		wait := t.policy.backoff(attempt)
+		log.Warnf("%s %s failed (%s), retry %d/%d in %v", req.Method, req.URL.Redacted(), reason, attempt+1, t.policy.Attempts, wait.Round(time.Millisecond))
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		t.stats.retried(wait)
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		req = retry
+	}
+}
+
+// --- End Retries With Backoff ---
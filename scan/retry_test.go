@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{Attempts: 3, Delay: 100 * time.Millisecond}
+	for attempt, full := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for range 20 {
+			if d := p.backoff(attempt); d < full/2 || d > full {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	networkErr := errors.New("connection reset")
+	tests := []struct {
+		name       string
+		failures   []any // status code or error of the first calls, then 200
+		attempts   int
+		body       bool // POST with a body to resend
+		wantCalls  int
+		wantStatus int
+		wantErr    error
+	}{
+		{name: "success", wantCalls: 1, wantStatus: http.StatusOK},
+		{name: "server errors", failures: []any{http.StatusBadGateway, http.StatusServiceUnavailable}, attempts: 3, wantCalls: 3, wantStatus: http.StatusOK},
+		{name: "network error", failures: []any{networkErr}, attempts: 1, wantCalls: 2, wantStatus: http.StatusOK},
+		{name: "body resent", failures: []any{http.StatusInternalServerError}, attempts: 1, body: true, wantCalls: 2, wantStatus: http.StatusOK},
+		{name: "out of attempts", failures: []any{http.StatusGatewayTimeout, http.StatusGatewayTimeout}, attempts: 1, wantCalls: 2, wantStatus: http.StatusGatewayTimeout},
+		{name: "not transient", failures: []any{http.StatusNotFound}, attempts: 3, wantCalls: 1, wantStatus: http.StatusNotFound},
+		{name: "offline", failures: []any{errOffline}, attempts: 3, wantCalls: 1, wantErr: errOffline},
+		{name: "canceled", failures: []any{context.Canceled}, attempts: 3, wantCalls: 1, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		calls := 0
+		base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			if req.Body != nil {
+				if b, _ := io.ReadAll(req.Body); string(b) != "query" {
+					t.Errorf("%s: body %q, want the request's", tt.name, b)
+				}
+			}
+			if calls > len(tt.failures) {
+				return tokenResponse(req, http.StatusOK), nil
+			}
+			switch f := tt.failures[calls-1].(type) {
+			case error:
+				return nil, f
+			default:
+				return tokenResponse(req, f.(int)), nil
+			}
+		})
+		stats := newAPIStats()
+		transport := NewRetryTransport(base, RetryPolicy{Attempts: tt.attempts, Delay: time.Millisecond}, stats)
+		req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/acme/log", nil)
+		if tt.body {
+			req, _ = http.NewRequest(http.MethodPost, "https://api.github.com/graphql", strings.NewReader("query"))
+		}
+		resp, err := transport.RoundTrip(req)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+		if calls != tt.wantCalls || stats.Retries != tt.wantCalls-1 {
+			t.Errorf("%s: %d calls, %d retries, want %d calls", tt.name, calls, stats.Retries, tt.wantCalls)
+		}
+	}
+}
+
+// TestRetryCanceled checks a request canceled while waiting for its retry isn't retried.
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	transport := NewRetryTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		cancel()
+		return tokenResponse(req, http.StatusServiceUnavailable), nil
+	}), RetryPolicy{Attempts: 3, Delay: time.Hour}, nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/acme/log", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("error %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
@@ -0,0 +1,249 @@
+// Package scan finds the Go modules of GitHub owners (or local directories) and their
+// dependencies: the cached GitHub client, the module proxy and deps.dev enrichments, and
+// the depgraph command's reports. Owners is the entry point for embedding the scan:
+//
+//	client := scan.NewClientWrapper(github.NewClient(nil), nil, nil) // or any provider.Provider
+//	g, err := scan.Owners(ctx, client, []string{"fortio", "grol-io/grol"}, scan.Options{Concurrency: 8})
+//
+// and the render package writes the resulting graph.Graph as DOT, text or JSON.
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/ldemailly/depgraph/graph"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// --- Scan Results ---
+
+// Values of the -visibility flag (and of the GitHub API repository type/visibility).
+const (
+	VisibilityAll     = "all"
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// Options are the settings affecting how repositories are scanned.
+type Options struct {
+	AllModules  bool          // find all go.mod in each repo's tree, not just the root one
+	Gists       bool          // also scan the owners' gists for go.mod files
+	Visibility  string        // VisibilityAll, VisibilityPublic or VisibilityPrivate repositories of the owners
+	Concurrency int           // number of repositories scanned in parallel
+	Ref         string        // git ref (branch, tag) to scan instead of the default branch, "" for default
+	GraphQL     bool          // batch the go.mod and fork parent fetches with the GraphQL API
+	RateWait    time.Duration // max wait for a GitHub rate limit reset (0 to fail instead)
+	Revalidate  bool          // revalidate the cached GitHub responses with conditional (ETag) calls
+	Incremental bool          // refresh the listings, refetch the contents of the repositories pushed to since cached
+	Tree        bool          // list the repos' root files (git tree) first, to only fetch the go.mod, go.sum and manifests present
+	Resume      bool          // resume the interrupted scan (with the same arguments), see StartCheckpoint
+	NoForks     bool          // skip the forks, see also Result.DropForks
+	TokenFile   string        // file with the GitHub token(s), see GitHubTokens
+}
+
+// Result accumulates what is found while scanning owners (or local directories).
+type Result struct {
+	Modules    map[string]*graph.ModuleInfo // modulePath -> info, for modules found in the scanned owners
+	AllPaths   map[string]bool              // all unique module paths encountered (sources and dependencies)
+	Transitive bool                         // also record the indirect/transitive dependencies
+	Replace    string                       // ReplaceOff, ReplaceAnnotate or ReplaceRewrite
+	Stats      *APIStats                    // API calls and cache usage of the scan (and enrichments)
+	LocalDirs  map[string]string            // modulePath -> absolute directory, for -local scans
+	Manifests  map[string]bool              // manifest types to scan (-manifests), "go" by default
+	ErrorNodes bool                         // record the modules whose go.mod failed to parse as error nodes
+	Errors     []*RepoError                 // errors that made the scan skip repositories (or files), see ErrorSummary
+}
+
+// child returns an empty Result with the same settings and stats, to scan a repository
+// in parallel with others, see merge.
+func (sr *Result) child() *Result {
+	c := NewResult()
+	c.Transitive, c.Replace, c.ErrorNodes, c.Manifests, c.Stats = sr.Transitive, sr.Replace, sr.ErrorNodes, sr.Manifests, sr.Stats
+	return c
+}
+
+// merge records what was found in a child Result, as if it had been scanned into sr.
+func (sr *Result) merge(c *Result) {
+	for path := range c.AllPaths {
+		sr.AllPaths[path] = true
+	}
+	for path, info := range c.Modules {
+		sr.Modules[path] = info
+	}
+	for path, dir := range c.LocalDirs {
+		sr.LocalDirs[path] = dir
+	}
+	sr.Errors = append(sr.Errors, c.Errors...)
+}
+
+// DropForks removes the forks from the modules found, e.g. from a snapshot with -no-forks,
+// and returns their number. Their module paths required by others remain, as external.
+func (sr *Result) DropForks() int {
+	n := 0
+	for path, info := range sr.Modules {
+		if info.IsFork {
+			delete(sr.Modules, path)
+			n++
+		}
+	}
+	return n
+}
+
+func NewResult() *Result {
+	return &Result{
+		Modules:   make(map[string]*graph.ModuleInfo),
+		AllPaths:  make(map[string]bool),
+		Stats:     newAPIStats(),
+		LocalDirs: make(map[string]string),
+		Manifests: map[string]bool{"go": true},
+	}
+}
+
+// skipModulePath returns true for go.mod (slash separated) paths in directories that
+// don't define real modules (vendor, testdata, hidden or _ prefixed directories).
+func skipModulePath(goModPath string) bool {
+	dirs := strings.Split(goModPath, "/")
+	for _, d := range dirs[:len(dirs)-1] {
+		if skipLocalDir(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// goModError is the error returned by parseGoMod, with the invalid content.
+type goModError struct {
+	location string
+	content  []byte
+	err      error
+}
+
+func (e *goModError) Error() string {
+	return e.err.Error()
+}
+
+func (e *goModError) Unwrap() error {
+	return e.err
+}
+
+// parseGoMod parses go.mod content and checks it declares a module path.
+// location is only used for error messages (e.g. "owner/repo/go.mod").
+// Errors are *goModError, see addErrorModule.
+func parseGoMod(location string, content []byte) (*modfile.File, error) {
+	modFile, err := modfile.Parse(location, content, nil)
+	if err != nil {
+		return nil, &goModError{location: location, content: content, err: err}
+	}
+	if modFile.Module == nil || modFile.Module.Mod.Path == "" {
+		return nil, &goModError{location: location, content: content, err: fmt.Errorf("empty module path in %s", location)}
+	}
+	return modFile, nil
+}
+
+// addErrorModule records, with -error-nodes, the module whose go.mod failed to parse (err
+// from parseGoMod, possibly wrapped) so it shows in the graph instead of only in the logs.
+// Its path is the declared module path when it can still be extracted, else "invalid:"
+// followed by the go.mod location. Does nothing for other errors.
+func (sr *Result) addErrorModule(info *graph.ModuleInfo, err error) {
+	var gerr *goModError
+	if !sr.ErrorNodes || !errors.As(err, &gerr) {
+		return
+	}
+	info.Path = modfile.ModulePath(gerr.content)
+	if info.Path == "" || sr.Modules[info.Path] != nil {
+		info.Path = "invalid:" + gerr.location
+	}
+	info.Error = gerr.Error()
+	log.LogVf("      Adding error node %s", info.Path)
+	sr.AllPaths[info.Path] = true
+	sr.Modules[info.Path] = info
+}
+
+// addModule records a found module and its direct dependencies.
+func (sr *Result) addModule(info *graph.ModuleInfo, modFile *modfile.File) {
+	modulePath := info.Path
+	if info.Deps == nil {
+		info.Deps = make(map[string]string)
+	}
+	info.Fetched = true
+	sr.AllPaths[modulePath] = true
+	sr.Modules[modulePath] = info
+	indirect := make(map[string]string)
+	for _, req := range modFile.Require {
+		if !req.Indirect {
+			depPath, depVersion := req.Mod.Path, req.Mod.Version
+			if repl := moduleReplace(modFile, depPath, depVersion); repl != "" && sr.Replace != ReplaceOff {
+				if info.Replaces == nil {
+					info.Replaces = make(map[string]string)
+				}
+				info.Replaces[depPath] = repl
+				if sr.Replace == ReplaceRewrite {
+					depPath, depVersion, _ = strings.Cut(repl, "@")
+					log.LogVf("      Rewriting dependency %s of %s to its replacement %s", req.Mod.Path, modulePath, repl)
+				}
+			}
+			info.Deps[depPath] = depVersion
+			sr.AllPaths[depPath] = true
+		} else if sr.Transitive {
+			indirect[req.Mod.Path] = req.Mod.Version
+		} else {
+			log.Debugf("      Skipping indirect dependency %s in %s", req.Mod.Path, modulePath)
+		}
+	}
+	sr.addTransitive(info, indirect)
+	info.LocalReplaces = localReplaces(modFile)
+	if modFile.Go != nil {
+		info.GoVersion = modFile.Go.Version
+	}
+	if modFile.Toolchain != nil {
+		info.Toolchain = modFile.Toolchain.Name
+	}
+	info.ModIssues = modCheck(modFile)
+	info.Excludes, info.Retracts = excludesAndRetracts(modFile)
+	warnLocalReplaces(info)
+}
+
+// addTransitive records transitive dependencies (path -> version) of a module, ignoring
+// the ones that are direct dependencies. Keeps the highest version when already present.
+func (sr *Result) addTransitive(info *graph.ModuleInfo, deps map[string]string) {
+	if !sr.Transitive {
+		return
+	}
+	if info.IndirectDeps == nil {
+		info.IndirectDeps = make(map[string]string)
+	}
+	for path, version := range deps {
+		if _, direct := info.Deps[path]; direct || path == info.Path {
+			continue
+		}
+		if prev, found := info.IndirectDeps[path]; found && semver.Compare(prev, version) >= 0 {
+			continue
+		}
+		info.IndirectDeps[path] = version
+		sr.AllPaths[path] = true
+	}
+}
+
+// parseGoSum returns the modules (path -> highest version) listed in go.sum content,
+// i.e. (a superset of) the transitive dependencies of the module.
+func parseGoSum(content []byte) map[string]string {
+	res := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		path, version := fields[0], strings.TrimSuffix(fields[1], "/go.mod")
+		if prev, found := res[path]; !found || semver.Compare(version, prev) > 0 {
+			res[path] = version
+		}
+	}
+	return res
+}
+
+// --- End Scan Results ---
@@ -0,0 +1,233 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+	"github.com/ldemailly/depgraph/graph"
+)
+
+// --- API Usage Statistics ---
+
+// APIStats counts the API calls (by endpoint) and cache lookups of a run, and remembers the
+// last GitHub rate limit seen, so users can tune their filters and caching to their quota.
+// All methods are no-ops on a nil *APIStats, and safe for concurrent use (parallel scans).
+type APIStats struct {
+	mu            sync.Mutex
+	Calls         map[string]int `json:"calls"` // endpoint -> number of API (http) calls
+	CacheHits     int            `json:"cache_hits"`
+	CacheMisses   int            `json:"cache_misses"`
+	NotModified   int            `json:"not_modified,omitempty"` // cache hits revalidated with a 304 (-revalidate)
+	RateLimit     int            `json:"rate_limit,omitempty"`   // GitHub rate limit (per hour)
+	RateRemaining int            `json:"rate_remaining,omitempty"`
+	RateReset     time.Time      `json:"rate_reset,omitzero"`
+	// HTTP level metrics (see metricsTransport): the calls above are the logical ones
+	Requests      int     `json:"requests,omitempty"`                // HTTP requests sent, retries included
+	RequestSecs   float64 `json:"request_seconds,omitempty"`         // total time of the HTTP requests
+	Retries       int     `json:"retries,omitempty"`                 // requests retried after a transient error (-retries)
+	RetryWaitSecs float64 `json:"retry_wait_seconds,omitempty"`      // time waited before the retries
+	RateWaitSecs  float64 `json:"rate_limit_wait_seconds,omitempty"` // time paused for GitHub rate limit resets
+}
+
+func newAPIStats() *APIStats {
+	return &APIStats{Calls: make(map[string]int)}
+}
+
+// hit records a cache hit, and notifies the context's hooks (see WithHooks).
+func (s *APIStats) hit(ctx context.Context, keyParts []string) {
+	ContextHooks(ctx).cacheHit(keyParts)
+	if s != nil {
+		s.mu.Lock()
+		s.CacheHits++
+		s.mu.Unlock()
+	}
+}
+
+func (s *APIStats) miss() {
+	if s != nil {
+		s.mu.Lock()
+		s.CacheMisses++
+		s.mu.Unlock()
+	}
+}
+
+// revalidated records a cache entry found still up to date by a conditional call.
+func (s *APIStats) revalidated() {
+	if s != nil {
+		s.mu.Lock()
+		s.NotModified++
+		s.mu.Unlock()
+	}
+}
+
+// request records an HTTP request sent, and how long it took.
+func (s *APIStats) request(d time.Duration) {
+	if s != nil {
+		s.mu.Lock()
+		s.Requests++
+		s.RequestSecs += d.Seconds()
+		s.mu.Unlock()
+	}
+}
+
+// retried records a request retried after waiting d.
+func (s *APIStats) retried(d time.Duration) {
+	if s != nil {
+		s.mu.Lock()
+		s.Retries++
+		s.RetryWaitSecs += d.Seconds()
+		s.mu.Unlock()
+	}
+}
+
+// rateWaited records time paused for a rate limit reset.
+func (s *APIStats) rateWaited(d time.Duration) {
+	if s != nil {
+		s.mu.Lock()
+		s.RateWaitSecs += d.Seconds()
+		s.mu.Unlock()
+	}
+}
+
+// call records an API call to endpoint and, for GitHub calls (resp not nil), the rate limit.
+// err is the call's error, if any.
+func (s *APIStats) call(endpoint string, resp *github.Response, err error) {
+	if s == nil || errors.Is(err, errOffline) {
+		return // no actual call with -offline
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Calls[endpoint]++
+	if resp != nil && resp.Rate.Limit > 0 {
+		s.RateLimit = resp.Rate.Limit
+		s.RateRemaining = resp.Rate.Remaining
+		s.RateReset = resp.Rate.Reset.Time
+	}
+}
+
+// totalCalls returns the number of API calls made, all endpoints included.
+func (s *APIStats) totalCalls() int {
+	total := 0
+	for _, n := range s.Calls {
+		total += n
+	}
+	return total
+}
+
+// hitRatio returns the cache hit ratio in percent.
+func (s *APIStats) hitRatio() float64 {
+	lookups := s.CacheHits + s.CacheMisses
+	if lookups == 0 {
+		return 0
+	}
+	return 100. * float64(s.CacheHits) / float64(lookups)
+}
+
+// LogReport logs the scan cost summary (on stderr, so it doesn't mix with the outputs).
+func (s *APIStats) LogReport() {
+	if s == nil || s.CacheHits+s.CacheMisses == 0 {
+		return // nothing to report (e.g. -local scan)
+	}
+	log.Infof("API calls: %d total, cache hits %d / misses %d (%.1f%% hit ratio)",
+		s.totalCalls(), s.CacheHits, s.CacheMisses, s.hitRatio())
+	if s.NotModified > 0 {
+		log.Infof("  %d cache hits revalidated (304 Not Modified)", s.NotModified)
+	}
+	if s.Requests > 0 {
+		log.Infof("  %d HTTP requests, %v average", s.Requests, secondsDuration(s.RequestSecs/float64(s.Requests)).Round(time.Millisecond))
+	}
+	if s.Retries > 0 {
+		log.Infof("  %d retries (transient errors), %v waiting", s.Retries, secondsDuration(s.RetryWaitSecs).Round(time.Second))
+	}
+	if s.RateWaitSecs > 0 {
+		log.Infof("  %v paused for rate limit resets", secondsDuration(s.RateWaitSecs).Round(time.Second))
+	}
+	for _, endpoint := range graph.SortedKeys(s.Calls) {
+		log.Infof("  %-14s %d", endpoint, s.Calls[endpoint])
+	}
+	if s.RateLimit > 0 {
+		log.Infof("GitHub rate limit: %d/%d remaining, resets at %s (in %v)", s.RateRemaining, s.RateLimit,
+			s.RateReset.Format(time.TimeOnly), time.Until(s.RateReset).Round(time.Second))
+	}
+}
+
+// Summary returns the API usage in a few words for the end of run summary: calls, cache hit
+// ratio and GitHub rate limit left, "" if there was none (e.g. -local scan).
+func (s *APIStats) Summary() string {
+	if s == nil || s.CacheHits+s.CacheMisses == 0 {
+		return ""
+	}
+	res := fmt.Sprintf("%d API calls, %.1f%% cache hits", s.totalCalls(), s.hitRatio())
+	if s.RateLimit > 0 {
+		res += fmt.Sprintf(", %d/%d GitHub API calls left", s.RateRemaining, s.RateLimit)
+	}
+	return res
+}
+
+// secondsDuration converts seconds to a time.Duration.
+func secondsDuration(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
+
+// MarshalJSON encodes the stats with their totals: the API calls, the cache hit ratio (in
+// percent) and, when the GitHub rate limit is known, the calls remaining (even if none).
+func (s *APIStats) MarshalJSON() ([]byte, error) {
+	type fields APIStats // without this method
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := struct {
+		*fields
+		TotalCalls    int     `json:"total_calls"`
+		CacheHitRatio float64 `json:"cache_hit_ratio"`
+		RateRemaining *int    `json:"rate_remaining,omitempty"`
+	}{fields: (*fields)(s), TotalCalls: s.totalCalls(), CacheHitRatio: math.Round(s.hitRatio()*10) / 10}
+	if s.RateLimit > 0 {
+		out.RateRemaining = &s.RateRemaining
+	}
+	return json.Marshal(out)
+}
+
+// WriteStatsJSON writes the stats as JSON to the file (-stats-json), for CI tracking.
+func (s *APIStats) WriteStatsJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// metricsTransport records the HTTP requests and their time in stats.
+type metricsTransport struct {
+	base  http.RoundTripper
+	stats *APIStats
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	t.stats.request(time.Since(start))
+	return resp, err
+}
+
+// maxIdleConnsPerHost is how many keep-alive connections per host the http clients keep:
+// more than the default 2, for the parallel scans (-concurrency) not to reconnect all the time.
+const maxIdleConnsPerHost = 32
+
+// BaseTransport returns the transport of the http clients: the default one, tuned for
+// parallel requests to a few hosts, and instrumented (stats, can be nil).
+func BaseTransport(stats *APIStats) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return &metricsTransport{base: t, stats: stats}
+}
+
+// --- End API Usage Statistics ---
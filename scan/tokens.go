@@ -0,0 +1,294 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fortio.org/log" // Using fortio log
+	"github.com/google/go-github/v62/github"
+)
+
+// --- Multiple Tokens Rotation ---
+
+// TokensEnv is the environment variable with several comma separated GitHub tokens, used in
+// turn to scan very large organizations in one session (instead of GITHUB_TOKEN).
+const TokensEnv = "GITHUB_TOKENS"
+
+// rotateBelow is the remaining rate limit of the current token under which the next request
+// uses the token with the most remaining, keeping some margin for parallel requests.
+const rotateBelow = 100
+
+// GitHubTokens returns the GitHub tokens to use and where they come from, the first set of:
+// the tokenFile (-token-file, one token per line), GITHUB_TOKENS, GITHUB_TOKEN, GH_TOKEN and
+// the output of `gh auth token` (if the GitHub CLI is installed and logged in). None if unset.
+func GitHubTokens(ctx context.Context, tokenFile string) ([]string, string, error) {
+	if tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading -token-file: %w", err)
+		}
+		tokens := splitTokens(string(content), "\n")
+		if len(tokens) == 0 {
+			return nil, "", fmt.Errorf("no token in -token-file %s", tokenFile)
+		}
+		return tokens, tokenFile, nil
+	}
+	if tokens := splitTokens(os.Getenv(TokensEnv), ","); len(tokens) > 0 {
+		return tokens, TokensEnv, nil
+	}
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if t := strings.TrimSpace(os.Getenv(env)); t != "" {
+			return []string{t}, env, nil
+		}
+	}
+	if _, err := exec.LookPath("gh"); err == nil {
+		out, err := exec.CommandContext(ctx, "gh", "auth", "token").Output()
+		if t := strings.TrimSpace(string(out)); err == nil && t != "" {
+			return []string{t}, "gh auth token", nil
+		}
+		log.LogVf("No token from gh auth token: %v", err)
+	}
+	return nil, "", nil
+}
+
+// splitTokens returns the non empty tokens separated by sep.
+func splitTokens(s, sep string) []string {
+	var tokens []string
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// CheckToken validates the token up front with the (free) rate limit endpoint, for a clear
+// error instead of failures mid-scan: an invalid (expired, revoked) token, or a classic token
+// without the repo scope needed to list private repositories (visibility). Fine-grained
+// tokens don't expose their permissions, so only their validity is checked.
+func CheckToken(ctx context.Context, client *github.Client, visibility string) error {
+	_, resp, err := client.RateLimit.Get(ctx)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return errors.New("the GitHub token is invalid (expired or revoked?)")
+	}
+	if err != nil {
+		return fmt.Errorf("checking the GitHub token: %w", err)
+	}
+	scopes := resp.Header.Values("X-OAuth-Scopes")
+	if scopes == nil || visibility == VisibilityPublic {
+		return nil // fine-grained token, or no scope needed
+	}
+	for _, scope := range strings.Split(strings.Join(scopes, ","), ",") {
+		if strings.TrimSpace(scope) == "repo" {
+			return nil
+		}
+	}
+	return fmt.Errorf("the GitHub token lacks the repo scope needed for -visibility=%s (its scopes: %q)", visibility, strings.Join(scopes, ","))
+}
+
+// tokenIndexKey is the context key of the token a TokenTransport must use for the request
+// (no rotation), see CheckTokens.
+type tokenIndexKey struct{}
+
+// CheckTokens runs CheckToken for each of the count tokens of the client's TokenTransport,
+// or for its only token if count is 1: a rotated token failing mid-scan is as bad as the first.
+func CheckTokens(ctx context.Context, client *github.Client, count int, visibility string) error {
+	if count <= 1 {
+		return CheckToken(ctx, client, visibility)
+	}
+	for i := range count {
+		if err := CheckToken(context.WithValue(ctx, tokenIndexKey{}, i), client, visibility); err != nil {
+			return fmt.Errorf("token #%d of %d: %w", i+1, count, err)
+		}
+	}
+	return nil
+}
+
+// tokenLimit is the last rate limit state seen for a token and resource (core, graphql...).
+type tokenLimit struct {
+	limit, remaining int
+	reset            time.Time
+}
+
+// TokenTransport authenticates the requests with one of several tokens, switching to the one
+// with the most remaining quota when the current one is about to be rate limited, and retrying
+// the rate limited requests with another token if one has quota left. The rate limit headers
+// of the responses are rewritten to the pool's totals, so go-github and RateLimitTransport only
+// see (and wait for) the limit being reached when all the tokens are exhausted.
+type TokenTransport struct {
+	base   http.RoundTripper
+	tokens []string
+
+	mu      sync.Mutex
+	current int
+	limits  map[string][]*tokenLimit // resource -> per token state, nil if not seen yet
+}
+
+// NewTokenTransport wraps base (http.DefaultTransport if nil).
+func NewTokenTransport(base http.RoundTripper, tokens []string) *TokenTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &TokenTransport{base: base, tokens: tokens, limits: make(map[string][]*tokenLimit)}
+}
+
+// requestResource returns the rate limit resource of the request (what GitHub returns as
+// X-RateLimit-Resource): the tokens are picked per resource.
+func requestResource(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+	return "core"
+}
+
+// available returns the quota left of a token, limit if unknown (not used yet, or reset since).
+func available(l *tokenLimit, limit int, now time.Time) int {
+	if l == nil || now.After(l.reset) {
+		return limit
+	}
+	return l.remaining
+}
+
+// pick returns the token to use for resource, switching to the one with the most quota left
+// if the current one is below rotateBelow, and skipping the ones in tried.
+func (t *TokenTransport) pick(resource string, tried map[int]bool) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limits := t.limits[resource]
+	now := time.Now()
+	const unknown = 1 << 30 // not seen yet: as good as it gets
+	quota := func(i int) int {
+		if limits == nil {
+			return unknown
+		}
+		return available(limits[i], unknown, now)
+	}
+	if !tried[t.current] && quota(t.current) >= rotateBelow {
+		return t.current, true
+	}
+	best := -1
+	for i := range t.tokens {
+		if !tried[i] && (best < 0 || quota(i) > quota(best)) {
+			best = i
+		}
+	}
+	if best < 0 || (len(tried) > 0 && quota(best) == 0) {
+		return 0, false
+	}
+	if best != t.current {
+		log.Infof("Switching to GitHub token #%d of %d (%s quota of token #%d running out)", best+1, len(t.tokens), resource, t.current+1)
+		t.current = best
+	}
+	return best, true
+}
+
+// record updates the token's rate limit state from the response, and rewrites its headers to
+// the pool's totals. A limited (rate limited) token, e.g. by a secondary rate limit with quota
+// left, isn't used again until its reset (Retry-After, or a minute if unknown).
+func (t *TokenTransport) record(i int, resp *http.Response, limited bool) {
+	h := resp.Header
+	limit, err1 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		if !limited {
+			return
+		}
+		limit, remaining, reset = 0, 0, time.Now().Add(time.Minute).Unix()
+	}
+	state := &tokenLimit{limit: limit, remaining: remaining, reset: time.Unix(reset, 0)}
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); limited && err == nil {
+		state.remaining, state.reset = 0, time.Now().Add(time.Duration(secs)*time.Second)
+	}
+	resource := h.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = requestResource(resp.Request)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limits := t.limits[resource]
+	if limits == nil {
+		limits = make([]*tokenLimit, len(t.tokens))
+		t.limits[resource] = limits
+	}
+	limits[i] = state
+	now := time.Now()
+	poolLimit, poolRemaining, poolReset := 0, 0, time.Time{}
+	for _, l := range limits {
+		if l == nil {
+			poolLimit += limit // not used yet, same as this one presumably
+			poolRemaining += limit
+			continue
+		}
+		poolLimit += l.limit
+		left := available(l, l.limit, now)
+		poolRemaining += left
+		if left == 0 && (poolReset.IsZero() || l.reset.Before(poolReset)) {
+			poolReset = l.reset
+		}
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(poolLimit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(poolRemaining))
+	if poolRemaining == 0 {
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(poolReset.Unix(), 10))
+	}
+}
+
+// rateLimited returns true if the response is a (primary or secondary) rate limit error.
+func rateLimited(resp *http.Response) bool {
+	return (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if i, pinned := req.Context().Value(tokenIndexKey{}).(int); pinned && i < len(t.tokens) {
+		authReq := req.Clone(req.Context())
+		authReq.Header.Set("Authorization", "Bearer "+t.tokens[i])
+		resp, err := t.base.RoundTrip(authReq)
+		if err == nil {
+			t.record(i, resp, rateLimited(resp))
+		}
+		return resp, err
+	}
+	resource := requestResource(req)
+	tried := make(map[int]bool)
+	for {
+		i, _ := t.pick(resource, tried) // can't fail: the others were checked before retrying
+		tried[i] = true
+		authReq := req.Clone(req.Context())
+		if req.GetBody != nil && len(tried) > 1 {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			authReq.Body = body
+		}
+		authReq.Header.Set("Authorization", "Bearer "+t.tokens[i])
+		resp, err := t.base.RoundTrip(authReq)
+		if err != nil {
+			return resp, err
+		}
+		limited := rateLimited(resp)
+		t.record(i, resp, limited)
+		if !limited || len(tried) == len(t.tokens) || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		if _, ok := t.pick(resource, tried); !ok {
+			return resp, nil // all the others are exhausted too
+		}
+		log.LogVf("Rate limited with GitHub token #%d, retrying %s %s with another one", i+1, req.Method, req.URL.Path)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// --- End Multiple Tokens Rotation ---
@@ -0,0 +1,162 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// tokenResponse returns a response with the headers (name, value pairs) for req.
+func tokenResponse(req *http.Request, status int, headers ...string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}")), Request: req}
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Header.Set(headers[i], headers[i+1])
+	}
+	return resp
+}
+
+func TestCheckTokens(t *testing.T) {
+	rateLimit := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		case "classic":
+			return tokenResponse(req, http.StatusOK, "X-OAuth-Scopes", "read:org, repo"), nil
+		case "public":
+			return tokenResponse(req, http.StatusOK, "X-OAuth-Scopes", "read:org"), nil
+		case "fine-grained":
+			return tokenResponse(req, http.StatusOK), nil
+		}
+		return tokenResponse(req, http.StatusUnauthorized), nil
+	})
+	tests := []struct {
+		name       string
+		tokens     []string
+		visibility string
+		wantErr    string // "" for none
+	}{
+		{name: "classic", tokens: []string{"classic"}, visibility: VisibilityAll},
+		{name: "fine-grained", tokens: []string{"fine-grained"}, visibility: VisibilityPrivate},
+		{name: "invalid", tokens: []string{"revoked"}, wantErr: "the GitHub token is invalid"},
+		{name: "public scope only", tokens: []string{"public"}, visibility: VisibilityPublic},
+		{name: "private without repo scope", tokens: []string{"public"}, visibility: VisibilityPrivate, wantErr: "lacks the repo scope"},
+		{name: "all valid", tokens: []string{"classic", "fine-grained", "classic"}, visibility: VisibilityAll},
+		{name: "rotated token invalid", tokens: []string{"classic", "fine-grained", "revoked"}, wantErr: "token #3 of 3: the GitHub token is invalid"},
+		{name: "rotated token without repo scope", tokens: []string{"classic", "public"}, visibility: VisibilityAll, wantErr: "token #2 of 2: the GitHub token lacks the repo scope"},
+	}
+	for _, tt := range tests {
+		client := github.NewClient(&http.Client{Transport: NewTokenTransport(rateLimit, tt.tokens)})
+		err := CheckTokens(context.Background(), client, len(tt.tokens), tt.visibility)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestTokenTransport checks the rotation: the first token is used until its quota runs low,
+// then the one with the most left, a rate limited request being retried with another token.
+func TestTokenTransport(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	remaining := map[string]int{"a": 150, "b": 5000, "c": 3000}
+	var used []string
+	transport := NewTokenTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		used = append(used, token)
+		if remaining[token] == 0 {
+			return tokenResponse(req, http.StatusForbidden, "X-RateLimit-Limit", "5000", "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", reset), nil
+		}
+		remaining[token] -= 50
+		return tokenResponse(req, http.StatusOK, "X-RateLimit-Limit", "5000",
+			"X-RateLimit-Remaining", strconv.Itoa(remaining[token]), "X-RateLimit-Reset", reset), nil
+	}), []string{"a", "b", "c"})
+	get := func() *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/acme/log", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	get()         // a: 100 left
+	get()         // a: 50 left, below rotateBelow
+	resp := get() // b, the most left of the unseen ones
+	if want := []string{"a", "a", "b"}; !slices.Equal(used, want) {
+		t.Errorf("tokens used %v, want %v", used, want)
+	}
+	// pool totals: a's 50 left, b's 4950, c unseen (as a full quota)
+	if got := resp.Header.Get("X-RateLimit-Remaining"); got != "10000" {
+		t.Errorf("pool remaining %s, want 10000", got)
+	}
+	if got := resp.Header.Get("X-RateLimit-Limit"); got != "15000" {
+		t.Errorf("pool limit %s, want 15000", got)
+	}
+	remaining["b"] = 0 // rate limited on its next request: retried with c
+	used = nil
+	if resp := get(); resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d after the retry, want 200", resp.StatusCode)
+	}
+	if want := []string{"b", "c"}; !slices.Equal(used, want) {
+		t.Errorf("tokens used %v, want %v", used, want)
+	}
+	remaining["a"], remaining["c"] = 0, 0 // all exhausted: the rate limit error is returned
+	used = nil
+	if resp := get(); resp.StatusCode != http.StatusForbidden || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("status %d, pool remaining %s, want 403 and 0", resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+	}
+	if len(used) != 2 {
+		t.Errorf("tokens used %v, want c and a", used)
+	}
+}
+
+func TestGitHubTokens(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(tokenFile, []byte("t1\n\n  t2  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		tokenFile  string
+		env        map[string]string
+		wantTokens []string
+		wantSource string
+		wantErr    bool
+	}{
+		{name: "token file first", tokenFile: tokenFile, env: map[string]string{TokensEnv: "e1"}, wantTokens: []string{"t1", "t2"}, wantSource: tokenFile},
+		{name: "empty token file", tokenFile: emptyFile, wantErr: true},
+		{name: "missing token file", tokenFile: filepath.Join(t.TempDir(), "nope"), wantErr: true},
+		{name: "tokens env", env: map[string]string{TokensEnv: " e1, ,e2", "GITHUB_TOKEN": "g"}, wantTokens: []string{"e1", "e2"}, wantSource: TokensEnv},
+		{name: "github token", env: map[string]string{"GITHUB_TOKEN": "g", "GH_TOKEN": "h"}, wantTokens: []string{"g"}, wantSource: "GITHUB_TOKEN"},
+		{name: "gh token", env: map[string]string{"GH_TOKEN": " h "}, wantTokens: []string{"h"}, wantSource: "GH_TOKEN"},
+		{name: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", "") // no gh
+			for _, env := range []string{TokensEnv, "GITHUB_TOKEN", "GH_TOKEN"} {
+				t.Setenv(env, tt.env[env])
+			}
+			tokens, source, err := GitHubTokens(context.Background(), tt.tokenFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want one %v", err, tt.wantErr)
+			}
+			if !slices.Equal(tokens, tt.wantTokens) || source != tt.wantSource {
+				t.Errorf("tokens %v from %q, want %v from %q", tokens, source, tt.wantTokens, tt.wantSource)
+			}
+		})
+	}
+}